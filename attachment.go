@@ -5,9 +5,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime/multipart"
+	"net/textproto"
 
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
 )
 
 func (c *Client) GetAttachment(ctx context.Context, attachmentID string) ([]byte, error) {
@@ -79,6 +82,81 @@ func (c *Client) UploadAttachment(ctx context.Context, addrKR *crypto.KeyRing, r
 	return res.Attachment, nil
 }
 
+// UploadAttachmentStream is like UploadAttachment but reads the attachment body from the given reader rather than from req.Body.
+// The body is encrypted and signed while it is being uploaded, so it is never held in memory in full.
+// Since the reader can only be consumed once, the upload is not retried; it fails with ErrStreamConsumed
+// if it would need to be, and the caller must then retry the whole call with a fresh reader.
+func (c *Client) UploadAttachmentStream(ctx context.Context, addrKR *crypto.KeyRing, body io.Reader, req CreateAttachmentReq) (Attachment, error) {
+	var res struct {
+		Attachment Attachment
+	}
+
+	kr, err := addrKR.FirstKey()
+	if err != nil {
+		return res.Attachment, fmt.Errorf("failed to get first key: %w", err)
+	}
+
+	sk, err := crypto.GenerateSessionKey()
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to generate session key: %w", err)
+	}
+
+	keyPacket, err := kr.EncryptSessionKey(sk)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to encrypt session key: %w", err)
+	}
+
+	boundary := uuid.NewString()
+
+	stream := newStreamBody(func(w io.Writer) error {
+		mw := multipart.NewWriter(w)
+
+		if err := mw.SetBoundary(boundary); err != nil {
+			return err
+		}
+
+		return writeAttachmentForm(mw, kr, sk, keyPacket, body, req)
+	})
+	defer stream.wait()
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(stream).
+			SetResult(&res).
+			SetHeader("Content-Type", "multipart/form-data; boundary="+boundary).
+			Post("/mail/v4/attachments")
+	}); err != nil {
+		return Attachment{}, err
+	}
+
+	return res.Attachment, nil
+}
+
+// DownloadAttachmentDecrypted downloads the attachment and writes its decrypted content to the given writer as it arrives.
+// The key packet is the decoded Attachment.KeyPackets of the attachment.
+func (c *Client) DownloadAttachmentDecrypted(ctx context.Context, attachmentID string, keyPacket []byte, kr *crypto.KeyRing, w io.Writer) error {
+	res, err := c.doRes(ctx, func(req *resty.Request) (*resty.Response, error) {
+		res, err := req.SetDoNotParseResponse(true).Get("/mail/v4/attachments/" + attachmentID)
+		return parseResponse(res, err)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to request attachment: %w", err)
+	}
+	defer func() {
+		_ = res.RawBody().Close()
+	}()
+
+	dec, err := kr.DecryptSplitStream(keyPacket, res.RawBody(), nil, 0)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt attachment: %w", err)
+	}
+
+	if _, err := io.Copy(w, dec); err != nil {
+		return fmt.Errorf("failed to decrypt attachment: %w", err)
+	}
+
+	return nil
+}
+
 func (c *Client) getAttachment(ctx context.Context, attachmentID string, reader io.ReaderFrom) error {
 	res, err := c.doRes(ctx, func(req *resty.Request) (*resty.Response, error) {
 		res, err := req.SetDoNotParseResponse(true).Get("/mail/v4/attachments/" + attachmentID)
@@ -97,3 +175,120 @@ func (c *Client) getAttachment(ctx context.Context, attachmentID string, reader
 
 	return nil
 }
+
+// writeAttachmentForm writes the multipart form of an attachment upload,
+// encrypting the body with the session key and signing it with the keyring as it is read.
+func writeAttachmentForm(
+	mw *multipart.Writer,
+	kr *crypto.KeyRing,
+	sk *crypto.SessionKey,
+	keyPacket []byte,
+	body io.Reader,
+	req CreateAttachmentReq,
+) error {
+	for _, field := range [][2]string{
+		{"MessageID", req.MessageID},
+		{"Filename", req.Filename},
+		{"MIMEType", string(req.MIMEType)},
+		{"Disposition", string(req.Disposition)},
+		{"ContentID", req.ContentID},
+	} {
+		if err := mw.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	if err := writeBlobField(mw, "KeyPackets", bytes.NewReader(keyPacket)); err != nil {
+		return err
+	}
+
+	sig, err := writeEncryptedBlobField(mw, "DataPacket", kr, sk, body, req.Filename)
+	if err != nil {
+		return err
+	}
+
+	if err := writeBlobField(mw, "Signature", bytes.NewReader(sig.GetBinary())); err != nil {
+		return err
+	}
+
+	return mw.Close()
+}
+
+// writeEncryptedBlobField writes the body encrypted with the session key to the given field,
+// returning a detached signature of the body computed at the same time.
+func writeEncryptedBlobField(
+	mw *multipart.Writer,
+	name string,
+	kr *crypto.KeyRing,
+	sk *crypto.SessionKey,
+	body io.Reader,
+	filename string,
+) (*crypto.PGPSignature, error) {
+	part, err := mw.CreatePart(newBlobFieldHeader(name))
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := sk.EncryptStream(part, crypto.NewPlainMessageMetadata(true, filename, crypto.GetUnixTime()), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sigR, sigW := io.Pipe()
+
+	type sigResult struct {
+		sig *crypto.PGPSignature
+		err error
+	}
+
+	sigCh := make(chan sigResult, 1)
+
+	go func() {
+		sig, err := kr.SignDetachedStream(sigR)
+
+		// Close the reader so that the copy below fails rather than blocks if signing stops early.
+		_ = sigR.CloseWithError(err)
+
+		sigCh <- sigResult{sig: sig, err: err}
+	}()
+
+	if _, err := io.Copy(enc, io.TeeReader(body, sigW)); err != nil {
+		_ = sigW.CloseWithError(err)
+		<-sigCh
+
+		return nil, err
+	}
+
+	_ = sigW.Close()
+
+	res := <-sigCh
+	if res.err != nil {
+		return nil, fmt.Errorf("failed to sign attachment: %w", res.err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return res.sig, nil
+}
+
+func writeBlobField(mw *multipart.Writer, name string, r io.Reader) error {
+	part, err := mw.CreatePart(newBlobFieldHeader(name))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, r)
+
+	return err
+}
+
+func newBlobFieldHeader(name string) textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename="blob"`, name))
+	header.Set("Content-Type", "application/octet-stream")
+
+	return header
+}
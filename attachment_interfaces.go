@@ -57,6 +57,8 @@ func (SequentialScheduler) Schedule(ctx context.Context, attachmentIDs []string,
 	return result, nil
 }
 
+// ParallelScheduler downloads the attachments concurrently with a fixed number of workers,
+// or as many as the AdaptiveLimiter of the context allows, if any.
 type ParallelScheduler struct {
 	workers      int
 	panicHandler async.PanicHandler
@@ -72,13 +74,15 @@ func NewParallelScheduler(workers int, panicHandler async.PanicHandler) *Paralle
 
 func (p ParallelScheduler) Schedule(ctx context.Context, attachmentIDs []string, storageProvider AttachmentAllocator, downloader func(context.Context, string, *bytes.Buffer) error) ([]*bytes.Buffer, error) {
 	// If we have less attachments than the maximum works, reduce worker count to match attachment count.
-	workers := min(len(attachmentIDs), p.workers)
+	workers := min(len(attachmentIDs), getAdaptiveWorkers(ctx, p.workers))
 
 	return parallel.MapContext(ctx, workers, attachmentIDs, func(ctx context.Context, id string) (*bytes.Buffer, error) {
 		defer async.HandlePanic(p.panicHandler)
 
 		buffer := storageProvider.NewBuffer()
-		if err := downloader(ctx, id, buffer); err != nil {
+		if err := doAdaptive(ctx, func(ctx context.Context) error {
+			return downloader(ctx, id, buffer)
+		}); err != nil {
 			return nil, err
 		}
 
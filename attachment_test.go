@@ -1,14 +1,22 @@
 package proton_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
+	"io"
+	"net"
 	"net/http"
+	"net/mail"
 	"sync"
 	"testing"
 
+	"github.com/ProtonMail/gluon/async"
 	"github.com/ProtonMail/go-proton-api"
 	"github.com/ProtonMail/go-proton-api/server"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -79,3 +87,159 @@ func TestAttachment_ContextCancelled(t *testing.T) {
 	require.Error(t, err)
 	require.True(t, errors.Is(err, context.Canceled))
 }
+
+func TestAttachment_Stream(t *testing.T) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+
+	user, err := c.GetUser(ctx)
+	require.NoError(t, err)
+
+	addr, err := c.GetAddresses(ctx)
+	require.NoError(t, err)
+
+	salt, err := c.GetSalts(ctx)
+	require.NoError(t, err)
+
+	keyPass, err := salt.SaltForKey([]byte("pass"), user.Keys.Primary().ID)
+	require.NoError(t, err)
+
+	_, addrKRs, err := proton.Unlock(user, addr, keyPass, async.NoopPanicHandler{})
+	require.NoError(t, err)
+
+	addrKR := addrKRs[addr[0].ID]
+
+	draft, err := c.CreateDraft(ctx, addrKR, proton.CreateDraftReq{
+		Message: proton.DraftTemplate{
+			Subject: "subject",
+			Sender:  &mail.Address{Address: addr[0].Email},
+			Body:    "body",
+		},
+	})
+	require.NoError(t, err)
+
+	data := make([]byte, 4<<20)
+
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+
+	att, err := c.UploadAttachmentStream(ctx, addrKR, bytes.NewReader(data), proton.CreateAttachmentReq{
+		MessageID:   draft.ID,
+		Filename:    "large.bin",
+		MIMEType:    "application/octet-stream",
+		Disposition: proton.AttachmentDisposition,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "large.bin", att.Name)
+
+	keyPacket, err := base64.StdEncoding.DecodeString(att.KeyPackets)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	require.NoError(t, c.DownloadAttachmentDecrypted(ctx, att.ID, keyPacket, addrKR, &buf))
+	require.Equal(t, data, buf.Bytes())
+}
+
+func TestAttachment_StreamReaderError(t *testing.T) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+
+	kr, err := crypto.GenerateKey("name", "user@proton.test", "x25519", 0)
+	require.NoError(t, err)
+
+	addrKR, err := crypto.NewKeyRing(kr)
+	require.NoError(t, err)
+
+	_, err = c.UploadAttachmentStream(ctx, addrKR, &failingReader{}, proton.CreateAttachmentReq{
+		Filename:    "large.bin",
+		Disposition: proton.AttachmentDisposition,
+	})
+	require.Error(t, err)
+}
+
+func TestAttachment_StreamRetry(t *testing.T) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(&droppingUploadTransport{RoundTripper: proton.InsecureTransport()}),
+	)
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+
+	kr, err := crypto.GenerateKey("name", "user@proton.test", "x25519", 0)
+	require.NoError(t, err)
+
+	addrKR, err := crypto.NewKeyRing(kr)
+	require.NoError(t, err)
+
+	// The connection drops while the body is uploaded; the request is retried but the body can't be sent again.
+	_, err = c.UploadAttachmentStream(ctx, addrKR, bytes.NewReader(make([]byte, 1<<20)), proton.CreateAttachmentReq{
+		Filename:    "large.bin",
+		Disposition: proton.AttachmentDisposition,
+	})
+	require.ErrorIs(t, err, proton.ErrStreamConsumed)
+}
+
+// droppingUploadTransport drops the connection of the first attachment upload after part of its body was sent.
+type droppingUploadTransport struct {
+	http.RoundTripper
+
+	dropped bool
+}
+
+func (t *droppingUploadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path != "/mail/v4/attachments" || t.dropped {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	t.dropped = true
+
+	if _, err := io.CopyN(io.Discard, req.Body, 1024); err != nil {
+		return nil, err
+	}
+
+	_ = req.Body.Close()
+
+	return nil, &net.OpError{Op: "write", Net: "tcp", Err: errors.New("connection reset by peer")}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
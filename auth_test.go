@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ProtonMail/gluon/async"
 	"github.com/ProtonMail/go-proton-api"
 	"github.com/ProtonMail/go-proton-api/server"
 	"github.com/bradenaw/juniper/parallel"
@@ -187,3 +188,331 @@ func TestAuth_Refresh_Deauth(t *testing.T) {
 	// The client shuold call de-auth handlers.
 	require.Eventually(t, func() bool { return deauth }, time.Second, 300*time.Millisecond)
 }
+
+func TestGenerateTOTP(t *testing.T) {
+	// The SHA-1 test vectors of RFC 6238, truncated to six digits.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	for at, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := proton.GenerateTOTP(secret, time.Unix(at, 0))
+		require.NoError(t, err)
+		require.Equal(t, want, code)
+	}
+
+	// Codes of neighbouring periods are accepted within the skew.
+	ok, err := proton.ValidateTOTP(secret, "287082", time.Unix(59+30, 0), 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = proton.ValidateTOTP(secret, "287082", time.Unix(59+60, 0), 1)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = proton.GenerateTOTP("not base32!", time.Now())
+	require.Error(t, err)
+}
+
+func TestAuth_FIDO2(t *testing.T) {
+	ctx := context.Background()
+
+	s := server.New()
+	defer s.Close()
+
+	userID, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	authenticator, err := proton.NewFIDO2Authenticator()
+	require.NoError(t, err)
+
+	publicKey, err := authenticator.PublicKey()
+	require.NoError(t, err)
+
+	require.NoError(t, s.RegisterFIDO2Key(userID, "key", authenticator.CredentialID(), publicKey))
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	c, auth, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.Equal(t, proton.HasFIDO2, auth.TwoFA.Enabled)
+	require.Len(t, auth.TwoFA.FIDO2.RegisteredKeys, 1)
+	require.Equal(t, "key", auth.TwoFA.FIDO2.RegisteredKeys[0].Name)
+
+	options, err := auth.TwoFA.FIDO2.Options()
+	require.NoError(t, err)
+	require.Equal(t, "proton.local", options.PublicKey.RPID)
+
+	// Other authenticators are not allowed.
+	other, err := proton.NewFIDO2Authenticator()
+	require.NoError(t, err)
+
+	_, err = other.Assert("https://account.proton.local", options)
+	require.Error(t, err)
+
+	// Assertions from other origins are rejected.
+	assertion, err := authenticator.Assert("https://example.com", options)
+	require.NoError(t, err)
+	require.Error(t, c.Auth2FA(ctx, proton.Auth2FAReq{FIDO2: proton.NewFIDO2Req(options, assertion)}))
+
+	// So are assertions whose signature doesn't match.
+	assertion, err = authenticator.Assert("https://account.proton.local", options)
+	require.NoError(t, err)
+
+	tampered := assertion
+	tampered.ClientDataJSON = append([]byte{' '}, tampered.ClientDataJSON...)
+	require.Error(t, c.Auth2FA(ctx, proton.Auth2FAReq{FIDO2: proton.NewFIDO2Req(options, tampered)}))
+
+	_, err = c.GetUser(ctx)
+	require.Error(t, err)
+
+	// A valid assertion grants the session full scope.
+	require.NoError(t, c.Auth2FA(ctx, proton.Auth2FAReq{FIDO2: proton.NewFIDO2Req(options, assertion)}))
+
+	_, err = c.GetUser(ctx)
+	require.NoError(t, err)
+
+	// Replayed assertions are rejected.
+	c2, auth2, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c2.Close()
+
+	options2, err := auth2.TwoFA.FIDO2.Options()
+	require.NoError(t, err)
+	require.NotEqual(t, options.PublicKey.Challenge, options2.PublicKey.Challenge)
+	require.Error(t, c2.Auth2FA(ctx, proton.Auth2FAReq{FIDO2: proton.NewFIDO2Req(options, assertion)}))
+
+	// With TOTP also enabled, either second factor can be used.
+	secret, err := s.EnableTOTP(userID)
+	require.NoError(t, err)
+
+	c3, auth3, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c3.Close()
+
+	require.Equal(t, proton.HasFIDO2AndTOTP, auth3.TwoFA.Enabled)
+
+	code, err := proton.GenerateTOTP(secret, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, c3.Auth2FA(ctx, proton.Auth2FAReq{TwoFactorCode: code}))
+
+	// Once the key is removed, new sessions no longer need it.
+	require.NoError(t, s.DisableTOTP(userID))
+	require.NoError(t, s.RemoveFIDO2Key(userID, authenticator.CredentialID()))
+
+	c4, auth4, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c4.Close()
+
+	require.Zero(t, auth4.TwoFA.Enabled)
+
+	_, err = c4.GetUser(ctx)
+	require.NoError(t, err)
+}
+
+func TestAuth_TwoPasswordMode(t *testing.T) {
+	ctx := context.Background()
+
+	s := server.New()
+	defer s.Close()
+
+	_, _, err := s.CreateUserWithMailboxPassword("user", []byte("pass"), []byte("mailbox pass"))
+	require.NoError(t, err)
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	// The mailbox password can't be used to log in.
+	_, _, err = m.NewClientWithLogin(ctx, "user", []byte("mailbox pass"))
+	require.Error(t, err)
+
+	c, auth, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.Equal(t, proton.TwoPasswordMode, auth.PasswordMode)
+
+	user, err := c.GetUser(ctx)
+	require.NoError(t, err)
+
+	addresses, err := c.GetAddresses(ctx)
+	require.NoError(t, err)
+
+	// The login password doesn't unlock the keys.
+	_, _, err = c.UnlockWithMailboxPassword(ctx, user, addresses, []byte("pass"), async.NoopPanicHandler{})
+	require.Error(t, err)
+
+	// The mailbox password does.
+	userKR, addrKRs, err := c.UnlockWithMailboxPassword(ctx, user, addresses, []byte("mailbox pass"), async.NoopPanicHandler{})
+	require.NoError(t, err)
+	require.NotZero(t, userKR.CountDecryptionEntities())
+	require.Len(t, addrKRs, 1)
+}
+
+func TestAuth_OnePasswordMode(t *testing.T) {
+	ctx := context.Background()
+
+	s := server.New()
+	defer s.Close()
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	c, auth, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.Equal(t, proton.OnePasswordMode, auth.PasswordMode)
+
+	user, err := c.GetUser(ctx)
+	require.NoError(t, err)
+
+	addresses, err := c.GetAddresses(ctx)
+	require.NoError(t, err)
+
+	// In one-password mode, the login password is the mailbox password.
+	_, _, err = c.UnlockWithMailboxPassword(ctx, user, addresses, []byte("pass"), async.NoopPanicHandler{})
+	require.NoError(t, err)
+}
+
+func TestAuth_ChangePassword(t *testing.T) {
+	ctx := context.Background()
+
+	s := server.New()
+	defer s.Close()
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	other, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer other.Close()
+
+	// The current password is needed.
+	require.Error(t, c.ChangePassword(ctx, []byte("wrong"), []byte("new pass")))
+
+	require.NoError(t, c.ChangePassword(ctx, []byte("pass"), []byte("new pass")))
+
+	// Only the new password can be used to log in.
+	_, _, err = m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.Error(t, err)
+
+	c2, _, err := m.NewClientWithLogin(ctx, "user", []byte("new pass"))
+	require.NoError(t, err)
+	defer c2.Close()
+
+	// The other sessions were revoked, but not the one that changed the password.
+	_, err = other.GetUser(ctx)
+	require.Error(t, err)
+
+	user, err := c.GetUser(ctx)
+	require.NoError(t, err)
+
+	addresses, err := c.GetAddresses(ctx)
+	require.NoError(t, err)
+
+	// The keys are unlocked with the new password, and the address keys still work.
+	_, _, err = c.UnlockWithMailboxPassword(ctx, user, addresses, []byte("pass"), async.NoopPanicHandler{})
+	require.Error(t, err)
+
+	_, addrKRs, err := c.UnlockWithMailboxPassword(ctx, user, addresses, []byte("new pass"), async.NoopPanicHandler{})
+	require.NoError(t, err)
+	require.Len(t, addrKRs, 1)
+
+	// One-password users can't change their login password alone.
+	require.Error(t, c.ChangeLoginPassword(ctx, []byte("new pass"), []byte("other pass")))
+}
+
+func TestAuth_ChangePassword_TwoPasswordMode(t *testing.T) {
+	ctx := context.Background()
+
+	s := server.New()
+	defer s.Close()
+
+	_, _, err := s.CreateUserWithMailboxPassword("user", []byte("pass"), []byte("mailbox pass"))
+	require.NoError(t, err)
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	unlock := func(mailboxPass string) error {
+		user, err := c.GetUser(ctx)
+		require.NoError(t, err)
+
+		addresses, err := c.GetAddresses(ctx)
+		require.NoError(t, err)
+
+		_, _, err = c.UnlockWithMailboxPassword(ctx, user, addresses, []byte(mailboxPass), async.NoopPanicHandler{})
+
+		return err
+	}
+
+	// Two-password users can't change both passwords at once.
+	require.Error(t, c.ChangePassword(ctx, []byte("pass"), []byte("new pass")))
+
+	// The login password changes alone.
+	require.NoError(t, c.ChangeLoginPassword(ctx, []byte("pass"), []byte("new pass")))
+
+	_, _, err = m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.Error(t, err)
+
+	c2, _, err := m.NewClientWithLogin(ctx, "user", []byte("new pass"))
+	require.NoError(t, err)
+	defer c2.Close()
+
+	require.NoError(t, unlock("mailbox pass"))
+
+	// So does the mailbox password, given the login password.
+	err = c.ChangeMailboxPassword(ctx, []byte("pass"), []byte("mailbox pass"), []byte("new mailbox pass"))
+	require.Error(t, err)
+
+	var apiErr *proton.APIError
+
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, proton.PasswordWrong, apiErr.Code)
+
+	require.NoError(t, c.ChangeMailboxPassword(ctx, []byte("new pass"), []byte("mailbox pass"), []byte("new mailbox pass")))
+
+	require.Error(t, unlock("mailbox pass"))
+	require.NoError(t, unlock("new mailbox pass"))
+
+	// The login password didn't change.
+	c3, _, err := m.NewClientWithLogin(ctx, "user", []byte("new pass"))
+	require.NoError(t, err)
+	defer c3.Close()
+}
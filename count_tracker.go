@@ -0,0 +1,340 @@
+package proton
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CountObserver is called with the counts of the labels whose counts changed.
+type CountObserver func(changed []MessageGroupCount)
+
+// CountTracker keeps the number of messages and unread messages of each label up to date.
+// It is seeded from the grouped message counts of the API, then updated from the message and label events;
+// events that ask for a mail refresh seed it again.
+//
+// Message events only carry the new state of messages, so the tracker learns the labels and unread flag
+// of each message from the events it applies to compute how later events change the counts. The first event
+// of a message it doesn't know yet is applied if it creates the message; an update or deletion can't be,
+// as the previous state of the message is unknown, so the tracker is seeded again instead.
+type CountTracker struct {
+	c *Client
+
+	eventID  string
+	counts   map[string]*MessageGroupCount
+	messages map[string]countedMessage
+	lock     sync.RWMutex
+
+	observers     []countObserver
+	nextObserver  int
+	observersLock sync.Mutex
+
+	syncLock sync.Mutex
+}
+
+type countObserver struct {
+	id       int
+	observer CountObserver
+}
+
+// countedMessage is what the tracker keeps of a message.
+type countedMessage struct {
+	labelIDs []string
+	unread   bool
+}
+
+// maxCountSeedAttempts is the number of times seeding fetches the counts again if events happened meanwhile.
+const maxCountSeedAttempts = 3
+
+// NewCountTracker returns a tracker of the message counts of the client. It is empty until it is seeded or synced.
+func NewCountTracker(c *Client) *CountTracker {
+	return &CountTracker{
+		c:        c,
+		counts:   make(map[string]*MessageGroupCount),
+		messages: make(map[string]countedMessage),
+	}
+}
+
+// EventID returns the ID of the last event applied to the tracker, or an empty string if it was never seeded.
+func (t *CountTracker) EventID() string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.eventID
+}
+
+// Count returns the counts of the label; they are zero if the label has no messages.
+func (t *CountTracker) Count(labelID string) MessageGroupCount {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if count, ok := t.counts[labelID]; ok {
+		return *count
+	}
+
+	return MessageGroupCount{LabelID: labelID}
+}
+
+// Counts returns the counts of all labels that have messages, sorted by label ID.
+func (t *CountTracker) Counts() []MessageGroupCount {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	res := make([]MessageGroupCount, 0, len(t.counts))
+
+	for _, count := range t.counts {
+		res = append(res, *count)
+	}
+
+	slices.SortFunc(res, func(a, b MessageGroupCount) int { return strings.Compare(a.LabelID, b.LabelID) })
+
+	return res
+}
+
+// Subscribe adds an observer called, after each change, with the counts of the labels that changed.
+// Labels that no longer have messages are reported with zero counts. Observers are called in the order
+// they subscribed. Subscribe returns a function that removes the observer.
+func (t *CountTracker) Subscribe(observer CountObserver) func() {
+	t.observersLock.Lock()
+	defer t.observersLock.Unlock()
+
+	id := t.nextObserver
+	t.nextObserver++
+
+	t.observers = append(t.observers, countObserver{id: id, observer: observer})
+
+	return func() {
+		t.observersLock.Lock()
+		defer t.observersLock.Unlock()
+
+		t.observers = slices.DeleteFunc(t.observers, func(o countObserver) bool { return o.id == id })
+	}
+}
+
+// Seed replaces the counts with those of the API.
+func (t *CountTracker) Seed(ctx context.Context) error {
+	t.syncLock.Lock()
+	defer t.syncLock.Unlock()
+
+	return t.seed(ctx)
+}
+
+// Sync brings the counts up to date by applying the events that happened since the last one applied.
+// If the tracker was never seeded, it is seeded.
+func (t *CountTracker) Sync(ctx context.Context) error {
+	t.syncLock.Lock()
+	defer t.syncLock.Unlock()
+
+	if t.EventID() == "" {
+		return t.seed(ctx)
+	}
+
+	return t.c.syncEvents(ctx, func() (string, error) {
+		return t.EventID(), nil
+	}, func(event Event) error {
+		return t.applyEvent(ctx, event)
+	})
+}
+
+// ApplyEvent updates the counts with the message and label changes of the event, as received from the event stream.
+// A mail refresh seeds the counts again.
+func (t *CountTracker) ApplyEvent(ctx context.Context, event Event) error {
+	t.syncLock.Lock()
+	defer t.syncLock.Unlock()
+
+	return t.applyEvent(ctx, event)
+}
+
+func (t *CountTracker) seed(ctx context.Context) error {
+	var (
+		eventID string
+		counts  []MessageGroupCount
+	)
+
+	// The counts must be those at the event the tracker continues from; events that happen while they
+	// are fetched would be counted twice, so they are fetched again, a few times at most.
+	for range maxCountSeedAttempts {
+		latestEventID, err := t.c.GetLatestEventID(ctx)
+		if err != nil {
+			return err
+		}
+
+		if latestEventID == eventID {
+			break
+		}
+
+		eventID = latestEventID
+
+		if counts, err = t.c.GetGroupedMessageCount(ctx); err != nil {
+			return err
+		}
+	}
+
+	t.lock.Lock()
+
+	// All the labels may have changed.
+	before := make(map[string]MessageGroupCount, len(t.counts))
+
+	for labelID, count := range t.counts {
+		before[labelID] = *count
+	}
+
+	t.eventID = eventID
+	t.counts = make(map[string]*MessageGroupCount, len(counts))
+	t.messages = make(map[string]countedMessage)
+
+	for _, count := range counts {
+		if count.Total > 0 {
+			t.counts[count.LabelID] = &count
+
+			if _, ok := before[count.LabelID]; !ok {
+				before[count.LabelID] = MessageGroupCount{LabelID: count.LabelID}
+			}
+		}
+	}
+
+	res := t.changedCounts(before)
+
+	t.lock.Unlock()
+
+	t.notify(res)
+
+	return nil
+}
+
+func (t *CountTracker) applyEvent(ctx context.Context, event Event) error {
+	if event.Refresh&RefreshMail != 0 || t.hasUnknownChanges(event) {
+		return t.seed(ctx)
+	}
+
+	t.lock.Lock()
+
+	// The counts of the labels the event touches, before it is applied.
+	before := make(map[string]MessageGroupCount)
+
+	for _, msgEvent := range event.Messages {
+		if old, ok := t.messages[msgEvent.ID]; ok {
+			t.count(old, -1, before)
+			delete(t.messages, msgEvent.ID)
+		}
+
+		if msgEvent.Action != EventDelete {
+			msg := countedMessage{labelIDs: msgEvent.Message.LabelIDs, unread: bool(msgEvent.Message.Unread)}
+
+			t.messages[msgEvent.ID] = msg
+			t.count(msg, 1, before)
+		}
+	}
+
+	// Messages lose the labels that are deleted.
+	for _, labelEvent := range event.Labels {
+		if labelEvent.Action != EventDelete {
+			continue
+		}
+
+		for messageID, msg := range t.messages {
+			if slices.Contains(msg.labelIDs, labelEvent.ID) {
+				msg.labelIDs = slices.DeleteFunc(slices.Clone(msg.labelIDs), func(id string) bool { return id == labelEvent.ID })
+				t.messages[messageID] = msg
+			}
+		}
+
+		if count, ok := t.counts[labelEvent.ID]; ok {
+			if _, ok := before[labelEvent.ID]; !ok {
+				before[labelEvent.ID] = *count
+			}
+
+			delete(t.counts, labelEvent.ID)
+		}
+	}
+
+	t.eventID = event.EventID
+
+	res := t.changedCounts(before)
+
+	t.lock.Unlock()
+
+	t.notify(res)
+
+	return nil
+}
+
+// hasUnknownChanges returns whether the event updates or deletes a message whose previous state the tracker doesn't know.
+func (t *CountTracker) hasUnknownChanges(event Event) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return slices.ContainsFunc(event.Messages, func(msgEvent MessageEvent) bool {
+		if msgEvent.Action == EventCreate {
+			return false
+		}
+
+		_, ok := t.messages[msgEvent.ID]
+
+		return !ok
+	})
+}
+
+// count adds the message, or removes it if delta is negative, to the counts of its labels,
+// recording the counts of the labels it touches first in before.
+// The caller must hold the lock.
+func (t *CountTracker) count(msg countedMessage, delta int, before map[string]MessageGroupCount) {
+	for _, labelID := range msg.labelIDs {
+		count, ok := t.counts[labelID]
+		if !ok {
+			count = &MessageGroupCount{LabelID: labelID}
+			t.counts[labelID] = count
+		}
+
+		if _, ok := before[labelID]; !ok {
+			before[labelID] = *count
+		}
+
+		count.Total += delta
+
+		if msg.unread {
+			count.Unread += delta
+		}
+
+		if count.Total <= 0 {
+			delete(t.counts, labelID)
+		}
+	}
+}
+
+// changedCounts returns the counts of the labels that differ from their counts before, sorted by label ID.
+// The caller must hold the lock.
+func (t *CountTracker) changedCounts(before map[string]MessageGroupCount) []MessageGroupCount {
+	var res []MessageGroupCount
+
+	for labelID, prev := range before {
+		count := MessageGroupCount{LabelID: labelID}
+
+		if cur, ok := t.counts[labelID]; ok {
+			count = *cur
+		}
+
+		if count.Total != prev.Total || count.Unread != prev.Unread {
+			res = append(res, count)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b MessageGroupCount) int { return strings.Compare(a.LabelID, b.LabelID) })
+
+	return res
+}
+
+func (t *CountTracker) notify(changed []MessageGroupCount) {
+	if len(changed) == 0 {
+		return
+	}
+
+	t.observersLock.Lock()
+	observers := slices.Clone(t.observers)
+	t.observersLock.Unlock()
+
+	for _, o := range observers {
+		o.observer(changed)
+	}
+}
@@ -0,0 +1,152 @@
+package proton_test
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountTracker(t *testing.T) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	userID, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	createTestMessages(t, c, "pass", 4)
+
+	messageIDs, err := c.GetAllMessageIDs(ctx, "")
+	require.NoError(t, err)
+
+	// The tracker never lists message metadata; it only fetches the grouped counts.
+	var listings, countRequests atomic.Int32
+
+	c.AddPreRequestHook(func(_ *resty.Client, req *resty.Request) error {
+		switch req.URL {
+		case "/mail/v4/messages":
+			listings.Add(1)
+
+		case "/mail/v4/messages/count":
+			countRequests.Add(1)
+		}
+
+		return nil
+	})
+
+	tracker := proton.NewCountTracker(c)
+
+	var changes [][]proton.MessageGroupCount
+
+	unsubscribe := tracker.Subscribe(func(changed []proton.MessageGroupCount) {
+		changes = append(changes, changed)
+	})
+
+	requireCounts := func() {
+		t.Helper()
+
+		want, err := c.GetGroupedMessageCount(ctx)
+		require.NoError(t, err)
+
+		want = slices.DeleteFunc(want, func(count proton.MessageGroupCount) bool { return count.Total == 0 })
+
+		slices.SortFunc(want, func(a, b proton.MessageGroupCount) int { return strings.Compare(a.LabelID, b.LabelID) })
+
+		require.Equal(t, want, tracker.Counts())
+	}
+
+	// The first sync seeds the counts.
+	require.NoError(t, tracker.Sync(ctx))
+	require.NotEmpty(t, tracker.EventID())
+	require.Equal(t, proton.MessageGroupCount{LabelID: proton.AllMailLabel, Total: 4, Unread: 4}, tracker.Count(proton.AllMailLabel))
+	require.Equal(t, [][]proton.MessageGroupCount{{{LabelID: proton.AllMailLabel, Total: 4, Unread: 4}}}, changes)
+	requireCounts()
+
+	// Unread flips are applied from events.
+	changes = nil
+
+	require.NoError(t, c.MarkMessagesRead(ctx, messageIDs[0], messageIDs[1]))
+	require.NoError(t, tracker.Sync(ctx))
+	require.Equal(t, proton.MessageGroupCount{LabelID: proton.AllMailLabel, Total: 4, Unread: 2}, tracker.Count(proton.AllMailLabel))
+	require.Equal(t, []proton.MessageGroupCount{{LabelID: proton.AllMailLabel, Total: 4, Unread: 2}}, changes[len(changes)-1])
+	requireCounts()
+
+	// So are labels added to and removed from messages.
+	changes = nil
+
+	label, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "label", Color: "#f66", Type: proton.LabelTypeLabel})
+	require.NoError(t, err)
+	require.NoError(t, c.LabelMessages(ctx, messageIDs[1:3], label.ID))
+	require.NoError(t, c.UnlabelMessages(ctx, messageIDs[1:2], label.ID))
+	require.NoError(t, tracker.Sync(ctx))
+	require.Equal(t, proton.MessageGroupCount{LabelID: label.ID, Total: 1, Unread: 1}, tracker.Count(label.ID))
+	requireCounts()
+
+	// Labels that lose all their messages are reported with zero counts.
+	changes = nil
+
+	require.NoError(t, c.DeleteLabel(ctx, label.ID))
+	require.NoError(t, tracker.Sync(ctx))
+	require.Equal(t, [][]proton.MessageGroupCount{{{LabelID: label.ID}}}, changes)
+	requireCounts()
+
+	// Deleted messages are no longer counted.
+	require.NoError(t, c.DeleteMessage(ctx, messageIDs[3]))
+	require.NoError(t, tracker.Sync(ctx))
+	require.Equal(t, proton.MessageGroupCount{LabelID: proton.AllMailLabel, Total: 3, Unread: 1}, tracker.Count(proton.AllMailLabel))
+	requireCounts()
+
+	// Messages created after the seed are followed from their events without fetching the counts again.
+	createTestMessages(t, c, "pass", 1)
+
+	newMessageIDs, err := c.GetAllMessageIDs(ctx, "")
+	require.NoError(t, err)
+
+	newMessageIDs = slices.DeleteFunc(newMessageIDs, func(id string) bool { return slices.Contains(messageIDs, id) })
+	require.Len(t, newMessageIDs, 1)
+
+	countRequests.Store(0)
+
+	require.NoError(t, tracker.Sync(ctx))
+	require.Equal(t, proton.MessageGroupCount{LabelID: proton.AllMailLabel, Total: 4, Unread: 2}, tracker.Count(proton.AllMailLabel))
+	require.NoError(t, c.MarkMessagesRead(ctx, newMessageIDs[0]))
+	require.NoError(t, tracker.Sync(ctx))
+	require.Equal(t, proton.MessageGroupCount{LabelID: proton.AllMailLabel, Total: 4, Unread: 1}, tracker.Count(proton.AllMailLabel))
+	require.Zero(t, countRequests.Load())
+	requireCounts()
+
+	// A mail refresh seeds the counts again; only the counts that differ are reported.
+	changes = nil
+
+	require.NoError(t, s.RefreshUser(userID, proton.RefreshMail))
+	require.NoError(t, tracker.Sync(ctx))
+	require.Empty(t, changes)
+	requireCounts()
+
+	// Unsubscribed observers are no longer called.
+	unsubscribe()
+
+	require.NoError(t, c.MarkMessagesUnread(ctx, messageIDs[0]))
+	require.NoError(t, tracker.Sync(ctx))
+	require.Empty(t, changes)
+	requireCounts()
+
+	require.Zero(t, listings.Load())
+}
@@ -55,8 +55,48 @@ func (c *Client) GetEvent(ctx context.Context, eventID string) ([]Event, bool, e
 	return events, more, nil
 }
 
+// syncEvents calls fn with each event following the last one applied, in order, until there are no more.
+// The ID of the last event applied is read from lastEventID, again after each event: fn may move it elsewhere,
+// e.g. to the latest event after resyncing from scratch, in which case the events are fetched again from there.
+func (c *Client) syncEvents(ctx context.Context, lastEventID func() (string, error), fn func(Event) error) error {
+	for {
+		eventID, err := lastEventID()
+		if err != nil {
+			return err
+		}
+
+		events, more, err := c.GetEvent(ctx, eventID)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			// The API answers with the same event when there is no new one.
+			if event.EventID == eventID {
+				continue
+			}
+
+			if err := fn(event); err != nil {
+				return err
+			}
+
+			if eventID, err = lastEventID(); err != nil {
+				return err
+			} else if eventID != event.EventID {
+				more = true
+				break
+			}
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
+
 // NewEventStreamer returns a new event stream.
 // It polls the API for new events at random intervals between `period` and `period+jitter`.
+// Failures to get events are silently retried; NewEventStreamer reports them, along with refreshes and catch-ups.
 func (c *Client) NewEventStream(ctx context.Context, period, jitter time.Duration, lastEventID string) <-chan Event {
 	eventCh := make(chan Event)
 
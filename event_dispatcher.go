@@ -0,0 +1,296 @@
+package proton
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EventDispatcher dispatches the changes of events to the handlers registered for them.
+//
+// Each event is split into entries, dispatched in this order: refresh, user, user settings, mail settings,
+// addresses, labels, messages, notifications and used space; entries of the same kind are dispatched in
+// the order of the event. The handlers of an entry run in the order they were registered, through the middlewares.
+type EventDispatcher struct {
+	handlers    map[eventHandlerKey][]EventEntryHandler
+	middlewares []EventMiddleware
+	lock        sync.RWMutex
+}
+
+type eventHandlerKey struct {
+	kind   EventEntryKind
+	action EventAction
+}
+
+// NewEventDispatcher returns a dispatcher with no handlers.
+func NewEventDispatcher() *EventDispatcher {
+	return &EventDispatcher{
+		handlers: make(map[eventHandlerKey][]EventEntryHandler),
+	}
+}
+
+// Use adds middlewares that wrap the handling of each entry. The first middleware added is the outermost.
+func (d *EventDispatcher) Use(middlewares ...EventMiddleware) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.middlewares = append(d.middlewares, middlewares...)
+}
+
+// Dispatch dispatches the entries of the event to their handlers.
+// All the handlers run even if some fail; the errors of the failed ones are joined in the returned error.
+// Dispatching stops early only if the context is cancelled.
+func (d *EventDispatcher) Dispatch(ctx context.Context, event Event) error {
+	d.lock.RLock()
+	handlers := maps.Clone(d.handlers)
+	middlewares := slices.Clone(d.middlewares)
+	d.lock.RUnlock()
+
+	handle := func(ctx context.Context, entry EventEntry) error {
+		var errs []error
+
+		for _, handler := range handlers[entry.key()] {
+			if err := handler(ctx, entry); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handle = middlewares[i](handle)
+	}
+
+	var errs []error
+
+	for _, entry := range eventEntries(event) {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		if err := handle(ctx, entry); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", entry, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// On registers a handler for the entries of the given kind and action.
+// The action is ignored for entries other than addresses, labels and messages.
+func (d *EventDispatcher) On(kind EventEntryKind, action EventAction, handler EventEntryHandler) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	key := EventEntry{Kind: kind, Action: action}.key()
+
+	d.handlers[key] = append(d.handlers[key], handler)
+}
+
+// OnRefresh registers a handler called when the event asks the client to resync the refreshed state.
+func (d *EventDispatcher) OnRefresh(fn func(context.Context, RefreshFlag) error) {
+	d.On(EventEntryRefresh, 0, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.Refresh)
+	})
+}
+
+func (d *EventDispatcher) OnUserUpdated(fn func(context.Context, User) error) {
+	d.On(EventEntryUser, 0, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.User)
+	})
+}
+
+func (d *EventDispatcher) OnUserSettingsUpdated(fn func(context.Context, UserSettings) error) {
+	d.On(EventEntryUserSettings, 0, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.UserSettings)
+	})
+}
+
+func (d *EventDispatcher) OnMailSettingsUpdated(fn func(context.Context, MailSettings) error) {
+	d.On(EventEntryMailSettings, 0, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.MailSettings)
+	})
+}
+
+func (d *EventDispatcher) OnAddressCreated(fn func(context.Context, Address) error) {
+	d.On(EventEntryAddress, EventCreate, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.Address)
+	})
+}
+
+// OnAddressUpdated registers a handler called when an address is updated, including when only its flags are.
+func (d *EventDispatcher) OnAddressUpdated(fn func(context.Context, Address) error) {
+	for _, action := range []EventAction{EventUpdate, EventUpdateFlags} {
+		d.On(EventEntryAddress, action, func(ctx context.Context, entry EventEntry) error {
+			return fn(ctx, entry.Address)
+		})
+	}
+}
+
+func (d *EventDispatcher) OnAddressDeleted(fn func(ctx context.Context, addressID string) error) {
+	d.On(EventEntryAddress, EventDelete, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.ID)
+	})
+}
+
+func (d *EventDispatcher) OnLabelCreated(fn func(context.Context, Label) error) {
+	d.On(EventEntryLabel, EventCreate, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.Label)
+	})
+}
+
+// OnLabelUpdated registers a handler called when a label is updated, including when only its flags are.
+func (d *EventDispatcher) OnLabelUpdated(fn func(context.Context, Label) error) {
+	for _, action := range []EventAction{EventUpdate, EventUpdateFlags} {
+		d.On(EventEntryLabel, action, func(ctx context.Context, entry EventEntry) error {
+			return fn(ctx, entry.Label)
+		})
+	}
+}
+
+func (d *EventDispatcher) OnLabelDeleted(fn func(ctx context.Context, labelID string) error) {
+	d.On(EventEntryLabel, EventDelete, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.ID)
+	})
+}
+
+func (d *EventDispatcher) OnMessageCreated(fn func(context.Context, MessageMetadata) error) {
+	d.On(EventEntryMessage, EventCreate, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.Message)
+	})
+}
+
+// OnMessageUpdated registers a handler called when a message is updated, including when only its flags are.
+func (d *EventDispatcher) OnMessageUpdated(fn func(context.Context, MessageMetadata) error) {
+	for _, action := range []EventAction{EventUpdate, EventUpdateFlags} {
+		d.On(EventEntryMessage, action, func(ctx context.Context, entry EventEntry) error {
+			return fn(ctx, entry.Message)
+		})
+	}
+}
+
+func (d *EventDispatcher) OnMessageDeleted(fn func(ctx context.Context, messageID string) error) {
+	d.On(EventEntryMessage, EventDelete, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.ID)
+	})
+}
+
+func (d *EventDispatcher) OnNotification(fn func(context.Context, NotificationEvent) error) {
+	d.On(EventEntryNotification, 0, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.Notification)
+	})
+}
+
+func (d *EventDispatcher) OnUsedSpaceUpdated(fn func(ctx context.Context, usedSpace int64) error) {
+	d.On(EventEntryUsedSpace, 0, func(ctx context.Context, entry EventEntry) error {
+		return fn(ctx, entry.UsedSpace)
+	})
+}
+
+// EventLogging returns a middleware that logs each entry at debug level, and the entries whose handling failed.
+func EventLogging(logger logrus.FieldLogger) EventMiddleware {
+	return func(next EventEntryHandler) EventEntryHandler {
+		return func(ctx context.Context, entry EventEntry) error {
+			logger := logger.WithFields(logrus.Fields{
+				"eventID": entry.EventID,
+				"entry":   entry.String(),
+			})
+
+			logger.Debug("Handling event entry")
+
+			if err := next(ctx, entry); err != nil {
+				logger.WithError(err).Warn("Failed to handle event entry")
+				return err
+			}
+
+			return nil
+		}
+	}
+}
+
+// EventAddressFilter returns a middleware that skips the address and message entries of other addresses.
+// Message deletions, which don't say which address the message belonged to, are never skipped.
+func EventAddressFilter(addressIDs ...string) EventMiddleware {
+	return func(next EventEntryHandler) EventEntryHandler {
+		return func(ctx context.Context, entry EventEntry) error {
+			switch entry.Kind {
+			case EventEntryAddress:
+				if !slices.Contains(addressIDs, entry.ID) {
+					return nil
+				}
+
+			case EventEntryMessage:
+				if entry.Action != EventDelete && !slices.Contains(addressIDs, entry.Message.AddressID) {
+					return nil
+				}
+			}
+
+			return next(ctx, entry)
+		}
+	}
+}
+
+// key returns the key of the handlers of the entry.
+func (entry EventEntry) key() eventHandlerKey {
+	switch entry.Kind {
+	case EventEntryAddress, EventEntryLabel, EventEntryMessage:
+		return eventHandlerKey{kind: entry.Kind, action: entry.Action}
+
+	default:
+		return eventHandlerKey{kind: entry.Kind}
+	}
+}
+
+// eventEntries splits the event into entries, in the order they are dispatched.
+func eventEntries(event Event) []EventEntry {
+	var entries []EventEntry
+
+	add := func(entry EventEntry) {
+		entry.EventID = event.EventID
+		entries = append(entries, entry)
+	}
+
+	if event.Refresh != 0 {
+		add(EventEntry{Kind: EventEntryRefresh, Refresh: event.Refresh})
+	}
+
+	if event.User != nil {
+		add(EventEntry{Kind: EventEntryUser, User: *event.User})
+	}
+
+	if event.UserSettings != nil {
+		add(EventEntry{Kind: EventEntryUserSettings, UserSettings: *event.UserSettings})
+	}
+
+	if event.MailSettings != nil {
+		add(EventEntry{Kind: EventEntryMailSettings, MailSettings: *event.MailSettings})
+	}
+
+	for _, addrEvent := range event.Addresses {
+		add(EventEntry{Kind: EventEntryAddress, Action: addrEvent.Action, ID: addrEvent.ID, Address: addrEvent.Address})
+	}
+
+	for _, labelEvent := range event.Labels {
+		add(EventEntry{Kind: EventEntryLabel, Action: labelEvent.Action, ID: labelEvent.ID, Label: labelEvent.Label})
+	}
+
+	for _, msgEvent := range event.Messages {
+		add(EventEntry{Kind: EventEntryMessage, Action: msgEvent.Action, ID: msgEvent.ID, Message: msgEvent.Message})
+	}
+
+	for _, notification := range event.Notifications {
+		add(EventEntry{Kind: EventEntryNotification, Notification: notification})
+	}
+
+	if event.UsedSpace != nil {
+		add(EventEntry{Kind: EventEntryUsedSpace, UsedSpace: *event.UsedSpace})
+	}
+
+	return entries
+}
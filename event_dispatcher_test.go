@@ -0,0 +1,188 @@
+package proton_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventDispatcher(t *testing.T) {
+	usedSpace := int64(42)
+
+	event := proton.Event{
+		EventID:   "eventID",
+		Refresh:   proton.RefreshMail,
+		User:      &proton.User{ID: "userID"},
+		UsedSpace: &usedSpace,
+		Messages: []proton.MessageEvent{
+			{EventItem: proton.EventItem{ID: "msg1", Action: proton.EventCreate}, Message: proton.MessageMetadata{ID: "msg1", AddressID: "addr1"}},
+			{EventItem: proton.EventItem{ID: "msg2", Action: proton.EventUpdateFlags}, Message: proton.MessageMetadata{ID: "msg2", AddressID: "addr2"}},
+			{EventItem: proton.EventItem{ID: "msg3", Action: proton.EventDelete}},
+		},
+		Labels: []proton.LabelEvent{
+			{EventItem: proton.EventItem{ID: "label1", Action: proton.EventDelete}},
+		},
+		Addresses: []proton.AddressEvent{
+			{EventItem: proton.EventItem{ID: "addr1", Action: proton.EventUpdate}, Address: proton.Address{ID: "addr1"}},
+			{EventItem: proton.EventItem{ID: "addr2", Action: proton.EventCreate}, Address: proton.Address{ID: "addr2"}},
+		},
+	}
+
+	var calls []string
+
+	record := func(format string, args ...any) {
+		calls = append(calls, fmt.Sprintf(format, args...))
+	}
+
+	d := proton.NewEventDispatcher()
+
+	d.OnRefresh(func(_ context.Context, refresh proton.RefreshFlag) error {
+		record("refresh %v", refresh)
+		return nil
+	})
+
+	d.OnUserUpdated(func(_ context.Context, user proton.User) error {
+		record("user %v", user.ID)
+		return nil
+	})
+
+	d.OnUsedSpaceUpdated(func(_ context.Context, usedSpace int64) error {
+		record("used space %v", usedSpace)
+		return nil
+	})
+
+	d.OnAddressCreated(func(_ context.Context, addr proton.Address) error {
+		record("address created %v", addr.ID)
+		return nil
+	})
+
+	d.OnAddressUpdated(func(_ context.Context, addr proton.Address) error {
+		record("address updated %v", addr.ID)
+		return nil
+	})
+
+	d.OnLabelDeleted(func(_ context.Context, labelID string) error {
+		record("label deleted %v", labelID)
+		return errors.New("label failure")
+	})
+
+	d.OnMessageCreated(func(_ context.Context, msg proton.MessageMetadata) error {
+		record("message created %v", msg.ID)
+		return nil
+	})
+
+	d.OnMessageUpdated(func(_ context.Context, msg proton.MessageMetadata) error {
+		record("message updated %v", msg.ID)
+		return nil
+	})
+
+	d.OnMessageDeleted(func(_ context.Context, messageID string) error {
+		record("message deleted %v", messageID)
+		return errors.New("first failure")
+	})
+
+	d.OnMessageDeleted(func(_ context.Context, messageID string) error {
+		record("message deleted again %v", messageID)
+		return errors.New("second failure")
+	})
+
+	// Entries are dispatched in dependency order, and all handlers run even if some fail.
+	err := d.Dispatch(context.Background(), event)
+	require.ErrorContains(t, err, "label label1 deleted: label failure")
+	require.ErrorContains(t, err, "message msg3 deleted: first failure\nsecond failure")
+
+	require.Equal(t, []string{
+		"refresh 1",
+		"user userID",
+		"address updated addr1",
+		"address created addr2",
+		"label deleted label1",
+		"message created msg1",
+		"message updated msg2",
+		"message deleted msg3",
+		"message deleted again msg3",
+		"used space 42",
+	}, calls)
+
+	// Middlewares wrap the handling of each entry, the first added being the outermost.
+	calls = nil
+
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	d.Use(
+		func(next proton.EventEntryHandler) proton.EventEntryHandler {
+			return func(ctx context.Context, entry proton.EventEntry) error {
+				record("outer %v", entry)
+				return next(ctx, entry)
+			}
+		},
+		proton.EventLogging(logger),
+		proton.EventAddressFilter("addr1"),
+	)
+
+	require.Error(t, d.Dispatch(context.Background(), event))
+
+	require.Equal(t, []string{
+		"outer refresh 1",
+		"refresh 1",
+		"outer user updated",
+		"user userID",
+		"outer address addr1 updated",
+		"address updated addr1",
+		"outer address addr2 created",
+		"outer label label1 deleted",
+		"label deleted label1",
+		"outer message msg1 created",
+		"message created msg1",
+		"outer message msg2 flags updated",
+		"outer message msg3 deleted",
+		"message deleted msg3",
+		"message deleted again msg3",
+		"outer used space updated",
+		"used space 42",
+	}, calls)
+
+	// Entries are logged, including those skipped by the filter, and failures are logged as warnings.
+	require.Len(t, hook.AllEntries(), 9+2)
+
+	var failed []any
+
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel {
+			failed = append(failed, entry.Data["entry"])
+		}
+	}
+
+	require.Equal(t, []any{"label label1 deleted", "message msg3 deleted"}, failed)
+}
+
+func TestEventDispatcher_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := proton.NewEventDispatcher()
+
+	var created []string
+
+	d.OnMessageCreated(func(_ context.Context, msg proton.MessageMetadata) error {
+		created = append(created, msg.ID)
+		cancel()
+
+		return nil
+	})
+
+	err := d.Dispatch(ctx, proton.Event{
+		Messages: []proton.MessageEvent{
+			{EventItem: proton.EventItem{ID: "msg1", Action: proton.EventCreate}, Message: proton.MessageMetadata{ID: "msg1"}},
+			{EventItem: proton.EventItem{ID: "msg2", Action: proton.EventCreate}, Message: proton.MessageMetadata{ID: "msg2"}},
+		},
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []string{"msg1"}, created)
+}
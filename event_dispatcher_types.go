@@ -0,0 +1,101 @@
+package proton
+
+import (
+	"context"
+	"fmt"
+)
+
+// EventEntryKind is the kind of change carried by an event entry.
+type EventEntryKind int
+
+const (
+	EventEntryRefresh EventEntryKind = iota
+	EventEntryUser
+	EventEntryUserSettings
+	EventEntryMailSettings
+	EventEntryAddress
+	EventEntryLabel
+	EventEntryMessage
+	EventEntryNotification
+	EventEntryUsedSpace
+)
+
+func (kind EventEntryKind) String() string {
+	switch kind {
+	case EventEntryRefresh:
+		return "refresh"
+
+	case EventEntryUser:
+		return "user"
+
+	case EventEntryUserSettings:
+		return "user settings"
+
+	case EventEntryMailSettings:
+		return "mail settings"
+
+	case EventEntryAddress:
+		return "address"
+
+	case EventEntryLabel:
+		return "label"
+
+	case EventEntryMessage:
+		return "message"
+
+	case EventEntryNotification:
+		return "notification"
+
+	case EventEntryUsedSpace:
+		return "used space"
+
+	default:
+		return "unknown"
+	}
+}
+
+// EventEntry is a single change of an event, as dispatched by an EventDispatcher.
+// Only the fields relevant to its kind are set.
+type EventEntry struct {
+	Kind EventEntryKind
+
+	// EventID is the ID of the event the entry is part of.
+	EventID string
+
+	// Action and ID are those of address, label and message entries.
+	Action EventAction
+	ID     string
+
+	Refresh      RefreshFlag
+	User         User
+	UserSettings UserSettings
+	MailSettings MailSettings
+	Address      Address
+	Label        Label
+	Message      MessageMetadata
+	Notification NotificationEvent
+	UsedSpace    int64
+}
+
+func (entry EventEntry) String() string {
+	switch entry.Kind {
+	case EventEntryAddress, EventEntryLabel, EventEntryMessage:
+		return fmt.Sprintf("%v %s %v", entry.Kind, entry.ID, entry.Action)
+
+	case EventEntryRefresh:
+		return fmt.Sprintf("refresh %v", entry.Refresh)
+
+	case EventEntryNotification:
+		return fmt.Sprintf("notification %s", entry.Notification.ID)
+
+	default:
+		return fmt.Sprintf("%v updated", entry.Kind)
+	}
+}
+
+// EventEntryHandler handles an entry of an event.
+type EventEntryHandler func(ctx context.Context, entry EventEntry) error
+
+// EventMiddleware wraps the handling of each entry dispatched by an EventDispatcher.
+// It may act before or after calling next, change the entry, or skip it by not calling next.
+type EventMiddleware func(next EventEntryHandler) EventEntryHandler
@@ -0,0 +1,270 @@
+package proton
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/gluon/async"
+)
+
+// defaultEventStreamPeriod is the interval between polls once caught up, if the config doesn't set one.
+const defaultEventStreamPeriod = 30 * time.Second
+
+// defaultEventStreamMaxBackoff is the longest delay between polls after failures, if the config doesn't set one.
+const defaultEventStreamMaxBackoff = 5 * time.Minute
+
+// EventStreamer streams the events of a client, reporting errors, refreshes and when it has caught up.
+// Its items are received from C, which is closed when the stream ends; Cause then returns why it ended.
+// A stream that ended can be resumed from the last event it delivered with Resume.
+type EventStreamer struct {
+	C <-chan EventStreamItem
+
+	c      *Client
+	cfg    EventStreamConfig
+	cancel context.CancelCauseFunc
+	doneCh chan struct{}
+
+	lastEventID string
+	metrics     EventStreamMetrics
+	cause       error
+	lock        sync.Mutex
+}
+
+// NewEventStreamer returns a new event stream of the events following lastEventID.
+// It polls for events immediately, then as configured; it ends when the context is cancelled,
+// when it is closed, or when polling fails more than the configured number of times in a row.
+func (c *Client) NewEventStreamer(ctx context.Context, cfg EventStreamConfig, lastEventID string) *EventStreamer {
+	if cfg.Period == 0 {
+		cfg.Period = defaultEventStreamPeriod
+	}
+
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = max(cfg.Period, time.Second)
+	}
+
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = max(cfg.MinBackoff, defaultEventStreamMaxBackoff)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	itemCh := make(chan EventStreamItem)
+
+	s := &EventStreamer{
+		C:           itemCh,
+		c:           c,
+		cfg:         cfg,
+		cancel:      cancel,
+		doneCh:      make(chan struct{}),
+		lastEventID: lastEventID,
+	}
+
+	go func() {
+		defer async.HandlePanic(c.m.panicHandler)
+
+		defer close(s.doneCh)
+
+		defer close(itemCh)
+
+		err := s.run(ctx, itemCh)
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		s.cause = err
+	}()
+
+	return s
+}
+
+// Close ends the stream, with ErrEventStreamClosed as cause, and waits for it to end.
+func (s *EventStreamer) Close() {
+	s.cancel(ErrEventStreamClosed)
+	<-s.doneCh
+}
+
+// Done returns a channel that is closed once the stream has ended.
+func (s *EventStreamer) Done() <-chan struct{} {
+	return s.doneCh
+}
+
+// Cause returns why the stream ended, or nil if it is still running.
+func (s *EventStreamer) Cause() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.cause
+}
+
+// LastEventID returns the ID of the last event delivered by the stream, from which it can be resumed.
+func (s *EventStreamer) LastEventID() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.lastEventID
+}
+
+// Metrics returns the current metrics of the stream.
+func (s *EventStreamer) Metrics() EventStreamMetrics {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.metrics
+}
+
+// Resume returns a new stream, with the same configuration, of the events following the last one this stream delivered.
+// This stream is closed first if it is still running.
+func (s *EventStreamer) Resume(ctx context.Context) *EventStreamer {
+	s.Close()
+
+	return s.c.NewEventStreamer(ctx, s.cfg, s.LastEventID())
+}
+
+func (s *EventStreamer) run(ctx context.Context, itemCh chan<- EventStreamItem) error {
+	var (
+		delay    time.Duration
+		caughtUp bool
+		attempt  int
+	)
+
+	for {
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return context.Cause(ctx)
+
+		case <-timer.C:
+			// ...
+		}
+
+		events, more, err := s.c.GetEvent(ctx, s.LastEventID())
+		if err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+
+			attempt++
+
+			s.withMetrics(func(metrics *EventStreamMetrics) {
+				metrics.Polls++
+				metrics.Failures++
+				metrics.ConsecutiveFailures = attempt
+				metrics.LastError = err
+			})
+
+			if apiErr := new(APIError); errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+				return fmt.Errorf("client is no longer authorized: %w", err)
+			}
+
+			if s.cfg.MaxAttempts > 0 && attempt >= s.cfg.MaxAttempts {
+				return fmt.Errorf("failed to get events %d times in a row: %w", attempt, err)
+			}
+
+			delay = withJitter(s.backoff(attempt), s.cfg.Jitter)
+			caughtUp = false
+
+			if !s.send(ctx, itemCh, EventStreamItem{Type: EventStreamError, Err: err, Attempt: attempt, Retry: delay}) {
+				return context.Cause(ctx)
+			}
+
+			continue
+		}
+
+		attempt = 0
+
+		s.withMetrics(func(metrics *EventStreamMetrics) {
+			metrics.Polls++
+			metrics.ConsecutiveFailures = 0
+			metrics.LastSuccess = time.Now()
+		})
+
+		for _, event := range events {
+			// The API answers with the same event when there is no new one.
+			if event.EventID == s.LastEventID() {
+				continue
+			}
+
+			item := EventStreamItem{Type: EventStreamEvent, Event: event}
+
+			if event.Refresh != 0 {
+				item.Type = EventStreamRefresh
+			}
+
+			if !s.send(ctx, itemCh, item) {
+				return context.Cause(ctx)
+			}
+
+			s.withMetrics(func(metrics *EventStreamMetrics) {
+				metrics.Events++
+
+				if event.Refresh != 0 {
+					metrics.Refreshes++
+				}
+			})
+
+			s.lock.Lock()
+			s.lastEventID = event.EventID
+			s.lock.Unlock()
+
+			caughtUp = false
+		}
+
+		if more {
+			delay = 0
+			continue
+		}
+
+		if !caughtUp {
+			if !s.send(ctx, itemCh, EventStreamItem{Type: EventStreamCaughtUp, EventID: s.LastEventID()}) {
+				return context.Cause(ctx)
+			}
+
+			caughtUp = true
+		}
+
+		delay = withJitter(s.cfg.Period, s.cfg.Jitter)
+	}
+}
+
+// send delivers the item to the consumer, returning false if the stream ended first.
+func (s *EventStreamer) send(ctx context.Context, itemCh chan<- EventStreamItem, item EventStreamItem) bool {
+	start := time.Now()
+
+	defer func() {
+		s.withMetrics(func(metrics *EventStreamMetrics) {
+			metrics.Blocked += time.Since(start)
+		})
+	}()
+
+	select {
+	case <-ctx.Done():
+		return false
+
+	case itemCh <- item:
+		return true
+	}
+}
+
+// backoff returns the delay before polling again after the given number of consecutive failures.
+func (s *EventStreamer) backoff(attempt int) time.Duration {
+	delay := s.cfg.MinBackoff
+
+	for i := 1; i < attempt && delay < s.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.cfg.MaxBackoff)
+}
+
+func (s *EventStreamer) withMetrics(fn func(*EventStreamMetrics)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fn(&s.metrics)
+}
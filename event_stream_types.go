@@ -0,0 +1,102 @@
+package proton
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrEventStreamClosed is the cause of the end of an event stream closed with EventStreamer.Close.
+var ErrEventStreamClosed = errors.New("event stream closed")
+
+type EventStreamItemType int
+
+const (
+	// EventStreamEvent items carry a new event.
+	EventStreamEvent EventStreamItemType = iota
+
+	// EventStreamRefresh items carry an event whose Refresh flag asks the client to resync the refreshed state.
+	EventStreamRefresh
+
+	// EventStreamError items report a failure to poll for events. The stream retries after the item's Retry delay.
+	EventStreamError
+
+	// EventStreamCaughtUp items report that all the events up to the item's EventID have been delivered.
+	// They are sent when the stream first reaches the latest event, and again after new events or errors.
+	EventStreamCaughtUp
+)
+
+func (t EventStreamItemType) String() string {
+	switch t {
+	case EventStreamEvent:
+		return "event"
+
+	case EventStreamRefresh:
+		return "refresh"
+
+	case EventStreamError:
+		return "error"
+
+	case EventStreamCaughtUp:
+		return "caught up"
+
+	default:
+		return "unknown"
+	}
+}
+
+// EventStreamItem is an item of an event stream.
+type EventStreamItem struct {
+	Type EventStreamItemType
+
+	// Event is the event of EventStreamEvent and EventStreamRefresh items.
+	Event Event
+
+	// EventID is the ID of the latest event of EventStreamCaughtUp items.
+	EventID string
+
+	// Err is the error of EventStreamError items, and Attempt the number of consecutive failures so far.
+	// The stream polls again after the Retry delay.
+	Err     error
+	Attempt int
+	Retry   time.Duration
+}
+
+// EventStreamConfig configures an event stream.
+type EventStreamConfig struct {
+	// Period and Jitter are the interval between polls once the stream has caught up:
+	// it polls at random intervals between Period, or thirty seconds if unset, and Period+Jitter.
+	Period time.Duration
+	Jitter time.Duration
+
+	// MinBackoff and MaxBackoff bound the delay before polling again after a failure.
+	// It starts at MinBackoff, or Period but at least a second if unset, and doubles with each consecutive failure
+	// up to MaxBackoff, or five minutes but at least MinBackoff if unset.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// MaxAttempts is the number of consecutive failures after which the stream gives up and closes.
+	// If zero, it never gives up; it always gives up when the client is no longer authorized.
+	MaxAttempts int
+}
+
+// EventStreamMetrics describes the activity of an event stream.
+type EventStreamMetrics struct {
+	// Polls is the number of requests for events, of which Failures failed.
+	Polls    int
+	Failures int
+
+	// ConsecutiveFailures is the number of failures since the last successful poll.
+	ConsecutiveFailures int
+
+	// Events is the number of events delivered, of which Refreshes asked the client to resync.
+	Events    int
+	Refreshes int
+
+	// LastSuccess is when a poll last succeeded, and LastError the error of the last failure.
+	LastSuccess time.Time
+	LastError   error
+
+	// Blocked is the total time the stream waited for the consumer to receive its items.
+	// The stream does not poll for more events while its consumer is busy.
+	Blocked time.Duration
+}
@@ -2,6 +2,9 @@ package proton_test
 
 import (
 	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -114,3 +117,204 @@ func TestMaxEventMerge(t *testing.T) {
 	require.False(t, more)
 	require.Equal(t, 26, len(events2))
 }
+
+func TestEventStreamer_Items(t *testing.T) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	userID, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	var failing atomic.Bool
+
+	s.AddStatusHook(func(req *http.Request) (int, bool) {
+		if failing.Load() && strings.HasPrefix(req.URL.Path, "/core/v4/events/") {
+			return http.StatusInternalServerError, true
+		}
+
+		return 0, false
+	})
+
+	latestID, err := c.GetLatestEventID(ctx)
+	require.NoError(t, err)
+
+	stream := c.NewEventStreamer(ctx, proton.EventStreamConfig{
+		Period:     10 * time.Millisecond,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	}, latestID)
+	defer stream.Close()
+
+	// The stream first reports that it caught up with the latest event.
+	item := <-stream.C
+	require.Equal(t, proton.EventStreamCaughtUp, item.Type)
+	require.Equal(t, latestID, item.EventID)
+
+	// New events are delivered, followed by another caught up item.
+	label, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: uuid.NewString(), Color: "#f66", Type: proton.LabelTypeLabel})
+	require.NoError(t, err)
+
+	item = <-stream.C
+	require.Equal(t, proton.EventStreamEvent, item.Type)
+	require.Len(t, item.Event.Labels, 1)
+	require.Equal(t, label.ID, item.Event.Labels[0].ID)
+
+	item = <-stream.C
+	require.Equal(t, proton.EventStreamCaughtUp, item.Type)
+	require.Equal(t, stream.LastEventID(), item.EventID)
+
+	// Refreshes are reported as such.
+	require.NoError(t, s.RefreshUser(userID, proton.RefreshMail))
+
+	item = <-stream.C
+	require.Equal(t, proton.EventStreamRefresh, item.Type)
+	require.Equal(t, proton.RefreshMail, item.Event.Refresh)
+
+	require.Equal(t, proton.EventStreamCaughtUp, (<-stream.C).Type)
+
+	// Failures are reported, with exponentially increasing delays.
+	failing.Store(true)
+
+	for attempt, retry := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond} {
+		item = <-stream.C
+		require.Equal(t, proton.EventStreamError, item.Type)
+		require.Error(t, item.Err)
+		require.Equal(t, attempt+1, item.Attempt)
+		require.Equal(t, retry, item.Retry)
+	}
+
+	metrics := stream.Metrics()
+	require.Equal(t, 3, metrics.ConsecutiveFailures)
+	require.GreaterOrEqual(t, metrics.Failures, 3)
+	require.Error(t, metrics.LastError)
+	require.Equal(t, 2, metrics.Events)
+	require.Equal(t, 1, metrics.Refreshes)
+
+	// Once the API recovers, the stream reports that it caught up again.
+	failing.Store(false)
+
+	for item = range stream.C {
+		if item.Type != proton.EventStreamError {
+			break
+		}
+	}
+
+	require.Equal(t, proton.EventStreamCaughtUp, item.Type)
+	require.Zero(t, stream.Metrics().ConsecutiveFailures)
+
+	// Closing the stream ends it with a cause.
+	stream.Close()
+
+	_, ok := <-stream.C
+	require.False(t, ok)
+	require.ErrorIs(t, stream.Cause(), proton.ErrEventStreamClosed)
+
+	// It can be resumed from the last event it delivered.
+	_, err = c.UpdateLabel(ctx, label.ID, proton.UpdateLabelReq{Name: uuid.NewString(), Color: "#f66"})
+	require.NoError(t, err)
+
+	resumed := stream.Resume(ctx)
+	defer resumed.Close()
+
+	item = <-resumed.C
+	require.Equal(t, proton.EventStreamEvent, item.Type)
+	require.Len(t, item.Event.Labels, 1)
+	require.Equal(t, proton.EventUpdate, item.Event.Labels[0].Action)
+}
+
+func TestEventStreamer_DefaultPeriod(t *testing.T) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	latestID, err := c.GetLatestEventID(ctx)
+	require.NoError(t, err)
+
+	stream := c.NewEventStreamer(ctx, proton.EventStreamConfig{}, latestID)
+	defer stream.Close()
+
+	require.Equal(t, proton.EventStreamCaughtUp, (<-stream.C).Type)
+
+	// Without a configured period, the stream doesn't poll again right away.
+	time.Sleep(100 * time.Millisecond)
+
+	require.Equal(t, 1, stream.Metrics().Polls)
+}
+
+func TestEventStreamer_GiveUp(t *testing.T) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	latestID, err := c.GetLatestEventID(ctx)
+	require.NoError(t, err)
+
+	s.AddStatusHook(func(req *http.Request) (int, bool) {
+		if strings.HasPrefix(req.URL.Path, "/core/v4/events/") {
+			return http.StatusInternalServerError, true
+		}
+
+		return 0, false
+	})
+
+	stream := c.NewEventStreamer(ctx, proton.EventStreamConfig{
+		MinBackoff:  time.Millisecond,
+		MaxAttempts: 3,
+	}, latestID)
+
+	var errs int
+
+	for item := range stream.C {
+		require.Equal(t, proton.EventStreamError, item.Type)
+		errs++
+	}
+
+	// The stream gives up after the configured number of attempts, reporting the last error as its cause.
+	require.Equal(t, 2, errs)
+
+	var apiErr *proton.APIError
+
+	require.ErrorAs(t, stream.Cause(), &apiErr)
+	require.Equal(t, http.StatusInternalServerError, apiErr.Status)
+	require.Equal(t, latestID, stream.LastEventID())
+}
@@ -97,6 +97,25 @@ const (
 	EventUpdateFlags
 )
 
+func (action EventAction) String() string {
+	switch action {
+	case EventDelete:
+		return "deleted"
+
+	case EventCreate:
+		return "created"
+
+	case EventUpdate:
+		return "updated"
+
+	case EventUpdateFlags:
+		return "flags updated"
+
+	default:
+		return "unknown"
+	}
+}
+
 type EventItem struct {
 	ID     string
 	Action EventAction
@@ -0,0 +1,169 @@
+package proton
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Options returns the authentication options of the info, decoded from whatever form they were received in.
+func (info FIDO2Info) Options() (FIDO2AuthenticationOptions, error) {
+	if options, ok := info.AuthenticationOptions.(FIDO2AuthenticationOptions); ok {
+		return options, nil
+	}
+
+	b, err := json.Marshal(info.AuthenticationOptions)
+	if err != nil {
+		return FIDO2AuthenticationOptions{}, err
+	}
+
+	var options FIDO2AuthenticationOptions
+
+	if err := json.Unmarshal(b, &options); err != nil {
+		return FIDO2AuthenticationOptions{}, fmt.Errorf("invalid FIDO2 authentication options: %w", err)
+	}
+
+	return options, nil
+}
+
+// FIDO2Assertion is the output of a WebAuthn authenticator asked to sign a challenge.
+type FIDO2Assertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// NewFIDO2Req returns the request that verifies the assertion made with the given options.
+// It is passed to Auth2FA as the FIDO2 field of Auth2FAReq.
+func NewFIDO2Req(options FIDO2AuthenticationOptions, assertion FIDO2Assertion) FIDO2Req {
+	return FIDO2Req{
+		AuthenticationOptions: options,
+		ClientData:            base64.StdEncoding.EncodeToString(assertion.ClientDataJSON),
+		AuthenticatorData:     base64.StdEncoding.EncodeToString(assertion.AuthenticatorData),
+		Signature:             base64.StdEncoding.EncodeToString(assertion.Signature),
+		CredentialID:          bytesToInts(assertion.CredentialID),
+	}
+}
+
+// FIDO2Authenticator is a software WebAuthn authenticator holding a single ES256 credential.
+// It is meant to test the security key login flow without hardware; its key is kept in memory unprotected.
+type FIDO2Authenticator struct {
+	credentialID []byte
+	key          *ecdsa.PrivateKey
+	signCount    uint32
+	lock         sync.Mutex
+}
+
+// NewFIDO2Authenticator returns an authenticator with a new random credential.
+func NewFIDO2Authenticator() (*FIDO2Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID := make([]byte, 32)
+
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+
+	return &FIDO2Authenticator{
+		credentialID: credentialID,
+		key:          key,
+	}, nil
+}
+
+// CredentialID returns the ID of the credential of the authenticator.
+func (a *FIDO2Authenticator) CredentialID() []byte {
+	return slices.Clone(a.credentialID)
+}
+
+// PublicKey returns the public key of the credential of the authenticator, PKIX-encoded.
+func (a *FIDO2Authenticator) PublicKey() ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(&a.key.PublicKey)
+}
+
+// Assert signs the challenge of the options as navigator.credentials.get would from the given origin,
+// e.g. "https://account.proton.me". It fails if the options don't allow the credential of the authenticator.
+func (a *FIDO2Authenticator) Assert(origin string, options FIDO2AuthenticationOptions) (FIDO2Assertion, error) {
+	allowed := len(options.PublicKey.AllowCredentials) == 0
+
+	for _, desc := range options.PublicKey.AllowCredentials {
+		if slices.Equal(intsToBytes(desc.ID), a.credentialID) {
+			allowed = true
+		}
+	}
+
+	if !allowed {
+		return FIDO2Assertion{}, fmt.Errorf("credential not allowed")
+	}
+
+	clientData, err := json.Marshal(struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}{
+		Type:      "webauthn.get",
+		Challenge: base64.RawURLEncoding.EncodeToString(intsToBytes(options.PublicKey.Challenge)),
+		Origin:    origin,
+	})
+	if err != nil {
+		return FIDO2Assertion{}, err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.signCount++
+
+	// The authenticator data is the hash of the relying party ID, the flags (user present) and the signature counter.
+	rpIDHash := sha256.Sum256([]byte(options.PublicKey.RPID))
+
+	authData := binary.BigEndian.AppendUint32(append(rpIDHash[:], 0x01), a.signCount)
+
+	clientDataHash := sha256.Sum256(clientData)
+
+	digest := sha256.Sum256(append(slices.Clone(authData), clientDataHash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return FIDO2Assertion{}, err
+	}
+
+	return FIDO2Assertion{
+		CredentialID:      slices.Clone(a.credentialID),
+		ClientDataJSON:    clientData,
+		AuthenticatorData: authData,
+		Signature:         sig,
+	}, nil
+}
+
+// bytesToInts converts bytes to the list of integers the API uses for binary FIDO2 values.
+func bytesToInts(b []byte) []int {
+	res := make([]int, len(b))
+
+	for i, v := range b {
+		res[i] = int(v)
+	}
+
+	return res
+}
+
+func intsToBytes(v []int) []byte {
+	res := make([]byte, len(v))
+
+	for i, x := range v {
+		res[i] = byte(x)
+	}
+
+	return res
+}
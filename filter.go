@@ -0,0 +1,100 @@
+package proton
+
+import (
+	"context"
+
+	"github.com/ProtonMail/go-proton-api/pkg/sieve"
+	"github.com/go-resty/resty/v2"
+)
+
+func (c *Client) GetFilters(ctx context.Context) ([]Filter, error) {
+	var res struct {
+		Filters []Filter
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetResult(&res).Get("/mail/v4/filters")
+	}); err != nil {
+		return nil, err
+	}
+
+	return res.Filters, nil
+}
+
+func (c *Client) CreateFilter(ctx context.Context, req CreateFilterReq) (Filter, error) {
+	var res struct {
+		Filter Filter
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).SetResult(&res).Post("/mail/v4/filters")
+	}); err != nil {
+		return Filter{}, err
+	}
+
+	return res.Filter, nil
+}
+
+func (c *Client) UpdateFilter(ctx context.Context, filterID string, req UpdateFilterReq) (Filter, error) {
+	var res struct {
+		Filter Filter
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).SetResult(&res).Put("/mail/v4/filters/" + filterID)
+	}); err != nil {
+		return Filter{}, err
+	}
+
+	return res.Filter, nil
+}
+
+func (c *Client) EnableFilter(ctx context.Context, filterID string) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.Put("/mail/v4/filters/" + filterID + "/enable")
+	})
+}
+
+func (c *Client) DisableFilter(ctx context.Context, filterID string) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.Put("/mail/v4/filters/" + filterID + "/disable")
+	})
+}
+
+func (c *Client) DeleteFilter(ctx context.Context, filterID string) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.Delete("/mail/v4/filters/" + filterID)
+	})
+}
+
+// OrderFilters sets the priority of the filters to the order of the given filter IDs.
+func (c *Client) OrderFilters(ctx context.Context, req OrderFiltersReq) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).Put("/mail/v4/filters/order")
+	})
+}
+
+// CheckSieve asks the API to check the given Sieve script, returning the issues found in it.
+func (c *Client) CheckSieve(ctx context.Context, req CheckSieveReq) ([]SieveIssue, error) {
+	var res struct {
+		Issues []SieveIssue
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).SetResult(&res).Put("/mail/v4/filters/check")
+	}); err != nil {
+		return nil, err
+	}
+
+	return res.Issues, nil
+}
+
+// ValidateSieve checks the given Sieve script locally, without a round trip to the API.
+// It only accepts the commands, tests and extensions that filters support; the returned error is a *sieve.Error.
+func ValidateSieve(script string) error {
+	if _, err := sieve.Parse(script); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package proton_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/pkg/sieve"
+	"github.com/bradenaw/juniper/xslices"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilters(t *testing.T) {
+	withTestClient(t, func(ctx context.Context, c *proton.Client) {
+		first, err := c.CreateFilter(ctx, proton.CreateFilterReq{
+			Name:    "first",
+			Status:  proton.FilterStatusEnabled,
+			Version: proton.SieveVersion,
+			Sieve:   `if header :contains "Subject" "spam" { discard; }`,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, first.Priority)
+
+		second, err := c.CreateFilter(ctx, proton.CreateFilterReq{
+			Name:    "second",
+			Status:  proton.FilterStatusEnabled,
+			Version: proton.SieveVersion,
+			Sieve:   `require "fileinto"; fileinto "Archive";`,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, second.Priority)
+
+		// Reorder the filters.
+		require.NoError(t, c.OrderFilters(ctx, proton.OrderFiltersReq{FilterIDs: []string{second.ID, first.ID}}))
+
+		filters, err := c.GetFilters(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{second.ID, first.ID}, xslices.Map(filters, func(f proton.Filter) string { return f.ID }))
+		require.Equal(t, []int{1, 2}, xslices.Map(filters, func(f proton.Filter) int { return f.Priority }))
+
+		// Disable, then enable again, the first filter.
+		require.NoError(t, c.DisableFilter(ctx, first.ID))
+
+		filters, err = c.GetFilters(ctx)
+		require.NoError(t, err)
+		require.Equal(t, proton.FilterStatusDisabled, filters[1].Status)
+
+		require.NoError(t, c.EnableFilter(ctx, first.ID))
+
+		// Update the second filter.
+		updated, err := c.UpdateFilter(ctx, second.ID, proton.UpdateFilterReq{
+			Name:    "renamed",
+			Status:  proton.FilterStatusEnabled,
+			Version: proton.SieveVersion,
+			Sieve:   `keep;`,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "renamed", updated.Name)
+		require.Equal(t, `keep;`, updated.Sieve)
+
+		// Delete the first filter.
+		require.NoError(t, c.DeleteFilter(ctx, first.ID))
+
+		filters, err = c.GetFilters(ctx)
+		require.NoError(t, err)
+		require.Len(t, filters, 1)
+		require.Equal(t, second.ID, filters[0].ID)
+	})
+}
+
+func TestFilters_InvalidSieve(t *testing.T) {
+	withTestClient(t, func(ctx context.Context, c *proton.Client) {
+		const script = "require \"fileinto\";\nfileinto;"
+
+		// The script is rejected locally...
+		var sieveErr *sieve.Error
+
+		require.True(t, errors.As(proton.ValidateSieve(script), &sieveErr))
+		require.Equal(t, 2, sieveErr.Line)
+
+		// ... by the check endpoint ...
+		issues, err := c.CheckSieve(ctx, proton.CheckSieveReq{Version: proton.SieveVersion, Sieve: script})
+		require.NoError(t, err)
+		require.Equal(t, []proton.SieveIssue{{Line: sieveErr.Line, Column: sieveErr.Column, Message: sieveErr.Message}}, issues)
+
+		// ... and when creating a filter.
+		_, err = c.CreateFilter(ctx, proton.CreateFilterReq{Name: "invalid", Version: proton.SieveVersion, Sieve: script})
+
+		var apiErr *proton.APIError
+
+		require.True(t, errors.As(err, &apiErr))
+		require.Equal(t, proton.InvalidValue, apiErr.Code)
+
+		// Valid scripts have no issues.
+		require.NoError(t, proton.ValidateSieve(`require "fileinto"; fileinto "Archive";`))
+
+		issues, err = c.CheckSieve(ctx, proton.CheckSieveReq{Version: proton.SieveVersion, Sieve: `keep;`})
+		require.NoError(t, err)
+		require.Empty(t, issues)
+	})
+}
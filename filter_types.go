@@ -0,0 +1,55 @@
+package proton
+
+// SieveVersion is the version of the filter format in which the filter is a Sieve script.
+const SieveVersion = 2
+
+type FilterStatus int
+
+const (
+	FilterStatusDisabled FilterStatus = iota
+	FilterStatusEnabled
+)
+
+// Filter is a mail filter. Enabled filters run in order of priority on incoming messages.
+type Filter struct {
+	ID       string
+	Name     string
+	Status   FilterStatus
+	Priority int
+	Version  int
+
+	Sieve string
+}
+
+type CreateFilterReq struct {
+	Name    string
+	Status  FilterStatus
+	Version int
+
+	Sieve string
+}
+
+type UpdateFilterReq struct {
+	Name    string
+	Status  FilterStatus
+	Version int
+
+	Sieve string
+}
+
+type OrderFiltersReq struct {
+	FilterIDs []string
+}
+
+type CheckSieveReq struct {
+	Version int
+
+	Sieve string
+}
+
+// SieveIssue is a problem found in a Sieve script.
+type SieveIssue struct {
+	Line    int
+	Column  int
+	Message string
+}
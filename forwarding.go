@@ -0,0 +1,252 @@
+package proton
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/go-resty/resty/v2"
+)
+
+var (
+	// ErrForwardingKeyUnsupported is returned when the primary key of an address cannot be shared with an internal
+	// forwardee. Only v4 keys with a Curve25519 encryption subkey support forwarding.
+	ErrForwardingKeyUnsupported = errors.New("address key does not support forwarding")
+
+	// ErrForwardeeNotInternal is returned when creating an internal forwarding to an address outside of Proton.
+	ErrForwardeeNotInternal = errors.New("forwardee is not an internal address")
+)
+
+func (c *Client) GetOutgoingForwardings(ctx context.Context) ([]OutgoingForwarding, error) {
+	var res struct {
+		OutgoingAddressForwardings []OutgoingForwarding
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetResult(&res).Get("/mail/v4/forwardings/outgoing")
+	}); err != nil {
+		return nil, err
+	}
+
+	return res.OutgoingAddressForwardings, nil
+}
+
+func (c *Client) GetIncomingForwardings(ctx context.Context) ([]IncomingForwarding, error) {
+	var res struct {
+		IncomingAddressForwardings []IncomingForwarding
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetResult(&res).Get("/mail/v4/forwardings/incoming")
+	}); err != nil {
+		return nil, err
+	}
+
+	return res.IncomingAddressForwardings, nil
+}
+
+// CreateForwarding creates a forwarding from the address whose keyring is given.
+// For internal forwardings, a forwardee key is derived from the primary key of the address
+// and shared with the forwardee, who must accept the forwarding before it becomes active.
+func (c *Client) CreateForwarding(ctx context.Context, addrKR *crypto.KeyRing, req CreateForwardingReq) (OutgoingForwarding, error) {
+	var res struct {
+		OutgoingAddressForwarding OutgoingForwarding
+	}
+
+	if req.Type == ForwardingTypeInternal {
+		if err := c.shareForwardingKey(ctx, addrKR, &req); err != nil {
+			return OutgoingForwarding{}, err
+		}
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).SetResult(&res).Post("/mail/v4/forwardings")
+	}); err != nil {
+		return OutgoingForwarding{}, err
+	}
+
+	return res.OutgoingAddressForwarding, nil
+}
+
+func (c *Client) PauseForwarding(ctx context.Context, forwardingID string) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.Put("/mail/v4/forwardings/outgoing/" + forwardingID + "/pause")
+	})
+}
+
+func (c *Client) ResumeForwarding(ctx context.Context, forwardingID string) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.Put("/mail/v4/forwardings/outgoing/" + forwardingID + "/resume")
+	})
+}
+
+// AcceptForwarding accepts an internal forwarding to one of the user's addresses.
+// The forwarding keys are unlocked with the address keyring and re-locked for the user keyring.
+func (c *Client) AcceptForwarding(ctx context.Context, fwd IncomingForwarding, userKR, addrKR *crypto.KeyRing) error {
+	var req AcceptForwardingReq
+
+	for _, fwdKey := range fwd.ForwardingKeys {
+		key, err := acceptForwardingKey(fwdKey, userKR, addrKR)
+		if err != nil {
+			return err
+		}
+
+		req.ForwardingKeys = append(req.ForwardingKeys, key)
+	}
+
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).Put("/mail/v4/forwardings/incoming/" + fwd.ID + "/accept")
+	})
+}
+
+func (c *Client) RejectForwarding(ctx context.Context, forwardingID string) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.Put("/mail/v4/forwardings/incoming/" + forwardingID + "/reject")
+	})
+}
+
+// DeleteForwarding deletes a forwarding; both the forwarder and the forwardee can delete it.
+func (c *Client) DeleteForwarding(ctx context.Context, forwardingID string) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.Delete("/mail/v4/forwardings/" + forwardingID)
+	})
+}
+
+// shareForwardingKey derives a forwardee key from the primary key of the address and fills in the request with it,
+// locked with an activation token encrypted to the forwardee, and with the proxy instances the server needs.
+func (c *Client) shareForwardingKey(ctx context.Context, addrKR *crypto.KeyRing, req *CreateForwardingReq) error {
+	pubKeys, recType, err := c.GetPublicKeys(ctx, req.ForwardeeEmail)
+	if err != nil {
+		return fmt.Errorf("failed to get forwardee keys: %w", err)
+	} else if recType != RecipientTypeInternal {
+		return ErrForwardeeNotInternal
+	}
+
+	forwardeeKR, err := pubKeys.GetKeyRing()
+	if err != nil {
+		return fmt.Errorf("failed to get forwardee keyring: %w", err)
+	}
+
+	addrKey, err := addrKR.GetKey(0)
+	if err != nil {
+		return fmt.Errorf("failed to get primary key: %w", err)
+	}
+
+	entity, instances, err := addrKey.GetEntity().NewForwardingEntity("", "", req.ForwardeeEmail, &packet.Config{}, true)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForwardingKeyUnsupported, err)
+	}
+
+	fwdKey, err := crypto.NewKeyFromEntity(entity)
+	if err != nil {
+		return err
+	}
+
+	token, err := crypto.RandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	lockedKey, err := fwdKey.Lock(token)
+	if err != nil {
+		return fmt.Errorf("failed to lock forwardee key: %w", err)
+	}
+
+	armKey, err := lockedKey.Armor()
+	if err != nil {
+		return err
+	}
+
+	encToken, err := forwardeeKR.Encrypt(crypto.NewPlainMessage(token), nil)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt activation token: %w", err)
+	}
+
+	armToken, err := encToken.GetArmored()
+	if err != nil {
+		return err
+	}
+
+	req.ForwardeePrivateKey = armKey
+	req.ActivationToken = armToken
+	req.ProxyInstances = nil
+
+	for _, instance := range instances {
+		req.ProxyInstances = append(req.ProxyInstances, ForwardingProxyInstance{
+			PgpVersion:              instance.KeyVersion,
+			ForwarderKeyFingerprint: instance.ForwarderFingerprint,
+			ForwardeeKeyFingerprint: instance.ForwardeeFingerprint,
+			ProxyParam:              instance.ProxyParameter,
+		})
+	}
+
+	return nil
+}
+
+// acceptForwardingKey unlocks a forwarding key with its activation token and re-locks it with a new token
+// encrypted and signed with the user keyring.
+func acceptForwardingKey(fwdKey ForwardingKey, userKR, addrKR *crypto.KeyRing) (AcceptForwardingKey, error) {
+	encToken, err := crypto.NewPGPMessageFromArmored(fwdKey.ActivationToken)
+	if err != nil {
+		return AcceptForwardingKey{}, err
+	}
+
+	token, err := addrKR.Decrypt(encToken, nil, 0)
+	if err != nil {
+		return AcceptForwardingKey{}, fmt.Errorf("failed to decrypt activation token: %w", err)
+	}
+
+	lockedKey, err := crypto.NewKeyFromArmored(fwdKey.PrivateKey)
+	if err != nil {
+		return AcceptForwardingKey{}, err
+	}
+
+	key, err := lockedKey.Unlock(token.GetBinary())
+	if err != nil {
+		return AcceptForwardingKey{}, fmt.Errorf("failed to unlock forwarding key: %w", err)
+	}
+	defer key.ClearPrivateParams()
+
+	newToken, err := crypto.RandomToken(32)
+	if err != nil {
+		return AcceptForwardingKey{}, err
+	}
+
+	relockedKey, err := key.Lock(newToken)
+	if err != nil {
+		return AcceptForwardingKey{}, err
+	}
+
+	armKey, err := relockedKey.Armor()
+	if err != nil {
+		return AcceptForwardingKey{}, err
+	}
+
+	encNewToken, err := userKR.Encrypt(crypto.NewPlainMessage(newToken), nil)
+	if err != nil {
+		return AcceptForwardingKey{}, err
+	}
+
+	armNewToken, err := encNewToken.GetArmored()
+	if err != nil {
+		return AcceptForwardingKey{}, err
+	}
+
+	sig, err := userKR.SignDetached(crypto.NewPlainMessage(newToken))
+	if err != nil {
+		return AcceptForwardingKey{}, err
+	}
+
+	armSig, err := sig.GetArmored()
+	if err != nil {
+		return AcceptForwardingKey{}, err
+	}
+
+	return AcceptForwardingKey{
+		PrivateKey: armKey,
+		Token:      armNewToken,
+		Signature:  armSig,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package proton
+
+type ForwardingType int
+
+const (
+	// ForwardingTypeInternal forwards messages end-to-end encrypted to another Proton address.
+	ForwardingTypeInternal ForwardingType = iota
+
+	// ForwardingTypeExternal forwards messages to an address outside of Proton.
+	ForwardingTypeExternal
+)
+
+type ForwardingState int
+
+const (
+	ForwardingStatePending ForwardingState = iota
+	ForwardingStateActive
+	ForwardingStateOutdated
+	ForwardingStatePaused
+	ForwardingStateRejected
+)
+
+// ForwardingFilter restricts a forwarding to some messages: those discarded by its Sieve script are not forwarded.
+type ForwardingFilter struct {
+	Version int
+	Sieve   string
+}
+
+// OutgoingForwarding is a forwarding of the messages received by one of the user's addresses.
+type OutgoingForwarding struct {
+	ID                 string
+	ForwarderAddressID string
+	ForwardeeEmail     string
+	Type               ForwardingType
+	State              ForwardingState
+	Filter             *ForwardingFilter
+	CreateTime         int64
+}
+
+// IncomingForwarding is a forwarding of the messages received by someone else to one of the user's addresses.
+// Until it is accepted, its keys are locked with activation tokens encrypted to the forwardee address.
+type IncomingForwarding struct {
+	ID                 string
+	ForwarderEmail     string
+	ForwardeeAddressID string
+	Type               ForwardingType
+	State              ForwardingState
+	CreateTime         int64
+
+	ForwardingKeys []ForwardingKey
+}
+
+type ForwardingKey struct {
+	PrivateKey      string
+	ActivationToken string
+}
+
+// ForwardingProxyInstance holds the parameters the server uses to re-encrypt messages
+// from a key of the forwarder to the corresponding key of the forwardee.
+type ForwardingProxyInstance struct {
+	PgpVersion              int
+	ForwarderKeyFingerprint []byte
+	ForwardeeKeyFingerprint []byte
+	ProxyParam              []byte
+}
+
+// CreateForwardingReq creates a forwarding from one of the user's addresses.
+// The key material of internal forwardings is filled in by the client.
+type CreateForwardingReq struct {
+	ForwarderAddressID string
+	ForwardeeEmail     string
+	Type               ForwardingType
+	Filter             *ForwardingFilter `json:",omitempty"`
+
+	ForwardeePrivateKey string                    `json:",omitempty"`
+	ActivationToken     string                    `json:",omitempty"`
+	ProxyInstances      []ForwardingProxyInstance `json:",omitempty"`
+}
+
+type AcceptForwardingReq struct {
+	ForwardingKeys []AcceptForwardingKey
+}
+
+// AcceptForwardingKey is a forwarding key re-locked by the forwardee, like an address key,
+// with a token encrypted and signed with their user key.
+type AcceptForwardingKey struct {
+	PrivateKey string
+	Token      string
+	Signature  string
+}
@@ -3,6 +3,8 @@ package proton
 import (
 	"encoding/json"
 	"errors"
+	"strings"
+
 	"gitlab.com/c0b/go-ordered-json"
 )
 
@@ -13,6 +15,17 @@ type Headers struct {
 	Order  []string
 }
 
+// get returns the values of the header with the given key, compared case-insensitively.
+func (h Headers) get(key string) []string {
+	for _, k := range h.Order {
+		if strings.EqualFold(k, key) {
+			return h.Values[k]
+		}
+	}
+
+	return nil
+}
+
 func (h *Headers) UnmarshalJSON(b []byte) error {
 	type rawHeaders map[string]any
 
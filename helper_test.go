@@ -8,6 +8,8 @@ import (
 
 	"github.com/ProtonMail/gluon/async"
 	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/bradenaw/juniper/iterator"
 	"github.com/bradenaw/juniper/stream"
 	"github.com/google/uuid"
@@ -88,3 +90,47 @@ func importMessage(t *testing.T, c *proton.Client, ctx context.Context, pass str
 
 	return c.ImportMessages(ctx, addrKRs[addr[0].ID], runtime.NumCPU(), runtime.NumCPU(), req...)
 }
+
+func unlockTestKeyRings(t *testing.T, c *proton.Client, ctx context.Context, pass string) map[string]*crypto.KeyRing {
+	t.Helper()
+
+	user, err := c.GetUser(ctx)
+	require.NoError(t, err)
+
+	addr, err := c.GetAddresses(ctx)
+	require.NoError(t, err)
+
+	salt, err := c.GetSalts(ctx)
+	require.NoError(t, err)
+
+	keyPass, err := salt.SaltForKey([]byte(pass), user.Keys.Primary().ID)
+	require.NoError(t, err)
+
+	_, addrKRs, err := proton.Unlock(user, addr, keyPass, async.NoopPanicHandler{})
+	require.NoError(t, err)
+
+	return addrKRs
+}
+
+// withTestClient runs fn with a client logged in to a new user of a new dev server.
+func withTestClient(t *testing.T, fn func(ctx context.Context, c *proton.Client)) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	fn(ctx, c)
+}
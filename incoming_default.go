@@ -0,0 +1,74 @@
+package proton
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func (c *Client) GetIncomingDefaults(ctx context.Context, page, pageSize int) ([]IncomingDefault, error) {
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	_, incomingDefaults, err := c.getIncomingDefaultsImpl(ctx, page, pageSize)
+
+	return incomingDefaults, err
+}
+
+func (c *Client) GetAllIncomingDefaults(ctx context.Context) ([]IncomingDefault, error) {
+	total, firstBatch, err := c.getIncomingDefaultsImpl(ctx, 0, maxPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for page := 1; page*maxPageSize < total; page++ {
+		_, batch, err := c.getIncomingDefaultsImpl(ctx, page, maxPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		firstBatch = append(firstBatch, batch...)
+	}
+
+	return firstBatch, nil
+}
+
+func (c *Client) getIncomingDefaultsImpl(ctx context.Context, page, pageSize int) (int, []IncomingDefault, error) {
+	var res struct {
+		IncomingDefaults []IncomingDefault
+		Total            int
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetQueryParams(map[string]string{
+			"Page":     strconv.Itoa(page),
+			"PageSize": strconv.Itoa(pageSize),
+		}).SetResult(&res).Get("/mail/v4/incomingdefaults")
+	}); err != nil {
+		return 0, nil, err
+	}
+
+	return res.Total, res.IncomingDefaults, nil
+}
+
+func (c *Client) AddIncomingDefault(ctx context.Context, req AddIncomingDefaultReq) (IncomingDefault, error) {
+	var res struct {
+		IncomingDefault IncomingDefault
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetQueryParam("Overwrite", "1").SetBody(req).SetResult(&res).Post("/mail/v4/incomingdefaults")
+	}); err != nil {
+		return IncomingDefault{}, err
+	}
+
+	return res.IncomingDefault, nil
+}
+
+func (c *Client) DeleteIncomingDefaults(ctx context.Context, incomingDefaultIDs ...string) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(DeleteIncomingDefaultsReq{IDs: incomingDefaultIDs}).Put("/mail/v4/incomingdefaults/delete")
+	})
+}
@@ -0,0 +1,41 @@
+package proton_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncomingDefaults(t *testing.T) {
+	withTestClient(t, func(ctx context.Context, c *proton.Client) {
+		blocked, err := c.AddIncomingDefault(ctx, proton.AddIncomingDefaultReq{Location: proton.IncomingDefaultLocationSpam, Email: "Spammer@Example.com"})
+		require.NoError(t, err)
+		require.Equal(t, proton.IncomingDefaultTypeEmail, blocked.Type)
+		require.Equal(t, "spammer@example.com", blocked.Email)
+
+		allowed, err := c.AddIncomingDefault(ctx, proton.AddIncomingDefaultReq{Location: proton.IncomingDefaultLocationInbox, Domain: "example.org"})
+		require.NoError(t, err)
+		require.Equal(t, proton.IncomingDefaultTypeDomain, allowed.Type)
+
+		// Adding the same sender again overwrites its entry.
+		unblocked, err := c.AddIncomingDefault(ctx, proton.AddIncomingDefaultReq{Location: proton.IncomingDefaultLocationInbox, Email: "spammer@example.com"})
+		require.NoError(t, err)
+		require.Equal(t, blocked.ID, unblocked.ID)
+
+		incomingDefaults, err := c.GetAllIncomingDefaults(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []proton.IncomingDefault{unblocked, allowed}, incomingDefaults)
+
+		// An entry needs either an email address or a domain.
+		_, err = c.AddIncomingDefault(ctx, proton.AddIncomingDefaultReq{Location: proton.IncomingDefaultLocationSpam})
+		require.Error(t, err)
+
+		require.NoError(t, c.DeleteIncomingDefaults(ctx, unblocked.ID))
+
+		incomingDefaults, err = c.GetIncomingDefaults(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Equal(t, []proton.IncomingDefault{allowed}, incomingDefaults)
+	})
+}
@@ -0,0 +1,41 @@
+package proton
+
+// IncomingDefault is an entry of the sender allow and block lists: messages from its email address or domain
+// are delivered to its location. Entries for an email address take precedence over entries for its domain.
+type IncomingDefault struct {
+	ID       string
+	Location IncomingDefaultLocation
+	Type     IncomingDefaultType
+	Time     int64
+
+	Email  string `json:",omitempty"`
+	Domain string `json:",omitempty"`
+}
+
+// IncomingDefaultLocation is where messages from a sender of the allow or block lists are delivered.
+type IncomingDefaultLocation int
+
+const (
+	IncomingDefaultLocationInbox IncomingDefaultLocation = 0
+	IncomingDefaultLocationSpam  IncomingDefaultLocation = 4
+)
+
+type IncomingDefaultType int
+
+const (
+	IncomingDefaultTypeEmail IncomingDefaultType = iota + 1
+	IncomingDefaultTypeDomain
+)
+
+// AddIncomingDefaultReq adds a sender, given by either its email address or its domain, to the allow or block lists.
+// An existing entry for the same sender is overwritten.
+type AddIncomingDefaultReq struct {
+	Location IncomingDefaultLocation
+
+	Email  string `json:",omitempty"`
+	Domain string `json:",omitempty"`
+}
+
+type DeleteIncomingDefaultsReq struct {
+	IDs []string
+}
@@ -3,6 +3,7 @@ package proton
 import (
 	"context"
 
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/go-resty/resty/v2"
 )
 
@@ -21,6 +22,50 @@ func (c *Client) GetPublicKeys(ctx context.Context, address string) (PublicKeys,
 	return res.Keys, res.RecipientType, nil
 }
 
+// GetVerificationKeys returns a keyring of the keys that can be used to verify signatures from the given address.
+// It contains the address's trusted public keys and any keys pinned in the address's contact.
+// The pinned keys are read from the signed card of the contact, whose signature is checked with the user keyring kr,
+// so that keys the user didn't pin aren't trusted.
+func (c *Client) GetVerificationKeys(ctx context.Context, kr *crypto.KeyRing, address string) (*crypto.KeyRing, error) {
+	pubKeys, _, err := c.GetPublicKeys(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := c.getContactSettings(ctx, kr, address)
+	if err != nil {
+		return nil, err
+	}
+
+	verifierKR, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pubKey := range pubKeys {
+		if pubKey.Flags&KeyStateTrusted == 0 {
+			continue
+		}
+
+		key, err := crypto.NewKeyFromArmored(pubKey.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := verifierKR.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range settings.Keys {
+		if err := verifierKR.AddKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return verifierKR, nil
+}
+
 func (c *Client) CreateAddressKey(ctx context.Context, req CreateAddressKeyReq) (Key, error) {
 	var res struct {
 		Key Key
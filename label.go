@@ -8,7 +8,10 @@ import (
 	"github.com/go-resty/resty/v2"
 )
 
-var ErrNoSuchLabel = errors.New("no such label")
+var (
+	ErrNoSuchLabel = errors.New("no such label")
+	ErrNotAFolder  = errors.New("label is not a folder")
+)
 
 func (c *Client) GetLabel(ctx context.Context, labelID string, labelTypes ...LabelType) (Label, error) {
 	labels, err := c.GetLabels(ctx, labelTypes...)
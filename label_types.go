@@ -20,6 +20,18 @@ const (
 	AllScheduledLabel = "12"
 )
 
+// IsSystemFolder returns whether the system label is a folder. Every message is in exactly one folder,
+// either a system folder or a custom one, but can carry any number of labels.
+func IsSystemFolder(labelID string) bool {
+	switch labelID {
+	case InboxLabel, TrashLabel, SpamLabel, ArchiveLabel, SentLabel, DraftsLabel:
+		return true
+
+	default:
+		return false
+	}
+}
+
 type Label struct {
 	ID       string
 	ParentID string
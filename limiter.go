@@ -0,0 +1,229 @@
+package proton
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveBackoffDecrease is the factor by which the limit shrinks when the API asks to retry after a delay.
+	adaptiveBackoffDecrease = 0.5
+
+	// adaptiveLatencyDecrease is the factor by which the limit shrinks when latency degrades.
+	adaptiveLatencyDecrease = 0.75
+
+	// adaptiveLatencyTolerance is how many times slower than the baseline a job may be before latency is considered degraded.
+	adaptiveLatencyTolerance = 2
+)
+
+type adaptiveLimiterKey struct{}
+
+type adaptiveSlotKey struct{}
+
+// AdaptiveLimiter bounds the number of concurrent jobs, adapting the bound AIMD-style:
+// it grows by one for each round of jobs that complete without sign of overload, and shrinks
+// multiplicatively when the API answers with a Retry-After delay (429 or 503) or when latency degrades.
+// While a Retry-After delay is pending, no new job starts.
+// A limiter can be shared by several operations, so that they adapt to the load of the API together.
+type AdaptiveLimiter struct {
+	minLimit, maxLimit int
+
+	limit    float64
+	inFlight int
+
+	// baseline is the reference latency of jobs; it follows decreases immediately and increases slowly.
+	baseline time.Duration
+
+	// lastDecrease is when the limit last shrank. It shrinks at most once per round trip or Retry-After delay.
+	lastDecrease time.Time
+
+	// lastBackoff is when the API last asked to retry after a delay, which pauses new jobs until pausedUntil.
+	lastBackoff time.Time
+	pausedUntil time.Time
+
+	// changed is closed and replaced whenever a waiting job may be able to start.
+	changed chan struct{}
+	lock    sync.Mutex
+}
+
+// NewAdaptiveLimiter returns a limiter that allows between minLimit and maxLimit concurrent jobs.
+// It starts at minLimit and grows as jobs succeed.
+func NewAdaptiveLimiter(minLimit, maxLimit int) *AdaptiveLimiter {
+	minLimit = max(minLimit, 1)
+	maxLimit = max(maxLimit, minLimit)
+
+	return &AdaptiveLimiter{
+		minLimit: minLimit,
+		maxLimit: maxLimit,
+		limit:    float64(minLimit),
+		changed:  make(chan struct{}),
+	}
+}
+
+// WithAdaptiveLimiter returns a context with which bulk operations, such as ImportMessages, LabelMessages and
+// the attachment downloads of a ParallelScheduler, run their requests as concurrently as the given limiter allows.
+func WithAdaptiveLimiter(ctx context.Context, limiter *AdaptiveLimiter) context.Context {
+	return context.WithValue(ctx, adaptiveLimiterKey{}, limiter)
+}
+
+// Limit returns the number of jobs currently allowed to run concurrently.
+func (l *AdaptiveLimiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return int(l.limit)
+}
+
+// Do runs fn once the limiter allows another job to start, and adapts the limit to how the job went.
+// Requests made with the context passed to fn report their Retry-After delays to the limiter;
+// nested calls to Do with that context run immediately, as their job already holds a slot.
+func (l *AdaptiveLimiter) Do(ctx context.Context, fn func(context.Context) error) error {
+	if slot, ok := ctx.Value(adaptiveSlotKey{}).(*AdaptiveLimiter); ok && slot == l {
+		return fn(ctx)
+	}
+
+	if err := l.acquire(ctx); err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	err := fn(context.WithValue(WithAdaptiveLimiter(ctx, l), adaptiveSlotKey{}, l))
+
+	l.release(start, err)
+
+	return err
+}
+
+func (l *AdaptiveLimiter) acquire(ctx context.Context) error {
+	for {
+		l.lock.Lock()
+
+		changed := l.changed
+
+		if wait := time.Until(l.pausedUntil); wait > 0 {
+			l.lock.Unlock()
+
+			timer := time.NewTimer(wait)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+
+			case <-timer.C:
+
+			case <-changed:
+				timer.Stop()
+			}
+
+			continue
+		}
+
+		if l.inFlight < int(l.limit) {
+			l.inFlight++
+			l.lock.Unlock()
+
+			return nil
+		}
+
+		l.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-changed:
+		}
+	}
+}
+
+func (l *AdaptiveLimiter) release(start time.Time, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	defer l.notify()
+
+	l.inFlight--
+
+	// Failed jobs say nothing reliable about the load; overload is signalled through Retry-After delays.
+	// Jobs that overlapped a backoff include its delay in their latency, so they are not taken into account either.
+	if err != nil || start.Before(l.lastBackoff) {
+		return
+	}
+
+	latency := time.Since(start)
+
+	switch {
+	case l.baseline == 0 || latency < l.baseline:
+		l.baseline = latency
+
+	case latency > adaptiveLatencyTolerance*l.baseline:
+		l.baseline += (latency - l.baseline) / 64
+		l.decrease(adaptiveLatencyDecrease, latency)
+
+		return
+
+	default:
+		l.baseline += (latency - l.baseline) / 64
+	}
+
+	l.limit = min(l.limit+1/l.limit, float64(l.maxLimit))
+}
+
+// backoff shrinks the limit and pauses new jobs after the API asked to retry after the given delay.
+func (l *AdaptiveLimiter) backoff(after time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	defer l.notify()
+
+	l.decrease(adaptiveBackoffDecrease, after)
+
+	l.lastBackoff = time.Now()
+
+	if until := l.lastBackoff.Add(after); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// decrease shrinks the limit by the given factor, unless it already shrank within the given cooldown,
+// so that the many jobs affected by a single overload only shrink it once.
+func (l *AdaptiveLimiter) decrease(factor float64, cooldown time.Duration) {
+	if time.Since(l.lastDecrease) < cooldown {
+		return
+	}
+
+	l.limit = max(l.limit*factor, float64(l.minLimit))
+	l.lastDecrease = time.Now()
+}
+
+func (l *AdaptiveLimiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+func getAdaptiveLimiter(ctx context.Context) (*AdaptiveLimiter, bool) {
+	limiter, ok := ctx.Value(adaptiveLimiterKey{}).(*AdaptiveLimiter)
+	return limiter, ok
+}
+
+// getAdaptiveWorkers returns the number of workers to run a bulk operation with:
+// the maximum of the limiter attached to the context, if any, which then bounds concurrency itself.
+func getAdaptiveWorkers(ctx context.Context, workers int) int {
+	if limiter, ok := getAdaptiveLimiter(ctx); ok {
+		return limiter.maxLimit
+	}
+
+	return workers
+}
+
+// doAdaptive runs fn under the limiter attached to the context, if any.
+func doAdaptive(ctx context.Context, fn func(context.Context) error) error {
+	if limiter, ok := getAdaptiveLimiter(ctx); ok {
+		return limiter.Do(ctx, fn)
+	}
+
+	return fn(ctx)
+}
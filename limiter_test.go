@@ -0,0 +1,107 @@
+package proton_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gluon/async"
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveLimiter_Grows(t *testing.T) {
+	limiter := proton.NewAdaptiveLimiter(1, 4)
+	require.Equal(t, 1, limiter.Limit())
+
+	for range 50 {
+		require.NoError(t, limiter.Do(context.Background(), func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}))
+	}
+
+	require.Equal(t, 4, limiter.Limit())
+}
+
+func TestAdaptiveLimiter_NestedDo(t *testing.T) {
+	limiter := proton.NewAdaptiveLimiter(1, 1)
+
+	// The nested call runs in the slot of the outer one rather than waiting for a slot of its own.
+	require.NoError(t, limiter.Do(context.Background(), func(ctx context.Context) error {
+		return limiter.Do(ctx, func(ctx context.Context) error {
+			return nil
+		})
+	}))
+}
+
+func TestAdaptivePool_BoundsConcurrency(t *testing.T) {
+	limiter := proton.NewAdaptiveLimiter(1, 4)
+
+	var (
+		running, maxRunning int
+		lock                sync.Mutex
+	)
+
+	pool := proton.NewAdaptivePool(limiter, async.NoopPanicHandler{}, func(ctx context.Context, req int) (int, error) {
+		lock.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+
+		return 2 * req, nil
+	})
+	defer pool.Done()
+
+	reqs := make([]int, 100)
+
+	for idx := range reqs {
+		reqs[idx] = idx
+	}
+
+	res, err := pool.ProcessAll(context.Background(), reqs)
+	require.NoError(t, err)
+	require.Equal(t, 198, res[99])
+
+	require.LessOrEqual(t, maxRunning, 4)
+	require.Greater(t, maxRunning, 1)
+}
+
+func TestAdaptiveLimiter_BacksOffOnRetryAfter(t *testing.T) {
+	s := server.New(server.WithRateLimit(1, time.Second))
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	limiter := proton.NewAdaptiveLimiter(1, 8)
+
+	for range 50 {
+		require.NoError(t, limiter.Do(context.Background(), func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}))
+	}
+
+	require.Equal(t, 8, limiter.Limit())
+
+	// The second ping exceeds the rate limit, and its Retry-After delay shrinks the limit.
+	for range 2 {
+		require.NoError(t, limiter.Do(context.Background(), func(ctx context.Context) error {
+			return m.Ping(ctx)
+		}))
+	}
+
+	require.Less(t, limiter.Limit(), 8)
+}
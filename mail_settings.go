@@ -103,3 +103,18 @@ func (c *Client) SetDefaultPGPScheme(ctx context.Context, req SetDefaultPGPSchem
 
 	return res.MailSettings, nil
 }
+
+// SetAutoResponder configures the automatic reply to received messages.
+func (c *Client) SetAutoResponder(ctx context.Context, req SetAutoResponderReq) (MailSettings, error) {
+	var res struct {
+		MailSettings MailSettings
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).SetResult(&res).Put("/mail/v4/settings/autoresponder")
+	}); err != nil {
+		return MailSettings{}, err
+	}
+
+	return res.MailSettings, nil
+}
@@ -1,6 +1,11 @@
 package proton
 
-import "github.com/ProtonMail/gluon/rfc822"
+import (
+	"slices"
+	"time"
+
+	"github.com/ProtonMail/gluon/rfc822"
+)
 
 type MailSettings struct {
 	DisplayName     string
@@ -9,6 +14,7 @@ type MailSettings struct {
 	AttachPublicKey Bool
 	Sign            SignExternalMessages
 	PGPScheme       EncryptionScheme
+	AutoResponder   AutoResponder
 }
 
 type SignExternalMessages int
@@ -41,3 +47,91 @@ type SetSignExternalMessagesReq struct {
 type SetDefaultPGPSchemeReq struct {
 	PGPScheme EncryptionScheme
 }
+
+type SetAutoResponderReq struct {
+	AutoResponder AutoResponder
+}
+
+// AutoResponder is the automatic reply sent to the senders of messages received while it is active.
+//
+// The meaning of StartTime and EndTime depends on Repeat: they are Unix timestamps for AutoResponderRepeatFixed,
+// and otherwise offsets in seconds, in the time zone Zone, from the start of the day, the week (Sunday) or the month.
+// An offset window whose end is before its start spans the end of its period.
+// DaysSelected restricts daily auto-responders to the given weekdays; all days are selected if it is empty.
+type AutoResponder struct {
+	IsEnabled Bool
+	Repeat    AutoResponderRepeat
+
+	StartTime    int64
+	EndTime      int64
+	DaysSelected []time.Weekday
+	Zone         string
+
+	Subject string
+	Message string
+}
+
+type AutoResponderRepeat int
+
+const (
+	AutoResponderRepeatFixed AutoResponderRepeat = iota
+	AutoResponderRepeatDaily
+	AutoResponderRepeatWeekly
+	AutoResponderRepeatMonthly
+	AutoResponderRepeatPermanent
+)
+
+// IsActive returns whether the auto-responder replies to messages received at the given time.
+func (r AutoResponder) IsActive(now time.Time) bool {
+	if !r.IsEnabled {
+		return false
+	}
+
+	loc, err := time.LoadLocation(r.Zone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	now = now.In(loc)
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch r.Repeat {
+	case AutoResponderRepeatFixed:
+		return now.Unix() >= r.StartTime && (r.EndTime == 0 || now.Unix() < r.EndTime)
+
+	case AutoResponderRepeatDaily:
+		offset := int64(now.Sub(midnight) / time.Second)
+
+		isSelected := func(day time.Weekday) bool {
+			return len(r.DaysSelected) == 0 || slices.Contains(r.DaysSelected, day)
+		}
+
+		if r.StartTime <= r.EndTime {
+			return isSelected(now.Weekday()) && offset >= r.StartTime && offset < r.EndTime
+		}
+
+		// The window starts on a selected day and ends on the next one.
+		return (isSelected(now.Weekday()) && offset >= r.StartTime) || (isSelected((now.Weekday()+6)%7) && offset < r.EndTime)
+
+	case AutoResponderRepeatWeekly:
+		return isInAutoResponderWindow(int64(now.Sub(midnight.AddDate(0, 0, -int(now.Weekday())))/time.Second), r.StartTime, r.EndTime)
+
+	case AutoResponderRepeatMonthly:
+		return isInAutoResponderWindow(int64(now.Sub(midnight.AddDate(0, 0, 1-now.Day()))/time.Second), r.StartTime, r.EndTime)
+
+	case AutoResponderRepeatPermanent:
+		return true
+
+	default:
+		return false
+	}
+}
+
+func isInAutoResponderWindow(offset, start, end int64) bool {
+	if start <= end {
+		return offset >= start && offset < end
+	}
+
+	return offset >= start || offset < end
+}
@@ -0,0 +1,94 @@
+package proton_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoResponder_IsActive(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	require.NoError(t, err)
+
+	// Wednesday 3 January 2024, 10:00 in Zurich.
+	now := time.Date(2024, time.January, 3, 10, 0, 0, 0, zurich)
+
+	hour := int64(time.Hour / time.Second)
+	day := 24 * hour
+
+	testCases := []struct {
+		name      string
+		responder proton.AutoResponder
+		active    bool
+	}{
+		{
+			name:      "disabled",
+			responder: proton.AutoResponder{Repeat: proton.AutoResponderRepeatPermanent},
+			active:    false,
+		},
+		{
+			name:      "permanent",
+			responder: proton.AutoResponder{IsEnabled: true, Repeat: proton.AutoResponderRepeatPermanent},
+			active:    true,
+		},
+		{
+			name:      "fixed, within",
+			responder: proton.AutoResponder{IsEnabled: true, StartTime: now.Add(-time.Hour).Unix(), EndTime: now.Add(time.Hour).Unix()},
+			active:    true,
+		},
+		{
+			name:      "fixed, ended",
+			responder: proton.AutoResponder{IsEnabled: true, StartTime: now.Add(-2 * time.Hour).Unix(), EndTime: now.Add(-time.Hour).Unix()},
+			active:    false,
+		},
+		{
+			name:      "daily, within, in zone",
+			responder: proton.AutoResponder{IsEnabled: true, Repeat: proton.AutoResponderRepeatDaily, Zone: "Europe/Zurich", StartTime: 9 * hour, EndTime: 11 * hour},
+			active:    true,
+		},
+		{
+			name:      "daily, outside, in UTC",
+			responder: proton.AutoResponder{IsEnabled: true, Repeat: proton.AutoResponderRepeatDaily, Zone: "UTC", StartTime: 10 * hour, EndTime: 11 * hour},
+			active:    false,
+		},
+		{
+			name: "daily, other day selected",
+			responder: proton.AutoResponder{
+				IsEnabled: true, Repeat: proton.AutoResponderRepeatDaily, Zone: "Europe/Zurich",
+				StartTime: 9 * hour, EndTime: 11 * hour, DaysSelected: []time.Weekday{time.Monday},
+			},
+			active: false,
+		},
+		{
+			name: "daily, overnight from the previous day",
+			responder: proton.AutoResponder{
+				IsEnabled: true, Repeat: proton.AutoResponderRepeatDaily, Zone: "Europe/Zurich",
+				StartTime: 20 * hour, EndTime: 12 * hour, DaysSelected: []time.Weekday{time.Tuesday},
+			},
+			active: true,
+		},
+		{
+			name:      "weekly, within",
+			responder: proton.AutoResponder{IsEnabled: true, Repeat: proton.AutoResponderRepeatWeekly, Zone: "Europe/Zurich", StartTime: 3 * day, EndTime: 4 * day},
+			active:    true,
+		},
+		{
+			name:      "weekly, spanning the weekend",
+			responder: proton.AutoResponder{IsEnabled: true, Repeat: proton.AutoResponderRepeatWeekly, Zone: "Europe/Zurich", StartTime: 5 * day, EndTime: day},
+			active:    false,
+		},
+		{
+			name:      "monthly, within",
+			responder: proton.AutoResponder{IsEnabled: true, Repeat: proton.AutoResponderRepeatMonthly, Zone: "Europe/Zurich", StartTime: 0, EndTime: 7 * day},
+			active:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.active, tc.responder.IsActive(now))
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package proton
+
+import (
+	"context"
+	"sync"
+)
+
+// MailboxChangeHandler is called with the changes applied to the store of a mailbox cache.
+type MailboxChangeHandler func(MailboxChanges)
+
+// MailboxCache keeps a local copy of the mailbox of a client in a store, in sync with the API.
+// It is first filled with a full resync, then kept up to date by applying the changes of each event;
+// events that ask for a mail refresh trigger another full resync.
+type MailboxCache struct {
+	c     *Client
+	store MailboxStore
+
+	handlers     []MailboxChangeHandler
+	handlersLock sync.RWMutex
+
+	syncLock sync.Mutex
+}
+
+// NewMailboxCache returns a cache of the mailbox of the client, kept in the given store.
+// A store that was already synced is resumed from the last event it applied.
+func NewMailboxCache(c *Client, store MailboxStore) *MailboxCache {
+	return &MailboxCache{
+		c:     c,
+		store: store,
+	}
+}
+
+// Store returns the store of the cache, from which the cached mailbox can be read.
+func (mc *MailboxCache) Store() MailboxStore {
+	return mc.store
+}
+
+// AddChangeHandler adds a handler that is called, in the order handlers were added,
+// after each batch of changes is applied to the store.
+func (mc *MailboxCache) AddChangeHandler(handler MailboxChangeHandler) {
+	mc.handlersLock.Lock()
+	defer mc.handlersLock.Unlock()
+
+	mc.handlers = append(mc.handlers, handler)
+}
+
+// Sync brings the cache up to date by applying the events that happened since the last one it applied.
+// If the cache was never synced, it is fully resynced.
+func (mc *MailboxCache) Sync(ctx context.Context) error {
+	mc.syncLock.Lock()
+	defer mc.syncLock.Unlock()
+
+	eventID, err := mc.store.EventID()
+	if err != nil {
+		return err
+	}
+
+	if eventID == "" {
+		return mc.resync(ctx)
+	}
+
+	return mc.c.syncEvents(ctx, mc.store.EventID, func(event Event) error {
+		return mc.applyEvent(ctx, event)
+	})
+}
+
+// Resync replaces the content of the cache with the current messages, labels and addresses of the mailbox.
+func (mc *MailboxCache) Resync(ctx context.Context) error {
+	mc.syncLock.Lock()
+	defer mc.syncLock.Unlock()
+
+	return mc.resync(ctx)
+}
+
+// ApplyEvent updates the cache with the message, label and address changes of the event,
+// as received from the event stream. A mail refresh resyncs the whole cache.
+func (mc *MailboxCache) ApplyEvent(ctx context.Context, event Event) error {
+	mc.syncLock.Lock()
+	defer mc.syncLock.Unlock()
+
+	return mc.applyEvent(ctx, event)
+}
+
+// Run syncs the cache, then keeps it in sync with an event stream configured with cfg until the context is cancelled.
+// Failures to poll for events are retried by the stream; Run returns the error that ended the stream,
+// or the first error applying an event.
+func (mc *MailboxCache) Run(ctx context.Context, cfg EventStreamConfig) error {
+	if err := mc.Sync(ctx); err != nil {
+		return err
+	}
+
+	eventID, err := mc.store.EventID()
+	if err != nil {
+		return err
+	}
+
+	stream := mc.c.NewEventStreamer(ctx, cfg, eventID)
+	defer stream.Close()
+
+	for item := range stream.C {
+		if item.Type != EventStreamEvent && item.Type != EventStreamRefresh {
+			continue
+		}
+
+		if err := mc.ApplyEvent(ctx, item.Event); err != nil {
+			return err
+		}
+	}
+
+	return stream.Cause()
+}
+
+func (mc *MailboxCache) resync(ctx context.Context) error {
+	// Events that happen while the mailbox is fetched are applied by the next sync.
+	eventID, err := mc.c.GetLatestEventID(ctx)
+	if err != nil {
+		return err
+	}
+
+	labels, err := mc.c.GetLabels(ctx, LabelTypeSystem, LabelTypeFolder, LabelTypeLabel)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := mc.c.GetAddresses(ctx)
+	if err != nil {
+		return err
+	}
+
+	messages, err := mc.c.GetMessageMetadata(ctx, MessageFilter{})
+	if err != nil {
+		return err
+	}
+
+	return mc.apply(MailboxChanges{
+		Reset:     true,
+		EventID:   eventID,
+		Messages:  messages,
+		Labels:    labels,
+		Addresses: addrs,
+	})
+}
+
+func (mc *MailboxCache) applyEvent(ctx context.Context, event Event) error {
+	if event.Refresh&RefreshMail != 0 {
+		return mc.resync(ctx)
+	}
+
+	changes := MailboxChanges{EventID: event.EventID}
+
+	for _, msgEvent := range event.Messages {
+		if msgEvent.Action == EventDelete {
+			changes.DeletedMessages = append(changes.DeletedMessages, msgEvent.ID)
+		} else {
+			changes.Messages = append(changes.Messages, msgEvent.Message)
+		}
+	}
+
+	for _, labelEvent := range event.Labels {
+		if labelEvent.Action == EventDelete {
+			changes.DeletedLabels = append(changes.DeletedLabels, labelEvent.ID)
+		} else if labelEvent.Label.Type != LabelTypeContactGroup {
+			changes.Labels = append(changes.Labels, labelEvent.Label)
+		}
+	}
+
+	for _, addrEvent := range event.Addresses {
+		if addrEvent.Action == EventDelete {
+			changes.DeletedAddresses = append(changes.DeletedAddresses, addrEvent.ID)
+		} else {
+			changes.Addresses = append(changes.Addresses, addrEvent.Address)
+		}
+	}
+
+	return mc.apply(changes)
+}
+
+// apply applies the changes to the store, then notifies the handlers if anything changed.
+func (mc *MailboxCache) apply(changes MailboxChanges) error {
+	if err := mc.store.Apply(changes); err != nil {
+		return err
+	}
+
+	if changes.IsEmpty() {
+		return nil
+	}
+
+	mc.handlersLock.RLock()
+	defer mc.handlersLock.RUnlock()
+
+	for _, handler := range mc.handlers {
+		handler(changes)
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package proton_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMailboxCache(t *testing.T) {
+	tests := []struct {
+		name  string
+		store func(t *testing.T, dir string) proton.MailboxStore
+	}{
+		{
+			name: "memory",
+			store: func(t *testing.T, _ string) proton.MailboxStore {
+				return proton.NewMemoryMailboxStore()
+			},
+		},
+		{
+			name: "file",
+			store: func(t *testing.T, dir string) proton.MailboxStore {
+				store, err := proton.OpenFileMailboxStore(dir)
+				require.NoError(t, err)
+
+				return store
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := t.Context()
+
+			s := server.New()
+			defer s.Close()
+
+			m := proton.New(
+				proton.WithHostURL(s.GetHostURL()),
+				proton.WithTransport(proton.InsecureTransport()),
+			)
+			defer m.Close()
+
+			userID, _, err := s.CreateUser("user", []byte("pass"))
+			require.NoError(t, err)
+
+			c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+			require.NoError(t, err)
+			defer c.Close()
+
+			createTestMessages(t, c, "pass", 5)
+
+			dir := t.TempDir()
+
+			store := tt.store(t, dir)
+			defer func() { require.NoError(t, store.Close()) }()
+
+			cache := proton.NewMailboxCache(c, store)
+
+			var (
+				changes     []proton.MailboxChanges
+				changesLock sync.Mutex
+			)
+
+			cache.AddChangeHandler(func(change proton.MailboxChanges) {
+				changesLock.Lock()
+				defer changesLock.Unlock()
+
+				changes = append(changes, change)
+			})
+
+			lastChange := func() proton.MailboxChanges {
+				changesLock.Lock()
+				defer changesLock.Unlock()
+
+				return changes[len(changes)-1]
+			}
+
+			// The first sync fills the cache.
+			require.NoError(t, cache.Sync(ctx))
+			require.True(t, lastChange().Reset)
+			requireMailboxCached(ctx, t, c, store)
+
+			addrs, err := store.Addresses()
+			require.NoError(t, err)
+			require.Len(t, addrs, 1)
+
+			// Changes to messages and labels are applied from events.
+			messageIDs, err := c.GetAllMessageIDs(ctx, "")
+			require.NoError(t, err)
+
+			label, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "label", Color: "#f66", Type: proton.LabelTypeLabel})
+			require.NoError(t, err)
+			require.NoError(t, c.LabelMessages(ctx, messageIDs[:2], label.ID))
+			require.NoError(t, c.MarkMessagesRead(ctx, messageIDs[0]))
+			require.NoError(t, c.DeleteMessage(ctx, messageIDs[4]))
+
+			require.NoError(t, cache.Sync(ctx))
+			require.False(t, lastChange().Reset)
+			requireMailboxCached(ctx, t, c, store)
+
+			labeled, err := store.Messages(label.ID)
+			require.NoError(t, err)
+			require.Len(t, labeled, 2)
+
+			_, ok, err := store.Message(messageIDs[4])
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			// Deleted labels are removed from the messages.
+			require.NoError(t, c.DeleteLabel(ctx, label.ID))
+			require.NoError(t, cache.Sync(ctx))
+			requireMailboxCached(ctx, t, c, store)
+
+			labeled, err = store.Messages(label.ID)
+			require.NoError(t, err)
+			require.Empty(t, labeled)
+
+			// A mail refresh resyncs the whole cache.
+			require.NoError(t, s.RefreshUser(userID, proton.RefreshMail))
+			require.NoError(t, cache.Sync(ctx))
+			require.True(t, lastChange().Reset)
+			requireMailboxCached(ctx, t, c, store)
+
+			// The cache can be kept in sync by running it.
+			runCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			runErrCh := make(chan error)
+
+			go func() { runErrCh <- cache.Run(runCtx, proton.EventStreamConfig{Period: 100 * time.Millisecond}) }()
+
+			require.NoError(t, c.LabelMessages(ctx, messageIDs[:1], proton.StarredLabel))
+
+			require.Eventually(t, func() bool {
+				starred, err := store.Messages(proton.StarredLabel)
+				require.NoError(t, err)
+
+				return len(starred) == 1
+			}, 5*time.Second, 50*time.Millisecond)
+
+			cancel()
+			require.ErrorIs(t, <-runErrCh, context.Canceled)
+			requireMailboxCached(ctx, t, c, store)
+
+			if tt.name != "file" {
+				return
+			}
+
+			// The file store is persisted, and a reopened store resumes from the last event it applied.
+			eventID, err := store.EventID()
+			require.NoError(t, err)
+
+			wantMessages, err := store.Messages("")
+			require.NoError(t, err)
+
+			require.NoError(t, store.Close())
+
+			store = tt.store(t, dir)
+
+			reopenedEventID, err := store.EventID()
+			require.NoError(t, err)
+			require.Equal(t, eventID, reopenedEventID)
+
+			gotMessages, err := store.Messages("")
+			require.NoError(t, err)
+			require.Equal(t, wantMessages, gotMessages)
+
+			require.NoError(t, c.MarkMessagesRead(ctx, messageIDs[1]))
+
+			reopened := proton.NewMailboxCache(c, store)
+
+			require.NoError(t, reopened.Sync(ctx))
+			requireMailboxCached(ctx, t, c, store)
+		})
+	}
+}
+
+// requireMailboxCached checks that the store contains the current messages, labels and counts of the mailbox.
+func requireMailboxCached(ctx context.Context, t *testing.T, c *proton.Client, store proton.MailboxStore) {
+	t.Helper()
+
+	wantMessages, err := c.GetMessageMetadata(ctx, proton.MessageFilter{})
+	require.NoError(t, err)
+
+	slices.SortFunc(wantMessages, func(a, b proton.MessageMetadata) int { return strings.Compare(a.ID, b.ID) })
+
+	gotMessages, err := store.Messages("")
+	require.NoError(t, err)
+	require.Equal(t, wantMessages, gotMessages)
+
+	wantLabels, err := c.GetLabels(ctx, proton.LabelTypeSystem, proton.LabelTypeFolder, proton.LabelTypeLabel)
+	require.NoError(t, err)
+
+	slices.SortFunc(wantLabels, func(a, b proton.Label) int { return strings.Compare(a.ID, b.ID) })
+
+	gotLabels, err := store.Labels()
+	require.NoError(t, err)
+	require.Equal(t, wantLabels, gotLabels)
+
+	wantCounts, err := c.GetGroupedMessageCount(ctx)
+	require.NoError(t, err)
+
+	wantCounts = slices.DeleteFunc(wantCounts, func(count proton.MessageGroupCount) bool { return count.Total == 0 })
+
+	slices.SortFunc(wantCounts, func(a, b proton.MessageGroupCount) int { return strings.Compare(a.LabelID, b.LabelID) })
+
+	gotCounts, err := store.Counts()
+	require.NoError(t, err)
+	require.Equal(t, wantCounts, gotCounts)
+}
@@ -0,0 +1,261 @@
+package proton
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MailboxStore stores the local copy of a mailbox kept in sync by a MailboxCache:
+// the metadata of its messages, its labels and addresses, the message counts of its labels,
+// and the ID of the last event applied to it.
+type MailboxStore interface {
+	// Apply applies the changes atomically.
+	Apply(changes MailboxChanges) error
+
+	// EventID returns the ID of the last event applied to the store, or an empty string if it was never synced.
+	EventID() (string, error)
+
+	// Message returns the metadata of the message, and false if it is not in the store.
+	Message(messageID string) (MessageMetadata, bool, error)
+
+	// Messages returns the metadata of the messages with the given label, or of all messages if labelID is empty.
+	Messages(labelID string) ([]MessageMetadata, error)
+
+	// Labels returns the labels of the mailbox.
+	Labels() ([]Label, error)
+
+	// Addresses returns the addresses of the mailbox.
+	Addresses() ([]Address, error)
+
+	// Counts returns the number of messages and unread messages of each label that has messages.
+	Counts() ([]MessageGroupCount, error)
+
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// MailboxChanges is a batch of changes to a mailbox store.
+type MailboxChanges struct {
+	// Reset clears the store before the other changes are applied, as part of a full resync.
+	Reset bool
+
+	// EventID is the ID of the event the changes come from, if any.
+	EventID string `json:",omitempty"`
+
+	// Messages, Labels and Addresses are created or updated; the Deleted IDs are removed.
+	Messages         []MessageMetadata `json:",omitempty"`
+	DeletedMessages  []string          `json:",omitempty"`
+	Labels           []Label           `json:",omitempty"`
+	DeletedLabels    []string          `json:",omitempty"`
+	Addresses        []Address         `json:",omitempty"`
+	DeletedAddresses []string          `json:",omitempty"`
+}
+
+// IsEmpty returns whether the changes change nothing but the event ID.
+func (changes MailboxChanges) IsEmpty() bool {
+	return !changes.Reset &&
+		len(changes.Messages) == 0 && len(changes.DeletedMessages) == 0 &&
+		len(changes.Labels) == 0 && len(changes.DeletedLabels) == 0 &&
+		len(changes.Addresses) == 0 && len(changes.DeletedAddresses) == 0
+}
+
+// NewMemoryMailboxStore returns a mailbox store that keeps everything in memory.
+func NewMemoryMailboxStore() MailboxStore {
+	return &memoryMailboxStore{state: newMailboxState()}
+}
+
+type memoryMailboxStore struct {
+	state *mailboxState
+	lock  sync.RWMutex
+}
+
+func (store *memoryMailboxStore) Apply(changes MailboxChanges) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.state.apply(changes)
+
+	return nil
+}
+
+func (store *memoryMailboxStore) EventID() (string, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return store.state.EventID, nil
+}
+
+func (store *memoryMailboxStore) Message(messageID string) (MessageMetadata, bool, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	msg, ok := store.state.Messages[messageID]
+
+	return msg, ok, nil
+}
+
+func (store *memoryMailboxStore) Messages(labelID string) ([]MessageMetadata, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	var res []MessageMetadata
+
+	for _, msg := range store.state.Messages {
+		if labelID == "" || slices.Contains(msg.LabelIDs, labelID) {
+			res = append(res, msg)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b MessageMetadata) int { return strings.Compare(a.ID, b.ID) })
+
+	return res, nil
+}
+
+func (store *memoryMailboxStore) Labels() ([]Label, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	res := make([]Label, 0, len(store.state.Labels))
+
+	for _, label := range store.state.Labels {
+		res = append(res, label)
+	}
+
+	slices.SortFunc(res, func(a, b Label) int { return strings.Compare(a.ID, b.ID) })
+
+	return res, nil
+}
+
+func (store *memoryMailboxStore) Addresses() ([]Address, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	res := make([]Address, 0, len(store.state.Addresses))
+
+	for _, addr := range store.state.Addresses {
+		res = append(res, addr)
+	}
+
+	slices.SortFunc(res, func(a, b Address) int { return a.Order - b.Order })
+
+	return res, nil
+}
+
+func (store *memoryMailboxStore) Counts() ([]MessageGroupCount, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	res := make([]MessageGroupCount, 0, len(store.state.counts))
+
+	for _, count := range store.state.counts {
+		res = append(res, *count)
+	}
+
+	slices.SortFunc(res, func(a, b MessageGroupCount) int { return strings.Compare(a.LabelID, b.LabelID) })
+
+	return res, nil
+}
+
+func (store *memoryMailboxStore) Close() error {
+	return nil
+}
+
+// mailboxState is the content of a mailbox store.
+type mailboxState struct {
+	EventID   string
+	Messages  map[string]MessageMetadata
+	Labels    map[string]Label
+	Addresses map[string]Address
+
+	// counts is derived from the messages, so it is not persisted.
+	counts map[string]*MessageGroupCount
+}
+
+func newMailboxState() *mailboxState {
+	return &mailboxState{
+		Messages:  make(map[string]MessageMetadata),
+		Labels:    make(map[string]Label),
+		Addresses: make(map[string]Address),
+		counts:    make(map[string]*MessageGroupCount),
+	}
+}
+
+// apply applies the changes. Applying the same changes again leaves the state as it is.
+func (state *mailboxState) apply(changes MailboxChanges) {
+	if changes.Reset {
+		*state = *newMailboxState()
+	}
+
+	if changes.EventID != "" {
+		state.EventID = changes.EventID
+	}
+
+	for _, msg := range changes.Messages {
+		state.putMessage(msg)
+	}
+
+	for _, messageID := range changes.DeletedMessages {
+		state.deleteMessage(messageID)
+	}
+
+	for _, label := range changes.Labels {
+		state.Labels[label.ID] = label
+	}
+
+	for _, labelID := range changes.DeletedLabels {
+		delete(state.Labels, labelID)
+
+		// Messages lose the labels that are deleted.
+		for _, msg := range state.Messages {
+			if slices.Contains(msg.LabelIDs, labelID) {
+				msg.LabelIDs = slices.DeleteFunc(slices.Clone(msg.LabelIDs), func(id string) bool { return id == labelID })
+				state.putMessage(msg)
+			}
+		}
+	}
+
+	for _, addr := range changes.Addresses {
+		state.Addresses[addr.ID] = addr
+	}
+
+	for _, addrID := range changes.DeletedAddresses {
+		delete(state.Addresses, addrID)
+	}
+}
+
+func (state *mailboxState) putMessage(msg MessageMetadata) {
+	state.deleteMessage(msg.ID)
+
+	state.Messages[msg.ID] = msg
+
+	state.count(msg, 1)
+}
+
+func (state *mailboxState) deleteMessage(messageID string) {
+	if msg, ok := state.Messages[messageID]; ok {
+		state.count(msg, -1)
+	}
+
+	delete(state.Messages, messageID)
+}
+
+func (state *mailboxState) count(msg MessageMetadata, delta int) {
+	for _, labelID := range msg.LabelIDs {
+		count, ok := state.counts[labelID]
+		if !ok {
+			count = &MessageGroupCount{LabelID: labelID}
+			state.counts[labelID] = count
+		}
+
+		count.Total += delta
+
+		if msg.Unread {
+			count.Unread += delta
+		}
+
+		if count.Total == 0 {
+			delete(state.counts, labelID)
+		}
+	}
+}
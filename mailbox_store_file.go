@@ -0,0 +1,200 @@
+package proton
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	mailboxSnapshotName = "mailbox.json"
+	mailboxJournalName  = "mailbox.journal"
+
+	// mailboxJournalMaxEntries is the number of journal entries after which the journal is compacted into the snapshot.
+	mailboxJournalMaxEntries = 1000
+)
+
+// OpenFileMailboxStore opens the mailbox store persisted in the given directory, creating it if needed.
+//
+// The store is kept in memory and persisted as a snapshot and a journal of the changes applied since.
+// Changes are written to the journal, and synced to disk, before Apply returns;
+// the journal is regularly compacted into a new snapshot.
+func OpenFileMailboxStore(dir string) (MailboxStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create mailbox store directory: %w", err)
+	}
+
+	store := &fileMailboxStore{
+		memoryMailboxStore: memoryMailboxStore{state: newMailboxState()},
+		dir:                dir,
+	}
+
+	replayed, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	journal, err := os.OpenFile(filepath.Join(dir, mailboxJournalName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mailbox journal: %w", err)
+	}
+
+	store.journal = journal
+
+	// Start over from a fresh snapshot, which also drops any incomplete last entry of the journal.
+	if replayed {
+		if err := store.compact(); err != nil {
+			_ = journal.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+type fileMailboxStore struct {
+	memoryMailboxStore
+
+	dir     string
+	journal *os.File
+	entries int
+}
+
+func (store *fileMailboxStore) Apply(changes MailboxChanges) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if store.journal == nil {
+		return fs.ErrClosed
+	}
+
+	// A reset replaces everything, so the journal is compacted right away instead.
+	if changes.Reset {
+		store.state.apply(changes)
+		return store.compact()
+	}
+
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox changes: %w", err)
+	}
+
+	if _, err := store.journal.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write mailbox journal: %w", err)
+	}
+
+	if err := store.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync mailbox journal: %w", err)
+	}
+
+	store.state.apply(changes)
+
+	if store.entries++; store.entries >= mailboxJournalMaxEntries {
+		return store.compact()
+	}
+
+	return nil
+}
+
+func (store *fileMailboxStore) Close() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if store.journal == nil {
+		return nil
+	}
+
+	err := store.journal.Close()
+
+	store.journal = nil
+
+	return err
+}
+
+// load reads the snapshot and replays the journal over it, returning whether the journal had content.
+// Changes are idempotent, so the journal may safely contain changes that are already in the snapshot.
+func (store *fileMailboxStore) load() (bool, error) {
+	snapshot, err := os.ReadFile(filepath.Join(store.dir, mailboxSnapshotName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to read mailbox snapshot: %w", err)
+	}
+
+	if len(snapshot) > 0 {
+		if err := json.Unmarshal(snapshot, store.state); err != nil {
+			return false, fmt.Errorf("failed to decode mailbox snapshot: %w", err)
+		}
+
+		// The counts are not persisted: they are derived from the messages.
+		for _, msg := range store.state.Messages {
+			store.state.count(msg, 1)
+		}
+	}
+
+	journal, err := os.ReadFile(filepath.Join(store.dir, mailboxJournalName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to read mailbox journal: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(journal))
+	scanner.Buffer(nil, len(journal)+1)
+
+	for scanner.Scan() {
+		var changes MailboxChanges
+
+		// The last entry is incomplete if the process stopped while writing it; it was never applied.
+		if err := json.Unmarshal(scanner.Bytes(), &changes); err != nil {
+			break
+		}
+
+		store.state.apply(changes)
+	}
+
+	return len(journal) > 0, nil
+}
+
+// compact atomically replaces the snapshot with the current state, then truncates the journal.
+// The caller must hold the lock.
+func (store *fileMailboxStore) compact() error {
+	b, err := json.Marshal(store.state)
+	if err != nil {
+		return fmt.Errorf("failed to encode mailbox snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(store.dir, mailboxSnapshotName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create mailbox snapshot: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write mailbox snapshot: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync mailbox snapshot: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write mailbox snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(store.dir, mailboxSnapshotName)); err != nil {
+		return fmt.Errorf("failed to replace mailbox snapshot: %w", err)
+	}
+
+	if err := store.journal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate mailbox journal: %w", err)
+	}
+
+	store.entries = 0
+
+	return nil
+}
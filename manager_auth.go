@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"net/http"
 
 	"github.com/ProtonMail/go-srp"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
@@ -72,6 +74,67 @@ func (m *Manager) NewClientWithLoginWithHVToken(ctx context.Context, username st
 	return newClient(m, auth.UID).withAuth(auth.AccessToken, auth.RefreshToken), auth, nil
 }
 
+// ResumedSession is a session resumed by ResumeAll.
+type ResumedSession struct {
+	Session
+
+	Client *Client
+	Auth   Auth
+}
+
+// ResumeAll resumes the sessions of all the accounts of the store, in the order of their user IDs.
+// Each resumed session is stored with its new refresh token, and tracked with TrackSession.
+// Sessions the API revoked are removed from the store; sessions that fail to resume for other reasons,
+// e.g. network errors, are kept, and their errors are joined in the returned error.
+func (m *Manager) ResumeAll(ctx context.Context, store SessionStore) ([]ResumedSession, error) {
+	sessions, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		resumed []ResumedSession
+		errs    []error
+	)
+
+	for _, session := range sessions {
+		c, auth, err := m.NewClientWithRefresh(ctx, session.UID, session.RefreshToken)
+		if err != nil {
+			if respErr := new(resty.ResponseError); errors.As(err, &respErr) {
+				switch respErr.Response.StatusCode() {
+				case http.StatusBadRequest, http.StatusUnprocessableEntity:
+					log.WithError(err).WithField("userID", session.UserID).Info("Session was revoked, removing it")
+
+					if err := store.Delete(session.UserID); err != nil {
+						errs = append(errs, fmt.Errorf("failed to delete session of user %s: %w", session.UserID, err))
+					}
+
+					continue
+				}
+			}
+
+			errs = append(errs, fmt.Errorf("failed to resume session of user %s: %w", session.UserID, err))
+
+			continue
+		}
+
+		session.UID = auth.UID
+		session.RefreshToken = auth.RefreshToken
+
+		// The previous refresh token is no longer valid, so the new one must be stored before anything else.
+		if err := TrackSession(c, store, session); err != nil {
+			c.Close()
+			errs = append(errs, fmt.Errorf("failed to store session of user %s: %w", session.UserID, err))
+
+			continue
+		}
+
+		resumed = append(resumed, ResumedSession{Session: session, Client: c, Auth: auth})
+	}
+
+	return resumed, errors.Join(errs...)
+}
+
 func (m *Manager) AuthInfo(ctx context.Context, req AuthInfoReq) (AuthInfo, error) {
 	var res struct {
 		AuthInfo
@@ -66,6 +66,24 @@ type FIDO2Info struct {
 	RegisteredKeys        []RegisteredKey
 }
 
+// FIDO2AuthenticationOptions are the WebAuthn options of an assertion, as found in FIDO2Info.
+type FIDO2AuthenticationOptions struct {
+	PublicKey FIDO2PublicKeyOptions `json:"publicKey"`
+}
+
+type FIDO2PublicKeyOptions struct {
+	Timeout          int                         `json:"timeout,omitempty"`
+	Challenge        []int                       `json:"challenge"`
+	RPID             string                      `json:"rpId"`
+	AllowCredentials []FIDO2CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                      `json:"userVerification,omitempty"`
+}
+
+type FIDO2CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   []int  `json:"id"`
+}
+
 type TwoFAInfo struct {
 	Enabled TwoFAStatus
 	FIDO2   FIDO2Info
@@ -3,9 +3,11 @@ package proton
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"runtime"
 	"strconv"
+	"sync"
 
 	"github.com/ProtonMail/gluon/async"
 	"github.com/bradenaw/juniper/parallel"
@@ -15,6 +17,9 @@ import (
 
 const maxMessageIDs = 1000
 
+// errLabelActionFailed is returned internally when a chunk of a label action fails.
+var errLabelActionFailed = errors.New("label action failed")
+
 func (c *Client) GetFullMessage(ctx context.Context, messageID string, scheduler Scheduler, storageProvider AttachmentAllocator) (FullMessage, error) {
 	message, err := c.GetMessage(ctx, messageID)
 	if err != nil {
@@ -179,70 +184,89 @@ func (c *Client) MarkMessagesUnread(ctx context.Context, messageIDs ...string) e
 }
 
 func (c *Client) LabelMessages(ctx context.Context, messageIDs []string, labelID string) error {
-	var results []LabelMessagesRes
+	return c.doLabelAction(ctx, messageIDs, labelID, "label")
+}
 
-	for _, chunk := range xslices.Chunk(messageIDs, maxPageSize) {
-		var res LabelMessagesRes
+func (c *Client) UnlabelMessages(ctx context.Context, messageIDs []string, labelID string) error {
+	return c.doLabelAction(ctx, messageIDs, labelID, "unlabel")
+}
 
-		if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
-			return r.SetBody(LabelMessagesReq{
-				LabelID: labelID,
-				IDs:     chunk,
-			}).SetResult(&res).Put("/mail/v4/messages/label")
-		}); err != nil {
+// MoveMessages moves the messages to the given system or custom folder.
+// As a message is in exactly one folder, it leaves its previous folder; its labels are kept.
+func (c *Client) MoveMessages(ctx context.Context, messageIDs []string, folderID string) error {
+	if !IsSystemFolder(folderID) {
+		if _, err := c.GetLabel(ctx, folderID, LabelTypeFolder); errors.Is(err, ErrNoSuchLabel) {
+			return ErrNotAFolder
+		} else if err != nil {
 			return err
 		}
-
-		if ok, errStr := res.ok(); !ok {
-			tokens := xslices.Map(results, func(res LabelMessagesRes) UndoToken {
-				return res.UndoToken
-			})
-
-			if _, undoErr := c.UndoActions(ctx, tokens...); undoErr != nil {
-				return fmt.Errorf("failed to undo label actions (undo reason: %v): %w", errStr, undoErr)
-			}
-
-			return fmt.Errorf("failed to label messages: %v", errStr)
-		}
-
-		results = append(results, res)
 	}
 
-	return nil
+	return c.LabelMessages(ctx, messageIDs, folderID)
 }
 
-func (c *Client) UnlabelMessages(ctx context.Context, messageIDs []string, labelID string) error {
-	var results []LabelMessagesRes
+// EmptyLabel permanently deletes the messages with the given label or in the given folder.
+// If addressID is not empty, only the messages of that address are deleted.
+func (c *Client) EmptyLabel(ctx context.Context, labelID, addressID string) error {
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		if addressID != "" {
+			r = r.SetQueryParam("AddressID", addressID)
+		}
 
-	for _, chunk := range xslices.Chunk(messageIDs, maxPageSize) {
-		var res LabelMessagesRes
+		return r.SetQueryParam("LabelID", labelID).Delete("/mail/v4/messages/empty")
+	})
+}
 
-		if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
-			return r.SetBody(LabelMessagesReq{
-				LabelID: labelID,
-				IDs:     chunk,
-			}).SetResult(&res).Put("/mail/v4/messages/unlabel")
+// doLabelAction applies the label action to the messages in chunks. If a chunk fails, the chunks already applied are undone.
+// Chunks are sent one at a time, or as concurrently as the AdaptiveLimiter of the context allows, if any.
+func (c *Client) doLabelAction(ctx context.Context, messageIDs []string, labelID, action string) error {
+	chunks := xslices.Chunk(messageIDs, maxPageSize)
+
+	var (
+		results = make([]LabelMessagesRes, len(chunks))
+		errStr  string
+		lock    sync.Mutex
+	)
+
+	if err := parallel.DoContext(ctx, getAdaptiveWorkers(ctx, 1), len(chunks), func(ctx context.Context, idx int) error {
+		if err := doAdaptive(ctx, func(ctx context.Context) error {
+			return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+				return r.SetBody(LabelMessagesReq{
+					LabelID: labelID,
+					IDs:     chunks[idx],
+				}).SetResult(&results[idx]).Put("/mail/v4/messages/" + action)
+			})
 		}); err != nil {
 			return err
 		}
 
-		if ok, errStr := res.ok(); !ok {
-			tokens := xslices.Map(results, func(res LabelMessagesRes) UndoToken {
-				return res.UndoToken
-			})
+		if ok, str := results[idx].ok(); !ok {
+			lock.Lock()
+			defer lock.Unlock()
 
-			if _, undoErr := c.UndoActions(ctx, tokens...); undoErr != nil {
-				return fmt.Errorf("failed to undo unlabel actions (undo reason: %v): %w", errStr, undoErr)
-			}
+			errStr = str
+
+			return errLabelActionFailed
+		}
 
-			return fmt.Errorf("failed to unlabel messages: %v", errStr)
+		return nil
+	}); !errors.Is(err, errLabelActionFailed) {
+		return err
+	}
 
+	var tokens []UndoToken
+
+	for _, res := range results {
+		if ok, _ := res.ok(); ok && res.UndoToken.Token != "" {
+			tokens = append(tokens, res.UndoToken)
 		}
+	}
 
-		results = append(results, res)
+	if _, undoErr := c.UndoActions(ctx, tokens...); undoErr != nil {
+		return fmt.Errorf("failed to undo %v actions (undo reason: %v): %w", action, errStr, undoErr)
 	}
 
-	return nil
+	return fmt.Errorf("failed to %v messages: %v", action, errStr)
 }
 
 func (c *Client) GetMessageIDs(ctx context.Context, afterID string, limit int) ([]string, error) {
@@ -18,9 +18,33 @@ import (
 	"github.com/google/uuid"
 )
 
-func BuildRFC822(kr *crypto.KeyRing, msg Message, attData map[string][]byte) ([]byte, error) {
+// SignatureStatusHeader is the header in which BuildRFC822 reports the result of the body signature verification.
+const SignatureStatusHeader = "X-Pm-Signature-Status"
+
+// BuildOption configures how BuildRFC822 builds a message.
+type BuildOption func(*buildConfig)
+
+type buildConfig struct {
+	verifierKR *crypto.KeyRing
+}
+
+// WithSignatureVerification verifies the message body with the given verifier keyring
+// and reports the result in the SignatureStatusHeader header.
+func WithSignatureVerification(verifierKR *crypto.KeyRing) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.verifierKR = verifierKR
+	}
+}
+
+func BuildRFC822(kr *crypto.KeyRing, msg Message, attData map[string][]byte, opts ...BuildOption) ([]byte, error) {
+	var cfg buildConfig
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	if msg.MIMEType == rfc822.MultipartMixed {
-		return buildPGPRFC822(kr, msg)
+		return buildPGPRFC822(kr, msg, cfg)
 	}
 
 	header, err := getMixedMessageHeader(msg)
@@ -28,6 +52,24 @@ func BuildRFC822(kr *crypto.KeyRing, msg Message, attData map[string][]byte) ([]
 		return nil, err
 	}
 
+	dec, err := cfg.decrypt(kr, msg, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	attDec := make(map[string][]byte, len(msg.Attachments))
+
+	for _, att := range msg.Attachments {
+		if attDec[att.ID], err = decryptAttachment(kr, att, attData[att.ID]); err != nil {
+			return nil, err
+		}
+	}
+
+	return buildMixedRFC822(header, msg, dec, attDec)
+}
+
+// buildMixedRFC822 builds a multipart/mixed message from the decrypted body and attachments.
+func buildMixedRFC822(header message.Header, msg Message, dec []byte, attDec map[string][]byte) ([]byte, error) {
 	buf := new(bytes.Buffer)
 
 	w, err := message.CreateWriter(buf, header)
@@ -45,23 +87,23 @@ func BuildRFC822(kr *crypto.KeyRing, msg Message, attData map[string][]byte) ([]
 	for _, att := range msg.Attachments {
 		if att.Disposition == InlineDisposition {
 			inlineAtts = append(inlineAtts, att)
-			inlineData = append(inlineData, attData[att.ID])
+			inlineData = append(inlineData, attDec[att.ID])
 		} else {
 			attachAtts = append(attachAtts, att)
-			attachData = append(attachData, attData[att.ID])
+			attachData = append(attachData, attDec[att.ID])
 		}
 	}
 
 	if len(inlineAtts) > 0 {
-		if err := writeRelatedParts(w, kr, msg, inlineAtts, inlineData); err != nil {
+		if err := writeRelatedParts(w, msg, dec, inlineAtts, inlineData); err != nil {
 			return nil, err
 		}
-	} else if err := writeTextPart(w, kr, msg); err != nil {
+	} else if err := writeTextPart(w, msg, dec); err != nil {
 		return nil, err
 	}
 
 	for i, att := range attachAtts {
-		if err := writeAttachmentPart(w, kr, att, attachData[i]); err != nil {
+		if err := writeAttachmentPart(w, att, attachData[i]); err != nil {
 			return nil, err
 		}
 	}
@@ -73,12 +115,24 @@ func BuildRFC822(kr *crypto.KeyRing, msg Message, attData map[string][]byte) ([]
 	return buf.Bytes(), nil
 }
 
-func writeTextPart(w *message.Writer, kr *crypto.KeyRing, msg Message) error {
-	dec, err := msg.Decrypt(kr)
+// decrypt decrypts the message body. If signature verification is enabled,
+// the verification result is added to the given header.
+func (cfg buildConfig) decrypt(kr *crypto.KeyRing, msg Message, header *message.Header) ([]byte, error) {
+	if cfg.verifierKR == nil {
+		return msg.Decrypt(kr)
+	}
+
+	dec, status, err := msg.DecryptAndVerify(kr, cfg.verifierKR)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	header.Set(SignatureStatusHeader, status.String())
+
+	return dec, nil
+}
+
+func writeTextPart(w *message.Writer, msg Message, dec []byte) error {
 	part, err := w.CreatePart(getTextPartHeader(dec, msg.MIMEType))
 	if err != nil {
 		return err
@@ -91,32 +145,36 @@ func writeTextPart(w *message.Writer, kr *crypto.KeyRing, msg Message) error {
 	return part.Close()
 }
 
-func writeAttachmentPart(w *message.Writer, kr *crypto.KeyRing, att Attachment, attData []byte) error {
+func decryptAttachment(kr *crypto.KeyRing, att Attachment, attData []byte) ([]byte, error) {
 	kps, err := base64.StdEncoding.DecodeString(att.KeyPackets)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	msg := crypto.NewPGPSplitMessage(kps, attData).GetPGPMessage()
 
 	dec, err := kr.Decrypt(msg, nil, crypto.GetUnixTime())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	return dec.GetBinary(), nil
+}
+
+func writeAttachmentPart(w *message.Writer, att Attachment, dec []byte) error {
 	part, err := w.CreatePart(getAttachmentPartHeader(att))
 	if err != nil {
 		return err
 	}
 
-	if _, err := part.Write(dec.GetBinary()); err != nil {
+	if _, err := part.Write(dec); err != nil {
 		return err
 	}
 
 	return part.Close()
 }
 
-func writeRelatedParts(w *message.Writer, kr *crypto.KeyRing, msg Message, atts []Attachment, attData [][]byte) error {
+func writeRelatedParts(w *message.Writer, msg Message, dec []byte, atts []Attachment, attData [][]byte) error {
 	var header message.Header
 
 	header.SetContentType(string(rfc822.MultipartRelated), nil)
@@ -126,12 +184,12 @@ func writeRelatedParts(w *message.Writer, kr *crypto.KeyRing, msg Message, atts
 		return err
 	}
 
-	if err := writeTextPart(rel, kr, msg); err != nil {
+	if err := writeTextPart(rel, msg, dec); err != nil {
 		return err
 	}
 
 	for i, att := range atts {
-		if err := writeAttachmentPart(rel, kr, att, attData[i]); err != nil {
+		if err := writeAttachmentPart(rel, att, attData[i]); err != nil {
 			return err
 		}
 	}
@@ -139,13 +197,15 @@ func writeRelatedParts(w *message.Writer, kr *crypto.KeyRing, msg Message, atts
 	return rel.Close()
 }
 
-func buildPGPRFC822(kr *crypto.KeyRing, msg Message) ([]byte, error) {
+func buildPGPRFC822(kr *crypto.KeyRing, msg Message, cfg buildConfig) ([]byte, error) {
 	raw, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader(msg.Header)))
 	if err != nil {
 		return nil, err
 	}
 
-	dec, err := msg.Decrypt(kr)
+	header := message.Header{Header: raw}
+
+	dec, err := cfg.decrypt(kr, msg, &header)
 	if err != nil {
 		return nil, err
 	}
@@ -156,10 +216,10 @@ func buildPGPRFC822(kr *crypto.KeyRing, msg Message) ([]byte, error) {
 	}
 
 	if len(sigs) > 0 {
-		return buildMultipartSignedRFC822(message.Header{Header: raw}, dec, sigs[0])
+		return buildMultipartSignedRFC822(header, dec, sigs[0])
 	}
 
-	return buildMultipartEncryptedRFC822(message.Header{Header: raw}, dec)
+	return buildMultipartEncryptedRFC822(header, dec)
 }
 
 func buildMultipartSignedRFC822(header message.Header, body []byte, sig Signature) ([]byte, error) {
@@ -238,6 +298,11 @@ func buildMultipartEncryptedRFC822(header message.Header, body []byte) ([]byte,
 	}
 
 	parsedHeader.Entries(func(key, val string) {
+		// The signature status comes from our own verification; the body can't report its own.
+		if strings.EqualFold(key, SignatureStatusHeader) {
+			return
+		}
+
 		header.Set(key, val)
 	})
 
@@ -0,0 +1,257 @@
+package proton
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/bradenaw/juniper/xslices"
+)
+
+// NewReplyDraftReq returns the request creating a draft that replies to the parent message from the sender address.
+// The body is the decrypted body of the parent, which is quoted in the draft.
+// Replies go to the parent's Reply-To addresses, or its sender; replies to all also go to its other recipients.
+// The addresses of the user, given in addrs, are never included in the recipients.
+func NewReplyDraftReq(parent Message, body []byte, sender *mail.Address, replyAll bool, addrs []Address) CreateDraftReq {
+	action := ReplyAction
+	if replyAll {
+		action = ReplyAllAction
+	}
+
+	toList, ccList := replyRecipients(parent, replyAll, addrs)
+
+	inReplyTo, references := threadingHeaders(parent)
+
+	return CreateDraftReq{
+		Message: DraftTemplate{
+			Subject:    prefixSubject(parent.Subject, "Re:", "Re:"),
+			Sender:     sender,
+			ToList:     toList,
+			CCList:     ccList,
+			Body:       quoteBody(parent, body),
+			MIMEType:   parent.MIMEType,
+			InReplyTo:  inReplyTo,
+			References: references,
+		},
+		ParentID: parent.ID,
+		Action:   action,
+	}
+}
+
+// NewForwardDraftReq returns the request creating a draft that forwards the parent message from the sender address.
+// The body is the decrypted body of the parent, which is included in the draft below the forwarded headers.
+// The attachments of the parent are carried over: their key packets are decrypted with parentKR,
+// the keyring of the address that received the parent, and encrypted with addrKR, the keyring of the sender address.
+func NewForwardDraftReq(parent Message, body []byte, sender *mail.Address, parentKR, addrKR *crypto.KeyRing) (CreateDraftReq, error) {
+	attKeyPackets := make([]string, 0, len(parent.Attachments))
+
+	for _, att := range parent.Attachments {
+		keyPackets, err := base64.StdEncoding.DecodeString(att.KeyPackets)
+		if err != nil {
+			return CreateDraftReq{}, fmt.Errorf("failed to decode key packets of attachment %s: %w", att.ID, err)
+		}
+
+		sessionKey, err := parentKR.DecryptSessionKey(keyPackets)
+		if err != nil {
+			return CreateDraftReq{}, fmt.Errorf("failed to decrypt session key of attachment %s: %w", att.ID, err)
+		}
+
+		encKeyPackets, err := addrKR.EncryptSessionKey(sessionKey)
+		if err != nil {
+			return CreateDraftReq{}, fmt.Errorf("failed to encrypt session key of attachment %s: %w", att.ID, err)
+		}
+
+		attKeyPackets = append(attKeyPackets, base64.StdEncoding.EncodeToString(encKeyPackets))
+	}
+
+	_, references := threadingHeaders(parent)
+
+	return CreateDraftReq{
+		Message: DraftTemplate{
+			Subject:    prefixSubject(parent.Subject, "Fwd:", "Fwd:", "Fw:"),
+			Sender:     sender,
+			Body:       forwardBody(parent, body),
+			MIMEType:   parent.MIMEType,
+			References: references,
+		},
+		AttachmentKeyPackets: attKeyPackets,
+		ParentID:             parent.ID,
+		Action:               ForwardAction,
+	}, nil
+}
+
+// replyRecipients returns the recipients of a reply to the parent message, without the addresses of the user.
+// Replies to messages the user sent go to the original recipients instead of back to the user.
+func replyRecipients(parent Message, replyAll bool, addrs []Address) ([]*mail.Address, []*mail.Address) {
+	var toList, ccList []*mail.Address
+
+	// Messages without a Reply-To header have a single empty one.
+	replyTos := xslices.Filter(parent.ReplyTos, func(addr *mail.Address) bool { return addr.Address != "" })
+
+	switch {
+	case parent.Flags.Has(MessageFlagSent):
+		toList = parent.ToList
+
+	case len(replyTos) > 0:
+		toList = replyTos
+
+	case parent.Sender != nil:
+		toList = []*mail.Address{parent.Sender}
+	}
+
+	if replyAll {
+		if !parent.Flags.Has(MessageFlagSent) {
+			toList = append(toList[:len(toList):len(toList)], parent.ToList...)
+		}
+
+		ccList = parent.CCList
+	}
+
+	seen := make(map[string]struct{}, len(addrs))
+
+	for _, addr := range addrs {
+		seen[strings.ToLower(addr.Email)] = struct{}{}
+	}
+
+	filter := func(list []*mail.Address) []*mail.Address {
+		var res []*mail.Address
+
+		for _, addr := range list {
+			if addr.Address == "" {
+				continue
+			}
+
+			if _, ok := seen[strings.ToLower(addr.Address)]; ok {
+				continue
+			}
+
+			seen[strings.ToLower(addr.Address)] = struct{}{}
+
+			res = append(res, addr)
+		}
+
+		return res
+	}
+
+	return filter(toList), filter(ccList)
+}
+
+// threadingHeaders returns the In-Reply-To and References headers of a reply to or forward of the parent message.
+func threadingHeaders(parent Message) (string, string) {
+	if parent.ExternalID == "" {
+		return "", ""
+	}
+
+	inReplyTo := "<" + parent.ExternalID + ">"
+
+	var references []string
+
+	for _, value := range parent.ParsedHeaders.get("References") {
+		references = append(references, strings.Fields(value)...)
+	}
+
+	return inReplyTo, strings.Join(append(references, inReplyTo), " ")
+}
+
+// prefixSubject prefixes the subject, unless it already starts with one of the given prefixes.
+func prefixSubject(subject, prefix string, existing ...string) string {
+	for _, existing := range existing {
+		if len(subject) >= len(existing) && strings.EqualFold(subject[:len(existing)], existing) {
+			return subject
+		}
+	}
+
+	return prefix + " " + subject
+}
+
+func quoteBody(parent Message, body []byte) string {
+	intro := fmt.Sprintf("On %v, %v wrote:", time.Unix(parent.Time, 0).Format("Mon, 2 Jan 2006 at 15:04"), formatAddress(parent.Sender))
+
+	if parent.MIMEType == rfc822.TextHTML {
+		return fmt.Sprintf(
+			`<div><br></div><div class="protonmail_quote">%v<br><blockquote class="protonmail_quote" type="cite">%s</blockquote></div>`,
+			html.EscapeString(intro),
+			body,
+		)
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n")
+
+	for i, line := range lines {
+		if line == "" || strings.HasPrefix(line, ">") {
+			lines[i] = ">" + line
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+
+	return "\n\n" + intro + "\n" + strings.Join(lines, "\n")
+}
+
+func forwardBody(parent Message, body []byte) string {
+	fields := [][2]string{
+		{"From", formatAddress(parent.Sender)},
+		{"Date", time.Unix(parent.Time, 0).Format(time.RFC1123Z)},
+		{"Subject", parent.Subject},
+		{"To", formatAddressList(parent.ToList)},
+	}
+
+	if len(parent.CCList) > 0 {
+		fields = append(fields, [2]string{"CC", formatAddressList(parent.CCList)})
+	}
+
+	if parent.MIMEType == rfc822.TextHTML {
+		var header strings.Builder
+
+		for _, field := range fields {
+			fmt.Fprintf(&header, "%v: %v<br>", field[0], html.EscapeString(field[1]))
+		}
+
+		return fmt.Sprintf(
+			`<div><br></div><div class="protonmail_quote">------- Forwarded Message -------<br>%v<br><blockquote class="protonmail_quote" type="cite">%s</blockquote></div>`,
+			header.String(),
+			body,
+		)
+	}
+
+	var res strings.Builder
+
+	res.WriteString("\n\n------- Forwarded Message -------\n")
+
+	for _, field := range fields {
+		fmt.Fprintf(&res, "%v: %v\n", field[0], field[1])
+	}
+
+	res.WriteString("\n")
+	res.Write(body)
+
+	return res.String()
+}
+
+func formatAddress(addr *mail.Address) string {
+	switch {
+	case addr == nil:
+		return ""
+
+	case addr.Name == "":
+		return "<" + addr.Address + ">"
+
+	default:
+		return addr.Name + " <" + addr.Address + ">"
+	}
+}
+
+func formatAddressList(addrs []*mail.Address) string {
+	res := make([]string, len(addrs))
+
+	for i, addr := range addrs {
+		res[i] = formatAddress(addr)
+	}
+
+	return strings.Join(res, ", ")
+}
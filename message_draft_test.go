@@ -0,0 +1,144 @@
+package proton
+
+import (
+	"net/mail"
+	"testing"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReplyDraftReq_Recipients(t *testing.T) {
+	var (
+		user  = &mail.Address{Name: "User", Address: "user@proton.local"}
+		alias = &mail.Address{Address: "alias@proton.local"}
+		alice = &mail.Address{Name: "Alice", Address: "alice@example.com"}
+		bob   = &mail.Address{Address: "bob@example.com"}
+		carol = &mail.Address{Address: "carol@example.com"}
+		list  = &mail.Address{Address: "list@example.com"}
+	)
+
+	addrs := []Address{{Email: user.Address}, {Email: alias.Address}}
+
+	tests := []struct {
+		name     string
+		parent   MessageMetadata
+		replyAll bool
+		wantTo   []*mail.Address
+		wantCC   []*mail.Address
+	}{
+		{
+			name:   "reply to sender",
+			parent: MessageMetadata{Sender: alice, ToList: []*mail.Address{user, bob}, CCList: []*mail.Address{carol}, ReplyTos: []*mail.Address{{}}},
+			wantTo: []*mail.Address{alice},
+		},
+		{
+			name:   "reply to reply-to",
+			parent: MessageMetadata{Sender: alice, ToList: []*mail.Address{user}, ReplyTos: []*mail.Address{list}},
+			wantTo: []*mail.Address{list},
+		},
+		{
+			name:     "reply all without own addresses",
+			parent:   MessageMetadata{Sender: alice, ToList: []*mail.Address{user, bob}, CCList: []*mail.Address{alias, carol}},
+			replyAll: true,
+			wantTo:   []*mail.Address{alice, bob},
+			wantCC:   []*mail.Address{carol},
+		},
+		{
+			name:     "reply all without duplicates",
+			parent:   MessageMetadata{Sender: alice, ToList: []*mail.Address{{Address: "ALICE@example.com"}, bob}, CCList: []*mail.Address{bob, carol}},
+			replyAll: true,
+			wantTo:   []*mail.Address{alice, bob},
+			wantCC:   []*mail.Address{carol},
+		},
+		{
+			name:     "reply all to sent message",
+			parent:   MessageMetadata{Sender: user, ToList: []*mail.Address{alice, bob}, CCList: []*mail.Address{carol}, Flags: MessageFlagSent},
+			replyAll: true,
+			wantTo:   []*mail.Address{alice, bob},
+			wantCC:   []*mail.Address{carol},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := NewReplyDraftReq(Message{MessageMetadata: tt.parent, MIMEType: rfc822.TextPlain}, nil, user, tt.replyAll, addrs)
+
+			require.Equal(t, tt.wantTo, req.Message.ToList)
+			require.Equal(t, tt.wantCC, req.Message.CCList)
+
+			if tt.replyAll {
+				require.Equal(t, ReplyAllAction, req.Action)
+			} else {
+				require.Equal(t, ReplyAction, req.Action)
+			}
+		})
+	}
+}
+
+func TestNewReplyDraftReq_Content(t *testing.T) {
+	parent := Message{
+		MessageMetadata: MessageMetadata{
+			ID:         "parentID",
+			ExternalID: "parent@example.com",
+			Subject:    "Hello",
+			Sender:     &mail.Address{Name: "Alice", Address: "alice@example.com"},
+		},
+		ParsedHeaders: Headers{
+			Values: map[string][]string{"References": {"<root@example.com> <previous@example.com>"}},
+			Order:  []string{"References"},
+		},
+		MIMEType: rfc822.TextPlain,
+	}
+
+	req := NewReplyDraftReq(parent, []byte("Hi,\n\n> earlier\nBye"), nil, false, nil)
+
+	require.Equal(t, "parentID", req.ParentID)
+	require.Equal(t, "Re: Hello", req.Message.Subject)
+	require.Equal(t, rfc822.TextPlain, req.Message.MIMEType)
+	require.Equal(t, "<parent@example.com>", req.Message.InReplyTo)
+	require.Equal(t, "<root@example.com> <previous@example.com> <parent@example.com>", req.Message.References)
+	require.Contains(t, req.Message.Body, "Alice <alice@example.com> wrote:\n> Hi,\n>\n>> earlier\n> Bye")
+
+	// Subjects are not prefixed twice.
+	parent.Subject = "RE: Hello"
+	require.Equal(t, "RE: Hello", NewReplyDraftReq(parent, nil, nil, false, nil).Message.Subject)
+
+	// HTML bodies are quoted in a blockquote.
+	parent.MIMEType = rfc822.TextHTML
+	req = NewReplyDraftReq(parent, []byte("<p>Hi</p>"), nil, false, nil)
+	require.Equal(t, rfc822.TextHTML, req.Message.MIMEType)
+	require.Contains(t, req.Message.Body, `Alice &lt;alice@example.com&gt; wrote:<br><blockquote class="protonmail_quote" type="cite"><p>Hi</p></blockquote>`)
+}
+
+func TestNewForwardDraftReq_Content(t *testing.T) {
+	parent := Message{
+		MessageMetadata: MessageMetadata{
+			ID:         "parentID",
+			ExternalID: "parent@example.com",
+			Subject:    "Hello",
+			Sender:     &mail.Address{Name: "Alice", Address: "alice@example.com"},
+			ToList:     []*mail.Address{{Address: "user@proton.local"}},
+		},
+		MIMEType: rfc822.TextPlain,
+	}
+
+	req, err := NewForwardDraftReq(parent, []byte("Hi"), nil, nil, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, ForwardAction, req.Action)
+	require.Equal(t, "Fwd: Hello", req.Message.Subject)
+	require.Empty(t, req.Message.ToList)
+	require.Empty(t, req.Message.InReplyTo)
+	require.Equal(t, "<parent@example.com>", req.Message.References)
+	require.Contains(t, req.Message.Body, "From: Alice <alice@example.com>\n")
+	require.Contains(t, req.Message.Body, "Subject: Hello\nTo: <user@proton.local>\n\nHi")
+	require.Empty(t, req.AttachmentKeyPackets)
+
+	// Subjects are not prefixed twice.
+	parent.Subject = "Fw: Hello"
+
+	req, err = NewForwardDraftReq(parent, []byte("Hi"), nil, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Fw: Hello", req.Message.Subject)
+}
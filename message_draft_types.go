@@ -17,6 +17,11 @@ type DraftTemplate struct {
 	Unread   Bool
 
 	ExternalID string `json:",omitempty"`
+
+	// InReplyTo and References are the threading headers of replies and forwards.
+	// If they are empty and the draft has a parent, the API derives them from the parent.
+	InReplyTo  string `json:",omitempty"`
+	References string `json:",omitempty"`
 }
 
 type CreateDraftAction int
@@ -30,7 +35,10 @@ const (
 )
 
 type CreateDraftReq struct {
-	Message              DraftTemplate
+	Message DraftTemplate
+
+	// AttachmentKeyPackets holds the base64-encoded key packets of the parent's attachments, in their order,
+	// encrypted to the draft's address key. Forward drafts that set them carry the parent's attachments over.
 	AttachmentKeyPackets []string
 
 	ParentID string `json:",omitempty"`
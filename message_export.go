@@ -0,0 +1,158 @@
+package proton
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+var (
+	// ErrUnknownExportFormat is returned when the requested export format is not supported.
+	ErrUnknownExportFormat = errors.New("unknown export format")
+
+	// ErrExportCheckpointMismatch is returned when resuming an export from a checkpoint written for another format.
+	ErrExportCheckpointMismatch = errors.New("checkpoint was written by an export in another format")
+)
+
+// ExportMessages writes all messages of the user to disk in the requested format.
+// Each message is decrypted with the keyring of its address, as returned by Unlock.
+// Progress is recorded in the checkpoint file, if any, after each message, so that an interrupted export can be resumed.
+// It returns the number of messages exported by this call.
+func (c *Client) ExportMessages(ctx context.Context, addrKRs map[string]*crypto.KeyRing, req ExportReq) (int, error) {
+	if req.Scheduler == nil {
+		req.Scheduler = NewSequentialScheduler()
+	}
+
+	if req.Allocator == nil {
+		req.Allocator = NewDefaultAttachmentAllocator()
+	}
+
+	checkpoint, err := loadExportCheckpoint(req.CheckpointPath, req.Format)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	labels, err := c.GetLabels(ctx, LabelTypeSystem, LabelTypeFolder, LabelTypeLabel)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get labels: %w", err)
+	}
+
+	w, err := newExportWriter(req, labels, checkpoint)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = w.close() }()
+
+	messageIDs, err := c.GetAllMessageIDs(ctx, checkpoint.LastMessageID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get message IDs: %w", err)
+	}
+
+	for idx, messageID := range messageIDs {
+		meta, literal, err := c.getExportLiteral(ctx, addrKRs, messageID, req)
+		if err != nil {
+			return idx, fmt.Errorf("failed to get message %q: %w", messageID, err)
+		}
+
+		if err := w.writeMessage(meta, literal); err != nil {
+			return idx, fmt.Errorf("failed to write message %q: %w", messageID, err)
+		}
+
+		checkpoint.LastMessageID = messageID
+		checkpoint.Offset = w.offset()
+
+		if err := saveExportCheckpoint(req.CheckpointPath, checkpoint); err != nil {
+			return idx + 1, fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+	}
+
+	if err := w.finish(); err != nil {
+		return len(messageIDs), fmt.Errorf("failed to finish export: %w", err)
+	}
+
+	if req.CheckpointPath != "" {
+		if err := os.Remove(req.CheckpointPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return len(messageIDs), fmt.Errorf("failed to remove checkpoint: %w", err)
+		}
+	}
+
+	return len(messageIDs), nil
+}
+
+func (c *Client) getExportLiteral(
+	ctx context.Context,
+	addrKRs map[string]*crypto.KeyRing,
+	messageID string,
+	req ExportReq,
+) (MessageMetadata, []byte, error) {
+	full, err := c.GetFullMessage(ctx, messageID, req.Scheduler, req.Allocator)
+	if err != nil {
+		return MessageMetadata{}, nil, err
+	}
+
+	kr, ok := addrKRs[full.AddressID]
+	if !ok {
+		return MessageMetadata{}, nil, fmt.Errorf("no keyring for address %q", full.AddressID)
+	}
+
+	attData := make(map[string][]byte, len(full.Attachments))
+
+	for idx, att := range full.Attachments {
+		attData[att.ID] = full.AttData[idx]
+	}
+
+	literal, err := BuildRFC822(kr, full.Message, attData)
+	if err != nil {
+		return MessageMetadata{}, nil, err
+	}
+
+	return full.MessageMetadata, literal, nil
+}
+
+func loadExportCheckpoint(path string, format ExportFormat) (exportCheckpoint, error) {
+	if path == "" {
+		return exportCheckpoint{Format: format}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return exportCheckpoint{Format: format}, nil
+	} else if err != nil {
+		return exportCheckpoint{}, err
+	}
+
+	var checkpoint exportCheckpoint
+
+	if err := json.Unmarshal(b, &checkpoint); err != nil {
+		return exportCheckpoint{}, err
+	}
+
+	if checkpoint.Format != format {
+		return exportCheckpoint{}, fmt.Errorf("%w: %s", ErrExportCheckpointMismatch, checkpoint.Format)
+	}
+
+	return checkpoint, nil
+}
+
+// saveExportCheckpoint atomically replaces the checkpoint file, so that an interruption never leaves it half-written.
+func saveExportCheckpoint(path string, checkpoint exportCheckpoint) error {
+	if path == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path+".tmp", b, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,430 @@
+package proton
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/bradenaw/juniper/xslices"
+)
+
+// exportWriter writes exported messages in a given format.
+type exportWriter interface {
+	// writeMessage writes the message with the given metadata and decrypted literal.
+	writeMessage(meta MessageMetadata, literal []byte) error
+
+	// offset returns the position to record in the checkpoint after the last written message.
+	offset() int64
+
+	// finish completes the export once all messages have been written.
+	finish() error
+
+	// close releases the resources of the writer, whether or not the export completed.
+	close() error
+}
+
+func newExportWriter(req ExportReq, labels []Label, checkpoint exportCheckpoint) (exportWriter, error) {
+	switch req.Format {
+	case ExportFormatMbox:
+		return newMboxWriter(req.Path, checkpoint.Offset)
+
+	case ExportFormatMaildir:
+		return newMaildirWriter(req.Path, labels, req.MaildirLabels)
+
+	case ExportFormatEMLZip:
+		return newEMLZipWriter(req.Path, labels)
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExportFormat, req.Format)
+	}
+}
+
+// mboxWriter writes messages to an mboxrd file.
+type mboxWriter struct {
+	file *os.File
+	size int64
+}
+
+func newMboxWriter(path string, offset int64) (*mboxWriter, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	// Discard anything written after the last checkpointed message.
+	if err := file.Truncate(offset); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &mboxWriter{file: file, size: offset}, nil
+}
+
+func (w *mboxWriter) writeMessage(meta MessageMetadata, literal []byte) error {
+	buf := new(bytes.Buffer)
+
+	sender := "MAILER-DAEMON"
+
+	if meta.Sender != nil && meta.Sender.Address != "" {
+		sender = meta.Sender.Address
+	}
+
+	fmt.Fprintf(buf, "From %s %s\n", sender, time.Unix(meta.Time, 0).UTC().Format(time.ANSIC))
+
+	for _, line := range bytes.SplitAfter(bytes.ReplaceAll(literal, []byte("\r\n"), []byte("\n")), []byte("\n")) {
+		// Quote lines that could be mistaken for a message separator; mboxrd also quotes already quoted ones.
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			buf.WriteByte('>')
+		}
+
+		buf.Write(line)
+	}
+
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteByte('\n')
+
+	n, err := w.file.Write(buf.Bytes())
+
+	w.size += int64(n)
+
+	if err != nil {
+		return err
+	}
+
+	return w.file.Sync()
+}
+
+func (w *mboxWriter) offset() int64 {
+	return w.size
+}
+
+func (w *mboxWriter) finish() error {
+	return nil
+}
+
+func (w *mboxWriter) close() error {
+	return w.file.Close()
+}
+
+// maildirSystemFolders are the system labels exported as Maildir folders.
+var maildirSystemFolders = []string{DraftsLabel, SentLabel, TrashLabel, SpamLabel, ArchiveLabel, OutboxLabel}
+
+// maildirWriter writes messages to a Maildir++ tree.
+// The inbox is the root Maildir; other system folders, user folders and labels are subfolders.
+type maildirWriter struct {
+	root   string
+	labels map[string]Label
+	mode   MaildirLabels
+}
+
+func newMaildirWriter(root string, labels []Label, mode MaildirLabels) (*maildirWriter, error) {
+	if err := createMaildir(root); err != nil {
+		return nil, err
+	}
+
+	return &maildirWriter{
+		root:   root,
+		labels: getLabelsByID(labels),
+		mode:   mode,
+	}, nil
+}
+
+func (w *maildirWriter) writeMessage(meta MessageMetadata, literal []byte) error {
+	dirs := []string{w.getFolderDir(meta)}
+
+	var keywords []string
+
+	for _, labelID := range meta.LabelIDs {
+		label, ok := w.labels[labelID]
+		if !ok || label.Type != LabelTypeLabel {
+			continue
+		}
+
+		switch w.mode {
+		case MaildirLabelsAsFolders:
+			dirs = append(dirs, getMaildirDir(append([]string{"Labels"}, label.Path...)))
+
+		case MaildirLabelsAsKeywords:
+			keywords = append(keywords, strings.Join(label.Path, "/"))
+		}
+	}
+
+	if len(keywords) > 0 {
+		var err error
+
+		if literal, err = rfc822.SetHeaderValue(literal, "X-Keywords", strings.Join(keywords, ", ")); err != nil {
+			return err
+		}
+	}
+
+	name := fmt.Sprintf("%d.%s.proton", meta.Time, getSafeFileName(meta.ID))
+
+	for _, dir := range dirs {
+		if err := writeMaildirMessage(filepath.Join(w.root, dir), name, getMaildirFlags(meta), literal); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *maildirWriter) offset() int64 {
+	return 0
+}
+
+func (w *maildirWriter) finish() error {
+	return nil
+}
+
+func (w *maildirWriter) close() error {
+	return nil
+}
+
+// getFolderDir returns the Maildir of the folder the message is in, relative to the root.
+func (w *maildirWriter) getFolderDir(meta MessageMetadata) string {
+	for _, labelID := range meta.LabelIDs {
+		label, ok := w.labels[labelID]
+		if !ok {
+			continue
+		}
+
+		switch {
+		case labelID == InboxLabel:
+			return ""
+
+		case label.Type == LabelTypeFolder:
+			return getMaildirDir(append([]string{"Folders"}, label.Path...))
+
+		case slices.Contains(maildirSystemFolders, labelID):
+			return getMaildirDir(label.Path)
+		}
+	}
+
+	return getMaildirDir([]string{"All Mail"})
+}
+
+// getMaildirDir returns the name of the Maildir++ folder with the given path.
+func getMaildirDir(path []string) string {
+	return "." + strings.Join(xslices.Map(path, func(name string) string {
+		return strings.NewReplacer(".", "_", "/", "_").Replace(name)
+	}), ".")
+}
+
+// getMaildirFlags returns the flags of the message in the info part of its Maildir file name, in ASCII order.
+func getMaildirFlags(meta MessageMetadata) string {
+	var flags strings.Builder
+
+	if meta.IsDraft() {
+		flags.WriteByte('D')
+	}
+
+	if meta.Starred() {
+		flags.WriteByte('F')
+	}
+
+	if meta.IsForwarded || meta.Flags&MessageFlagForwarded != 0 {
+		flags.WriteByte('P')
+	}
+
+	if meta.IsReplied || meta.IsRepliedAll || meta.Flags&(MessageFlagReplied|MessageFlagRepliedAll) != 0 {
+		flags.WriteByte('R')
+	}
+
+	if meta.Seen() {
+		flags.WriteByte('S')
+	}
+
+	return flags.String()
+}
+
+// writeMaildirMessage delivers the message to the Maildir through its tmp directory, so that it never appears partially written.
+func writeMaildirMessage(dir, name, flags string, literal []byte) error {
+	if err := createMaildir(dir); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, "tmp", name)
+
+	if err := os.WriteFile(tmp, literal, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, "cur", name+":2,"+flags))
+}
+
+func createMaildir(dir string) error {
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// emlZipWriter writes messages to a zip of .eml files with a JSON manifest.
+// Since a zip cannot be appended to once interrupted, messages are staged in a directory next to the archive
+// and the archive is only built once all messages have been written.
+type emlZipWriter struct {
+	path   string
+	stage  string
+	labels []Label
+}
+
+func newEMLZipWriter(path string, labels []Label) (*emlZipWriter, error) {
+	stage := path + ".partial"
+
+	if err := os.MkdirAll(stage, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &emlZipWriter{
+		path:   path,
+		stage:  stage,
+		labels: labels,
+	}, nil
+}
+
+func (w *emlZipWriter) writeMessage(meta MessageMetadata, literal []byte) error {
+	name := getSafeFileName(meta.ID)
+
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(w.stage, name+".eml"), literal, 0o600); err != nil {
+		return err
+	}
+
+	// The metadata is written last, as its presence marks the message as staged.
+	return os.WriteFile(filepath.Join(w.stage, name+".json"), b, 0o600)
+}
+
+func (w *emlZipWriter) offset() int64 {
+	return 0
+}
+
+func (w *emlZipWriter) finish() error {
+	entries, err := os.ReadDir(w.stage)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(w.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	zw := zip.NewWriter(file)
+
+	manifest := ExportManifest{Labels: w.labels}
+
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+
+		msg, err := w.addMessage(zw, name)
+		if err != nil {
+			return err
+		}
+
+		manifest.Messages = append(manifest.Messages, msg)
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	mw, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+
+	if _, err := mw.Write(b); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(w.path+".tmp", w.path); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(w.stage)
+}
+
+func (w *emlZipWriter) addMessage(zw *zip.Writer, name string) (ExportManifestMessage, error) {
+	b, err := os.ReadFile(filepath.Join(w.stage, name+".json"))
+	if err != nil {
+		return ExportManifestMessage{}, err
+	}
+
+	msg := ExportManifestMessage{File: "messages/" + name + ".eml"}
+
+	if err := json.Unmarshal(b, &msg.MessageMetadata); err != nil {
+		return ExportManifestMessage{}, err
+	}
+
+	literal, err := os.Open(filepath.Join(w.stage, name+".eml"))
+	if err != nil {
+		return ExportManifestMessage{}, err
+	}
+	defer func() { _ = literal.Close() }()
+
+	fw, err := zw.Create(msg.File)
+	if err != nil {
+		return ExportManifestMessage{}, err
+	}
+
+	if _, err := io.Copy(fw, literal); err != nil {
+		return ExportManifestMessage{}, err
+	}
+
+	return msg, nil
+}
+
+func (w *emlZipWriter) close() error {
+	return nil
+}
+
+func getLabelsByID(labels []Label) map[string]Label {
+	res := make(map[string]Label, len(labels))
+
+	for _, label := range labels {
+		res[label.ID] = label
+	}
+
+	return res
+}
+
+// getSafeFileName returns a file name for the given ID, which may contain path separators.
+func getSafeFileName(id string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(id)
+}
@@ -0,0 +1,238 @@
+package proton_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server"
+	"github.com/bradenaw/juniper/stream"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportMessages_Mbox(t *testing.T) {
+	withExportClient(t, func(ctx context.Context, c *proton.Client, messageIDs []string, labelID string) {
+		dir := t.TempDir()
+
+		n, err := c.ExportMessages(ctx, unlockTestKeyRings(t, c, ctx, "pass"), proton.ExportReq{
+			Format:         proton.ExportFormatMbox,
+			Path:           filepath.Join(dir, "export.mbox"),
+			CheckpointPath: filepath.Join(dir, "checkpoint.json"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, len(messageIDs), n)
+
+		b, err := os.ReadFile(filepath.Join(dir, "export.mbox"))
+		require.NoError(t, err)
+		require.Equal(t, len(messageIDs), countMboxMessages(b))
+		require.Contains(t, string(b), "\n>From the body\n")
+
+		// The checkpoint is removed once the export completes.
+		require.NoFileExists(t, filepath.Join(dir, "checkpoint.json"))
+	})
+}
+
+func TestExportMessages_MboxResume(t *testing.T) {
+	withExportClient(t, func(ctx context.Context, c *proton.Client, messageIDs []string, labelID string) {
+		dir := t.TempDir()
+
+		// Simulate an export interrupted while writing the second message.
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "export.mbox"), []byte("firstPARTIAL"), 0o600))
+
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, "checkpoint.json"),
+			fmt.Appendf(nil, `{"Format":"mbox","LastMessageID":%q,"Offset":5}`, messageIDs[0]),
+			0o600,
+		))
+
+		n, err := c.ExportMessages(ctx, unlockTestKeyRings(t, c, ctx, "pass"), proton.ExportReq{
+			Format:         proton.ExportFormatMbox,
+			Path:           filepath.Join(dir, "export.mbox"),
+			CheckpointPath: filepath.Join(dir, "checkpoint.json"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, len(messageIDs)-1, n)
+
+		b, err := os.ReadFile(filepath.Join(dir, "export.mbox"))
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(string(b), "firstFrom "))
+		require.NotContains(t, string(b), "PARTIAL")
+	})
+}
+
+func TestExportMessages_CheckpointMismatch(t *testing.T) {
+	withExportClient(t, func(ctx context.Context, c *proton.Client, messageIDs []string, labelID string) {
+		dir := t.TempDir()
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "checkpoint.json"), []byte(`{"Format":"mbox"}`), 0o600))
+
+		_, err := c.ExportMessages(ctx, unlockTestKeyRings(t, c, ctx, "pass"), proton.ExportReq{
+			Format:         proton.ExportFormatMaildir,
+			Path:           filepath.Join(dir, "export"),
+			CheckpointPath: filepath.Join(dir, "checkpoint.json"),
+		})
+		require.ErrorIs(t, err, proton.ErrExportCheckpointMismatch)
+	})
+}
+
+func TestExportMessages_Maildir(t *testing.T) {
+	withExportClient(t, func(ctx context.Context, c *proton.Client, messageIDs []string, labelID string) {
+		t.Run("labels as folders", func(t *testing.T) {
+			dir := t.TempDir()
+
+			_, err := c.ExportMessages(ctx, unlockTestKeyRings(t, c, ctx, "pass"), proton.ExportReq{
+				Format: proton.ExportFormatMaildir,
+				Path:   dir,
+			})
+			require.NoError(t, err)
+
+			// The root Maildir is the inbox, which is empty since imported messages are only in all mail.
+			require.DirExists(t, filepath.Join(dir, "cur"))
+
+			allMail, err := os.ReadDir(filepath.Join(dir, ".All Mail", "cur"))
+			require.NoError(t, err)
+			require.Len(t, allMail, len(messageIDs))
+
+			for _, entry := range allMail {
+				// The messages are unread, so they have no flags.
+				require.True(t, strings.HasSuffix(entry.Name(), ":2,"))
+			}
+
+			labelled, err := os.ReadDir(filepath.Join(dir, ".Labels.my_label", "cur"))
+			require.NoError(t, err)
+			require.Len(t, labelled, 1)
+		})
+
+		t.Run("labels as keywords", func(t *testing.T) {
+			dir := t.TempDir()
+
+			_, err := c.ExportMessages(ctx, unlockTestKeyRings(t, c, ctx, "pass"), proton.ExportReq{
+				Format:        proton.ExportFormatMaildir,
+				Path:          dir,
+				MaildirLabels: proton.MaildirLabelsAsKeywords,
+			})
+			require.NoError(t, err)
+			require.NoDirExists(t, filepath.Join(dir, ".Labels.my_label"))
+
+			allMail, err := os.ReadDir(filepath.Join(dir, ".All Mail", "cur"))
+			require.NoError(t, err)
+
+			var keywords int
+
+			for _, entry := range allMail {
+				b, err := os.ReadFile(filepath.Join(dir, ".All Mail", "cur", entry.Name()))
+				require.NoError(t, err)
+
+				if strings.Contains(string(b), "X-Keywords: my.label\r\n") {
+					keywords++
+				}
+			}
+
+			require.Equal(t, 1, keywords)
+		})
+	})
+}
+
+func TestExportMessages_EMLZip(t *testing.T) {
+	withExportClient(t, func(ctx context.Context, c *proton.Client, messageIDs []string, labelID string) {
+		dir := t.TempDir()
+
+		_, err := c.ExportMessages(ctx, unlockTestKeyRings(t, c, ctx, "pass"), proton.ExportReq{
+			Format: proton.ExportFormatEMLZip,
+			Path:   filepath.Join(dir, "export.zip"),
+		})
+		require.NoError(t, err)
+		require.NoDirExists(t, filepath.Join(dir, "export.zip.partial"))
+
+		zr, err := zip.OpenReader(filepath.Join(dir, "export.zip"))
+		require.NoError(t, err)
+		defer func() { _ = zr.Close() }()
+
+		f, err := zr.Open("manifest.json")
+		require.NoError(t, err)
+
+		var manifest proton.ExportManifest
+
+		require.NoError(t, json.NewDecoder(f).Decode(&manifest))
+		require.Len(t, manifest.Messages, len(messageIDs))
+		require.True(t, containsLabel(manifest.Labels, labelID))
+
+		for _, msg := range manifest.Messages {
+			require.Contains(t, messageIDs, msg.ID)
+
+			literal, err := zr.Open(msg.File)
+			require.NoError(t, err)
+			require.NoError(t, literal.Close())
+		}
+	})
+}
+
+func withExportClient(t *testing.T, fn func(ctx context.Context, c *proton.Client, messageIDs []string, labelID string)) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	createTestMessages(t, c, "pass", 2)
+
+	// A message whose body contains a line that looks like an mbox separator.
+	res, err := importMessage(t, c, ctx, "pass", "From: sender@example.com\r\nSubject: from\r\n\r\nHello\r\nFrom the body\r\n")
+	require.NoError(t, err)
+
+	imported, err := stream.Collect(ctx, res)
+	require.NoError(t, err)
+	require.Equal(t, proton.SuccessCode, imported[0].Code)
+
+	messageIDs, err := c.GetAllMessageIDs(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, messageIDs, 3)
+
+	label, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "my.label", Type: proton.LabelTypeLabel})
+	require.NoError(t, err)
+
+	require.NoError(t, c.LabelMessages(ctx, messageIDs[:1], label.ID))
+
+	fn(ctx, c, messageIDs, label.ID)
+}
+
+func countMboxMessages(b []byte) int {
+	var n int
+
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("From ")) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func containsLabel(labels []proton.Label, labelID string) bool {
+	for _, label := range labels {
+		if label.ID == labelID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package proton
+
+// ExportFormat is the on-disk format of a mailbox export.
+type ExportFormat string
+
+const (
+	// ExportFormatMbox writes all messages to a single mboxrd file.
+	ExportFormatMbox ExportFormat = "mbox"
+
+	// ExportFormatMaildir writes messages to a Maildir++ tree, with one Maildir per folder.
+	ExportFormatMaildir ExportFormat = "maildir"
+
+	// ExportFormatEMLZip writes messages as .eml files to a zip archive, along with a JSON manifest.
+	ExportFormatEMLZip ExportFormat = "eml-zip"
+)
+
+// MaildirLabels controls how labels are represented in a Maildir export.
+type MaildirLabels int
+
+const (
+	// MaildirLabelsAsFolders stores a copy of each message in a Maildir per label, under "Labels".
+	MaildirLabelsAsFolders MaildirLabels = iota
+
+	// MaildirLabelsAsKeywords lists the labels of each message in its X-Keywords header.
+	MaildirLabelsAsKeywords
+)
+
+type ExportReq struct {
+	Format ExportFormat
+
+	// Path is the mbox file, Maildir root directory or zip file to write.
+	Path string
+
+	// CheckpointPath is the file in which the progress of the export is recorded.
+	// If it exists, the export resumes after the last message it records. It is removed once the export completes.
+	// If empty, the export cannot be resumed.
+	CheckpointPath string
+
+	// MaildirLabels controls how labels are represented in a Maildir export.
+	MaildirLabels MaildirLabels
+
+	// Scheduler and Allocator control how attachments are downloaded. They default to sequential downloads.
+	Scheduler Scheduler
+	Allocator AttachmentAllocator
+}
+
+// ExportManifest is the manifest.json file of an EML zip export.
+type ExportManifest struct {
+	Labels   []Label
+	Messages []ExportManifestMessage
+}
+
+type ExportManifestMessage struct {
+	MessageMetadata
+
+	// File is the path of the message in the archive.
+	File string
+}
+
+type exportCheckpoint struct {
+	Format        ExportFormat
+	LastMessageID string
+
+	// Offset is the size of the mbox file after the last exported message.
+	Offset int64
+}
@@ -31,6 +31,8 @@ var ErrImportSizeExceeded = errors.New("message exceeds maximum import size of 5
 
 type ImportResStream stream.Stream[ImportRes] // gomock does not support generics. In order to be able to mock ImportMessages, we introduce a typedef.
 
+// ImportMessages imports the messages in chunks, with up to workers chunks in flight at once.
+// If the context carries an AdaptiveLimiter, chunks are instead imported as concurrently as it allows.
 func (c *Client) ImportMessages(ctx context.Context, addrKR *crypto.KeyRing, workers, buffer int, req ...ImportReq) (ImportResStream, error) {
 	// Encrypt each message.
 	for idx := range req {
@@ -57,13 +59,20 @@ func (c *Client) ImportMessages(ctx context.Context, addrKR *crypto.KeyRing, wor
 		stream.FromIterator(iterator.Slice(ChunkSized(req, maxImportCount, MaxImportSize, func(req ImportReq) int {
 			return len(req.encryptedMessage)
 		}))),
-		workers,
+		getAdaptiveWorkers(ctx, workers),
 		buffer,
 		func(ctx context.Context, req []ImportReq) (stream.Stream[ImportRes], error) {
 			defer async.HandlePanic(c.m.panicHandler)
 
-			res, err := c.importMessages(ctx, req)
-			if err != nil {
+			var res []ImportRes
+
+			if err := doAdaptive(ctx, func(ctx context.Context) error {
+				var err error
+
+				res, err = c.importMessages(ctx, req)
+
+				return err
+			}); err != nil {
 				return nil, fmt.Errorf("failed to import messages: %w", err)
 			}
 
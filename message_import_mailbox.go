@@ -0,0 +1,441 @@
+package proton
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ProtonMail/gluon/async"
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/bradenaw/juniper/parallel"
+	"github.com/bradenaw/juniper/stream"
+)
+
+var (
+	// ErrUnknownImportFormat is returned when the requested import format is not supported.
+	ErrUnknownImportFormat = errors.New("unknown import format")
+
+	// ErrImportCheckpointMismatch is returned when resuming an import from a checkpoint written for another format.
+	ErrImportCheckpointMismatch = errors.New("checkpoint was written by an import in another format")
+)
+
+// ImportMailbox imports the messages of an mbox file or Maildir++ tree into the given address.
+// Messages are read as the returned stream is consumed, and imported in batches of up to workers concurrent requests,
+// or as many as the AdaptiveLimiter of the context allows, if any.
+// The stream yields a result per message in source order; a message that could not be imported is reported
+// with a non-success code and the source of the message in its error, rather than ending the stream.
+// Messages whose Message-ID was already imported are skipped.
+// Progress is recorded in the checkpoint file, if any, after each batch, so that an interrupted import can be resumed.
+func (c *Client) ImportMailbox(ctx context.Context, addrKR *crypto.KeyRing, workers int, req ImportMailboxReq) (ImportResStream, error) {
+	if req.Format != ExportFormatMbox && req.Format != ExportFormatMaildir {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownImportFormat, req.Format)
+	}
+
+	checkpoint, err := loadImportCheckpoint(req.CheckpointPath, req.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	r, err := newMailboxReader(req, checkpoint)
+	if err != nil {
+		_ = checkpoint.close()
+		return nil, err
+	}
+
+	return &mailboxImportStream{
+		c:          c,
+		addrKR:     addrKR,
+		workers:    max(workers, 1),
+		req:        req,
+		r:          r,
+		checkpoint: checkpoint,
+	}, nil
+}
+
+// mailboxImportStream imports the messages of a mailbox batch by batch as its results are consumed.
+type mailboxImportStream struct {
+	c       *Client
+	addrKR  *crypto.KeyRing
+	workers int
+	req     ImportMailboxReq
+
+	r          mailboxReader
+	checkpoint *importCheckpoint
+
+	pending []ImportRes
+	done    bool
+}
+
+func (s *mailboxImportStream) Next(ctx context.Context) (ImportRes, error) {
+	for len(s.pending) == 0 {
+		if s.done {
+			return ImportRes{}, stream.End
+		}
+
+		if err := s.importBatch(ctx); err != nil {
+			return ImportRes{}, err
+		}
+	}
+
+	res := s.pending[0]
+
+	s.pending = s.pending[1:]
+
+	return res, nil
+}
+
+func (s *mailboxImportStream) Close() {
+	_ = s.r.close()
+	_ = s.checkpoint.close()
+}
+
+// importBatch reads and imports the next batch of messages, then records them in the checkpoint.
+// Errors specific to a message are reported as its result; other errors leave the batch to be imported again on resume.
+// Only the messages that were imported are recorded, so that a resumed Maildir import retries the others
+// and later messages with the same Message-ID aren't skipped; a resumed mbox import continues after the batch.
+func (s *mailboxImportStream) importBatch(ctx context.Context) error {
+	entry := importCheckpointEntry{Format: s.req.Format, Offset: s.checkpoint.offset}
+
+	var (
+		results    []ImportRes
+		files      []string
+		messageIDs []string
+		reqs       []ImportReq
+		sources    []string
+		slots      []int
+
+		// duplicates are the files of messages whose Message-ID is already in the batch;
+		// they are recorded if the first message with that Message-ID is imported.
+		duplicates = make(map[string][]string)
+	)
+
+	for len(results) < s.workers*maxImportCount {
+		msg, err := s.r.next()
+		if errors.Is(err, io.EOF) {
+			s.done = true
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+
+		entry.Offset = msg.offset
+
+		messageID := getMessageID(msg.literal)
+
+		if messageID != "" && s.checkpoint.hasMessageID(messageID) {
+			if msg.file != "" {
+				entry.Files = append(entry.Files, msg.file)
+			}
+
+			continue
+		}
+
+		if messageID != "" && slices.Contains(messageIDs, messageID) {
+			duplicates[messageID] = append(duplicates[messageID], msg.file)
+			continue
+		}
+
+		files = append(files, msg.file)
+		messageIDs = append(messageIDs, messageID)
+
+		req, err := s.getImportReq(msg)
+		if err != nil {
+			results = append(results, newImportFailure(msg.source, err))
+			continue
+		}
+
+		reqs = append(reqs, req)
+		sources = append(sources, msg.source)
+		slots = append(slots, len(results))
+		results = append(results, ImportRes{})
+	}
+
+	imported, err := s.importMessages(ctx, reqs, sources)
+	if err != nil {
+		return err
+	}
+
+	for idx, res := range imported {
+		results[slots[idx]] = res
+	}
+
+	for idx, res := range results {
+		if res.Code != SuccessCode {
+			continue
+		}
+
+		if files[idx] != "" {
+			entry.Files = append(entry.Files, files[idx])
+		}
+
+		if messageID := messageIDs[idx]; messageID != "" {
+			entry.MessageIDs = append(entry.MessageIDs, messageID)
+
+			for _, file := range duplicates[messageID] {
+				if file != "" {
+					entry.Files = append(entry.Files, file)
+				}
+			}
+		}
+	}
+
+	if err := s.checkpoint.save(entry); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	if s.done {
+		if err := s.checkpoint.remove(); err != nil {
+			return fmt.Errorf("failed to remove checkpoint: %w", err)
+		}
+	}
+
+	s.pending = results
+
+	return nil
+}
+
+func (s *mailboxImportStream) getImportReq(msg mailboxMessage) (ImportReq, error) {
+	enc, err := EncryptRFC822(s.addrKR, slices.Clone(msg.literal))
+	if err != nil {
+		return ImportReq{}, fmt.Errorf("%w: %v", ErrImportEncrypt, err)
+	}
+
+	if len(enc) > MaxImportSize {
+		return ImportReq{}, ErrImportSizeExceeded
+	}
+
+	labelIDs := slices.Clone(s.req.LabelIDs)
+
+	for _, labelID := range msg.labelIDs {
+		if !slices.Contains(labelIDs, labelID) {
+			labelIDs = append(labelIDs, labelID)
+		}
+	}
+
+	return ImportReq{
+		Metadata: ImportMetadata{
+			AddressID: s.req.AddressID,
+			LabelIDs:  labelIDs,
+			Unread:    Bool(msg.unread),
+			Flags:     msg.flags,
+		},
+		Message:          msg.literal,
+		encryptedMessage: enc,
+	}, nil
+}
+
+// importMessages imports the messages in concurrent requests, reporting failed messages along with their source.
+func (s *mailboxImportStream) importMessages(ctx context.Context, reqs []ImportReq, sources []string) ([]ImportRes, error) {
+	type indexedReq struct {
+		ImportReq
+
+		idx int
+	}
+
+	indexed := make([]indexedReq, len(reqs))
+
+	for idx, req := range reqs {
+		indexed[idx] = indexedReq{ImportReq: req, idx: idx}
+	}
+
+	chunks := ChunkSized(indexed, maxImportCount, MaxImportSize, func(req indexedReq) int {
+		return len(req.encryptedMessage)
+	})
+
+	res, err := parallel.MapContext(ctx, getAdaptiveWorkers(ctx, s.workers), chunks, func(ctx context.Context, chunk []indexedReq) ([]ImportRes, error) {
+		defer async.HandlePanic(s.c.m.panicHandler)
+
+		var req []ImportReq
+
+		for _, r := range chunk {
+			req = append(req, r.ImportReq)
+		}
+
+		var res []ImportRes
+
+		if err := doAdaptive(ctx, func(ctx context.Context) error {
+			var err error
+
+			res, err = s.c.importMessages(ctx, req)
+
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("failed to import messages: %w", err)
+		}
+
+		for idx := range res {
+			if res[idx].Code != SuccessCode {
+				res[idx].Message = fmt.Sprintf("%s: %s", sources[chunk[idx].idx], res[idx].Message)
+			}
+		}
+
+		return res, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var flat []ImportRes
+
+	for _, res := range res {
+		flat = append(flat, res...)
+	}
+
+	return flat, nil
+}
+
+func newImportFailure(source string, err error) ImportRes {
+	return ImportRes{APIError: APIError{
+		Code:    InvalidValue,
+		Message: fmt.Sprintf("%s: %v", source, err),
+	}}
+}
+
+// getMessageID returns the Message-ID of the message, without angle brackets, or an empty string if it has none.
+func getMessageID(literal []byte) string {
+	header, err := rfc822.Parse(literal).ParseHeader()
+	if err != nil {
+		return ""
+	}
+
+	return strings.Trim(strings.TrimSpace(header.Get("Message-Id")), "<>")
+}
+
+// importCheckpoint is the progress of an import.
+// Its file is a sequence of JSON entries, one per batch, so that saving progress only appends to it.
+type importCheckpoint struct {
+	path string
+	file *os.File
+
+	offset     int64
+	files      map[string]struct{}
+	messageIDs map[string]struct{}
+}
+
+func loadImportCheckpoint(path string, format ExportFormat) (*importCheckpoint, error) {
+	checkpoint := &importCheckpoint{
+		path:       path,
+		files:      make(map[string]struct{}),
+		messageIDs: make(map[string]struct{}),
+	}
+
+	if path == "" {
+		return checkpoint, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(file)
+
+	for {
+		var entry importCheckpointEntry
+
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		} else if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+
+		if entry.Format != format {
+			_ = file.Close()
+			return nil, fmt.Errorf("%w: %s", ErrImportCheckpointMismatch, entry.Format)
+		}
+
+		checkpoint.add(entry)
+	}
+
+	// Discard the last entry if it was only partially written.
+	if err := file.Truncate(dec.InputOffset()); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if _, err := file.Seek(dec.InputOffset(), io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	checkpoint.file = file
+
+	return checkpoint, nil
+}
+
+func (c *importCheckpoint) add(entry importCheckpointEntry) {
+	c.offset = entry.Offset
+
+	for _, file := range entry.Files {
+		c.files[file] = struct{}{}
+	}
+
+	for _, messageID := range entry.MessageIDs {
+		c.messageIDs[messageID] = struct{}{}
+	}
+}
+
+func (c *importCheckpoint) hasFile(file string) bool {
+	_, ok := c.files[file]
+	return ok
+}
+
+func (c *importCheckpoint) hasMessageID(messageID string) bool {
+	_, ok := c.messageIDs[messageID]
+	return ok
+}
+
+func (c *importCheckpoint) save(entry importCheckpointEntry) error {
+	c.add(entry)
+
+	if c.file == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	return c.file.Sync()
+}
+
+func (c *importCheckpoint) remove() error {
+	if c.file == nil {
+		return nil
+	}
+
+	if err := c.close(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (c *importCheckpoint) close() error {
+	if c.file == nil {
+		return nil
+	}
+
+	file := c.file
+
+	c.file = nil
+
+	return file.Close()
+}
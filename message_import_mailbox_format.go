@@ -0,0 +1,364 @@
+package proton
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/gluon/rfc822"
+)
+
+// mailboxMessage is a message read from a mailbox, along with the metadata derived from its source.
+type mailboxMessage struct {
+	literal  []byte
+	labelIDs []string
+	unread   bool
+	flags    MessageFlag
+
+	// source describes where the message was read from, to report import failures.
+	source string
+
+	// offset is the position in the mbox file after the message.
+	offset int64
+
+	// file identifies the Maildir message by its folder and unique name, which do not change with its flags.
+	file string
+}
+
+// mailboxReader reads messages to import from a mailbox in a given format.
+type mailboxReader interface {
+	// next returns the next message, or io.EOF once all messages have been read.
+	next() (mailboxMessage, error)
+
+	// close releases the resources of the reader.
+	close() error
+}
+
+func newMailboxReader(req ImportMailboxReq, checkpoint *importCheckpoint) (mailboxReader, error) {
+	switch req.Format {
+	case ExportFormatMbox:
+		return newMboxReader(req.Path, checkpoint.offset)
+
+	case ExportFormatMaildir:
+		return newMaildirReader(req.Path, req.FolderLabelIDs, checkpoint)
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownImportFormat, req.Format)
+	}
+}
+
+// mboxReader reads messages from an mboxrd file, one at a time.
+type mboxReader struct {
+	file *os.File
+	r    *bufio.Reader
+
+	// pos is the position in the file of the next byte to read.
+	pos int64
+
+	// sepPos is the position of the separator line of the next message, if it was already read.
+	sepPos int64
+	sep    bool
+}
+
+func newMboxReader(path string, offset int64) (*mboxReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &mboxReader{file: file, r: bufio.NewReader(file), pos: offset}, nil
+}
+
+func (r *mboxReader) next() (mailboxMessage, error) {
+	// Skip anything before the separator line of the next message.
+	for !r.sep {
+		pos := r.pos
+
+		line, err := r.readLine()
+		if isMboxSeparator(line) {
+			r.sep, r.sepPos = true, pos
+		} else if err != nil {
+			return mailboxMessage{}, err
+		}
+	}
+
+	start := r.sepPos
+
+	r.sep = false
+
+	buf := new(bytes.Buffer)
+
+	for {
+		pos := r.pos
+
+		line, err := r.readLine()
+		if isMboxSeparator(line) {
+			r.sep, r.sepPos = true, pos
+			break
+		}
+
+		// Unquote lines that were quoted so as not to be mistaken for a message separator.
+		if bytes.HasPrefix(line, []byte(">")) && bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			line = line[1:]
+		}
+
+		buf.Write(line)
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return mailboxMessage{}, err
+		}
+	}
+
+	// The blank line before the next separator belongs to the mbox format rather than to the message.
+	literal := bytes.ReplaceAll(buf.Bytes(), []byte("\r\n"), []byte("\n"))
+	literal = bytes.TrimSuffix(literal, []byte("\n\n"))
+	literal = bytes.ReplaceAll(append(literal, '\n'), []byte("\n"), []byte("\r\n"))
+
+	msg := mailboxMessage{
+		literal: literal,
+		flags:   MessageFlagReceived,
+		source:  fmt.Sprintf("mbox message at offset %d", start),
+		offset:  r.pos,
+	}
+
+	if r.sep {
+		msg.offset = r.sepPos
+	}
+
+	if header, err := rfc822.Parse(literal).ParseHeader(); err == nil {
+		setMboxStatus(&msg, header.Get("Status"), header.Get("X-Status"))
+	}
+
+	return msg, nil
+}
+
+func (r *mboxReader) readLine() ([]byte, error) {
+	line, err := r.r.ReadBytes('\n')
+
+	r.pos += int64(len(line))
+
+	return line, err
+}
+
+func (r *mboxReader) close() error {
+	return r.file.Close()
+}
+
+func isMboxSeparator(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("From "))
+}
+
+// setMboxStatus sets the metadata of the message from its Status and X-Status headers, as written by mail clients.
+// Messages without a Status header are considered read.
+func setMboxStatus(msg *mailboxMessage, status, xStatus string) {
+	msg.unread = status != "" && !strings.Contains(status, "R")
+
+	if strings.Contains(xStatus, "A") {
+		msg.flags |= MessageFlagReplied
+	}
+
+	if strings.Contains(xStatus, "F") {
+		msg.labelIDs = append(msg.labelIDs, StarredLabel)
+	}
+
+	if strings.Contains(xStatus, "T") {
+		msg.flags = 0
+	}
+}
+
+// maildirSystemLabels maps the Maildir folders of the system labels to their IDs; the root Maildir is the inbox.
+var maildirSystemLabels = map[string]string{
+	"":        InboxLabel,
+	"Drafts":  DraftsLabel,
+	"Sent":    SentLabel,
+	"Trash":   TrashLabel,
+	"Spam":    SpamLabel,
+	"Junk":    SpamLabel,
+	"Archive": ArchiveLabel,
+}
+
+// maildirReader reads messages from a Maildir++ tree, folder by folder.
+type maildirReader struct {
+	root           string
+	folderLabelIDs map[string]string
+	files          []maildirFile
+}
+
+type maildirFile struct {
+	// dir is the Maildir of the folder, relative to the root.
+	dir string
+
+	// path is the message file, relative to the root.
+	path string
+
+	// info is the part of the file name after the unique name, holding its flags.
+	info string
+}
+
+func newMaildirReader(root string, folderLabelIDs map[string]string, checkpoint *importCheckpoint) (*maildirReader, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{""}
+
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), ".") && isMaildir(filepath.Join(root, entry.Name())) {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+
+	var files []maildirFile
+
+	for _, dir := range dirs {
+		for _, sub := range []string{"new", "cur"} {
+			entries, err := os.ReadDir(filepath.Join(root, dir, sub))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+
+				file := maildirFile{dir: dir, path: filepath.Join(dir, sub, entry.Name())}
+
+				if _, info, ok := strings.Cut(entry.Name(), ":"); ok {
+					file.info = info
+				}
+
+				if !checkpoint.hasFile(file.key()) {
+					files = append(files, file)
+				}
+			}
+		}
+	}
+
+	return &maildirReader{
+		root:           root,
+		folderLabelIDs: folderLabelIDs,
+		files:          files,
+	}, nil
+}
+
+func (r *maildirReader) next() (mailboxMessage, error) {
+	if len(r.files) == 0 {
+		return mailboxMessage{}, io.EOF
+	}
+
+	file := r.files[0]
+
+	r.files = r.files[1:]
+
+	literal, err := os.ReadFile(filepath.Join(r.root, file.path))
+	if err != nil {
+		return mailboxMessage{}, err
+	}
+
+	msg := mailboxMessage{
+		literal: literal,
+		flags:   MessageFlagReceived,
+		source:  fmt.Sprintf("maildir message %s", filepath.ToSlash(file.path)),
+		file:    file.key(),
+	}
+
+	folder := getMaildirFolder(file.dir)
+
+	if labelID, ok := r.getLabelID(folder); ok {
+		msg.labelIDs = append(msg.labelIDs, labelID)
+
+		switch labelID {
+		case SentLabel:
+			msg.flags = MessageFlagSent
+
+		case DraftsLabel:
+			msg.flags = 0
+		}
+	}
+
+	setMaildirFlags(&msg, file.info)
+
+	if header, err := rfc822.Parse(literal).ParseHeader(); err == nil {
+		for _, keyword := range strings.Split(header.Get("X-Keywords"), ",") {
+			if labelID, ok := r.folderLabelIDs[strings.TrimSpace(keyword)]; ok {
+				msg.labelIDs = append(msg.labelIDs, labelID)
+			}
+		}
+	}
+
+	return msg, nil
+}
+
+func (r *maildirReader) close() error {
+	return nil
+}
+
+// getLabelID returns the label of the given Maildir folder, preferring the mapping of the request to the system labels.
+func (r *maildirReader) getLabelID(folder string) (string, bool) {
+	if labelID, ok := r.folderLabelIDs[folder]; ok {
+		return labelID, true
+	}
+
+	labelID, ok := maildirSystemLabels[folder]
+
+	return labelID, ok
+}
+
+// key identifies the message regardless of whether it was moved from new to cur or its flags changed.
+func (f maildirFile) key() string {
+	name, _, _ := strings.Cut(filepath.Base(f.path), ":")
+
+	return filepath.ToSlash(filepath.Join(f.dir, name))
+}
+
+// setMaildirFlags sets the metadata of the message from the flags in the info part of its file name.
+// Messages without flags, such as those still in new, are unread.
+func setMaildirFlags(msg *mailboxMessage, info string) {
+	flags, _ := strings.CutPrefix(info, "2,")
+
+	msg.unread = !strings.Contains(flags, "S")
+
+	if strings.Contains(flags, "D") {
+		msg.flags = 0
+	}
+
+	if strings.Contains(flags, "F") {
+		msg.labelIDs = append(msg.labelIDs, StarredLabel)
+	}
+
+	if strings.Contains(flags, "P") {
+		msg.flags |= MessageFlagForwarded
+	}
+
+	if strings.Contains(flags, "R") {
+		msg.flags |= MessageFlagReplied
+	}
+}
+
+// getMaildirFolder returns the name of the folder of the given Maildir++ directory, such as "Folders/Work".
+func getMaildirFolder(dir string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(dir, "."), ".", "/")
+}
+
+func isMaildir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "cur"))
+
+	return err == nil && info.IsDir()
+}
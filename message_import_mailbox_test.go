@@ -0,0 +1,321 @@
+package proton_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/bradenaw/juniper/stream"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportMailbox_Mbox(t *testing.T) {
+	withImportMailboxClient(t, func(ctx context.Context, c *proton.Client, addrID string, addrKR *crypto.KeyRing) {
+		dir := t.TempDir()
+
+		mbox := strings.Join([]string{
+			"From sender@example.com Thu Jan  1 00:00:00 1970",
+			"Message-Id: <1@example.com>",
+			"Subject: first",
+			"Status: RO",
+			"",
+			"Hello",
+			">From the body",
+			"",
+			"From sender@example.com Thu Jan  1 00:00:00 1970",
+			"Message-Id: <2@example.com>",
+			"Subject: second",
+			"Status: O",
+			"X-Status: F",
+			"",
+			"World",
+			"",
+			"From sender@example.com Thu Jan  1 00:00:00 1970",
+			"Message-Id: <1@example.com>",
+			"Subject: duplicate",
+			"",
+			"Hello again",
+			"",
+		}, "\n")
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "import.mbox"), []byte(mbox), 0o600))
+
+		res, err := c.ImportMailbox(ctx, addrKR, 1, proton.ImportMailboxReq{
+			Format:         proton.ExportFormatMbox,
+			Path:           filepath.Join(dir, "import.mbox"),
+			CheckpointPath: filepath.Join(dir, "checkpoint.json"),
+			AddressID:      addrID,
+			LabelIDs:       []string{proton.InboxLabel},
+		})
+		require.NoError(t, err)
+
+		imported, err := stream.Collect(ctx, res)
+		require.NoError(t, err)
+		require.Len(t, imported, 2)
+
+		first, err := c.GetMessage(ctx, imported[0].MessageID)
+		require.NoError(t, err)
+		require.Equal(t, "first", first.Subject)
+		require.False(t, bool(first.Unread))
+		require.Contains(t, first.LabelIDs, proton.InboxLabel)
+
+		body, err := first.Decrypt(addrKR)
+		require.NoError(t, err)
+		require.Equal(t, "Hello\r\nFrom the body\r\n", string(body))
+
+		second, err := c.GetMessage(ctx, imported[1].MessageID)
+		require.NoError(t, err)
+		require.Equal(t, "second", second.Subject)
+		require.True(t, bool(second.Unread))
+		require.Contains(t, second.LabelIDs, proton.StarredLabel)
+
+		// The checkpoint is removed once the import completes.
+		require.NoFileExists(t, filepath.Join(dir, "checkpoint.json"))
+	})
+}
+
+func TestImportMailbox_MboxResume(t *testing.T) {
+	withImportMailboxClient(t, func(ctx context.Context, c *proton.Client, addrID string, addrKR *crypto.KeyRing) {
+		dir := t.TempDir()
+
+		var mbox strings.Builder
+
+		for idx := 0; idx < 15; idx++ {
+			fmt.Fprintf(&mbox, "From sender@example.com Thu Jan  1 00:00:00 1970\nMessage-Id: <%d@example.com>\nSubject: %d\n\nBody\n\n", idx, idx)
+		}
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "import.mbox"), []byte(mbox.String()), 0o600))
+
+		req := proton.ImportMailboxReq{
+			Format:         proton.ExportFormatMbox,
+			Path:           filepath.Join(dir, "import.mbox"),
+			CheckpointPath: filepath.Join(dir, "checkpoint.json"),
+			AddressID:      addrID,
+		}
+
+		// Stop after the first batch, as if the import crashed.
+		res, err := c.ImportMailbox(ctx, addrKR, 1, req)
+		require.NoError(t, err)
+
+		for idx := 0; idx < 10; idx++ {
+			item, err := res.Next(ctx)
+			require.NoError(t, err)
+			require.Equal(t, proton.SuccessCode, item.Code)
+		}
+
+		res.Close()
+
+		// Simulate an entry left partially written by the crash.
+		file, err := os.OpenFile(filepath.Join(dir, "checkpoint.json"), os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"Format":"mbox","Off`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		res, err = c.ImportMailbox(ctx, addrKR, 1, req)
+		require.NoError(t, err)
+
+		imported, err := stream.Collect(ctx, res)
+		require.NoError(t, err)
+		require.Len(t, imported, 5)
+
+		messageIDs, err := c.GetAllMessageIDs(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, messageIDs, 15)
+	})
+}
+
+func TestImportMailbox_Maildir(t *testing.T) {
+	withImportMailboxClient(t, func(ctx context.Context, c *proton.Client, addrID string, addrKR *crypto.KeyRing) {
+		root := t.TempDir()
+
+		folder, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "Work", Type: proton.LabelTypeFolder})
+		require.NoError(t, err)
+
+		label, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "Important", Type: proton.LabelTypeLabel})
+		require.NoError(t, err)
+
+		writeMaildirFile(t, root, "", "new/1", "Message-Id: <1@example.com>\r\nSubject: new\r\n\r\nBody\r\n")
+		writeMaildirFile(t, root, "", "cur/2:2,RS", "Message-Id: <2@example.com>\r\nSubject: read\r\nX-Keywords: Important\r\n\r\nBody\r\n")
+		writeMaildirFile(t, root, ".Sent", "cur/3:2,S", "Message-Id: <3@example.com>\r\nSubject: sent\r\n\r\nBody\r\n")
+		writeMaildirFile(t, root, ".Folders.Work", "cur/4:2,FS", "Message-Id: <4@example.com>\r\nSubject: work\r\n\r\nBody\r\n")
+		writeMaildirFile(t, root, ".Labels.Important", "cur/5:2,RS", "Message-Id: <2@example.com>\r\nSubject: read\r\n\r\nBody\r\n")
+
+		res, err := c.ImportMailbox(ctx, addrKR, 2, proton.ImportMailboxReq{
+			Format:         proton.ExportFormatMaildir,
+			Path:           root,
+			CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.json"),
+			AddressID:      addrID,
+			FolderLabelIDs: map[string]string{"Folders/Work": folder.ID, "Important": label.ID},
+		})
+		require.NoError(t, err)
+
+		imported, err := stream.Collect(ctx, res)
+		require.NoError(t, err)
+		require.Len(t, imported, 4)
+
+		messages := make(map[string]proton.Message)
+
+		for _, res := range imported {
+			require.Equal(t, proton.SuccessCode, res.Code)
+
+			message, err := c.GetMessage(ctx, res.MessageID)
+			require.NoError(t, err)
+
+			messages[message.Subject] = message
+		}
+
+		require.True(t, bool(messages["new"].Unread))
+		require.Contains(t, messages["new"].LabelIDs, proton.InboxLabel)
+
+		require.False(t, bool(messages["read"].Unread))
+		require.True(t, messages["read"].Flags.Has(proton.MessageFlagReplied))
+		require.Contains(t, messages["read"].LabelIDs, label.ID)
+
+		require.Contains(t, messages["sent"].LabelIDs, proton.SentLabel)
+		require.True(t, messages["sent"].Flags.Has(proton.MessageFlagSent))
+
+		require.Contains(t, messages["work"].LabelIDs, folder.ID)
+		require.Contains(t, messages["work"].LabelIDs, proton.StarredLabel)
+	})
+}
+
+func TestImportMailbox_ReportsFailures(t *testing.T) {
+	withImportMailboxClient(t, func(ctx context.Context, c *proton.Client, addrID string, addrKR *crypto.KeyRing) {
+		root := t.TempDir()
+
+		writeMaildirFile(t, root, "", "cur/1:2,S", "Subject: good\r\n\r\nBody\r\n")
+		writeMaildirFile(t, root, ".Folders.Deleted", "cur/2:2,S", "Subject: bad\r\n\r\nBody\r\n")
+		writeMaildirFile(t, root, ".Folders.Work", "cur/3:2,S", "Subject: good\r\n\r\nBody\r\n")
+
+		folder, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "Work", Type: proton.LabelTypeFolder})
+		require.NoError(t, err)
+
+		// The folder of the second message no longer exists, so the server rejects it.
+		res, err := c.ImportMailbox(ctx, addrKR, 1, proton.ImportMailboxReq{
+			Format:         proton.ExportFormatMaildir,
+			Path:           root,
+			AddressID:      addrID,
+			FolderLabelIDs: map[string]string{"Folders/Deleted": "deleted", "Folders/Work": folder.ID},
+		})
+		require.NoError(t, err)
+
+		imported, err := stream.Collect(ctx, res)
+		require.NoError(t, err)
+		require.Len(t, imported, 3)
+
+		require.Equal(t, proton.SuccessCode, imported[0].Code)
+		require.NotEqual(t, proton.SuccessCode, imported[1].Code)
+		require.Contains(t, imported[1].Message, ".Folders.Deleted/cur/2:2,S")
+		require.Equal(t, proton.SuccessCode, imported[2].Code)
+	})
+}
+
+func TestImportMailbox_ResumeRetriesFailures(t *testing.T) {
+	withImportMailboxClient(t, func(ctx context.Context, c *proton.Client, addrID string, addrKR *crypto.KeyRing) {
+		root := t.TempDir()
+
+		for idx := 0; idx < 9; idx++ {
+			writeMaildirFile(t, root, "", fmt.Sprintf("cur/%d:2,S", idx), fmt.Sprintf("Message-Id: <%d@example.com>\r\nSubject: %d\r\n\r\nBody\r\n", idx, idx))
+		}
+
+		writeMaildirFile(t, root, ".Folders.Deleted", "cur/9:2,S", "Message-Id: <9@example.com>\r\nSubject: 9\r\n\r\nBody\r\n")
+		writeMaildirFile(t, root, ".Folders.Work", "cur/10:2,S", "Message-Id: <10@example.com>\r\nSubject: 10\r\n\r\nBody\r\n")
+		writeMaildirFile(t, root, ".Folders.Work", "cur/11:2,S", "Message-Id: <9@example.com>\r\nSubject: 9\r\n\r\nBody\r\n")
+
+		folder, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "Work", Type: proton.LabelTypeFolder})
+		require.NoError(t, err)
+
+		req := proton.ImportMailboxReq{
+			Format:         proton.ExportFormatMaildir,
+			Path:           root,
+			CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.json"),
+			AddressID:      addrID,
+			FolderLabelIDs: map[string]string{"Folders/Deleted": "deleted", "Folders/Work": folder.ID},
+		}
+
+		// The folder of the last message of the first batch no longer exists, so the server rejects it.
+		// Stop after the first batch, as if the import crashed.
+		res, err := c.ImportMailbox(ctx, addrKR, 1, req)
+		require.NoError(t, err)
+
+		for idx := 0; idx < 10; idx++ {
+			item, err := res.Next(ctx)
+			require.NoError(t, err)
+
+			if idx < 9 {
+				require.Equal(t, proton.SuccessCode, item.Code)
+			} else {
+				require.NotEqual(t, proton.SuccessCode, item.Code)
+			}
+		}
+
+		res.Close()
+
+		// Once the folder is mapped again, resuming retries the failed message; its Message-ID wasn't recorded,
+		// so the later message with the same Message-ID is imported only once, as a duplicate of it.
+		req.FolderLabelIDs["Folders/Deleted"] = folder.ID
+
+		res, err = c.ImportMailbox(ctx, addrKR, 1, req)
+		require.NoError(t, err)
+
+		imported, err := stream.Collect(ctx, res)
+		require.NoError(t, err)
+		require.Len(t, imported, 2)
+
+		for _, res := range imported {
+			require.Equal(t, proton.SuccessCode, res.Code)
+		}
+
+		messageIDs, err := c.GetAllMessageIDs(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, messageIDs, 11)
+	})
+}
+
+func TestImportMailbox_UnknownFormat(t *testing.T) {
+	withImportMailboxClient(t, func(ctx context.Context, c *proton.Client, addrID string, addrKR *crypto.KeyRing) {
+		_, err := c.ImportMailbox(ctx, addrKR, 1, proton.ImportMailboxReq{
+			Format:    proton.ExportFormatEMLZip,
+			Path:      filepath.Join(t.TempDir(), "import.zip"),
+			AddressID: addrID,
+		})
+		require.ErrorIs(t, err, proton.ErrUnknownImportFormat)
+	})
+}
+
+func withImportMailboxClient(t *testing.T, fn func(ctx context.Context, c *proton.Client, addrID string, addrKR *crypto.KeyRing)) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	_, addrID, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	fn(ctx, c, addrID, unlockTestKeyRings(t, c, ctx, "pass")[addrID])
+}
+
+func writeMaildirFile(t *testing.T, root, dir, name, literal string) {
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir, sub), 0o700))
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, dir, name), []byte(literal), 0o600))
+}
@@ -0,0 +1,40 @@
+package proton
+
+type ImportMailboxReq struct {
+	// Format is the format of the source: ExportFormatMbox or ExportFormatMaildir.
+	Format ExportFormat
+
+	// Path is the mbox file or Maildir++ root directory to read.
+	Path string
+
+	// CheckpointPath is the file in which the progress of the import is recorded.
+	// If it exists, the import skips the messages it records. It is removed once the import completes.
+	// If empty, the import cannot be resumed.
+	CheckpointPath string
+
+	// AddressID is the address the messages are imported into.
+	AddressID string
+
+	// LabelIDs are applied to every imported message, in addition to those derived from the source.
+	LabelIDs []string
+
+	// FolderLabelIDs maps Maildir folder names, such as "Folders/Work", and X-Keywords keywords to label IDs.
+	// The inbox and the Drafts, Sent, Trash, Spam and Archive folders are mapped to their system labels.
+	// Messages of other folders are imported to All Mail only.
+	FolderLabelIDs map[string]string
+}
+
+// importCheckpointEntry is a line of an import checkpoint file, appended once a batch of messages is imported.
+type importCheckpointEntry struct {
+	Format ExportFormat
+
+	// Offset is the position in the mbox file after the last message of the batch.
+	Offset int64 `json:",omitempty"`
+
+	// Files are the Maildir messages of the batch that were imported, or skipped as duplicates of imported messages,
+	// as folder and unique name.
+	Files []string `json:",omitempty"`
+
+	// MessageIDs are the Message-IDs of the messages of the batch that were imported, to skip later duplicates.
+	MessageIDs []string `json:",omitempty"`
+}
@@ -32,6 +32,8 @@ type ImportMetadata struct {
 
 type ImportRes struct {
 	APIError
+
+	// MessageID is the ID of the imported message. It is empty if the filters of the user discarded the message.
 	MessageID string
 }
 
@@ -0,0 +1,387 @@
+package proton
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/textproto"
+)
+
+const (
+	multipartEncrypted rfc822.MIMEType = "multipart/encrypted"
+	multipartSigned    rfc822.MIMEType = "multipart/signed"
+)
+
+const (
+	pgpMessageBegin       = "-----BEGIN PGP MESSAGE-----"
+	pgpMessageEnd         = "-----END PGP MESSAGE-----"
+	pgpSignedMessageBegin = "-----BEGIN PGP SIGNED MESSAGE-----"
+	pgpSignatureEnd       = "-----END PGP SIGNATURE-----"
+)
+
+// ErrInvalidPGPMIME is returned when a multipart/encrypted or multipart/signed part is malformed.
+var ErrInvalidPGPMIME = errors.New("invalid PGP/MIME structure")
+
+// DecodedMessage is an external PGP message decoded into a plain MIME message.
+type DecodedMessage struct {
+	// Literal is the decoded RFC822 message, without any multipart/encrypted or multipart/signed wrapping.
+	Literal []byte
+
+	// Status is the combined result of all signature verifications performed while decoding.
+	Status SignatureStatus
+}
+
+// DecodePGPMessage decodes a message received from an external PGP user.
+//
+// PGP/MIME messages have their multipart/encrypted parts decrypted with the given keyring
+// and their multipart/signed parts verified with the verifier keyring.
+// PGP/Inline messages have their armored text blocks decrypted or verified,
+// and their .pgp/.gpg attachments decrypted.
+//
+// The verification result is returned in the decoded message and also reported in the SignatureStatusHeader header.
+// The verifier keyring may be nil, in which case any signature is reported as made by an unknown key.
+func DecodePGPMessage(kr, verifierKR *crypto.KeyRing, msg Message, attData map[string][]byte) (DecodedMessage, error) {
+	if verifierKR == nil {
+		var err error
+
+		if verifierKR, err = crypto.NewKeyRing(nil); err != nil {
+			return DecodedMessage{}, err
+		}
+	}
+
+	if msg.MIMEType == rfc822.MultipartMixed {
+		return decodePGPMIME(kr, verifierKR, msg)
+	}
+
+	return decodePGPInline(kr, verifierKR, msg, attData)
+}
+
+func decodePGPMIME(kr, verifierKR *crypto.KeyRing, msg Message) (DecodedMessage, error) {
+	raw, err := textproto.ReadHeader(bufio.NewReader(strings.NewReader(msg.Header)))
+	if err != nil {
+		return DecodedMessage{}, err
+	}
+
+	dec, status, err := msg.DecryptAndVerify(kr, verifierKR)
+	if err != nil {
+		return DecodedMessage{}, err
+	}
+
+	body, bodyStatus, err := unwrapPGPMIME(kr, verifierKR, dec)
+	if err != nil {
+		return DecodedMessage{}, err
+	}
+
+	header := message.Header{Header: raw}
+
+	status = status.combineLayers(bodyStatus)
+
+	header.Set(SignatureStatusHeader, status.String())
+
+	literal, err := buildMultipartEncryptedRFC822(header, body)
+	if err != nil {
+		return DecodedMessage{}, err
+	}
+
+	return DecodedMessage{Literal: literal, Status: status}, nil
+}
+
+// unwrapPGPMIME recursively removes the multipart/encrypted and multipart/signed layers of the given MIME entity.
+func unwrapPGPMIME(kr, verifierKR *crypto.KeyRing, literal []byte) ([]byte, SignatureStatus, error) {
+	section := rfc822.Parse(literal)
+
+	mimeType, _, err := section.ContentType()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if mimeType != multipartEncrypted && mimeType != multipartSigned {
+		return literal, SignatureStatusUnsigned, nil
+	}
+
+	children, err := section.Children()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(children) != 2 {
+		return nil, 0, fmt.Errorf("%w: %s has %d parts", ErrInvalidPGPMIME, mimeType, len(children))
+	}
+
+	armored, err := children[1].DecodedBody()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		inner  []byte
+		status SignatureStatus
+	)
+
+	if mimeType == multipartEncrypted {
+		enc, err := crypto.NewPGPMessageFromArmored(string(armored))
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidPGPMIME, err)
+		}
+
+		dec, err := kr.Decrypt(enc, verifierKR, crypto.GetUnixTime())
+
+		if status, err = getSignatureStatus(err); err != nil {
+			return nil, 0, err
+		}
+
+		inner = dec.GetBinary()
+	} else {
+		sig, err := crypto.NewPGPSignatureFromArmored(string(armored))
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidPGPMIME, err)
+		}
+
+		inner = children[0].Literal()
+
+		if status, err = verifyDetached(verifierKR, crypto.NewPlainMessage(canonicalizeLineEndings(inner)), sig); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	body, bodyStatus, err := unwrapPGPMIME(kr, verifierKR, inner)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return body, status.combineLayers(bodyStatus), nil
+}
+
+func decodePGPInline(kr, verifierKR *crypto.KeyRing, msg Message, attData map[string][]byte) (DecodedMessage, error) {
+	header, err := getMixedMessageHeader(msg)
+	if err != nil {
+		return DecodedMessage{}, err
+	}
+
+	dec, status, err := msg.DecryptAndVerify(kr, verifierKR)
+	if err != nil {
+		return DecodedMessage{}, err
+	}
+
+	dec, textStatus, err := decodeInlineText(kr, verifierKR, dec)
+	if err != nil {
+		return DecodedMessage{}, err
+	}
+
+	// The signature of the body covers the text only; the text and the attachments are parts of the content,
+	// which is only valid if all of them are.
+	status = status.combineLayers(textStatus)
+
+	msg.Attachments = slices.Clone(msg.Attachments)
+
+	attDec := make(map[string][]byte, len(msg.Attachments))
+
+	for i, att := range msg.Attachments {
+		if attDec[att.ID], err = decryptAttachment(kr, att, attData[att.ID]); err != nil {
+			return DecodedMessage{}, err
+		}
+
+		name, ok := trimPGPExtension(att.Name)
+		if !ok {
+			status = status.combineParts(SignatureStatusUnsigned)
+			continue
+		}
+
+		dec, err := kr.Decrypt(crypto.NewPGPMessage(attDec[att.ID]), verifierKR, crypto.GetUnixTime())
+
+		attStatus, err := getSignatureStatus(err)
+		if err != nil {
+			return DecodedMessage{}, fmt.Errorf("failed to decrypt attachment %q: %w", att.Name, err)
+		}
+
+		attDec[att.ID] = dec.GetBinary()
+
+		msg.Attachments[i].Name = name
+		msg.Attachments[i].MIMEType = getMIMETypeByName(name)
+
+		status = status.combineParts(attStatus)
+	}
+
+	header.Set(SignatureStatusHeader, status.String())
+
+	literal, err := buildMixedRFC822(header, msg, dec, attDec)
+	if err != nil {
+		return DecodedMessage{}, err
+	}
+
+	return DecodedMessage{Literal: literal, Status: status}, nil
+}
+
+// decodeInlineText replaces each armored PGP message and cleartext signed message in the text
+// with its decrypted or verified content. Every block is verified, and the text outside of the blocks
+// counts as unsigned content unless it is only whitespace.
+func decodeInlineText(kr, verifierKR *crypto.KeyRing, text []byte) ([]byte, SignatureStatus, error) {
+	var (
+		res    []byte
+		status SignatureStatus
+	)
+
+	for {
+		begin, end, signed, ok := findInlineBlock(text)
+		if !ok {
+			break
+		}
+
+		dec, blockStatus, err := decodeInlineBlock(kr, verifierKR, text[begin:end], signed)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		status = status.combineParts(inlineTextStatus(text[:begin])).combineParts(blockStatus)
+
+		res = slices.Concat(res, text[:begin], dec)
+
+		text = text[end:]
+	}
+
+	status = status.combineParts(inlineTextStatus(text))
+
+	if status == 0 {
+		status = SignatureStatusUnsigned
+	}
+
+	return slices.Concat(res, text), status, nil
+}
+
+// decodeInlineBlock decrypts an armored PGP message, or verifies a cleartext signed message if signed is true.
+func decodeInlineBlock(kr, verifierKR *crypto.KeyRing, block []byte, signed bool) ([]byte, SignatureStatus, error) {
+	if !signed {
+		enc, err := crypto.NewPGPMessageFromArmored(string(block))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		dec, err := kr.Decrypt(enc, verifierKR, crypto.GetUnixTime())
+
+		status, err := getSignatureStatus(err)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return dec.GetBinary(), status, nil
+	}
+
+	msg, err := crypto.NewClearTextMessageFromArmored(string(block))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	status, err := verifyDetached(
+		verifierKR,
+		crypto.NewPlainMessageFromString(msg.GetString()),
+		crypto.NewPGPSignature(msg.GetBinarySignature()),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return msg.GetBinary(), status, nil
+}
+
+// inlineTextStatus returns the status of text outside of any armored block: unsigned, or zero if it is only whitespace.
+func inlineTextStatus(text []byte) SignatureStatus {
+	if len(bytes.TrimSpace(text)) == 0 {
+		return 0
+	}
+
+	return SignatureStatusUnsigned
+}
+
+// verifyDetached verifies a detached signature.
+// Unlike a decryption, a failed detached verification doesn't tell whether the signing key was missing,
+// so signatures made by keys absent from the verifier keyring are reported as such before verifying.
+func verifyDetached(verifierKR *crypto.KeyRing, data *crypto.PlainMessage, sig *crypto.PGPSignature) (SignatureStatus, error) {
+	if keyIDs, ok := sig.GetSignatureKeyIDs(); ok && !hasAnyKeyID(verifierKR, keyIDs) {
+		return SignatureStatusUnknownKey, nil
+	}
+
+	return getSignatureStatus(verifierKR.VerifyDetached(data, sig, crypto.GetUnixTime()))
+}
+
+func hasAnyKeyID(kr *crypto.KeyRing, keyIDs []uint64) bool {
+	for _, key := range kr.GetKeys() {
+		entity := key.GetEntity()
+
+		if slices.Contains(keyIDs, entity.PrimaryKey.KeyId) {
+			return true
+		}
+
+		for _, subKey := range entity.Subkeys {
+			if slices.Contains(keyIDs, subKey.PublicKey.KeyId) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// findInlineBlock returns the bounds of the first armored PGP message or cleartext signed message in the text,
+// and whether it is a cleartext signed message.
+func findInlineBlock(text []byte) (int, int, bool, bool) {
+	msgBegin, msgEnd, msgOK := findArmoredBlock(text, pgpMessageBegin, pgpMessageEnd)
+	sigBegin, sigEnd, sigOK := findArmoredBlock(text, pgpSignedMessageBegin, pgpSignatureEnd)
+
+	switch {
+	case msgOK && (!sigOK || msgBegin < sigBegin):
+		return msgBegin, msgEnd, false, true
+
+	case sigOK:
+		return sigBegin, sigEnd, true, true
+
+	default:
+		return 0, 0, false, false
+	}
+}
+
+// findArmoredBlock returns the bounds of the first block delimited by the given armor lines.
+func findArmoredBlock(text []byte, beginLine, endLine string) (int, int, bool) {
+	begin := bytes.Index(text, []byte(beginLine))
+	if begin < 0 {
+		return 0, 0, false
+	}
+
+	end := bytes.Index(text[begin:], []byte(endLine))
+	if end < 0 {
+		return 0, 0, false
+	}
+
+	return begin, begin + end + len(endLine), true
+}
+
+func trimPGPExtension(name string) (string, bool) {
+	for _, ext := range []string{".pgp", ".gpg"} {
+		if trimmed, ok := strings.CutSuffix(name, ext); ok && trimmed != "" {
+			return trimmed, true
+		}
+	}
+
+	return name, false
+}
+
+func getMIMETypeByName(name string) rfc822.MIMEType {
+	if mimeType, _, err := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(name))); err == nil {
+		return rfc822.MIMEType(mimeType)
+	}
+
+	return "application/octet-stream"
+}
+
+// canonicalizeLineEndings converts all line endings to CRLF, as required for verifying PGP/MIME signatures.
+func canonicalizeLineEndings(b []byte) []byte {
+	return bytes.ReplaceAll(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n"))
+}
@@ -0,0 +1,274 @@
+package proton_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePGPMessage_PGPMIME(t *testing.T) {
+	kr := newTestKeyRing(t, "user@proton.test")
+	senderKR := newTestKeyRing(t, "sender@external.test")
+
+	const part = "Content-Type: text/plain; charset=utf-8\r\n\r\nhello from outside"
+
+	tests := []struct {
+		name       string
+		body       string
+		verifierKR *crypto.KeyRing
+		wantStatus proton.SignatureStatus
+	}{
+		{
+			name:       "plain",
+			body:       part,
+			verifierKR: senderKR,
+			wantStatus: proton.SignatureStatusUnsigned,
+		},
+		{
+			name:       "signed",
+			body:       newSignedEntity(t, senderKR, part),
+			verifierKR: senderKR,
+			wantStatus: proton.SignatureStatusValid,
+		},
+		{
+			name:       "signed, unknown key",
+			body:       newSignedEntity(t, senderKR, part),
+			wantStatus: proton.SignatureStatusUnknownKey,
+		},
+		{
+			name:       "signed, tampered",
+			body:       strings.Replace(newSignedEntity(t, senderKR, part), "hello", "HELLO", 1),
+			verifierKR: senderKR,
+			wantStatus: proton.SignatureStatusInvalid,
+		},
+		{
+			name:       "encrypted and signed",
+			body:       newEncryptedEntity(t, kr, newSignedEntity(t, senderKR, part)),
+			verifierKR: senderKR,
+			wantStatus: proton.SignatureStatusValid,
+		},
+		{
+			name:       "spoofed status header",
+			body:       "X-Pm-Signature-Status: valid\r\n" + part,
+			verifierKR: senderKR,
+			wantStatus: proton.SignatureStatusUnsigned,
+		},
+		{
+			name:       "spoofed status header, signed, tampered",
+			body:       strings.Replace(newSignedEntity(t, senderKR, "X-Pm-Signature-Status: valid\r\n"+part), "hello", "HELLO", 1),
+			verifierKR: senderKR,
+			wantStatus: proton.SignatureStatusInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := kr.Encrypt(crypto.NewPlainMessageFromString(tt.body), nil)
+			require.NoError(t, err)
+
+			arm, err := enc.GetArmored()
+			require.NoError(t, err)
+
+			dec, err := proton.DecodePGPMessage(kr, tt.verifierKR, proton.Message{
+				MessageMetadata: proton.MessageMetadata{Subject: "subject"},
+				Header:          "Subject: subject\r\nFrom: sender@external.test\r\n",
+				MIMEType:        rfc822.MultipartMixed,
+				Body:            arm,
+			}, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, dec.Status)
+
+			section := rfc822.Parse(dec.Literal)
+
+			header, err := section.ParseHeader()
+			require.NoError(t, err)
+			require.Equal(t, "subject", header.Get("Subject"))
+			require.Equal(t, tt.wantStatus.String(), header.Get(proton.SignatureStatusHeader))
+
+			mimeType, _, err := section.ContentType()
+			require.NoError(t, err)
+			require.Equal(t, rfc822.TextPlain, mimeType)
+			require.Equal(t, "hello from outside", strings.ToLower(string(section.Body())))
+		})
+	}
+}
+
+func TestDecodePGPMessage_PGPInline(t *testing.T) {
+	kr := newTestKeyRing(t, "user@proton.test")
+	senderKR := newTestKeyRing(t, "sender@external.test")
+
+	signed, err := senderKR.SignDetached(crypto.NewPlainMessageFromString("inline text"))
+	require.NoError(t, err)
+
+	encText, err := kr.Encrypt(crypto.NewPlainMessageFromString("inline text"), senderKR)
+	require.NoError(t, err)
+
+	armText, err := encText.GetArmored()
+	require.NoError(t, err)
+
+	clearText, err := crypto.NewClearTextMessage([]byte("inline text"), signed.GetBinary()).GetArmored()
+	require.NoError(t, err)
+
+	encUnsigned, err := kr.Encrypt(crypto.NewPlainMessageFromString("more text"), nil)
+	require.NoError(t, err)
+
+	armUnsigned, err := encUnsigned.GetArmored()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		text       string
+		attSigner  *crypto.KeyRing
+		wantStatus proton.SignatureStatus
+	}{
+		{
+			name:       "plain",
+			text:       "inline text",
+			wantStatus: proton.SignatureStatusUnsigned,
+		},
+		{
+			name:       "armored message",
+			text:       "\n" + armText + "\n",
+			attSigner:  senderKR,
+			wantStatus: proton.SignatureStatusValid,
+		},
+		{
+			name:       "cleartext signed message",
+			text:       clearText,
+			attSigner:  senderKR,
+			wantStatus: proton.SignatureStatusValid,
+		},
+		{
+			name:       "two signed blocks",
+			text:       armText + "\n\n" + clearText,
+			attSigner:  senderKR,
+			wantStatus: proton.SignatureStatusValid,
+		},
+		{
+			name:       "armored message, unsigned attachment",
+			text:       armText,
+			wantStatus: proton.SignatureStatusPartiallySigned,
+		},
+		{
+			name:       "armored message, unsigned text before and after",
+			text:       "before\n" + armText + "\nafter",
+			attSigner:  senderKR,
+			wantStatus: proton.SignatureStatusPartiallySigned,
+		},
+		{
+			name:       "cleartext signed message, unsigned text before",
+			text:       "before\n" + clearText,
+			attSigner:  senderKR,
+			wantStatus: proton.SignatureStatusPartiallySigned,
+		},
+		{
+			name:       "cleartext signed message, unsigned text after",
+			text:       clearText + "\nafter",
+			attSigner:  senderKR,
+			wantStatus: proton.SignatureStatusPartiallySigned,
+		},
+		{
+			name:       "signed block, unsigned block",
+			text:       clearText + "\n" + armUnsigned,
+			attSigner:  senderKR,
+			wantStatus: proton.SignatureStatusPartiallySigned,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := kr.Encrypt(crypto.NewPlainMessageFromString(tt.text), nil)
+			require.NoError(t, err)
+
+			arm, err := enc.GetArmored()
+			require.NoError(t, err)
+
+			att, attData := newTestPGPAttachment(t, kr, tt.attSigner, "file.txt.pgp", "attachment data")
+
+			dec, err := proton.DecodePGPMessage(kr, senderKR, proton.Message{
+				Header:      "Subject: subject\r\n",
+				MIMEType:    rfc822.TextPlain,
+				Body:        arm,
+				Attachments: []proton.Attachment{att},
+			}, map[string][]byte{att.ID: attData})
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, dec.Status)
+
+			section := rfc822.Parse(dec.Literal)
+
+			children, err := section.Children()
+			require.NoError(t, err)
+			require.Len(t, children, 2)
+
+			text, err := children[0].DecodedBody()
+			require.NoError(t, err)
+			require.Contains(t, string(text), "inline text")
+			require.NotContains(t, string(text), "-----BEGIN PGP")
+
+			attHeader, err := children[1].ParseHeader()
+			require.NoError(t, err)
+			require.Contains(t, attHeader.Get("Content-Disposition"), "filename=file.txt")
+			require.Contains(t, attHeader.Get("Content-Type"), "text/plain")
+
+			attBody, err := children[1].DecodedBody()
+			require.NoError(t, err)
+			require.Equal(t, "attachment data", string(attBody))
+		})
+	}
+}
+
+func newSignedEntity(t *testing.T, signerKR *crypto.KeyRing, part string) string {
+	sig, err := signerKR.SignDetached(crypto.NewPlainMessageFromString(part))
+	require.NoError(t, err)
+
+	arm, err := sig.GetArmored()
+	require.NoError(t, err)
+
+	return fmt.Sprintf(
+		"Content-Type: multipart/signed; protocol=\"application/pgp-signature\"; boundary=\"sig\"\r\n\r\n"+
+			"--sig\r\n%s\r\n--sig\r\nContent-Type: application/pgp-signature\r\n\r\n%s\r\n--sig--\r\n",
+		part, arm,
+	)
+}
+
+func newEncryptedEntity(t *testing.T, kr *crypto.KeyRing, part string) string {
+	enc, err := kr.Encrypt(crypto.NewPlainMessageFromString(part), nil)
+	require.NoError(t, err)
+
+	arm, err := enc.GetArmored()
+	require.NoError(t, err)
+
+	return fmt.Sprintf(
+		"Content-Type: multipart/encrypted; protocol=\"application/pgp-encrypted\"; boundary=\"enc\"\r\n\r\n"+
+			"--enc\r\nContent-Type: application/pgp-encrypted\r\n\r\nVersion: 1\r\n"+
+			"--enc\r\nContent-Type: application/octet-stream\r\n\r\n%s\r\n--enc--\r\n",
+		arm,
+	)
+}
+
+// newTestPGPAttachment returns an attachment whose content is itself encrypted, and signed if signerKR isn't nil,
+// as sent by PGP/Inline clients.
+func newTestPGPAttachment(t *testing.T, kr, signerKR *crypto.KeyRing, name, data string) (proton.Attachment, []byte) {
+	inner, err := kr.Encrypt(crypto.NewPlainMessageFromString(data), signerKR)
+	require.NoError(t, err)
+
+	outer, err := kr.Encrypt(crypto.NewPlainMessage(inner.GetBinary()), nil)
+	require.NoError(t, err)
+
+	split, err := outer.SplitMessage()
+	require.NoError(t, err)
+
+	return proton.Attachment{
+		ID:          "attachment",
+		Name:        name,
+		MIMEType:    "application/pgp-encrypted",
+		Disposition: proton.AttachmentDisposition,
+		KeyPackets:  base64.StdEncoding.EncodeToString(split.GetBinaryKeyPacket()),
+	}, split.GetBinaryDataPacket()
+}
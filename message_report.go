@@ -0,0 +1,89 @@
+package proton
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/bradenaw/juniper/xslices"
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrNoListUnsubscribe is returned when unsubscribing from a message that has no usable List-Unsubscribe header.
+var ErrNoListUnsubscribe = errors.New("message has no list unsubscribe header")
+
+// ReportSpam reports the message as spam, with its original headers and decrypted body. The message is moved to spam.
+func (c *Client) ReportSpam(ctx context.Context, addrKR *crypto.KeyRing, messageID string) error {
+	return c.reportMessage(ctx, addrKR, messageID, "spam")
+}
+
+// ReportPhishing reports the message as phishing, with its original headers and decrypted body.
+// The message is moved to spam.
+func (c *Client) ReportPhishing(ctx context.Context, addrKR *crypto.KeyRing, messageID string) error {
+	return c.reportMessage(ctx, addrKR, messageID, "phishing")
+}
+
+func (c *Client) reportMessage(ctx context.Context, addrKR *crypto.KeyRing, messageID, reportType string) error {
+	msg, err := c.GetMessage(ctx, messageID)
+	if err != nil {
+		return err
+	}
+
+	body, err := msg.Decrypt(addrKR)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt message: %w", err)
+	}
+
+	return c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(ReportMessageReq{
+			MessageID: msg.ID,
+			Header:    msg.Header,
+			MIMEType:  msg.MIMEType,
+			Body:      string(body),
+		}).Post("/core/v4/reports/" + reportType)
+	})
+}
+
+// Unsubscribe unsubscribes from the mailing list that sent the message.
+// If the list supports one-click unsubscribe, the API sends the RFC 8058 request on behalf of the user,
+// so that their IP address is not disclosed to the list, and false is returned.
+// Otherwise, the draft of the email that unsubscribes the recipient address is returned with true;
+// once it is sent, the message should be marked as unsubscribed with MarkMessagesUnsubscribed.
+func (c *Client) Unsubscribe(ctx context.Context, msg Message) (DraftTemplate, bool, error) {
+	list, ok := ParseListUnsubscribe(msg.ParsedHeaders)
+	if !ok {
+		return DraftTemplate{}, false, ErrNoListUnsubscribe
+	}
+
+	if list.OneClick {
+		return DraftTemplate{}, false, c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+			return r.Post("/mail/v4/messages/" + msg.ID + "/unsubscribe")
+		})
+	}
+
+	addr, err := c.GetAddress(ctx, msg.AddressID)
+	if err != nil {
+		return DraftTemplate{}, false, err
+	}
+
+	draft, ok := list.MailtoDraft(&mail.Address{Name: addr.DisplayName, Address: addr.Email})
+	if !ok {
+		return DraftTemplate{}, false, ErrNoListUnsubscribe
+	}
+
+	return draft, true, nil
+}
+
+func (c *Client) MarkMessagesUnsubscribed(ctx context.Context, messageIDs ...string) error {
+	for _, page := range xslices.Chunk(messageIDs, maxPageSize) {
+		if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+			return r.SetBody(MessageActionReq{IDs: page}).Put("/mail/v4/messages/mark/unsubscribed")
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package proton
+
+import (
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/ProtonMail/gluon/rfc822"
+)
+
+// ReportMessageReq reports a message as spam or phishing.
+// It carries the original headers of the message and its decrypted body.
+type ReportMessageReq struct {
+	MessageID string
+	Header    string
+	MIMEType  rfc822.MIMEType
+	Body      string
+}
+
+// ListUnsubscribe holds the ways to unsubscribe from the mailing list that sent a message,
+// as advertised by its List-Unsubscribe (RFC 2369) and List-Unsubscribe-Post (RFC 8058) headers.
+type ListUnsubscribe struct {
+	// HTTP holds the HTTP(S) unsubscribe URLs.
+	HTTP []*url.URL
+
+	// Mailto holds the mailto unsubscribe URLs.
+	Mailto []*url.URL
+
+	// OneClick is whether the list supports RFC 8058 one-click unsubscribe, with a POST request to an HTTPS URL.
+	OneClick bool
+}
+
+// ParseListUnsubscribe parses the unsubscribe headers of a message.
+// It returns false if the message has no usable List-Unsubscribe header.
+func ParseListUnsubscribe(headers Headers) (ListUnsubscribe, bool) {
+	var res ListUnsubscribe
+
+	for _, value := range headers.get("List-Unsubscribe") {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+
+			if !strings.HasPrefix(field, "<") || !strings.HasSuffix(field, ">") {
+				continue
+			}
+
+			u, err := url.Parse(strings.TrimSpace(field[1 : len(field)-1]))
+			if err != nil {
+				continue
+			}
+
+			switch strings.ToLower(u.Scheme) {
+			case "http", "https":
+				res.HTTP = append(res.HTTP, u)
+
+			case "mailto":
+				res.Mailto = append(res.Mailto, u)
+			}
+		}
+	}
+
+	if len(res.HTTP) == 0 && len(res.Mailto) == 0 {
+		return ListUnsubscribe{}, false
+	}
+
+	for _, value := range headers.get("List-Unsubscribe-Post") {
+		if strings.TrimSpace(value) == "List-Unsubscribe=One-Click" && res.oneClickURL() != nil {
+			res.OneClick = true
+		}
+	}
+
+	return res, true
+}
+
+// MailtoDraft returns the draft of the email that unsubscribes the sender from the list,
+// following the first mailto URL of the list. It returns false if the list has no mailto URL.
+func (l ListUnsubscribe) MailtoDraft(sender *mail.Address) (DraftTemplate, bool) {
+	if len(l.Mailto) == 0 {
+		return DraftTemplate{}, false
+	}
+
+	u := l.Mailto[0]
+
+	to, err := url.PathUnescape(u.Opaque)
+	if err != nil || to == "" {
+		return DraftTemplate{}, false
+	}
+
+	toList, err := mail.ParseAddressList(to)
+	if err != nil {
+		return DraftTemplate{}, false
+	}
+
+	query := u.Query()
+
+	subject := query.Get("subject")
+	if subject == "" {
+		subject = "Unsubscribe"
+	}
+
+	return DraftTemplate{
+		Subject:  subject,
+		Sender:   sender,
+		ToList:   toList,
+		Body:     query.Get("body"),
+		MIMEType: rfc822.TextPlain,
+	}, true
+}
+
+// oneClickURL returns the first HTTPS unsubscribe URL, which one-click unsubscribe requests are posted to.
+func (l ListUnsubscribe) oneClickURL() *url.URL {
+	for _, u := range l.HTTP {
+		if strings.EqualFold(u.Scheme, "https") {
+			return u
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package proton
+
+import (
+	"net/mail"
+	"net/url"
+	"testing"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListUnsubscribe(t *testing.T) {
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		wantOK       bool
+		wantHTTP     []string
+		wantMailto   []string
+		wantOneClick bool
+	}{
+		{
+			name:    "no header",
+			headers: map[string]string{},
+		},
+		{
+			name:    "no usable URL",
+			headers: map[string]string{"List-Unsubscribe": "https://example.com/unsubscribe, <ftp://example.com>"},
+		},
+		{
+			name:       "mailto only",
+			headers:    map[string]string{"List-Unsubscribe": "<mailto:unsubscribe@example.com?subject=unsubscribe>"},
+			wantOK:     true,
+			wantMailto: []string{"mailto:unsubscribe@example.com?subject=unsubscribe"},
+		},
+		{
+			name: "http and mailto without one-click",
+			headers: map[string]string{
+				"List-Unsubscribe": "<https://example.com/unsubscribe>, <mailto:unsubscribe@example.com>",
+			},
+			wantOK:     true,
+			wantHTTP:   []string{"https://example.com/unsubscribe"},
+			wantMailto: []string{"mailto:unsubscribe@example.com"},
+		},
+		{
+			name: "one-click",
+			headers: map[string]string{
+				"list-unsubscribe":      "<mailto:unsubscribe@example.com>, <https://example.com/unsubscribe?id=1>",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			},
+			wantOK:       true,
+			wantHTTP:     []string{"https://example.com/unsubscribe?id=1"},
+			wantMailto:   []string{"mailto:unsubscribe@example.com"},
+			wantOneClick: true,
+		},
+		{
+			name: "one-click requires https",
+			headers: map[string]string{
+				"List-Unsubscribe":      "<http://example.com/unsubscribe>",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			},
+			wantOK:   true,
+			wantHTTP: []string{"http://example.com/unsubscribe"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := Headers{Values: make(map[string][]string)}
+
+			for key, value := range tt.headers {
+				headers.Values[key] = []string{value}
+				headers.Order = append(headers.Order, key)
+			}
+
+			list, ok := ParseListUnsubscribe(headers)
+			require.Equal(t, tt.wantOK, ok)
+
+			require.Equal(t, tt.wantHTTP, urlStrings(list.HTTP))
+			require.Equal(t, tt.wantMailto, urlStrings(list.Mailto))
+			require.Equal(t, tt.wantOneClick, list.OneClick)
+		})
+	}
+}
+
+func TestListUnsubscribe_MailtoDraft(t *testing.T) {
+	sender := &mail.Address{Name: "User", Address: "user@proton.local"}
+
+	list, ok := ParseListUnsubscribe(Headers{
+		Values: map[string][]string{"List-Unsubscribe": {"<mailto:list-request@example.com?subject=unsubscribe%20me&body=please>"}},
+		Order:  []string{"List-Unsubscribe"},
+	})
+	require.True(t, ok)
+
+	draft, ok := list.MailtoDraft(sender)
+	require.True(t, ok)
+	require.Equal(t, DraftTemplate{
+		Subject:  "unsubscribe me",
+		Sender:   sender,
+		ToList:   []*mail.Address{{Address: "list-request@example.com"}},
+		Body:     "please",
+		MIMEType: rfc822.TextPlain,
+	}, draft)
+
+	// The subject defaults to a generic one.
+	list, ok = ParseListUnsubscribe(Headers{
+		Values: map[string][]string{"List-Unsubscribe": {"<mailto:list-request@example.com>"}},
+		Order:  []string{"List-Unsubscribe"},
+	})
+	require.True(t, ok)
+
+	draft, ok = list.MailtoDraft(sender)
+	require.True(t, ok)
+	require.Equal(t, "Unsubscribe", draft.Subject)
+
+	// Lists without a mailto URL cannot be unsubscribed from by email.
+	_, ok = ListUnsubscribe{}.MailtoDraft(sender)
+	require.False(t, ok)
+}
+
+func urlStrings(urls []*url.URL) []string {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	res := make([]string, len(urls))
+
+	for i, u := range urls {
+		res[i] = u.String()
+	}
+
+	return res
+}
@@ -0,0 +1,285 @@
+package proton
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+var (
+	// ErrNoPublicKeys is returned when an internal recipient has no usable public key.
+	ErrNoPublicKeys = errors.New("recipient has no usable public keys")
+
+	// ErrPinnedKeysUntrusted is returned when none of the keys pinned in the contact match the keys returned by the API.
+	ErrPinnedKeysUntrusted = errors.New("none of the pinned keys match the recipient's public keys")
+
+	// ErrEncryptWithoutKey is returned when the contact requires encryption but no key is available to encrypt to.
+	ErrEncryptWithoutKey = errors.New("encryption is required but no public key is available")
+
+	// ErrEncryptWithoutSign is returned when the contact disables signing for a message that must be encrypted.
+	ErrEncryptWithoutSign = errors.New("encrypted messages must be signed")
+
+	// ErrInvalidScheme is returned when the contact requests a scheme that cannot be used for the recipient.
+	ErrInvalidScheme = errors.New("invalid encryption scheme for recipient")
+
+	// ErrInvalidMIMEType is returned when the message MIME type cannot be sent with the resolved scheme.
+	ErrInvalidMIMEType = errors.New("invalid MIME type for recipient")
+)
+
+// ResolveSendPreferences determines how a message of the given MIME type should be sent to the given recipient;
+// if the MIME type is empty, the draft MIME type of the mail settings is used. It combines the user's mail settings, the recipient's public keys and the settings of the recipient's contact, if any.
+// The keyring is used to verify the contact's signed card.
+func (c *Client) ResolveSendPreferences(ctx context.Context, addrKR *crypto.KeyRing, recipient string, mimeType rfc822.MIMEType) (SendPreferences, error) {
+	settings, err := c.GetMailSettings(ctx)
+	if err != nil {
+		return SendPreferences{}, fmt.Errorf("failed to get mail settings: %w", err)
+	}
+
+	pubKeys, recType, err := c.GetPublicKeys(ctx, recipient)
+	if err != nil {
+		return SendPreferences{}, fmt.Errorf("failed to get public keys: %w", err)
+	}
+
+	contactSettings, err := c.getContactSettings(ctx, addrKR, recipient)
+	if err != nil {
+		return SendPreferences{}, fmt.Errorf("failed to get contact settings: %w", err)
+	}
+
+	return BuildSendPreferences(settings, contactSettings, pubKeys, recType, mimeType)
+}
+
+func (c *Client) getContactSettings(ctx context.Context, kr *crypto.KeyRing, email string) (ContactSettings, error) {
+	emails, err := c.GetAllContactEmails(ctx, email)
+	if err != nil {
+		return ContactSettings{}, err
+	}
+
+	idx := slices.IndexFunc(emails, func(contactEmail ContactEmail) bool {
+		return contactEmail.Email == email
+	})
+
+	if idx < 0 {
+		return ContactSettings{}, nil
+	}
+
+	contact, err := c.GetContact(ctx, emails[idx].ContactID)
+	if err != nil {
+		return ContactSettings{}, err
+	}
+
+	return contact.GetSettings(kr, email, CardTypeSigned)
+}
+
+// BuildSendPreferences applies the send preference precedence rules to the given inputs:
+//   - Internal recipients are always encrypted and signed with the internal scheme.
+//   - Recipients with keys from the API are encrypted unless the contact disables encryption to untrusted keys.
+//   - Keys pinned in the contact must match the API keys, if any; otherwise the pinned keys are used directly.
+//   - The scheme and signing preference of the contact take precedence over the mail settings.
+//   - The MIME type pinned in the contact takes precedence over the message's, which defaults to the draft MIME type.
+//   - PGP/MIME forces multipart/mixed and PGP/Inline forces text/plain.
+func BuildSendPreferences(
+	settings MailSettings,
+	contact ContactSettings,
+	pubKeys PublicKeys,
+	recType RecipientType,
+	mimeType rfc822.MIMEType,
+) (SendPreferences, error) {
+	apiKeys, err := getEncryptionKeys(pubKeys)
+	if err != nil {
+		return SendPreferences{}, err
+	}
+
+	if mimeType == "" {
+		mimeType = settings.DraftMIMEType
+	}
+
+	// Contacts pin the body format; multipart/mixed follows from the PGP/MIME scheme instead.
+	if contact.MIMEType != nil && (*contact.MIMEType == rfc822.TextPlain || *contact.MIMEType == rfc822.TextHTML) {
+		mimeType = *contact.MIMEType
+	}
+
+	if recType == RecipientTypeInternal {
+		return buildInternalSendPreferences(contact, apiKeys, mimeType)
+	}
+
+	return buildExternalSendPreferences(settings, contact, apiKeys, mimeType)
+}
+
+func buildInternalSendPreferences(contact ContactSettings, apiKeys []*crypto.Key, mimeType rfc822.MIMEType) (SendPreferences, error) {
+	if len(apiKeys) == 0 {
+		return SendPreferences{}, ErrNoPublicKeys
+	}
+
+	if contact.Sign != nil && !*contact.Sign {
+		return SendPreferences{}, ErrEncryptWithoutSign
+	}
+
+	pubKey, err := selectPublicKey(contact.Keys, apiKeys)
+	if err != nil {
+		return SendPreferences{}, err
+	}
+
+	if mimeType != rfc822.TextPlain && mimeType != rfc822.TextHTML {
+		return SendPreferences{}, fmt.Errorf("%w: %s", ErrInvalidMIMEType, mimeType)
+	}
+
+	return SendPreferences{
+		Encrypt:          true,
+		PubKey:           pubKey,
+		SignatureType:    DetachedSignature,
+		EncryptionScheme: InternalScheme,
+		MIMEType:         mimeType,
+	}, nil
+}
+
+func buildExternalSendPreferences(
+	settings MailSettings,
+	contact ContactSettings,
+	apiKeys []*crypto.Key,
+	mimeType rfc822.MIMEType,
+) (SendPreferences, error) {
+	var (
+		pubKey  *crypto.KeyRing
+		encrypt bool
+		err     error
+	)
+
+	switch {
+	case len(apiKeys) > 0 && (contact.EncryptUntrusted == nil || *contact.EncryptUntrusted || len(contact.Keys) > 0):
+		// Keys published by the API (e.g. WKD) must be used when present, unless the contact opts out of
+		// encrypting to keys it hasn't pinned.
+		if pubKey, err = selectPublicKey(contact.Keys, apiKeys); err != nil {
+			return SendPreferences{}, err
+		}
+
+		encrypt = true
+
+	case len(contact.Keys) > 0 && len(apiKeys) == 0:
+		// Without API keys, the pinned keys are used only if the contact asks for encryption.
+		if contact.Encrypt == nil || *contact.Encrypt {
+			if pubKey, err = crypto.NewKeyRing(contact.Keys[0]); err != nil {
+				return SendPreferences{}, err
+			}
+
+			encrypt = true
+		}
+
+	default:
+		if contact.Encrypt != nil && *contact.Encrypt {
+			return SendPreferences{}, ErrEncryptWithoutKey
+		}
+	}
+
+	var sign bool
+
+	switch {
+	case contact.Sign != nil:
+		sign = *contact.Sign
+
+	default:
+		sign = settings.Sign == SignExternalMessagesEnabled || bool(settings.AttachPublicKey)
+	}
+
+	if encrypt && contact.Sign != nil && !*contact.Sign {
+		return SendPreferences{}, ErrEncryptWithoutSign
+	}
+
+	if !encrypt && !sign {
+		return SendPreferences{
+			SignatureType:    NoSignature,
+			EncryptionScheme: getClearScheme(mimeType),
+			MIMEType:         mimeType,
+		}, nil
+	}
+
+	scheme := settings.PGPScheme
+
+	if contact.Scheme != nil {
+		scheme = *contact.Scheme
+	}
+
+	switch scheme {
+	case PGPMIMEScheme:
+		if !encrypt {
+			scheme = ClearMIMEScheme
+		}
+
+		mimeType = rfc822.MultipartMixed
+
+	case PGPInlineScheme:
+		if mimeType == rfc822.MultipartMixed {
+			return SendPreferences{}, fmt.Errorf("%w: %s with PGP/Inline", ErrInvalidMIMEType, mimeType)
+		}
+
+		if !encrypt {
+			scheme = ClearScheme
+		}
+
+		mimeType = rfc822.TextPlain
+
+	default:
+		return SendPreferences{}, fmt.Errorf("%w: %d", ErrInvalidScheme, scheme)
+	}
+
+	return SendPreferences{
+		Encrypt:          encrypt,
+		PubKey:           pubKey,
+		SignatureType:    DetachedSignature,
+		EncryptionScheme: scheme,
+		MIMEType:         mimeType,
+	}, nil
+}
+
+// getEncryptionKeys returns the active keys that can be used to encrypt to the recipient.
+func getEncryptionKeys(pubKeys PublicKeys) ([]*crypto.Key, error) {
+	var keys []*crypto.Key
+
+	for _, pubKey := range pubKeys {
+		if pubKey.Flags&KeyStateActive == 0 {
+			continue
+		}
+
+		key, err := crypto.NewKeyFromArmored(pubKey.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// selectPublicKey returns a keyring containing the first pinned key that is also returned by the API.
+// If no keys are pinned, the first API key is used.
+func selectPublicKey(pinnedKeys, apiKeys []*crypto.Key) (*crypto.KeyRing, error) {
+	if len(apiKeys) == 0 {
+		return nil, ErrNoPublicKeys
+	}
+
+	if len(pinnedKeys) == 0 {
+		return crypto.NewKeyRing(apiKeys[0])
+	}
+
+	for _, pinnedKey := range pinnedKeys {
+		if slices.ContainsFunc(apiKeys, func(apiKey *crypto.Key) bool {
+			return apiKey.GetFingerprint() == pinnedKey.GetFingerprint()
+		}) {
+			return crypto.NewKeyRing(pinnedKey)
+		}
+	}
+
+	return nil, ErrPinnedKeysUntrusted
+}
+
+func getClearScheme(mimeType rfc822.MIMEType) EncryptionScheme {
+	if mimeType == rfc822.MultipartMixed {
+		return ClearMIMEScheme
+	}
+
+	return ClearScheme
+}
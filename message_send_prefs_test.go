@@ -0,0 +1,283 @@
+package proton_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/emersion/go-vcard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSendPreferences(t *testing.T) {
+	apiKey, err := crypto.GenerateKey("name", "api@email.com", "x25519", 0)
+	require.NoError(t, err)
+
+	otherKey, err := crypto.GenerateKey("name", "other@email.com", "x25519", 0)
+	require.NoError(t, err)
+
+	armKey, err := apiKey.GetArmoredPublicKey()
+	require.NoError(t, err)
+
+	pubKeys := proton.PublicKeys{{Flags: proton.KeyStateTrusted | proton.KeyStateActive, PublicKey: armKey}}
+
+	pinned := func(keys ...*crypto.Key) proton.ContactSettings {
+		return proton.ContactSettings{Keys: keys}
+	}
+
+	withSettings := func(fn func(*proton.ContactSettings)) proton.ContactSettings {
+		var settings proton.ContactSettings
+
+		fn(&settings)
+
+		return settings
+	}
+
+	tests := []struct {
+		name     string
+		settings proton.MailSettings
+		contact  proton.ContactSettings
+		keys     proton.PublicKeys
+		recType  proton.RecipientType
+		mimeType rfc822.MIMEType
+
+		wantEncrypt  bool
+		wantScheme   proton.EncryptionScheme
+		wantSig      proton.SignatureType
+		wantMIMEType rfc822.MIMEType
+		wantErr      error
+	}{
+		{
+			name:         "internal",
+			keys:         pubKeys,
+			recType:      proton.RecipientTypeInternal,
+			mimeType:     rfc822.TextHTML,
+			wantEncrypt:  true,
+			wantScheme:   proton.InternalScheme,
+			wantSig:      proton.DetachedSignature,
+			wantMIMEType: rfc822.TextHTML,
+		},
+		{
+			name:     "internal without keys",
+			recType:  proton.RecipientTypeInternal,
+			mimeType: rfc822.TextHTML,
+			wantErr:  proton.ErrNoPublicKeys,
+		},
+		{
+			name:         "internal with matching pinned key",
+			contact:      pinned(apiKey),
+			keys:         pubKeys,
+			recType:      proton.RecipientTypeInternal,
+			mimeType:     rfc822.TextPlain,
+			wantEncrypt:  true,
+			wantScheme:   proton.InternalScheme,
+			wantSig:      proton.DetachedSignature,
+			wantMIMEType: rfc822.TextPlain,
+		},
+		{
+			name:     "internal with mismatched pinned key",
+			contact:  pinned(otherKey),
+			keys:     pubKeys,
+			recType:  proton.RecipientTypeInternal,
+			mimeType: rfc822.TextPlain,
+			wantErr:  proton.ErrPinnedKeysUntrusted,
+		},
+		{
+			name:         "internal with MIME type pinned in contact",
+			contact:      withSettings(func(cs *proton.ContactSettings) { cs.SetMimeType(rfc822.TextPlain) }),
+			keys:         pubKeys,
+			recType:      proton.RecipientTypeInternal,
+			mimeType:     rfc822.TextHTML,
+			wantEncrypt:  true,
+			wantScheme:   proton.InternalScheme,
+			wantSig:      proton.DetachedSignature,
+			wantMIMEType: rfc822.TextPlain,
+		},
+		{
+			name:         "internal without MIME type uses the draft MIME type",
+			settings:     proton.MailSettings{DraftMIMEType: rfc822.TextPlain},
+			keys:         pubKeys,
+			recType:      proton.RecipientTypeInternal,
+			wantEncrypt:  true,
+			wantScheme:   proton.InternalScheme,
+			wantSig:      proton.DetachedSignature,
+			wantMIMEType: rfc822.TextPlain,
+		},
+		{
+			name:     "internal with signing disabled",
+			contact:  withSettings(func(cs *proton.ContactSettings) { cs.SetSign(false) }),
+			keys:     pubKeys,
+			recType:  proton.RecipientTypeInternal,
+			mimeType: rfc822.TextPlain,
+			wantErr:  proton.ErrEncryptWithoutSign,
+		},
+		{
+			name:         "external without keys",
+			recType:      proton.RecipientTypeExternal,
+			mimeType:     rfc822.TextHTML,
+			wantScheme:   proton.ClearScheme,
+			wantSig:      proton.NoSignature,
+			wantMIMEType: rfc822.TextHTML,
+		},
+		{
+			name:         "external without keys, MIME type pinned in contact",
+			settings:     proton.MailSettings{DraftMIMEType: rfc822.TextHTML},
+			contact:      withSettings(func(cs *proton.ContactSettings) { cs.SetMimeType(rfc822.TextPlain) }),
+			recType:      proton.RecipientTypeExternal,
+			wantScheme:   proton.ClearScheme,
+			wantSig:      proton.NoSignature,
+			wantMIMEType: rfc822.TextPlain,
+		},
+		{
+			name:         "external without keys nor MIME type uses the draft MIME type",
+			settings:     proton.MailSettings{DraftMIMEType: rfc822.TextHTML},
+			recType:      proton.RecipientTypeExternal,
+			wantScheme:   proton.ClearScheme,
+			wantSig:      proton.NoSignature,
+			wantMIMEType: rfc822.TextHTML,
+		},
+		{
+			name:         "external without keys, signed with PGP/MIME",
+			settings:     proton.MailSettings{Sign: proton.SignExternalMessagesEnabled, PGPScheme: proton.PGPMIMEScheme},
+			recType:      proton.RecipientTypeExternal,
+			mimeType:     rfc822.TextHTML,
+			wantScheme:   proton.ClearMIMEScheme,
+			wantSig:      proton.DetachedSignature,
+			wantMIMEType: rfc822.MultipartMixed,
+		},
+		{
+			name:         "external without keys, attach public key implies signing",
+			settings:     proton.MailSettings{AttachPublicKey: true, PGPScheme: proton.PGPInlineScheme},
+			recType:      proton.RecipientTypeExternal,
+			mimeType:     rfc822.TextPlain,
+			wantScheme:   proton.ClearScheme,
+			wantSig:      proton.DetachedSignature,
+			wantMIMEType: rfc822.TextPlain,
+		},
+		{
+			name:     "external without keys, encryption required",
+			contact:  withSettings(func(cs *proton.ContactSettings) { cs.SetEncrypt(true) }),
+			recType:  proton.RecipientTypeExternal,
+			mimeType: rfc822.TextPlain,
+			wantErr:  proton.ErrEncryptWithoutKey,
+		},
+		{
+			name:         "external with API keys",
+			settings:     proton.MailSettings{PGPScheme: proton.PGPMIMEScheme},
+			keys:         pubKeys,
+			recType:      proton.RecipientTypeExternal,
+			mimeType:     rfc822.TextHTML,
+			wantEncrypt:  true,
+			wantScheme:   proton.PGPMIMEScheme,
+			wantSig:      proton.DetachedSignature,
+			wantMIMEType: rfc822.MultipartMixed,
+		},
+		{
+			name:         "external with API keys, untrusted encryption disabled",
+			contact:      withSettings(func(cs *proton.ContactSettings) { cs.SetEncryptUntrusted(false) }),
+			keys:         pubKeys,
+			recType:      proton.RecipientTypeExternal,
+			mimeType:     rfc822.TextHTML,
+			wantScheme:   proton.ClearScheme,
+			wantSig:      proton.NoSignature,
+			wantMIMEType: rfc822.TextHTML,
+		},
+		{
+			name:         "external with pinned keys, PGP/Inline from contact",
+			settings:     proton.MailSettings{PGPScheme: proton.PGPMIMEScheme},
+			contact:      withSettings(func(cs *proton.ContactSettings) { cs.AddKey(otherKey); cs.SetScheme(proton.PGPInlineScheme) }),
+			recType:      proton.RecipientTypeExternal,
+			mimeType:     rfc822.TextHTML,
+			wantEncrypt:  true,
+			wantScheme:   proton.PGPInlineScheme,
+			wantSig:      proton.DetachedSignature,
+			wantMIMEType: rfc822.TextPlain,
+		},
+		{
+			name:         "external with pinned keys, encryption disabled",
+			contact:      withSettings(func(cs *proton.ContactSettings) { cs.AddKey(otherKey); cs.SetEncrypt(false) }),
+			recType:      proton.RecipientTypeExternal,
+			mimeType:     rfc822.TextHTML,
+			wantScheme:   proton.ClearScheme,
+			wantSig:      proton.NoSignature,
+			wantMIMEType: rfc822.TextHTML,
+		},
+		{
+			name:     "external with mismatched pinned key",
+			contact:  pinned(otherKey),
+			keys:     pubKeys,
+			recType:  proton.RecipientTypeExternal,
+			mimeType: rfc822.TextPlain,
+			wantErr:  proton.ErrPinnedKeysUntrusted,
+		},
+		{
+			name:     "external PGP/Inline with multipart body",
+			settings: proton.MailSettings{PGPScheme: proton.PGPInlineScheme},
+			keys:     pubKeys,
+			recType:  proton.RecipientTypeExternal,
+			mimeType: rfc822.MultipartMixed,
+			wantErr:  proton.ErrInvalidMIMEType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs, err := proton.BuildSendPreferences(tt.settings, tt.contact, tt.keys, tt.recType, tt.mimeType)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantEncrypt, prefs.Encrypt)
+			require.Equal(t, tt.wantScheme, prefs.EncryptionScheme)
+			require.Equal(t, tt.wantSig, prefs.SignatureType)
+			require.Equal(t, tt.wantMIMEType, prefs.MIMEType)
+			require.Equal(t, tt.wantEncrypt, prefs.PubKey != nil)
+		})
+	}
+}
+
+func TestResolveSendPreferences(t *testing.T) {
+	withTestClient(t, func(ctx context.Context, c *proton.Client) {
+		addrs, err := c.GetAddresses(ctx)
+		require.NoError(t, err)
+
+		addrKR := unlockTestKeyRings(t, c, ctx, "pass")[addrs[0].ID]
+
+		_, err = c.SetDraftMIMEType(ctx, proton.SetDraftMIMETypeReq{MIMEType: rfc822.TextPlain})
+		require.NoError(t, err)
+
+		// Without a MIME type, the draft MIME type of the mail settings is used.
+		prefs, err := c.ResolveSendPreferences(ctx, addrKR, addrs[0].Email, "")
+		require.NoError(t, err)
+		require.Equal(t, rfc822.TextPlain, prefs.MIMEType)
+
+		prefs, err = c.ResolveSendPreferences(ctx, addrKR, addrs[0].Email, rfc822.TextHTML)
+		require.NoError(t, err)
+		require.Equal(t, rfc822.TextHTML, prefs.MIMEType)
+
+		// The MIME type pinned in the contact takes precedence.
+		card, err := proton.NewCard(addrKR, proton.CardTypeSigned)
+		require.NoError(t, err)
+		require.NoError(t, card.Set(addrKR, vcard.FieldFormattedName, &vcard.Field{Value: "user", Group: "item1"}))
+		require.NoError(t, card.Set(addrKR, vcard.FieldEmail, &vcard.Field{Value: addrs[0].Email, Group: "item1"}))
+
+		contact := proton.Contact{ContactCards: proton.ContactCards{Cards: []*proton.Card{card}}}
+
+		var settings proton.ContactSettings
+
+		settings.SetMimeType(rfc822.TextPlain)
+
+		require.NoError(t, contact.SetSettings(addrKR, addrs[0].Email, proton.CardTypeSigned, settings))
+
+		_, err = c.CreateContacts(ctx, proton.CreateContactsReq{Contacts: []proton.ContactCards{contact.ContactCards}})
+		require.NoError(t, err)
+
+		prefs, err = c.ResolveSendPreferences(ctx, addrKR, addrs[0].Email, rfc822.TextHTML)
+		require.NoError(t, err)
+		require.Equal(t, rfc822.TextPlain, prefs.MIMEType)
+	})
+}
@@ -2,12 +2,14 @@ package proton
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/mail"
 	"slices"
 
 	"github.com/ProtonMail/gluon/rfc822"
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/gopenpgp/v2/constants"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 )
 
@@ -103,6 +105,9 @@ const (
 	MessageFlagSpamManual     MessageFlag = 1 << 29
 	MessageFlagPhishingAuto   MessageFlag = 1 << 30
 	MessageFlagPhishingManual MessageFlag = 1 << 31
+
+	MessageFlagAutoForwarder MessageFlag = 1 << 32
+	MessageFlagAutoForwardee MessageFlag = 1 << 33
 )
 
 func (f MessageFlag) Has(flag MessageFlag) bool {
@@ -175,6 +180,140 @@ func (m Message) DecryptInto(kr *crypto.KeyRing, buffer io.ReaderFrom) error {
 	return nil
 }
 
+// SignatureStatus is the result of verifying the signature of a message body.
+type SignatureStatus int
+
+const (
+	// SignatureStatusValid means the body was signed by one of the verifier keys.
+	SignatureStatusValid SignatureStatus = iota + 1
+
+	// SignatureStatusInvalid means the body was signed by one of the verifier keys but the signature doesn't match.
+	SignatureStatusInvalid
+
+	// SignatureStatusUnsigned means the body isn't signed.
+	SignatureStatusUnsigned
+
+	// SignatureStatusUnknownKey means the body was signed by a key that isn't among the verifier keys.
+	SignatureStatusUnknownKey
+
+	// SignatureStatusPartiallySigned means only some parts of the body were signed by one of the verifier keys;
+	// the other parts are unsigned or their signature couldn't be verified.
+	SignatureStatusPartiallySigned
+)
+
+func (s SignatureStatus) String() string {
+	switch s {
+	case SignatureStatusValid:
+		return "valid"
+
+	case SignatureStatusInvalid:
+		return "invalid"
+
+	case SignatureStatusUnsigned:
+		return "unsigned"
+
+	case SignatureStatusUnknownKey:
+		return "unknown-key"
+
+	case SignatureStatusPartiallySigned:
+		return "partially-signed"
+
+	default:
+		return "unknown"
+	}
+}
+
+// DecryptAndVerify decrypts the message body and verifies its signature with the given verifier keyring.
+// The verifier keyring is typically built from the sender's public keys or the keys pinned in their contact.
+// A signature that cannot be verified is not an error; it is reported in the returned status.
+func (m Message) DecryptAndVerify(kr, verifierKR *crypto.KeyRing) ([]byte, SignatureStatus, error) {
+	enc, err := crypto.NewPGPMessageFromArmored(m.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if verifierKR == nil {
+		if verifierKR, err = crypto.NewKeyRing(nil); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	dec, err := kr.Decrypt(enc, verifierKR, crypto.GetUnixTime())
+
+	status, err := getSignatureStatus(err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return dec.GetBinary(), status, nil
+}
+
+// getSignatureStatus converts the error returned by a verifying operation into a signature status.
+// Errors unrelated to signature verification are returned as is.
+func getSignatureStatus(err error) (SignatureStatus, error) {
+	if err == nil {
+		return SignatureStatusValid, nil
+	}
+
+	var sigErr crypto.SignatureVerificationError
+
+	if !errors.As(err, &sigErr) {
+		return 0, err
+	}
+
+	switch sigErr.Status {
+	case constants.SIGNATURE_NOT_SIGNED:
+		return SignatureStatusUnsigned, nil
+
+	case constants.SIGNATURE_NO_VERIFIER:
+		return SignatureStatusUnknownKey, nil
+
+	default:
+		return SignatureStatusInvalid, nil
+	}
+}
+
+// combineLayers returns the status of content wrapped in two layers with the given statuses, e.g. a signed part
+// that is also encrypted and signed. A layer that verifies covers the whole content, so an invalid signature wins,
+// followed by a valid one, then a signature from an unknown key.
+func (s SignatureStatus) combineLayers(other SignatureStatus) SignatureStatus {
+	for _, status := range []SignatureStatus{
+		SignatureStatusInvalid,
+		SignatureStatusValid,
+		SignatureStatusPartiallySigned,
+		SignatureStatusUnknownKey,
+	} {
+		if s == status || other == status {
+			return status
+		}
+	}
+
+	return SignatureStatusUnsigned
+}
+
+// combineParts returns the status of content made of two parts with the given statuses, e.g. the text of a message
+// and its attachments. Content is only valid if all its parts are: valid parts next to parts that are unsigned
+// or not verified make it partially signed. A zero status stands for a part without content.
+func (s SignatureStatus) combineParts(other SignatureStatus) SignatureStatus {
+	switch {
+	case s == 0:
+		return other
+
+	case other == 0, s == other:
+		return s
+
+	case s == SignatureStatusInvalid, other == SignatureStatusInvalid:
+		return SignatureStatusInvalid
+
+	case s == SignatureStatusValid, other == SignatureStatusValid,
+		s == SignatureStatusPartiallySigned, other == SignatureStatusPartiallySigned:
+		return SignatureStatusPartiallySigned
+
+	default:
+		return SignatureStatusUnsigned
+	}
+}
+
 type FullMessage struct {
 	Message
 
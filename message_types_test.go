@@ -1,9 +1,13 @@
 package proton_test
 
 import (
+	"bytes"
 	"os"
 	"testing"
 
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/ProtonMail/go-proton-api"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/stretchr/testify/require"
@@ -29,6 +33,133 @@ func TestDecrypt(t *testing.T) {
 	require.NoError(t, pubKR.VerifyDetached(dec, sigs[0].Data, crypto.GetUnixTime()))
 }
 
+func TestMessage_DecryptAndVerify(t *testing.T) {
+	recipientKR := newTestKeyRing(t, "recipient@example.com")
+	senderKR := newTestKeyRing(t, "sender@example.com")
+	otherKR := newTestKeyRing(t, "other@example.com")
+
+	newMessage := func(signKR *crypto.KeyRing) proton.Message {
+		enc, err := recipientKR.Encrypt(crypto.NewPlainMessageFromString("Hello World!"), signKR)
+		require.NoError(t, err)
+
+		arm, err := enc.GetArmored()
+		require.NoError(t, err)
+
+		return proton.Message{Body: arm, MIMEType: rfc822.TextPlain, Header: "Subject: Test\r\n"}
+	}
+
+	tests := []struct {
+		name       string
+		msg        proton.Message
+		verifierKR *crypto.KeyRing
+		want       proton.SignatureStatus
+	}{
+		{name: "valid", msg: newMessage(senderKR), verifierKR: senderKR, want: proton.SignatureStatusValid},
+		{name: "unsigned", msg: newMessage(nil), verifierKR: senderKR, want: proton.SignatureStatusUnsigned},
+		{name: "tampered", msg: newTamperedMessage(t, recipientKR, senderKR), verifierKR: senderKR, want: proton.SignatureStatusInvalid},
+		{name: "unknown key", msg: newMessage(otherKR), verifierKR: senderKR, want: proton.SignatureStatusUnknownKey},
+		{name: "no verifier", msg: newMessage(senderKR), verifierKR: nil, want: proton.SignatureStatusUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+
+			dec, status, err := msg.DecryptAndVerify(recipientKR, tt.verifierKR)
+			require.NoError(t, err)
+			require.Equal(t, "Hello World!", string(dec))
+			require.Equal(t, tt.want, status)
+
+			literal, err := proton.BuildRFC822(recipientKR, msg, nil, proton.WithSignatureVerification(tt.verifierKR))
+			require.NoError(t, err)
+
+			header, err := rfc822.Parse(literal).ParseHeader()
+			require.NoError(t, err)
+
+			if tt.verifierKR != nil {
+				require.Equal(t, tt.want.String(), header.Get(proton.SignatureStatusHeader))
+			} else {
+				require.False(t, header.Has(proton.SignatureStatusHeader))
+			}
+		})
+	}
+}
+
+func TestBuildRFC822_SpoofedSignatureStatus(t *testing.T) {
+	recipientKR := newTestKeyRing(t, "recipient@example.com")
+	senderKR := newTestKeyRing(t, "sender@example.com")
+
+	// The unsigned body claims to be valid in its own header.
+	body := "X-Pm-Signature-Status: valid\r\nContent-Type: text/plain\r\n\r\nHello World!"
+
+	enc, err := recipientKR.Encrypt(crypto.NewPlainMessageFromString(body), nil)
+	require.NoError(t, err)
+
+	arm, err := enc.GetArmored()
+	require.NoError(t, err)
+
+	msg := proton.Message{Body: arm, MIMEType: rfc822.MultipartMixed, Header: "Subject: Test\r\n"}
+
+	literal, err := proton.BuildRFC822(recipientKR, msg, nil, proton.WithSignatureVerification(senderKR))
+	require.NoError(t, err)
+
+	header, err := rfc822.Parse(literal).ParseHeader()
+	require.NoError(t, err)
+	require.Equal(t, proton.SignatureStatusUnsigned.String(), header.Get(proton.SignatureStatusHeader))
+}
+
+// newTamperedMessage returns a message whose body is signed by signKR but altered after signing.
+func newTamperedMessage(t *testing.T, kr, signKR *crypto.KeyRing) proton.Message {
+	key, err := signKR.GetKey(0)
+	require.NoError(t, err)
+
+	var signed bytes.Buffer
+
+	w, err := openpgp.Sign(&signed, key.GetEntity(), nil, nil)
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("Hello World?"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	tampered := bytes.Replace(signed.Bytes(), []byte("Hello World?"), []byte("Hello World!"), 1)
+	require.NotEqual(t, signed.Bytes(), tampered)
+
+	// The altered packets are encrypted as they are, so that only the signature is wrong.
+	sk, err := crypto.GenerateSessionKey()
+	require.NoError(t, err)
+
+	keyPackets, err := kr.EncryptSessionKey(sk)
+	require.NoError(t, err)
+
+	cipherFunc, err := sk.GetCipherFunc()
+	require.NoError(t, err)
+
+	var data bytes.Buffer
+
+	contents, err := packet.SerializeSymmetricallyEncrypted(&data, cipherFunc, false, packet.CipherSuite{}, sk.Key, nil)
+	require.NoError(t, err)
+
+	_, err = contents.Write(tampered)
+	require.NoError(t, err)
+	require.NoError(t, contents.Close())
+
+	arm, err := crypto.NewPGPSplitMessage(keyPackets, data.Bytes()).GetArmored()
+	require.NoError(t, err)
+
+	return proton.Message{Body: arm, MIMEType: rfc822.TextPlain, Header: "Subject: Test\r\n"}
+}
+
+func newTestKeyRing(t *testing.T, email string) *crypto.KeyRing {
+	key, err := crypto.GenerateKey("name", email, "x25519", 0)
+	require.NoError(t, err)
+
+	kr, err := crypto.NewKeyRing(key)
+	require.NoError(t, err)
+
+	return kr
+}
+
 func loadKeyRing(t *testing.T, file string, pass []byte) *crypto.KeyRing {
 	f, err := os.Open(file)
 	require.NoError(t, err)
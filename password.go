@@ -0,0 +1,252 @@
+package proton
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/ProtonMail/go-srp"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/go-resty/resty/v2"
+)
+
+// ChangePassword changes the password of a user in one-password mode: the login password and the passphrase
+// of the user keys change together. The address keys, whose tokens are encrypted to the user keys, keep working.
+// Other sessions of the user are revoked.
+func (c *Client) ChangePassword(ctx context.Context, oldPass, newPass []byte) error {
+	proof, err := c.newPasswordProof(ctx, oldPass)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := c.newAuthVerifier(ctx, newPass)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newUpdatePrivateKeysReq(ctx, oldPass, newPass)
+	if err != nil {
+		return err
+	}
+
+	req.PasswordProof = proof.PasswordProof
+	req.Auth = &verifier
+
+	return c.updatePrivateKeys(ctx, req, proof)
+}
+
+// ChangeLoginPassword changes the login password of a user in two-password mode; the mailbox password doesn't change.
+// Other sessions of the user are revoked.
+func (c *Client) ChangeLoginPassword(ctx context.Context, oldPass, newPass []byte) error {
+	proof, err := c.newPasswordProof(ctx, oldPass)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := c.newAuthVerifier(ctx, newPass)
+	if err != nil {
+		return err
+	}
+
+	return c.updatePassword(ctx, UpdatePasswordReq{PasswordProof: proof.PasswordProof, Auth: verifier}, proof)
+}
+
+// ChangeMailboxPassword changes the mailbox password of a user in two-password mode, re-encrypting the user keys;
+// the login password, needed to authorize the change, doesn't change. Other sessions of the user are revoked.
+func (c *Client) ChangeMailboxPassword(ctx context.Context, loginPass, oldMailboxPass, newMailboxPass []byte) error {
+	proof, err := c.newPasswordProof(ctx, loginPass)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newUpdatePrivateKeysReq(ctx, oldMailboxPass, newMailboxPass)
+	if err != nil {
+		return err
+	}
+
+	req.PasswordProof = proof.PasswordProof
+
+	return c.updatePrivateKeys(ctx, req, proof)
+}
+
+func (c *Client) updatePassword(ctx context.Context, req UpdatePasswordReq, proof passwordProof) error {
+	var res struct {
+		ServerProof string
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).SetResult(&res).Put("/core/v4/settings/password")
+	}); err != nil {
+		return err
+	}
+
+	return proof.verify(res.ServerProof)
+}
+
+func (c *Client) updatePrivateKeys(ctx context.Context, req UpdatePrivateKeysReq, proof passwordProof) error {
+	var res struct {
+		ServerProof string
+	}
+
+	if err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).SetResult(&res).Put("/core/v4/keys/private")
+	}); err != nil {
+		return err
+	}
+
+	return proof.verify(res.ServerProof)
+}
+
+// passwordProof is a proof of the login password, with the server proof expected in return.
+type passwordProof struct {
+	PasswordProof
+
+	expectedServerProof []byte
+}
+
+func (proof passwordProof) verify(serverProof string) error {
+	b, err := base64.StdEncoding.DecodeString(serverProof)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(b, proof.expectedServerProof) {
+		return fmt.Errorf("invalid server proof")
+	}
+
+	return nil
+}
+
+func (c *Client) newPasswordProof(ctx context.Context, password []byte) (passwordProof, error) {
+	user, err := c.GetUser(ctx)
+	if err != nil {
+		return passwordProof{}, err
+	}
+
+	info, err := c.m.AuthInfo(ctx, AuthInfoReq{Username: user.Name})
+	if err != nil {
+		return passwordProof{}, err
+	}
+
+	srpAuth, err := srp.NewAuth(info.Version, user.Name, password, info.Salt, info.Modulus, info.ServerEphemeral)
+	if err != nil {
+		return passwordProof{}, err
+	}
+
+	proofs, err := srpAuth.GenerateProofs(2048)
+	if err != nil {
+		return passwordProof{}, err
+	}
+
+	return passwordProof{
+		PasswordProof: PasswordProof{
+			ClientEphemeral: base64.StdEncoding.EncodeToString(proofs.ClientEphemeral),
+			ClientProof:     base64.StdEncoding.EncodeToString(proofs.ClientProof),
+			SRPSession:      info.SRPSession,
+		},
+		expectedServerProof: proofs.ExpectedServerProof,
+	}, nil
+}
+
+// newAuthVerifier returns the SRP verifier of the password, with a new salt, for the current modulus of the API.
+func (c *Client) newAuthVerifier(ctx context.Context, password []byte) (AuthVerifier, error) {
+	modulus, err := c.m.AuthModulus(ctx)
+	if err != nil {
+		return AuthVerifier{}, err
+	}
+
+	salt, err := crypto.RandomToken(10)
+	if err != nil {
+		return AuthVerifier{}, err
+	}
+
+	srpAuth, err := srp.NewAuthForVerifier(password, modulus.Modulus, salt)
+	if err != nil {
+		return AuthVerifier{}, err
+	}
+
+	verifier, err := srpAuth.GenerateVerifier(2048)
+	if err != nil {
+		return AuthVerifier{}, err
+	}
+
+	return AuthVerifier{
+		Version:   4,
+		ModulusID: modulus.ModulusID,
+		Salt:      base64.StdEncoding.EncodeToString(salt),
+		Verifier:  base64.StdEncoding.EncodeToString(verifier),
+	}, nil
+}
+
+// newUpdatePrivateKeysReq returns the request that locks the user keys, currently locked with the old mailbox password,
+// with the new mailbox password and a new key salt.
+func (c *Client) newUpdatePrivateKeysReq(ctx context.Context, oldPass, newPass []byte) (UpdatePrivateKeysReq, error) {
+	user, err := c.GetUser(ctx)
+	if err != nil {
+		return UpdatePrivateKeysReq{}, err
+	}
+
+	salts, err := c.GetSalts(ctx)
+	if err != nil {
+		return UpdatePrivateKeysReq{}, err
+	}
+
+	keySalt, err := crypto.RandomToken(16)
+	if err != nil {
+		return UpdatePrivateKeysReq{}, err
+	}
+
+	newKeyPass, err := saltKeyPass(newPass, keySalt)
+	if err != nil {
+		return UpdatePrivateKeysReq{}, err
+	}
+
+	req := UpdatePrivateKeysReq{KeySalt: base64.StdEncoding.EncodeToString(keySalt)}
+
+	for _, key := range user.Keys {
+		oldKeyPass, err := salts.SaltForKey(oldPass, key.ID)
+		if err != nil {
+			return UpdatePrivateKeysReq{}, err
+		}
+
+		armKey, err := relockKey(key.PrivateKey, oldKeyPass, newKeyPass)
+		if err != nil {
+			return UpdatePrivateKeysReq{}, fmt.Errorf("failed to re-encrypt key %s: %w", key.ID, err)
+		}
+
+		req.UserKeys = append(req.UserKeys, PrivateKeyEntry{ID: key.ID, PrivateKey: armKey})
+	}
+
+	return req, nil
+}
+
+// saltKeyPass returns the passphrase of keys locked with the given password and key salt, as Salts.SaltForKey does.
+func saltKeyPass(password, keySalt []byte) ([]byte, error) {
+	saltedKeyPass, err := srp.MailboxPassword(password, keySalt)
+	if err != nil {
+		return nil, err
+	}
+
+	return saltedKeyPass[len(saltedKeyPass)-31:], nil
+}
+
+func relockKey(privateKey []byte, oldPass, newPass []byte) (string, error) {
+	key, err := crypto.NewKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	unlocked, err := key.Unlock(oldPass)
+	if err != nil {
+		return "", err
+	}
+	defer unlocked.ClearPrivateParams()
+
+	locked, err := unlocked.Lock(newPass)
+	if err != nil {
+		return "", err
+	}
+
+	return locked.Armor()
+}
@@ -0,0 +1,30 @@
+package proton
+
+// PasswordProof proves the knowledge of the current login password, as computed by SRP from the AuthInfo of the user.
+type PasswordProof struct {
+	ClientEphemeral string
+	ClientProof     string
+	SRPSession      string
+}
+
+type UpdatePasswordReq struct {
+	PasswordProof
+
+	Auth AuthVerifier
+}
+
+type UpdatePrivateKeysReq struct {
+	PasswordProof
+
+	// KeySalt is the base64-encoded salt of the passphrase the user keys are locked with.
+	KeySalt  string
+	UserKeys []PrivateKeyEntry
+
+	// Auth is the verifier of the new login password, if it changes with the keys (i.e. in one-password mode).
+	Auth *AuthVerifier `json:",omitempty"`
+}
+
+type PrivateKeyEntry struct {
+	ID         string
+	PrivateKey string
+}
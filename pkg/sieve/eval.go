@@ -0,0 +1,306 @@
+package sieve
+
+import (
+	"net/mail"
+	"slices"
+	"strings"
+)
+
+// Message is the message a script is evaluated against.
+type Message interface {
+	// Header returns the values of the header fields with the given name, in any case.
+	Header(name string) []string
+
+	// Size returns the size of the message in octets.
+	Size() int
+}
+
+// Actions are the actions a script takes on a message.
+type Actions struct {
+	// FileInto holds the mailboxes the message is filed into, in order.
+	FileInto []string
+
+	// Flags holds the IMAP flags set on the message, such as \Seen or \Flagged.
+	Flags []string
+
+	// Keep is whether the message is kept in its default mailbox, either explicitly or implicitly.
+	Keep bool
+
+	// Discard is whether the message is discarded.
+	Discard bool
+}
+
+// Evaluate runs the script against the message and returns the actions it takes.
+func (s *Script) Evaluate(msg Message) Actions {
+	e := &evaluation{msg: msg}
+
+	e.run(s.commands)
+
+	if !e.cancelKeep {
+		e.actions.Keep = true
+	}
+
+	return e.actions
+}
+
+type evaluation struct {
+	msg     Message
+	actions Actions
+
+	// cancelKeep is whether the implicit keep was cancelled by a fileinto or discard action.
+	cancelKeep bool
+}
+
+// run runs the commands and returns false if evaluation stopped.
+func (e *evaluation) run(commands []command) bool {
+	// matched is whether the branch of the preceding if or elsif command was taken.
+	var matched bool
+
+	for _, cmd := range commands {
+		switch cmd.name {
+		case "if":
+			if matched = e.test(cmd.tests[0]); matched && !e.run(cmd.block) {
+				return false
+			}
+
+		case "elsif":
+			if matched {
+				continue
+			}
+
+			if matched = e.test(cmd.tests[0]); matched && !e.run(cmd.block) {
+				return false
+			}
+
+		case "else":
+			if !matched && !e.run(cmd.block) {
+				return false
+			}
+
+		case "stop":
+			return false
+
+		case "keep":
+			e.actions.Keep = true
+
+		case "discard":
+			e.actions.Discard, e.cancelKeep = true, true
+
+		case "fileinto":
+			if !slices.Contains(e.actions.FileInto, cmd.strs[0]) {
+				e.actions.FileInto = append(e.actions.FileInto, cmd.strs[0])
+			}
+
+			e.cancelKeep = true
+
+		case "setflag":
+			e.actions.Flags = nil
+			e.addFlags(cmd.strs)
+
+		case "addflag":
+			e.addFlags(cmd.strs)
+
+		case "removeflag":
+			e.actions.Flags = slices.DeleteFunc(e.actions.Flags, func(flag string) bool {
+				return slices.ContainsFunc(cmd.strs, func(other string) bool { return strings.EqualFold(flag, other) })
+			})
+		}
+	}
+
+	return true
+}
+
+func (e *evaluation) addFlags(flags []string) {
+	for _, flag := range flags {
+		if !slices.ContainsFunc(e.actions.Flags, func(other string) bool { return strings.EqualFold(flag, other) }) {
+			e.actions.Flags = append(e.actions.Flags, flag)
+		}
+	}
+}
+
+func (e *evaluation) test(t test) bool {
+	switch t.name {
+	case "true":
+		return true
+
+	case "false":
+		return false
+
+	case "not":
+		return !e.test(t.tests[0])
+
+	case "allof":
+		for _, t := range t.tests {
+			if !e.test(t) {
+				return false
+			}
+		}
+
+		return true
+
+	case "anyof":
+		for _, t := range t.tests {
+			if e.test(t) {
+				return true
+			}
+		}
+
+		return false
+
+	case "exists":
+		for _, name := range t.headers {
+			if len(e.msg.Header(name)) == 0 {
+				return false
+			}
+		}
+
+		return true
+
+	case "size":
+		if t.over {
+			return e.msg.Size() > t.limit
+		}
+
+		return e.msg.Size() < t.limit
+
+	case "header":
+		for _, name := range t.headers {
+			for _, value := range e.msg.Header(name) {
+				if t.match(value) {
+					return true
+				}
+			}
+		}
+
+		return false
+
+	case "address":
+		for _, name := range t.headers {
+			for _, value := range e.msg.Header(name) {
+				for _, addr := range getAddresses(value) {
+					if t.match(getAddressPart(addr, t.addressPart)) {
+						return true
+					}
+				}
+			}
+		}
+
+		return false
+
+	default:
+		return false
+	}
+}
+
+// match returns whether the value matches any of the keys of the test.
+func (t test) match(value string) bool {
+	if t.comparator == "i;ascii-casemap" {
+		value = strings.ToLower(value)
+	}
+
+	for _, key := range t.keys {
+		if t.comparator == "i;ascii-casemap" {
+			key = strings.ToLower(key)
+		}
+
+		switch t.matchType {
+		case "is":
+			if value == key {
+				return true
+			}
+
+		case "contains":
+			if strings.Contains(value, key) {
+				return true
+			}
+
+		case "matches":
+			if matchWildcard(key, value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchWildcard returns whether the value matches the pattern, in which * matches any sequence of characters,
+// ? matches a single character and a backslash escapes the next character.
+func matchWildcard(pattern, value string) bool {
+	p, v := []rune(pattern), []rune(value)
+
+	// starP and starV record where the last * was seen, to backtrack to when a later part does not match.
+	starP, starV := -1, 0
+
+	for pi, vi := 0, 0; vi < len(v) || pi < len(p); {
+		if pi < len(p) {
+			switch c := p[pi]; {
+			case c == '*':
+				starP, starV = pi, vi
+				pi++
+
+				continue
+
+			case c == '?' && vi < len(v):
+				pi++
+				vi++
+
+				continue
+
+			case c == '\\' && pi+1 < len(p) && vi < len(v) && p[pi+1] == v[vi]:
+				pi += 2
+				vi++
+
+				continue
+
+			case c != '\\' && c != '?' && vi < len(v) && c == v[vi]:
+				pi++
+				vi++
+
+				continue
+			}
+		}
+
+		if starP < 0 || starV >= len(v) {
+			return false
+		}
+
+		starV++
+		pi, vi = starP+1, starV
+	}
+
+	return true
+}
+
+func getAddresses(value string) []string {
+	list, err := mail.ParseAddressList(value)
+	if err != nil {
+		return []string{strings.TrimSpace(value)}
+	}
+
+	addrs := make([]string, 0, len(list))
+
+	for _, addr := range list {
+		addrs = append(addrs, addr.Address)
+	}
+
+	return addrs
+}
+
+func getAddressPart(addr, part string) string {
+	at := strings.LastIndex(addr, "@")
+
+	switch {
+	case part == "localpart" && at >= 0:
+		return addr[:at]
+
+	case part == "domain" && at >= 0:
+		return addr[at+1:]
+
+	case part == "domain":
+		return ""
+
+	default:
+		return addr
+	}
+}
@@ -0,0 +1,300 @@
+package sieve
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenTag
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  int
+	pos  Position
+}
+
+// Position is a location in a script.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Error is a syntax or semantic error in a script.
+type Error struct {
+	Position
+
+	Message string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", err.Line, err.Column, err.Message)
+}
+
+func errorf(pos Position, format string, args ...any) *Error {
+	return &Error{Position: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// lexer splits a script into tokens, as described in RFC 5228 section 8.1.
+type lexer struct {
+	src []rune
+	off int
+	pos Position
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: []rune(src), pos: Position{Line: 1, Column: 1}}
+}
+
+func (l *lexer) tokens() ([]token, error) {
+	var tokens []token
+
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, tok)
+
+		if tok.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+
+	pos := l.pos
+
+	if l.off >= len(l.src) {
+		return token{kind: tokenEOF, pos: pos}, nil
+	}
+
+	switch r := l.src[l.off]; {
+	case r == '"':
+		text, err := l.readString()
+		if err != nil {
+			return token{}, err
+		}
+
+		return token{kind: tokenString, text: text, pos: pos}, nil
+
+	case r == ':':
+		l.advance()
+
+		name := l.readWhile(isIdentifierRune)
+		if name == "" {
+			return token{}, errorf(pos, "expected tag name after ':'")
+		}
+
+		return token{kind: tokenTag, text: strings.ToLower(name), pos: pos}, nil
+
+	case r >= '0' && r <= '9':
+		return l.readNumber(pos)
+
+	case isIdentifierRune(r):
+		name := l.readWhile(isIdentifierRune)
+
+		// Multi-line strings start with the "text:" identifier.
+		if strings.EqualFold(name, "text") && l.off < len(l.src) && l.src[l.off] == ':' {
+			l.advance()
+
+			text, err := l.readMultiline(pos)
+			if err != nil {
+				return token{}, err
+			}
+
+			return token{kind: tokenString, text: text, pos: pos}, nil
+		}
+
+		return token{kind: tokenIdentifier, text: strings.ToLower(name), pos: pos}, nil
+
+	case strings.ContainsRune(";,[](){}", r):
+		l.advance()
+
+		return token{kind: tokenPunct, text: string(r), pos: pos}, nil
+
+	default:
+		return token{}, errorf(pos, "unexpected character %q", r)
+	}
+}
+
+func (l *lexer) skipSpace() error {
+	for l.off < len(l.src) {
+		switch r := l.src[l.off]; {
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			l.advance()
+
+		case r == '#':
+			l.readWhile(func(r rune) bool { return r != '\n' })
+
+		case r == '/' && l.peek(1) == '*':
+			pos := l.pos
+
+			l.advance()
+			l.advance()
+
+			for {
+				if l.off+1 >= len(l.src) {
+					return errorf(pos, "unterminated comment")
+				}
+
+				if l.src[l.off] == '*' && l.src[l.off+1] == '/' {
+					break
+				}
+
+				l.advance()
+			}
+
+			l.advance()
+			l.advance()
+
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (l *lexer) readString() (string, error) {
+	pos := l.pos
+
+	l.advance()
+
+	var b strings.Builder
+
+	for {
+		if l.off >= len(l.src) {
+			return "", errorf(pos, "unterminated string")
+		}
+
+		r := l.advance()
+
+		switch r {
+		case '"':
+			return b.String(), nil
+
+		case '\\':
+			if l.off >= len(l.src) {
+				return "", errorf(pos, "unterminated string")
+			}
+
+			b.WriteRune(l.advance())
+
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// readMultiline reads the lines of a multi-line string up to the line holding a single dot.
+func (l *lexer) readMultiline(pos Position) (string, error) {
+	// The rest of the line after "text:" may only hold whitespace or a comment.
+	l.readWhile(func(r rune) bool { return r != '\n' })
+
+	if l.off >= len(l.src) {
+		return "", errorf(pos, "unterminated multi-line string")
+	}
+
+	l.advance()
+
+	var lines []string
+
+	for {
+		if l.off >= len(l.src) {
+			return "", errorf(pos, "unterminated multi-line string")
+		}
+
+		line := strings.TrimSuffix(l.readWhile(func(r rune) bool { return r != '\n' }), "\r")
+
+		if l.off < len(l.src) {
+			l.advance()
+		}
+
+		if line == "." {
+			return strings.Join(lines, "\r\n"), nil
+		}
+
+		// Lines starting with a dot have it doubled.
+		lines = append(lines, strings.TrimPrefix(line, "."))
+	}
+}
+
+func (l *lexer) readNumber(pos Position) (token, error) {
+	digits := l.readWhile(func(r rune) bool { return r >= '0' && r <= '9' })
+
+	num, err := strconv.Atoi(digits)
+	if err != nil {
+		return token{}, errorf(pos, "invalid number %q", digits)
+	}
+
+	if l.off < len(l.src) {
+		switch l.src[l.off] {
+		case 'K', 'k':
+			num <<= 10
+			l.advance()
+
+		case 'M', 'm':
+			num <<= 20
+			l.advance()
+
+		case 'G', 'g':
+			num <<= 30
+			l.advance()
+		}
+	}
+
+	return token{kind: tokenNumber, num: num, pos: pos}, nil
+}
+
+func (l *lexer) readWhile(fn func(rune) bool) string {
+	start := l.off
+
+	for l.off < len(l.src) && fn(l.src[l.off]) {
+		l.advance()
+	}
+
+	return string(l.src[start:l.off])
+}
+
+func (l *lexer) advance() rune {
+	r := l.src[l.off]
+
+	l.off++
+
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+	} else {
+		l.pos.Column++
+	}
+
+	return r
+}
+
+func (l *lexer) peek(n int) rune {
+	if l.off+n >= len(l.src) {
+		return 0
+	}
+
+	return l.src[l.off+n]
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,552 @@
+// Package sieve implements the subset of the Sieve mail filtering language (RFC 5228) used by mail filters:
+// the fileinto and imap4flags extensions, and the header, address, exists and size tests.
+package sieve
+
+import (
+	"slices"
+	"strings"
+)
+
+// Extensions are the extensions a script may require.
+var Extensions = []string{"fileinto", "imap4flags"}
+
+// Script is a parsed and validated Sieve script.
+type Script struct {
+	commands []command
+}
+
+type argument struct {
+	pos Position
+
+	// tag is the name of a tagged argument, without the colon.
+	tag string
+
+	// strs holds the value of a string or string list argument.
+	strs   []string
+	isStrs bool
+
+	// num holds the value of a number argument.
+	num   int
+	isNum bool
+}
+
+type command struct {
+	name  string
+	pos   Position
+	args  []argument
+	tests []test
+	block []command
+
+	// strs holds the mailbox of fileinto or the flags of the imap4flags commands.
+	strs []string
+}
+
+type test struct {
+	name  string
+	pos   Position
+	args  []argument
+	tests []test
+
+	// The following hold the validated arguments of the header, address, exists and size tests.
+	comparator  string
+	matchType   string
+	addressPart string
+	headers     []string
+	keys        []string
+	over        bool
+	limit       int
+}
+
+// Parse parses the script and checks that it only uses supported commands, tests and extensions.
+// The returned error is an *Error locating the problem.
+func Parse(src string) (*Script, error) {
+	tokens, err := newLexer(src).tokens()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	commands, err := p.parseCommands()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errorf(tok.pos, "unexpected %q", tok.text)
+	}
+
+	c := &checker{}
+
+	if err := c.checkCommands(commands, true); err != nil {
+		return nil, err
+	}
+
+	return &Script{commands: commands}, nil
+}
+
+type parser struct {
+	tokens []token
+	off    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.off]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.off]
+
+	if tok.kind != tokenEOF {
+		p.off++
+	}
+
+	return tok
+}
+
+func (p *parser) isPunct(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokenPunct && tok.text == text
+}
+
+func (p *parser) expectPunct(text string) error {
+	if tok := p.next(); tok.kind != tokenPunct || tok.text != text {
+		return errorf(tok.pos, "expected %q", text)
+	}
+
+	return nil
+}
+
+// parseCommands parses commands up to the end of the script or of the enclosing block.
+func (p *parser) parseCommands() ([]command, error) {
+	var commands []command
+
+	for p.peek().kind != tokenEOF && !p.isPunct("}") {
+		cmd, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+
+		commands = append(commands, cmd)
+	}
+
+	return commands, nil
+}
+
+func (p *parser) parseCommand() (command, error) {
+	tok := p.next()
+	if tok.kind != tokenIdentifier {
+		return command{}, errorf(tok.pos, "expected command")
+	}
+
+	cmd := command{name: tok.text, pos: tok.pos}
+
+	args, tests, err := p.parseArguments()
+	if err != nil {
+		return command{}, err
+	}
+
+	cmd.args, cmd.tests = args, tests
+
+	if p.isPunct("{") {
+		p.next()
+
+		block, err := p.parseCommands()
+		if err != nil {
+			return command{}, err
+		}
+
+		if err := p.expectPunct("}"); err != nil {
+			return command{}, err
+		}
+
+		cmd.block = block
+
+		if cmd.block == nil {
+			cmd.block = []command{}
+		}
+
+		return cmd, nil
+	}
+
+	if err := p.expectPunct(";"); err != nil {
+		return command{}, err
+	}
+
+	return cmd, nil
+}
+
+// parseArguments parses the arguments of a command or test, followed by an optional test or test list.
+func (p *parser) parseArguments() ([]argument, []test, error) {
+	var args []argument
+
+	for {
+		tok := p.peek()
+
+		switch {
+		case tok.kind == tokenTag:
+			p.next()
+			args = append(args, argument{pos: tok.pos, tag: tok.text})
+
+		case tok.kind == tokenNumber:
+			p.next()
+			args = append(args, argument{pos: tok.pos, num: tok.num, isNum: true})
+
+		case tok.kind == tokenString:
+			p.next()
+			args = append(args, argument{pos: tok.pos, strs: []string{tok.text}, isStrs: true})
+
+		case p.isPunct("["):
+			strs, err := p.parseStringList()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			args = append(args, argument{pos: tok.pos, strs: strs, isStrs: true})
+
+		case tok.kind == tokenIdentifier:
+			t, err := p.parseTest()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return args, []test{t}, nil
+
+		case p.isPunct("("):
+			tests, err := p.parseTestList()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return args, tests, nil
+
+		default:
+			return args, nil, nil
+		}
+	}
+}
+
+func (p *parser) parseStringList() ([]string, error) {
+	if err := p.expectPunct("["); err != nil {
+		return nil, err
+	}
+
+	var strs []string
+
+	for {
+		tok := p.next()
+		if tok.kind != tokenString {
+			return nil, errorf(tok.pos, "expected string")
+		}
+
+		strs = append(strs, tok.text)
+
+		if !p.isPunct(",") {
+			break
+		}
+
+		p.next()
+	}
+
+	if err := p.expectPunct("]"); err != nil {
+		return nil, err
+	}
+
+	return strs, nil
+}
+
+func (p *parser) parseTest() (test, error) {
+	tok := p.next()
+	if tok.kind != tokenIdentifier {
+		return test{}, errorf(tok.pos, "expected test")
+	}
+
+	args, tests, err := p.parseArguments()
+	if err != nil {
+		return test{}, err
+	}
+
+	return test{name: tok.text, pos: tok.pos, args: args, tests: tests}, nil
+}
+
+func (p *parser) parseTestList() ([]test, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+
+	var tests []test
+
+	for {
+		t, err := p.parseTest()
+		if err != nil {
+			return nil, err
+		}
+
+		tests = append(tests, t)
+
+		if !p.isPunct(",") {
+			break
+		}
+
+		p.next()
+	}
+
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+
+	return tests, nil
+}
+
+// checker validates the commands and tests of a script and fills in their arguments.
+type checker struct {
+	requires []string
+}
+
+func (c *checker) checkCommands(commands []command, topLevel bool) error {
+	canRequire := topLevel
+
+	for idx := range commands {
+		cmd := &commands[idx]
+
+		if cmd.name != "require" {
+			canRequire = false
+		}
+
+		if err := c.checkCommand(cmd, canRequire, commands[:idx]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *checker) checkCommand(cmd *command, canRequire bool, previous []command) error {
+	switch cmd.name {
+	case "require":
+		if !canRequire {
+			return errorf(cmd.pos, "require must come before other commands")
+		}
+
+		strs, err := c.checkPlainArgs(cmd, 1)
+		if err != nil {
+			return err
+		}
+
+		for _, ext := range strs[0] {
+			if !slices.Contains(Extensions, ext) {
+				return errorf(cmd.args[0].pos, "unsupported extension %q", ext)
+			}
+		}
+
+		c.requires = append(c.requires, strs[0]...)
+
+	case "if", "elsif", "else":
+		if cmd.name != "if" {
+			if len(previous) == 0 || (previous[len(previous)-1].name != "if" && previous[len(previous)-1].name != "elsif") {
+				return errorf(cmd.pos, "%s must follow if or elsif", cmd.name)
+			}
+		}
+
+		if len(cmd.args) > 0 {
+			return errorf(cmd.args[0].pos, "unexpected argument")
+		}
+
+		if wantTests := map[bool]int{true: 0, false: 1}[cmd.name == "else"]; len(cmd.tests) != wantTests {
+			return errorf(cmd.pos, "%s expects %d test(s)", cmd.name, wantTests)
+		}
+
+		if cmd.block == nil {
+			return errorf(cmd.pos, "%s expects a block", cmd.name)
+		}
+
+		for idx := range cmd.tests {
+			if err := c.checkTest(&cmd.tests[idx]); err != nil {
+				return err
+			}
+		}
+
+		return c.checkCommands(cmd.block, false)
+
+	case "stop", "keep", "discard":
+		if _, err := c.checkPlainArgs(cmd, 0); err != nil {
+			return err
+		}
+
+	case "fileinto":
+		if err := c.checkRequired(cmd.pos, "fileinto"); err != nil {
+			return err
+		}
+
+		strs, err := c.checkPlainArgs(cmd, 1)
+		if err != nil {
+			return err
+		}
+
+		if len(strs[0]) != 1 {
+			return errorf(cmd.args[0].pos, "fileinto expects a single mailbox")
+		}
+
+		cmd.strs = strs[0]
+
+	case "addflag", "setflag", "removeflag":
+		if err := c.checkRequired(cmd.pos, "imap4flags"); err != nil {
+			return err
+		}
+
+		strs, err := c.checkPlainArgs(cmd, 1)
+		if err != nil {
+			return err
+		}
+
+		for _, str := range strs[0] {
+			cmd.strs = append(cmd.strs, strings.Fields(str)...)
+		}
+
+	default:
+		return errorf(cmd.pos, "unknown command %q", cmd.name)
+	}
+
+	if cmd.name != "if" && cmd.name != "elsif" && cmd.name != "else" && cmd.block != nil {
+		return errorf(cmd.pos, "%s does not take a block", cmd.name)
+	}
+
+	return nil
+}
+
+// checkPlainArgs checks that the command has the given number of string arguments and nothing else.
+func (c *checker) checkPlainArgs(cmd *command, count int) ([][]string, error) {
+	if len(cmd.tests) > 0 {
+		return nil, errorf(cmd.tests[0].pos, "%s does not take a test", cmd.name)
+	}
+
+	var strs [][]string
+
+	for _, arg := range cmd.args {
+		if !arg.isStrs {
+			return nil, errorf(arg.pos, "unexpected argument to %s", cmd.name)
+		}
+
+		strs = append(strs, arg.strs)
+	}
+
+	if len(strs) != count {
+		return nil, errorf(cmd.pos, "%s expects %d argument(s)", cmd.name, count)
+	}
+
+	return strs, nil
+}
+
+func (c *checker) checkRequired(pos Position, ext string) error {
+	if !slices.Contains(c.requires, ext) {
+		return errorf(pos, "missing require %q", ext)
+	}
+
+	return nil
+}
+
+func (c *checker) checkTest(t *test) error {
+	switch t.name {
+	case "true", "false":
+		if len(t.args) > 0 || len(t.tests) > 0 {
+			return errorf(t.pos, "%s does not take arguments", t.name)
+		}
+
+	case "not":
+		if len(t.args) > 0 || len(t.tests) != 1 {
+			return errorf(t.pos, "not expects a single test")
+		}
+
+		return c.checkTest(&t.tests[0])
+
+	case "allof", "anyof":
+		if len(t.args) > 0 || len(t.tests) == 0 {
+			return errorf(t.pos, "%s expects a test list", t.name)
+		}
+
+		for idx := range t.tests {
+			if err := c.checkTest(&t.tests[idx]); err != nil {
+				return err
+			}
+		}
+
+	case "header", "address", "exists", "size":
+		if len(t.tests) > 0 {
+			return errorf(t.tests[0].pos, "%s does not take a test", t.name)
+		}
+
+		return c.checkMatchTest(t)
+
+	default:
+		return errorf(t.pos, "unknown test %q", t.name)
+	}
+
+	return nil
+}
+
+// checkMatchTest checks the tagged and positional arguments of the header, address, exists and size tests.
+func (c *checker) checkMatchTest(t *test) error {
+	t.comparator, t.matchType, t.addressPart = "i;ascii-casemap", "is", "all"
+
+	var (
+		positional []argument
+		sizeSet    bool
+	)
+
+	for idx := 0; idx < len(t.args); idx++ {
+		arg := t.args[idx]
+
+		switch {
+		case arg.tag == "":
+			positional = append(positional, arg)
+
+		case arg.tag == "comparator" && (t.name == "header" || t.name == "address"):
+			if idx+1 >= len(t.args) || !t.args[idx+1].isStrs || len(t.args[idx+1].strs) != 1 {
+				return errorf(arg.pos, "comparator expects a string")
+			}
+
+			idx++
+
+			if t.comparator = t.args[idx].strs[0]; t.comparator != "i;ascii-casemap" && t.comparator != "i;octet" {
+				return errorf(t.args[idx].pos, "unsupported comparator %q", t.comparator)
+			}
+
+		case (arg.tag == "is" || arg.tag == "contains" || arg.tag == "matches") && (t.name == "header" || t.name == "address"):
+			t.matchType = arg.tag
+
+		case (arg.tag == "all" || arg.tag == "localpart" || arg.tag == "domain") && t.name == "address":
+			t.addressPart = arg.tag
+
+		case (arg.tag == "over" || arg.tag == "under") && t.name == "size":
+			t.over, sizeSet = arg.tag == "over", true
+
+		default:
+			return errorf(arg.pos, "unexpected tag :%s for %s", arg.tag, t.name)
+		}
+	}
+
+	switch t.name {
+	case "header", "address":
+		if len(positional) != 2 || !positional[0].isStrs || !positional[1].isStrs {
+			return errorf(t.pos, "%s expects a header list and a key list", t.name)
+		}
+
+		t.headers, t.keys = positional[0].strs, positional[1].strs
+
+	case "exists":
+		if len(positional) != 1 || !positional[0].isStrs {
+			return errorf(t.pos, "exists expects a header list")
+		}
+
+		t.headers = positional[0].strs
+
+	case "size":
+		if !sizeSet || len(positional) != 1 || !positional[0].isNum {
+			return errorf(t.pos, "size expects :over or :under and a limit")
+		}
+
+		t.limit = positional[0].num
+	}
+
+	return nil
+}
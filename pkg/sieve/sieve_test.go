@@ -0,0 +1,131 @@
+package sieve
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testMessage struct {
+	header map[string][]string
+	size   int
+}
+
+func (msg testMessage) Header(name string) []string {
+	for key, values := range msg.header {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+
+	return nil
+}
+
+func (msg testMessage) Size() int {
+	return msg.size
+}
+
+func TestParse_Errors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		script string
+		line   int
+		column int
+	}{
+		{name: "unknown command", script: "redirect \"a@b.c\";", line: 1, column: 1},
+		{name: "missing require", script: "fileinto \"Work\";", line: 1, column: 1},
+		{name: "unsupported extension", script: "require \"vacation\";", line: 1, column: 9},
+		{name: "late require", script: "keep;\nrequire \"fileinto\";", line: 2, column: 1},
+		{name: "missing semicolon", script: "keep\nstop;", line: 2, column: 1},
+		{name: "dangling else", script: "else { keep; }", line: 1, column: 1},
+		{name: "unknown test", script: "if foo { keep; }", line: 1, column: 4},
+		{name: "bad match tag", script: "if size :is 10 { keep; }", line: 1, column: 9},
+		{name: "unterminated string", script: "if header \"Subject\" \"x { keep; }", line: 1, column: 21},
+		{name: "unterminated comment", script: "keep; /* comment", line: 1, column: 7},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.script)
+
+			var sieveErr *Error
+
+			require.True(t, errors.As(err, &sieveErr))
+			require.Equal(t, Position{Line: tc.line, Column: tc.column}, sieveErr.Position)
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	script, err := Parse(`
+		require ["fileinto", "imap4flags"];
+
+		# Newsletters are read later.
+		if address :domain :is "From" "news.example.com" {
+			fileinto "Newsletters";
+			addflag "\\Seen";
+			stop;
+		}
+
+		/* Large mail from the boss is flagged. */
+		if allof (header :contains "Subject" "report", size :over 1K) {
+			addflag "\\Flagged";
+		} elsif header :matches "Subject" "*[SPAM]*" {
+			discard;
+		} else {
+			keep;
+		}
+	`)
+	require.NoError(t, err)
+
+	require.Equal(t, Actions{
+		FileInto: []string{"Newsletters"},
+		Flags:    []string{`\Seen`},
+	}, script.Evaluate(testMessage{
+		header: map[string][]string{"From": {"News <weekly@NEWS.example.com>"}, "Subject": {"report"}},
+		size:   2048,
+	}))
+
+	require.Equal(t, Actions{
+		Flags: []string{`\Flagged`},
+		Keep:  true,
+	}, script.Evaluate(testMessage{
+		header: map[string][]string{"From": {"boss@example.com"}, "Subject": {"Quarterly Report"}},
+		size:   2048,
+	}))
+
+	require.Equal(t, Actions{
+		Discard: true,
+	}, script.Evaluate(testMessage{
+		header: map[string][]string{"Subject": {"Buy now [SPAM] cheap"}},
+	}))
+
+	require.Equal(t, Actions{
+		Keep: true,
+	}, script.Evaluate(testMessage{
+		header: map[string][]string{"Subject": {"Hello"}},
+	}))
+}
+
+func TestMatchWildcard(t *testing.T) {
+	testCases := []struct {
+		pattern, value string
+		match          bool
+	}{
+		{"*", "", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"*/*", "a/b", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{"*x*y", "axbxy", true},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.match, matchWildcard(tc.pattern, tc.value), "%q %q", tc.pattern, tc.value)
+	}
+}
@@ -58,6 +58,24 @@ func NewPool[In comparable, Out any](size int, panicHandler async.PanicHandler,
 	return pool
 }
 
+// NewAdaptivePool returns a pool whose number of concurrent jobs is bounded by the given limiter rather than fixed.
+// Requests made by the jobs report their Retry-After delays to the limiter.
+func NewAdaptivePool[In comparable, Out any](limiter *AdaptiveLimiter, panicHandler async.PanicHandler, work func(context.Context, In) (Out, error)) *Pool[In, Out] {
+	return NewPool(limiter.maxLimit, panicHandler, func(ctx context.Context, req In) (Out, error) {
+		var res Out
+
+		err := limiter.Do(ctx, func(ctx context.Context) error {
+			var err error
+
+			res, err = work(ctx, req)
+
+			return err
+		})
+
+		return res, err
+	})
+}
+
 // Process submits jobs to the pool. The callback provides access to the result, or an error if one occurred.
 func (pool *Pool[In, Out]) Process(ctx context.Context, reqs []In, fn func(int, In, Out, error) error) error {
 	ctx, cancel := context.WithCancel(ctx)
@@ -0,0 +1,81 @@
+package proton
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrStreamConsumed is returned when a request with a streamed body would need to be sent again.
+var ErrStreamConsumed = errors.New("request body stream already consumed")
+
+// streamBody is a request body written on the fly rather than held in memory.
+// It is set as the body of the request and read by the transport as it is written.
+// It can only be sent once: when a request that read from it is sent again, the body fails with ErrStreamConsumed.
+type streamBody struct {
+	write func(io.Writer) error
+
+	pr       *io.PipeReader
+	done     chan struct{}
+	consumed bool
+	lock     sync.Mutex
+}
+
+func newStreamBody(write func(io.Writer) error) *streamBody {
+	return &streamBody{write: write}
+}
+
+// Read starts writing the body on the first call and reads what was written.
+func (b *streamBody) Read(p []byte) (int, error) {
+	b.lock.Lock()
+
+	if b.consumed {
+		b.lock.Unlock()
+		return 0, ErrStreamConsumed
+	}
+
+	if b.pr == nil {
+		pr, pw := io.Pipe()
+
+		b.pr = pr
+		b.done = make(chan struct{})
+
+		go func() {
+			defer close(b.done)
+
+			pw.CloseWithError(b.write(pw))
+		}()
+	}
+
+	pr := b.pr
+
+	b.lock.Unlock()
+
+	return pr.Read(p)
+}
+
+// Close is called by the transport once the request was sent; a body it started reading can't be read again.
+func (b *streamBody) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.pr != nil {
+		b.consumed = true
+	}
+
+	return nil
+}
+
+// wait stops writing the body, if it was ever read, and waits for the writer to return.
+func (b *streamBody) wait() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.pr == nil {
+		return
+	}
+
+	_ = b.pr.Close()
+
+	<-b.done
+}
@@ -28,6 +28,7 @@ const (
 	UsernameInvalid             Code = 6003 // Deprecated, but still used.
 	PasswordWrong               Code = 8002
 	HumanVerificationRequired   Code = 9001
+	InsufficientScope           Code = 9101
 	PaidPlanRequired            Code = 10004
 	AuthRefreshTokenInvalid     Code = 10013
 	HumanValidationInvalidToken Code = 12087
@@ -157,6 +158,11 @@ func catchRetryAfter(_ *resty.Client, res *resty.Response) (time.Duration, error
 		after = 10
 	}
 
+	// Let the limiter of the request, if any, back off as well so that concurrent jobs do not retry in a storm.
+	if limiter, ok := getAdaptiveLimiter(res.Request.Context()); ok {
+		limiter.backoff(time.Duration(after) * time.Second)
+	}
+
 	// Add some jitter to the delay.
 	after += rand.Intn(10)
 
@@ -183,11 +189,16 @@ func retryRefreshFailed(res *resty.Response, err error) bool {
 }
 
 func catchTooManyRequests(res *resty.Response, _ error) bool {
+	// The response is nil if the request could not be built.
+	if res == nil {
+		return false
+	}
+
 	return res.StatusCode() == http.StatusTooManyRequests || res.StatusCode() == http.StatusServiceUnavailable
 }
 
 func catchDialError(res *resty.Response, err error) bool {
-	return res.RawResponse == nil
+	return res != nil && res.RawResponse == nil
 }
 
 func catchDropError(_ *resty.Response, err error) bool {
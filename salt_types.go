@@ -4,8 +4,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"slices"
-
-	"github.com/ProtonMail/go-srp"
 )
 
 type Salt struct {
@@ -28,10 +26,10 @@ func (salts Salts) SaltForKey(keyPass []byte, keyID string) ([]byte, error) {
 		return nil, err
 	}
 
-	saltedKeyPass, err := srp.MailboxPassword(keyPass, keySalt)
+	saltedKeyPass, err := saltKeyPass(keyPass, keySalt)
 	if err != nil {
 		return nil, nil
 	}
 
-	return saltedKeyPass[len(saltedKeyPass)-31:], nil
+	return saltedKeyPass, nil
 }
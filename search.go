@@ -0,0 +1,471 @@
+package proton
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/bradenaw/juniper/parallel"
+)
+
+// SearchIndex is a local full-text index of the messages of a user.
+//
+// Message bodies are end-to-end encrypted, so the API cannot search them; the index decrypts messages locally instead.
+// It is stored in a single file, encrypted with a session key that is itself encrypted with the user's keyring.
+// The index remembers the last event it applied so that it can be kept up to date from the event stream.
+type SearchIndex struct {
+	path       string
+	kr         *crypto.KeyRing
+	sessionKey *crypto.SessionKey
+
+	// syncLock serializes the operations that fetch messages and modify the index.
+	syncLock sync.Mutex
+
+	// lock protects the fields below.
+	lock     sync.RWMutex
+	eventID  string
+	docs     map[string]searchDoc
+	postings map[string]searchSet
+}
+
+// OpenSearchIndex opens the search index stored at the given path, decrypting it with the keyring.
+// If there is no index at the path yet, an empty one is returned; it should be built with Build or Sync.
+func OpenSearchIndex(path string, kr *crypto.KeyRing) (*SearchIndex, error) {
+	idx := &SearchIndex{
+		path:     path,
+		kr:       kr,
+		docs:     make(map[string]searchDoc),
+		postings: make(map[string]searchSet),
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		sessionKey, err := crypto.GenerateSessionKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate search index key: %w", err)
+		}
+
+		idx.sessionKey = sessionKey
+
+		return idx, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read search index: %w", err)
+	}
+
+	if len(b) < 4 || int(binary.BigEndian.Uint32(b)) > len(b)-4 {
+		return nil, errors.New("malformed search index")
+	}
+
+	keyPacket, dataPacket := b[4:4+binary.BigEndian.Uint32(b)], b[4+binary.BigEndian.Uint32(b):]
+
+	if idx.sessionKey, err = kr.DecryptSessionKey(keyPacket); err != nil {
+		return nil, fmt.Errorf("failed to decrypt search index key: %w", err)
+	}
+
+	dec, err := idx.sessionKey.Decrypt(dataPacket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt search index: %w", err)
+	}
+
+	var state searchState
+
+	if err := json.Unmarshal(dec.GetBinary(), &state); err != nil {
+		return nil, fmt.Errorf("failed to decode search index: %w", err)
+	}
+
+	idx.eventID = state.EventID
+
+	for id, doc := range state.Docs {
+		idx.insert(id, doc)
+	}
+
+	return idx, nil
+}
+
+// EventID returns the ID of the last event applied to the index, or an empty string if it has not been built yet.
+func (idx *SearchIndex) EventID() string {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	return idx.eventID
+}
+
+// Len returns the number of messages in the index.
+func (idx *SearchIndex) Len() int {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	return len(idx.docs)
+}
+
+// Save writes the index to disk, atomically replacing the previous version.
+func (idx *SearchIndex) Save() error {
+	idx.lock.RLock()
+	state, err := json.Marshal(searchState{EventID: idx.eventID, Docs: idx.docs})
+	idx.lock.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to encode search index: %w", err)
+	}
+
+	keyPacket, err := idx.kr.EncryptSessionKey(idx.sessionKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt search index key: %w", err)
+	}
+
+	dataPacket, err := idx.sessionKey.Encrypt(crypto.NewPlainMessage(state))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt search index: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(keyPacket))))
+	buf.Write(keyPacket)
+	buf.Write(dataPacket)
+
+	tmp, err := os.CreateTemp(filepath.Dir(idx.path), filepath.Base(idx.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create search index file: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), idx.path)
+}
+
+// Build indexes all the messages of the user, replacing the current content of the index.
+// The messages are decrypted with the keyrings of their addresses.
+func (idx *SearchIndex) Build(ctx context.Context, c *Client, addrKRs map[string]*crypto.KeyRing) error {
+	idx.syncLock.Lock()
+	defer idx.syncLock.Unlock()
+
+	return idx.build(ctx, c, addrKRs)
+}
+
+// Sync brings the index up to date by applying the events that happened since the last one it applied.
+// If the index has not been built yet, it is built.
+func (idx *SearchIndex) Sync(ctx context.Context, c *Client, addrKRs map[string]*crypto.KeyRing) error {
+	idx.syncLock.Lock()
+	defer idx.syncLock.Unlock()
+
+	if idx.EventID() == "" {
+		return idx.build(ctx, c, addrKRs)
+	}
+
+	return c.syncEvents(ctx, func() (string, error) {
+		return idx.EventID(), nil
+	}, func(event Event) error {
+		return idx.applyEvent(ctx, c, addrKRs, event)
+	})
+}
+
+// ApplyEvent updates the index with the message changes of the event, as received from the event stream.
+// Created messages are fetched and indexed, and a mail refresh rebuilds the whole index.
+func (idx *SearchIndex) ApplyEvent(ctx context.Context, c *Client, addrKRs map[string]*crypto.KeyRing, event Event) error {
+	idx.syncLock.Lock()
+	defer idx.syncLock.Unlock()
+
+	return idx.applyEvent(ctx, c, addrKRs, event)
+}
+
+// Search returns the metadata of the indexed messages that match the query and the filter, most recent first.
+//
+// Words and quoted phrases match messages that contain all of their terms, case-insensitively, in their subject,
+// addresses, body, attachment names or text attachments. A word ending with '*' matches any term with that prefix.
+// Adjacent words must all match; they can be combined with AND, OR and NOT (or a leading '-'),
+// and grouped with parentheses. An empty query matches all messages.
+func (idx *SearchIndex) Search(query string, filter SearchFilter) ([]MessageMetadata, error) {
+	node, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+
+	var res []MessageMetadata
+
+	for id := range node.eval(idx) {
+		if meta := idx.docs[id].Metadata; filter.matches(meta) {
+			res = append(res, meta)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b MessageMetadata) int {
+		if a.Time != b.Time {
+			return cmp.Compare(b.Time, a.Time)
+		}
+
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return res, nil
+}
+
+func (idx *SearchIndex) build(ctx context.Context, c *Client, addrKRs map[string]*crypto.KeyRing) error {
+	// Events that happen while the messages are fetched are applied by the next sync.
+	eventID, err := c.GetLatestEventID(ctx)
+	if err != nil {
+		return err
+	}
+
+	messageIDs, err := c.GetAllMessageIDs(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	docs, err := parallel.MapContext(ctx, runtime.NumCPU(), messageIDs, func(ctx context.Context, messageID string) (*searchDoc, error) {
+		return fetchSearchDoc(ctx, c, addrKRs, messageID)
+	})
+	if err != nil {
+		return err
+	}
+
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	idx.docs = make(map[string]searchDoc, len(docs))
+	idx.postings = make(map[string]searchSet)
+
+	for _, doc := range docs {
+		if doc != nil {
+			idx.insert(doc.Metadata.ID, *doc)
+		}
+	}
+
+	idx.eventID = eventID
+
+	return nil
+}
+
+func (idx *SearchIndex) applyEvent(ctx context.Context, c *Client, addrKRs map[string]*crypto.KeyRing, event Event) error {
+	if event.Refresh&RefreshMail != 0 {
+		return idx.build(ctx, c, addrKRs)
+	}
+
+	for _, msgEvent := range event.Messages {
+		switch msgEvent.Action {
+		case EventDelete:
+			idx.lock.Lock()
+			idx.remove(msgEvent.ID)
+			idx.lock.Unlock()
+
+		case EventUpdateFlags:
+			idx.lock.Lock()
+			doc, ok := idx.docs[msgEvent.ID]
+			if ok {
+				doc.Metadata = msgEvent.Message
+				idx.docs[msgEvent.ID] = doc
+			}
+			idx.lock.Unlock()
+
+			if ok {
+				continue
+			}
+
+			// The message was not indexed yet: index it.
+			fallthrough
+
+		case EventCreate, EventUpdate:
+			// Updates of drafts change their content, so messages are indexed again.
+			doc, err := fetchSearchDoc(ctx, c, addrKRs, msgEvent.ID)
+			if err != nil {
+				return err
+			}
+
+			idx.lock.Lock()
+			idx.remove(msgEvent.ID)
+			if doc != nil {
+				idx.insert(msgEvent.ID, *doc)
+			}
+			idx.lock.Unlock()
+		}
+	}
+
+	idx.lock.Lock()
+	idx.eventID = event.EventID
+	idx.lock.Unlock()
+
+	return nil
+}
+
+// lookup returns the messages that contain the term, or any term with its prefix if it ends with '*'.
+// The caller must hold the lock.
+func (idx *SearchIndex) lookup(term string) searchSet {
+	res := make(searchSet)
+
+	if prefix, ok := strings.CutSuffix(term, "*"); ok {
+		for term, ids := range idx.postings {
+			if strings.HasPrefix(term, prefix) {
+				for id := range ids {
+					res[id] = struct{}{}
+				}
+			}
+		}
+	} else {
+		for id := range idx.postings[term] {
+			res[id] = struct{}{}
+		}
+	}
+
+	return res
+}
+
+// insert adds the document to the index. The caller must hold the lock.
+func (idx *SearchIndex) insert(messageID string, doc searchDoc) {
+	idx.docs[messageID] = doc
+
+	for _, term := range doc.Terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(searchSet)
+		}
+
+		idx.postings[term][messageID] = struct{}{}
+	}
+}
+
+// remove removes the message from the index, if it is there. The caller must hold the lock.
+func (idx *SearchIndex) remove(messageID string) {
+	doc, ok := idx.docs[messageID]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.Terms {
+		delete(idx.postings[term], messageID)
+
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+
+	delete(idx.docs, messageID)
+}
+
+// fetchSearchDoc fetches and decrypts the message to index it; only its text attachments are downloaded.
+// It returns nil if the message no longer exists.
+// Messages that cannot be decrypted are indexed by their metadata and attachment names only.
+func fetchSearchDoc(ctx context.Context, c *Client, addrKRs map[string]*crypto.KeyRing, messageID string) (*searchDoc, error) {
+	msg, err := c.GetMessage(ctx, messageID)
+	if err != nil {
+		if isMessageGone(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var text []string
+
+	text = append(text, msg.Subject)
+	text = append(text, searchAddresses(msg.Sender)...)
+	text = append(text, searchAddresses(msg.ToList...)...)
+	text = append(text, searchAddresses(msg.CCList...)...)
+	text = append(text, searchAddresses(msg.BCCList...)...)
+
+	for _, att := range msg.Attachments {
+		text = append(text, att.Name)
+	}
+
+	if kr, ok := addrKRs[msg.AddressID]; ok {
+		if body, err := msg.Decrypt(kr); err == nil {
+			text = append(text, searchText(msg.MIMEType, body))
+		}
+
+		for _, att := range msg.Attachments {
+			if att.MIMEType.Type() != "text" {
+				continue
+			}
+
+			attData, err := c.GetAttachment(ctx, att.ID)
+			if err != nil {
+				if isMessageGone(err) {
+					return nil, nil
+				}
+
+				return nil, err
+			}
+
+			if data, err := decryptAttachment(kr, att, attData); err == nil {
+				text = append(text, searchText(att.MIMEType, data))
+			}
+		}
+	}
+
+	terms := make(map[string]struct{})
+
+	for _, text := range text {
+		for _, term := range searchTerms(text) {
+			terms[term] = struct{}{}
+		}
+	}
+
+	doc := &searchDoc{Metadata: msg.MessageMetadata}
+
+	for term := range terms {
+		doc.Terms = append(doc.Terms, term)
+	}
+
+	slices.Sort(doc.Terms)
+
+	return doc, nil
+}
+
+// isMessageGone returns whether the error is the API's answer for a message, or one of its attachments, that no longer exists.
+func isMessageGone(err error) bool {
+	apiErr := new(APIError)
+
+	return errors.As(err, &apiErr) && (apiErr.Status == http.StatusNotFound || apiErr.Status == http.StatusUnprocessableEntity)
+}
+
+// searchText returns the text to index of a body, without the markup of HTML bodies.
+func searchText(mimeType rfc822.MIMEType, body []byte) string {
+	if mimeType != rfc822.TextHTML {
+		return string(body)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return string(body)
+	}
+
+	return doc.Text()
+}
+
+func searchAddresses(addrs ...*mail.Address) []string {
+	var res []string
+
+	for _, addr := range addrs {
+		if addr != nil {
+			res = append(res, addr.Name, addr.Address)
+		}
+	}
+
+	return res
+}
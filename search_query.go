@@ -0,0 +1,320 @@
+package proton
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidSearchQuery is returned when a search query cannot be parsed.
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+// searchSet is a set of message IDs.
+type searchSet map[string]struct{}
+
+// searchNode is a node of a parsed search query, which evaluates to the messages it matches.
+type searchNode interface {
+	eval(idx *SearchIndex) searchSet
+}
+
+// searchTermNode matches messages that contain all of the terms; a term ending with '*' matches any term with that prefix.
+type searchTermNode struct {
+	terms []string
+}
+
+func (node searchTermNode) eval(idx *SearchIndex) searchSet {
+	var res searchSet
+
+	for _, term := range node.terms {
+		matches := idx.lookup(term)
+
+		if res == nil {
+			res = matches
+		} else {
+			res = intersectSearchSets(res, matches)
+		}
+	}
+
+	return res
+}
+
+type searchAndNode struct {
+	left, right searchNode
+}
+
+func (node searchAndNode) eval(idx *SearchIndex) searchSet {
+	return intersectSearchSets(node.left.eval(idx), node.right.eval(idx))
+}
+
+type searchOrNode struct {
+	left, right searchNode
+}
+
+func (node searchOrNode) eval(idx *SearchIndex) searchSet {
+	res := node.left.eval(idx)
+
+	for id := range node.right.eval(idx) {
+		res[id] = struct{}{}
+	}
+
+	return res
+}
+
+type searchNotNode struct {
+	node searchNode
+}
+
+func (node searchNotNode) eval(idx *SearchIndex) searchSet {
+	excluded := node.node.eval(idx)
+
+	res := make(searchSet)
+
+	for id := range idx.docs {
+		if _, ok := excluded[id]; !ok {
+			res[id] = struct{}{}
+		}
+	}
+
+	return res
+}
+
+// searchAllNode matches all messages; it is the query that has no terms.
+type searchAllNode struct{}
+
+func (searchAllNode) eval(idx *SearchIndex) searchSet {
+	res := make(searchSet, len(idx.docs))
+
+	for id := range idx.docs {
+		res[id] = struct{}{}
+	}
+
+	return res
+}
+
+// parseSearchQuery parses a search query, whose syntax is described by SearchIndex.Search.
+// NOT binds tighter than AND, which binds tighter than OR.
+func parseSearchQuery(query string) (searchNode, error) {
+	tokens, err := lexSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return searchAllNode{}, nil
+	}
+
+	p := &searchParser{tokens: tokens}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidSearchQuery, p.tokens[p.pos].value)
+	}
+
+	return node, nil
+}
+
+type searchTokenKind int
+
+const (
+	searchTokenWord searchTokenKind = iota
+	searchTokenPhrase
+	searchTokenLParen
+	searchTokenRParen
+	searchTokenNot
+)
+
+type searchToken struct {
+	kind  searchTokenKind
+	value string
+}
+
+func lexSearchQuery(query string) ([]searchToken, error) {
+	var tokens []searchToken
+
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, searchToken{kind: searchTokenLParen, value: "("})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, searchToken{kind: searchTokenRParen, value: ")"})
+			i++
+
+		case r == '-':
+			tokens = append(tokens, searchToken{kind: searchTokenNot, value: "-"})
+			i++
+
+		case r == '"':
+			end := i + 1
+
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+
+			if end == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated phrase", ErrInvalidSearchQuery)
+			}
+
+			tokens = append(tokens, searchToken{kind: searchTokenPhrase, value: string(runes[i+1 : end])})
+			i = end + 1
+
+		default:
+			end := i
+
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"`, runes[end]) {
+				end++
+			}
+
+			tokens = append(tokens, searchToken{kind: searchTokenWord, value: string(runes[i:end])})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+type searchParser struct {
+	tokens []searchToken
+	pos    int
+}
+
+func (p *searchParser) peekOperator(op string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == searchTokenWord && p.tokens[p.pos].value == op
+}
+
+func (p *searchParser) parseOr() (searchNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekOperator("OR") {
+		p.pos++
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = searchOrNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *searchParser) parseAnd() (searchNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.pos < len(p.tokens) && p.tokens[p.pos].kind != searchTokenRParen && !p.peekOperator("OR") {
+		if p.peekOperator("AND") {
+			p.pos++
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = searchAndNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *searchParser) parseUnary() (searchNode, error) {
+	if p.pos < len(p.tokens) && (p.tokens[p.pos].kind == searchTokenNot || p.peekOperator("NOT")) {
+		p.pos++
+
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return searchNotNode{node: node}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *searchParser) parsePrimary() (searchNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected end of query", ErrInvalidSearchQuery)
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case searchTokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != searchTokenRParen {
+			return nil, fmt.Errorf("%w: missing closing parenthesis", ErrInvalidSearchQuery)
+		}
+
+		p.pos++
+
+		return node, nil
+
+	case searchTokenWord, searchTokenPhrase:
+		if tok.kind == searchTokenWord && (tok.value == "AND" || tok.value == "OR" || tok.value == "NOT") {
+			return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidSearchQuery, tok.value)
+		}
+
+		terms := searchTerms(tok.value)
+
+		// Keep the prefix marker of the last term of a word.
+		if tok.kind == searchTokenWord && strings.HasSuffix(tok.value, "*") && len(terms) > 0 {
+			terms[len(terms)-1] += "*"
+		}
+
+		if len(terms) == 0 {
+			return searchAllNode{}, nil
+		}
+
+		return searchTermNode{terms: terms}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidSearchQuery, tok.value)
+	}
+}
+
+// searchTerms splits the text into lowercase terms made of letters and digits.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func intersectSearchSets(a, b searchSet) searchSet {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	res := make(searchSet, len(a))
+
+	for id := range a {
+		if _, ok := b[id]; ok {
+			res[id] = struct{}{}
+		}
+	}
+
+	return res
+}
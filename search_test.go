@@ -0,0 +1,317 @@
+package proton_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/mail"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gluon/async"
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/bradenaw/juniper/stream"
+	"github.com/bradenaw/juniper/xslices"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchIndex(t *testing.T) {
+	withTestClient(t, func(ctx context.Context, c *proton.Client) {
+		user, err := c.GetUser(ctx)
+		require.NoError(t, err)
+
+		addr, err := c.GetAddresses(ctx)
+		require.NoError(t, err)
+
+		salt, err := c.GetSalts(ctx)
+		require.NoError(t, err)
+
+		keyPass, err := salt.SaltForKey([]byte("pass"), user.Keys.Primary().ID)
+		require.NoError(t, err)
+
+		userKR, addrKRs, err := proton.Unlock(user, addr, keyPass, async.NoopPanicHandler{})
+		require.NoError(t, err)
+
+		invoice := importSearchMessage(ctx, t, c, "From: Alice <alice@example.com>\r\n"+
+			"Subject: Invoice for March\r\n"+
+			"Date: Mon, 02 Jan 2023 10:00:00 +0000\r\n\r\n"+
+			"Please find the quarterly report attached.")
+
+		party := importSearchMessage(ctx, t, c, "From: Bob <bob@example.com>\r\n"+
+			"Subject: Party\r\n"+
+			"Date: Mon, 03 Jul 2023 10:00:00 +0000\r\n"+
+			"Content-Type: text/html\r\n\r\n"+
+			"<html><body><p>Bring <b>snacks</b> to the party!</p></body></html>")
+
+		notes := importSearchMessage(ctx, t, c, "From: Alice <alice@example.com>\r\n"+
+			"Subject: Notes\r\n"+
+			"Date: Mon, 01 Jan 2024 10:00:00 +0000\r\n"+
+			"Content-Type: multipart/mixed; boundary=boundary\r\n\r\n"+
+			"--boundary\r\nContent-Type: text/plain\r\n\r\nSee the attachment.\r\n"+
+			"--boundary\r\nContent-Type: application/octet-stream\r\nContent-Disposition: attachment; filename=minutes.txt\r\n\r\nMinutes\r\n"+
+			"--boundary--\r\n")
+
+		path := filepath.Join(t.TempDir(), "search.idx")
+
+		idx, err := proton.OpenSearchIndex(path, userKR)
+		require.NoError(t, err)
+		require.Empty(t, idx.EventID())
+
+		// The first sync builds the index.
+		require.NoError(t, idx.Sync(ctx, c, addrKRs))
+		require.NotEmpty(t, idx.EventID())
+		require.Equal(t, 3, idx.Len())
+
+		search := func(query string, filter proton.SearchFilter) []string {
+			res, err := idx.Search(query, filter)
+			require.NoError(t, err)
+
+			return xslices.Map(res, func(meta proton.MessageMetadata) string { return meta.ID })
+		}
+
+		// Terms are searched in subjects, addresses, bodies and attachment names, case-insensitively.
+		require.Equal(t, []string{invoice}, search("invoice", proton.SearchFilter{}))
+		require.Equal(t, []string{invoice}, search("QUARTERLY", proton.SearchFilter{}))
+		require.Equal(t, []string{party}, search("snacks", proton.SearchFilter{}))
+		require.Equal(t, []string{notes}, search("minutes", proton.SearchFilter{}))
+		require.Equal(t, []string{notes, invoice}, search("alice@example.com", proton.SearchFilter{}))
+		require.Empty(t, search("html", proton.SearchFilter{}))
+
+		// Terms can be combined with boolean operators, and results are sorted by date, most recent first.
+		require.Equal(t, []string{notes, party, invoice}, search("", proton.SearchFilter{}))
+		require.Equal(t, []string{party, invoice}, search("invoice OR snacks", proton.SearchFilter{}))
+		require.Equal(t, []string{invoice}, search("alice report", proton.SearchFilter{}))
+		require.Equal(t, []string{invoice}, search("alice AND report", proton.SearchFilter{}))
+		require.Equal(t, []string{notes}, search("alice -report", proton.SearchFilter{}))
+		require.Equal(t, []string{party}, search("NOT alice", proton.SearchFilter{}))
+		require.Equal(t, []string{party, invoice}, search("(snacks OR report) AND NOT notes", proton.SearchFilter{}))
+		require.Equal(t, []string{invoice}, search(`"quarterly report"`, proton.SearchFilter{}))
+		require.Equal(t, []string{party}, search("par*", proton.SearchFilter{}))
+
+		for _, query := range []string{"(invoice", "invoice)", "invoice OR", `"invoice`, "AND"} {
+			_, err := idx.Search(query, proton.SearchFilter{})
+			require.ErrorIs(t, err, proton.ErrInvalidSearchQuery, query)
+		}
+
+		// Results can be filtered by date and label.
+		require.Equal(t, []string{party, invoice}, search("", proton.SearchFilter{End: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}))
+		require.Equal(t, []string{notes, party}, search("", proton.SearchFilter{Begin: time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC)}))
+		require.Empty(t, search("", proton.SearchFilter{LabelIDs: []string{proton.StarredLabel}}))
+
+		// The index is kept up to date from events.
+		require.NoError(t, c.LabelMessages(ctx, []string{party}, proton.StarredLabel))
+		require.NoError(t, c.DeleteMessage(ctx, invoice))
+
+		report := importSearchMessage(ctx, t, c, "From: Carol <carol@example.com>\r\n"+
+			"Subject: Another report\r\n"+
+			"Date: Mon, 05 Feb 2024 10:00:00 +0000\r\n\r\n"+
+			"Numbers.")
+
+		require.NoError(t, idx.Sync(ctx, c, addrKRs))
+		require.Equal(t, []string{party}, search("", proton.SearchFilter{LabelIDs: []string{proton.StarredLabel}}))
+		require.Equal(t, []string{report}, search("report", proton.SearchFilter{}))
+		require.Empty(t, search("invoice", proton.SearchFilter{}))
+
+		// Only the text attachments of messages are downloaded, to index their content.
+		var (
+			downloaded []string
+			lock       sync.Mutex
+		)
+
+		c.AddPreRequestHook(func(_ *resty.Client, req *resty.Request) error {
+			if attID, ok := strings.CutPrefix(req.URL, "/mail/v4/attachments/"); ok && req.Method == "GET" {
+				lock.Lock()
+				defer lock.Unlock()
+
+				downloaded = append(downloaded, attID)
+			}
+
+			return nil
+		})
+
+		draft, err := c.CreateDraft(ctx, addrKRs[addr[0].ID], proton.CreateDraftReq{
+			Message: proton.DraftTemplate{
+				Subject: "Meeting",
+				Sender:  &mail.Address{Address: addr[0].Email},
+				Body:    "See the attached files.",
+			},
+		})
+		require.NoError(t, err)
+
+		agenda, err := c.UploadAttachment(ctx, addrKRs[addr[0].ID], proton.CreateAttachmentReq{
+			MessageID:   draft.ID,
+			Filename:    "agenda.txt",
+			MIMEType:    "text/plain",
+			Disposition: proton.AttachmentDisposition,
+			Body:        []byte("Budget review"),
+		})
+		require.NoError(t, err)
+
+		_, err = c.UploadAttachment(ctx, addrKRs[addr[0].ID], proton.CreateAttachmentReq{
+			MessageID:   draft.ID,
+			Filename:    "photo.png",
+			MIMEType:    "image/png",
+			Disposition: proton.AttachmentDisposition,
+			Body:        []byte("Holiday"),
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, idx.Sync(ctx, c, addrKRs))
+		require.Equal(t, []string{draft.ID}, search("budget", proton.SearchFilter{}))
+		require.Equal(t, []string{draft.ID}, search("photo.png", proton.SearchFilter{}))
+		require.Empty(t, search("holiday", proton.SearchFilter{}))
+		require.NotEmpty(t, downloaded)
+		require.Equal(t, []string{agenda.ID}, xslices.Unique(downloaded))
+
+		// The index can be saved and opened again with the same keyring.
+		require.NoError(t, idx.Save())
+
+		reopened, err := proton.OpenSearchIndex(path, userKR)
+		require.NoError(t, err)
+		require.Equal(t, idx.EventID(), reopened.EventID())
+
+		res, err := reopened.Search("report OR snacks", proton.SearchFilter{})
+		require.NoError(t, err)
+		require.Equal(t, []string{report, party}, xslices.Map(res, func(meta proton.MessageMetadata) string { return meta.ID }))
+
+		// It cannot be opened with another keyring.
+		otherKey, err := crypto.GenerateKey("other", "other@example.com", "x25519", 0)
+		require.NoError(t, err)
+
+		otherKR, err := crypto.NewKeyRing(otherKey)
+		require.NoError(t, err)
+
+		_, err = proton.OpenSearchIndex(path, otherKR)
+		require.Error(t, err)
+	})
+}
+
+func TestSearchIndex_RepeatedEvent(t *testing.T) {
+	ctx := t.Context()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(&repeatingEventTransport{RoundTripper: proton.InsecureTransport(), events: make(map[string][]byte)}),
+	)
+	defer m.Close()
+
+	_, _, err := s.CreateUser("user", []byte("pass"))
+	require.NoError(t, err)
+
+	c, _, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	user, err := c.GetUser(ctx)
+	require.NoError(t, err)
+
+	addr, err := c.GetAddresses(ctx)
+	require.NoError(t, err)
+
+	salt, err := c.GetSalts(ctx)
+	require.NoError(t, err)
+
+	keyPass, err := salt.SaltForKey([]byte("pass"), user.Keys.Primary().ID)
+	require.NoError(t, err)
+
+	userKR, addrKRs, err := proton.Unlock(user, addr, keyPass, async.NoopPanicHandler{})
+	require.NoError(t, err)
+
+	idx, err := proton.OpenSearchIndex(filepath.Join(t.TempDir(), "search.idx"), userKR)
+	require.NoError(t, err)
+	require.NoError(t, idx.Sync(ctx, c, addrKRs))
+
+	messageID := importSearchMessage(ctx, t, c, "From: Alice <alice@example.com>\r\nSubject: Hello\r\n\r\nHello.")
+
+	var (
+		fetched []string
+		lock    sync.Mutex
+	)
+
+	c.AddPreRequestHook(func(_ *resty.Client, req *resty.Request) error {
+		if id, ok := strings.CutPrefix(req.URL, "/mail/v4/messages/"); ok && req.Method == "GET" {
+			lock.Lock()
+			defer lock.Unlock()
+
+			fetched = append(fetched, id)
+		}
+
+		return nil
+	})
+
+	// The creation event of the message is applied once.
+	require.NoError(t, idx.Sync(ctx, c, addrKRs))
+	require.Equal(t, []string{messageID}, fetched)
+
+	// The API answers with that event again when there is no new one; it isn't applied again.
+	require.NoError(t, idx.Sync(ctx, c, addrKRs))
+	require.Equal(t, []string{messageID}, fetched)
+}
+
+// repeatingEventTransport answers requests for the events following the latest one with the latest event in full,
+// as the API may, where the dev server answers with an empty event.
+type repeatingEventTransport struct {
+	http.RoundTripper
+
+	events map[string][]byte
+	lock   sync.Mutex
+}
+
+func (t *repeatingEventTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.RoundTripper.RoundTrip(req)
+	if err != nil || !strings.Contains(req.URL.Path, "/core/v4/events/") {
+		return res, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = res.Body.Close()
+
+	var event struct{ EventID string }
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if prev, ok := t.events[event.EventID]; ok && event.EventID == path.Base(req.URL.Path) {
+		body = prev
+	} else {
+		t.events[event.EventID] = body
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+
+	return res, nil
+}
+
+func importSearchMessage(ctx context.Context, t *testing.T, c *proton.Client, literal string) string {
+	t.Helper()
+
+	str, err := importMessage(t, c, ctx, "pass", literal)
+	require.NoError(t, err)
+
+	res, err := stream.Collect(ctx, str)
+	require.NoError(t, err)
+	require.Equal(t, proton.SuccessCode, res[0].Code)
+
+	return res[0].MessageID
+}
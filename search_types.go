@@ -0,0 +1,53 @@
+package proton
+
+import (
+	"slices"
+	"time"
+)
+
+// SearchFilter restricts the messages returned by a search, in addition to its query.
+type SearchFilter struct {
+	// LabelIDs restricts the results to messages that have all of the given labels.
+	LabelIDs []string
+
+	// AddressID restricts the results to messages of the given address.
+	AddressID string
+
+	// Begin and End restrict the results to messages received at or after Begin and before End, if they are non-zero.
+	Begin time.Time
+	End   time.Time
+}
+
+func (filter SearchFilter) matches(meta MessageMetadata) bool {
+	for _, labelID := range filter.LabelIDs {
+		if !slices.Contains(meta.LabelIDs, labelID) {
+			return false
+		}
+	}
+
+	if filter.AddressID != "" && filter.AddressID != meta.AddressID {
+		return false
+	}
+
+	if !filter.Begin.IsZero() && meta.Time < filter.Begin.Unix() {
+		return false
+	}
+
+	if !filter.End.IsZero() && meta.Time >= filter.End.Unix() {
+		return false
+	}
+
+	return true
+}
+
+// searchDoc is a message in the search index: its metadata and the terms of its content.
+type searchDoc struct {
+	Metadata MessageMetadata
+	Terms    []string
+}
+
+// searchState is the persisted state of the search index.
+type searchState struct {
+	EventID string
+	Docs    map[string]searchDoc
+}
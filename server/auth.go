@@ -2,9 +2,11 @@ package server
 
 import (
 	"encoding/base64"
+	"errors"
 	"net/http"
 
 	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server/backend"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -61,6 +63,25 @@ func (s *Server) handlePostAuth() gin.HandlerFunc {
 	}
 }
 
+func (s *Server) handlePostAuth2FA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.Auth2FAReq
+
+		if err := c.BindJSON(&req); err != nil {
+			return
+		}
+
+		if _, err := s.b.Auth2FA(c.GetString("UserID"), c.GetString("AuthUID"), req); err != nil {
+			log.WithError(err).Errorf("User '%v' failed two-factor authentication", c.GetString("UserID"))
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, proton.APIError{
+				Code:    proton.PasswordWrong,
+				Message: "Incorrect login credentials",
+			})
+			return
+		}
+	}
+}
+
 func (s *Server) handlePostAuthRefresh() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req proton.AuthRefreshReq
@@ -79,6 +100,27 @@ func (s *Server) handlePostAuthRefresh() gin.HandlerFunc {
 	}
 }
 
+func (s *Server) handleGetAuthModulus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.b.GetModulus())
+	}
+}
+
+// abortWithPasswordError aborts a request that changes the password or keys of the user.
+func abortWithPasswordError(c *gin.Context, err error) {
+	if errors.Is(err, backend.ErrWrongPassword) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, proton.APIError{
+			Code:    proton.PasswordWrong,
+			Message: "Incorrect login credentials",
+		})
+	} else {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, proton.APIError{
+			Code:    proton.InvalidValue,
+			Message: err.Error(),
+		})
+	}
+}
+
 func (s *Server) handleDeleteAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if err := s.b.DeleteSession(c.GetString("UserID"), c.GetString("AuthUID")); err != nil {
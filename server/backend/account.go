@@ -1,6 +1,8 @@
 package backend
 
 import (
+	"slices"
+
 	"github.com/ProtonMail/go-proton-api"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/bradenaw/juniper/xslices"
@@ -18,16 +20,33 @@ type account struct {
 
 	auth map[string]auth
 
+	// totpSecret is the base32-encoded TOTP secret of the account, if it has TOTP enabled.
+	totpSecret string
+
+	// fido2Keys holds the FIDO2 security keys registered for the account.
+	fido2Keys []*fido2Key
+
 	keys     []key
 	salt     []byte
 	verifier []byte
 
+	// keySalt salts the mailbox password into the passphrase of the user keys.
+	// In one-password mode, the mailbox password is the login password.
+	keySalt      []byte
+	passwordMode proton.PasswordMode
+
 	labelIDs   []string
 	messageIDs []string
 	updateIDs  []ID
+
+	// filters holds the mail filters of the account, in order of priority.
+	filters []*filter
+
+	// incomingDefaults holds the sender allow and block lists of the account.
+	incomingDefaults []proton.IncomingDefault
 }
 
-func newAccount(userID, username string, armKey string, salt, verifier []byte) *account {
+func newAccount(userID, username string, armKey string, salt, keySalt, verifier []byte, passwordMode proton.PasswordMode) *account {
 	return &account{
 		userID:       userID,
 		username:     username,
@@ -40,6 +59,9 @@ func newAccount(userID, username string, armKey string, salt, verifier []byte) *
 		keys:     []key{{keyID: uuid.NewString(), key: armKey}},
 		salt:     salt,
 		verifier: verifier,
+
+		keySalt:      keySalt,
+		passwordMode: passwordMode,
 	}
 }
 
@@ -89,3 +111,67 @@ func (acc *account) getAddr(email string) (*address, bool) {
 
 	return nil, false
 }
+
+// hasLabel returns whether the label is a system label or one of the labels and folders of the account.
+func (acc *account) hasLabel(labelID string) bool {
+	switch labelID {
+	case
+		proton.InboxLabel,
+		proton.AllDraftsLabel,
+		proton.AllSentLabel,
+		proton.TrashLabel,
+		proton.SpamLabel,
+		proton.AllMailLabel,
+		proton.ArchiveLabel,
+		proton.SentLabel,
+		proton.DraftsLabel,
+		proton.OutboxLabel,
+		proton.StarredLabel,
+		proton.AllScheduledLabel:
+		return true
+	}
+
+	return slices.Contains(acc.labelIDs, labelID)
+}
+
+// hasMessage returns whether the message belongs to one of the addresses of the account.
+func (acc *account) hasMessage(msg *message) bool {
+	_, ok := acc.addresses[msg.addrID]
+	return ok
+}
+
+func (acc *account) encrypt(addrID, decBody string) (string, error) {
+	pubKey, err := acc.addresses[addrID].keys[0].getPubKey()
+	if err != nil {
+		return "", err
+	}
+
+	kr, err := crypto.NewKeyRing(pubKey)
+	if err != nil {
+		return "", err
+	}
+
+	enc, err := kr.Encrypt(crypto.NewPlainMessageFromString(decBody), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return enc.GetArmored()
+}
+
+// twoFA returns the second factors enabled for the account, or zero if it has none.
+func (acc *account) twoFA() proton.TwoFAStatus {
+	switch {
+	case acc.totpSecret != "" && len(acc.fido2Keys) > 0:
+		return proton.HasFIDO2AndTOTP
+
+	case len(acc.fido2Keys) > 0:
+		return proton.HasFIDO2
+
+	case acc.totpSecret != "":
+		return proton.HasTOTP
+
+	default:
+		return 0
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"slices"
 
@@ -52,7 +53,7 @@ func (b *Backend) GetKeySalts(userID string) ([]proton.Salt, error) {
 			return xslices.Map(acc.keys, func(key key) proton.Salt {
 				return proton.Salt{
 					ID:      key.keyID,
-					KeySalt: base64.StdEncoding.EncodeToString(acc.salt),
+					KeySalt: base64.StdEncoding.EncodeToString(acc.keySalt),
 				}
 			}), nil
 		})
@@ -103,6 +104,29 @@ func (b *Backend) SetMailSettingsPGPScheme(userID string, scheme proton.Encrypti
 	})
 }
 
+func (b *Backend) SetMailSettingsAutoResponder(userID string, autoResponder proton.AutoResponder) (proton.MailSettings, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (proton.MailSettings, error) {
+		return withAcc(b, userID, func(acc *account) (proton.MailSettings, error) {
+			if _, err := time.LoadLocation(autoResponder.Zone); err != nil {
+				return proton.MailSettings{}, fmt.Errorf("invalid zone: %w", err)
+			}
+
+			if autoResponder.Repeat < proton.AutoResponderRepeatFixed || autoResponder.Repeat > proton.AutoResponderRepeatPermanent {
+				return proton.MailSettings{}, fmt.Errorf("invalid repeat mode: %v", autoResponder.Repeat)
+			}
+
+			if autoResponder.DaysSelected == nil {
+				autoResponder.DaysSelected = []time.Weekday{}
+			}
+
+			acc.mailSettings.autoResponder = autoResponder
+			acc.mailSettings.autoResponded = make(map[string]struct{})
+
+			return acc.mailSettings.toMailSettings(), nil
+		})
+	})
+}
+
 func (b *Backend) GetUserSettings(userID string) (proton.UserSettings, error) {
 	return readBackendRetErr(b, func(b *unsafeBackend) (proton.UserSettings, error) {
 		return withAcc(b, userID, func(acc *account) (proton.UserSettings, error) {
@@ -436,7 +460,18 @@ func (b *Backend) DeleteLabel(userID, labelID string) error {
 					acc.updateIDs = append(acc.updateIDs, updateID)
 				}
 
-				return nil
+				// Messages lose the deleted labels, so that their metadata and the label counts no longer list them;
+				// as with the API, this is implied by the label deletion events and no message events are sent.
+				return b.withMessages(func(messages map[string]*message) error {
+					for _, messageID := range acc.messageIDs {
+						messages[messageID].labelIDs = utils.Filter(messages[messageID].labelIDs, func(otherID string) bool {
+							_, ok := labels[otherID]
+							return ok
+						})
+					}
+
+					return nil
+				})
 			})
 		})
 	})
@@ -631,22 +666,26 @@ func (b *Backend) labelMessages(userID, labelID string, doEvents bool, messageID
 		return b.withAcc(userID, func(acc *account) error {
 			return b.withMessages(func(messages map[string]*message) error {
 				return b.withLabels(func(labels map[string]*label) error {
+					if !acc.hasLabel(labelID) {
+						return fmt.Errorf("no such label: %s", labelID)
+					}
+
 					for _, messageID := range messageIDs {
 						message, ok := messages[messageID]
-						if !ok {
+						if !ok || !acc.hasMessage(message) {
 							continue
 						}
 
-						message.addLabel(labelID, labels)
-
-						if doEvents {
-							updateID, err := b.newUpdate(&messageUpdated{messageID: messageID})
-							if err != nil {
-								return err
-							}
+						if !message.changeLabels(func() { message.addLabel(labelID, labels) }) || !doEvents {
+							continue
+						}
 
-							acc.updateIDs = append(acc.updateIDs, updateID)
+						updateID, err := b.newUpdate(&messageUpdated{messageID: messageID})
+						if err != nil {
+							return err
 						}
+
+						acc.updateIDs = append(acc.updateIDs, updateID)
 					}
 
 					return nil
@@ -666,7 +705,14 @@ func (b *Backend) UnlabelMessages(userID, labelID string, messageIDs ...string)
 			return b.withMessages(func(messages map[string]*message) error {
 				return b.withLabels(func(labels map[string]*label) error {
 					for _, messageID := range messageIDs {
-						messages[messageID].remLabel(labelID, labels)
+						message, ok := messages[messageID]
+						if !ok || !acc.hasMessage(message) {
+							continue
+						}
+
+						if !message.changeLabels(func() { message.remLabel(labelID, labels) }) {
+							continue
+						}
 
 						updateID, err := b.newUpdate(&messageUpdated{messageID: messageID})
 						if err != nil {
@@ -683,34 +729,99 @@ func (b *Backend) UnlabelMessages(userID, labelID string, messageIDs ...string)
 	})
 }
 
-func (b *Backend) DeleteMessage(userID, messageID string) error {
+// EmptyLabel permanently deletes the messages of the user with the given label.
+// If addrID is not empty, only the messages of that address are deleted.
+func (b *Backend) EmptyLabel(userID, labelID, addrID string) error {
 	return writeBackendRet(b, func(b *unsafeBackend) error {
+		if labelID == proton.AllMailLabel || labelID == proton.AllDraftsLabel || labelID == proton.AllSentLabel {
+			return fmt.Errorf("not allowed")
+		}
+
 		return b.withAcc(userID, func(acc *account) error {
 			return b.withMessages(func(messages map[string]*message) error {
-				message, ok := messages[messageID]
-				if !ok {
-					return errors.New("no such message")
+				if !acc.hasLabel(labelID) {
+					return fmt.Errorf("no such label: %s", labelID)
 				}
 
-				for _, attID := range message.attIDs {
-					if xslices.CountFunc(utils.Values(b.attachments), func(att *attachment) bool {
-						return att.attDataID == b.attachments[attID].attDataID
-					}) == 1 {
-						delete(b.attData, b.attachments[attID].attDataID)
+				if addrID != "" {
+					if _, ok := acc.addresses[addrID]; !ok {
+						return fmt.Errorf("no such address: %s", addrID)
+					}
+				}
+
+				for _, messageID := range slices.Clone(acc.messageIDs) {
+					message := messages[messageID]
+
+					if addrID != "" && message.addrID != addrID {
+						continue
+					}
+
+					if !slices.Contains(message.allLabelIDs(), labelID) {
+						continue
 					}
 
-					delete(b.attachments, attID)
+					if err := b.deleteMessage(acc, messageID); err != nil {
+						return err
+					}
 				}
 
-				delete(b.messages, messageID)
+				return nil
+			})
+		})
+	})
+}
 
-				updateID, err := b.newUpdate(&messageDeleted{messageID: messageID})
-				if err != nil {
-					return err
+func (b *Backend) DeleteMessage(userID, messageID string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			return b.withMessages(func(messages map[string]*message) error {
+				if _, ok := messages[messageID]; !ok {
+					return errors.New("no such message")
 				}
 
-				acc.messageIDs = utils.Filter(acc.messageIDs, func(otherID string) bool { return otherID != messageID })
-				acc.updateIDs = append(acc.updateIDs, updateID)
+				return b.deleteMessage(acc, messageID)
+			})
+		})
+	})
+}
+
+func (b *unsafeBackend) deleteMessage(acc *account, messageID string) error {
+	for _, attID := range b.messages[messageID].attIDs {
+		if xslices.CountFunc(utils.Values(b.attachments), func(att *attachment) bool {
+			return att.attDataID == b.attachments[attID].attDataID
+		}) == 1 {
+			delete(b.attData, b.attachments[attID].attDataID)
+		}
+
+		delete(b.attachments, attID)
+	}
+
+	delete(b.messages, messageID)
+
+	updateID, err := b.newUpdate(&messageDeleted{messageID: messageID})
+	if err != nil {
+		return err
+	}
+
+	acc.messageIDs = utils.Filter(acc.messageIDs, func(otherID string) bool { return otherID != messageID })
+	acc.updateIDs = append(acc.updateIDs, updateID)
+
+	return nil
+}
+
+// SetMessageThreading sets the Message-Id, In-Reply-To and References headers of a message.
+func (b *Backend) SetMessageThreading(userID, messageID, externalID, inReplyTo, references string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			return b.withMessages(func(messages map[string]*message) error {
+				msg, ok := messages[messageID]
+				if !ok || !acc.hasMessage(msg) {
+					return errors.New("no such message")
+				}
+
+				msg.externalID = externalID
+				msg.inReplyTo = inReplyTo
+				msg.references = references
 
 				return nil
 			})
@@ -718,36 +829,71 @@ func (b *Backend) DeleteMessage(userID, messageID string) error {
 	})
 }
 
-func (b *Backend) CreateDraft(userID, addrID string, draft proton.DraftTemplate, parentID string, action proton.CreateDraftAction) (proton.Message, error) {
+func (b *Backend) CreateDraft(
+	userID, addrID string,
+	draft proton.DraftTemplate,
+	parentID string,
+	action proton.CreateDraftAction,
+	attKeyPackets []string,
+) (proton.Message, error) {
 	return writeBackendRetErr(b, func(b *unsafeBackend) (proton.Message, error) {
 		return withAcc(b, userID, func(acc *account) (proton.Message, error) {
 			return withMessages(b, func(messages map[string]*message) (proton.Message, error) {
 				return withLabels(b, func(labels map[string]*label) (proton.Message, error) {
-					// Convert the parentID into externalRef.\
-					var parentRef string
-					var internalParentID string
-					if parentID != "" {
-						parentMsg, ok := messages[parentID]
-						if ok {
-							parentRef = "<" + parentMsg.externalID + ">"
-							internalParentID = parentID
+					return withAtts(b, func(atts map[string]*attachment) (proton.Message, error) {
+						// Convert the parentID into externalRef.\
+						var parentRef string
+						var internalParentID string
+						var parentMsg *message
+						if parentID != "" {
+							if msg, ok := messages[parentID]; ok {
+								parentRef = "<" + msg.externalID + ">"
+								internalParentID = parentID
+								parentMsg = msg
+							}
 						}
-					}
-					msg := newMessageFromTemplate(addrID, draft, parentRef, internalParentID, action)
-					// Drafts automatically get the sysLabel "Drafts".
-					msg.addLabel(proton.DraftsLabel, labels)
+						msg := newMessageFromTemplate(addrID, draft, parentRef, internalParentID, action)
+						// Drafts automatically get the sysLabel "Drafts".
+						msg.addLabel(proton.DraftsLabel, labels)
+
+						// Forward drafts carry the attachments of their parent over, with the given key packets.
+						if len(attKeyPackets) > 0 {
+							if action != proton.ForwardAction || parentMsg == nil || len(attKeyPackets) != len(parentMsg.attIDs) {
+								return proton.Message{}, errors.New("attachment key packets must match the attachments of the forwarded message")
+							}
 
-					messages[msg.messageID] = msg
+							for i, attID := range parentMsg.attIDs {
+								keyPackets, err := base64.StdEncoding.DecodeString(attKeyPackets[i])
+								if err != nil {
+									return proton.Message{}, fmt.Errorf("invalid attachment key packets: %w", err)
+								}
 
-					updateID, err := b.newUpdate(&messageCreated{messageID: msg.messageID})
-					if err != nil {
-						return proton.Message{}, err
-					}
+								att := newAttachment(
+									atts[attID].filename,
+									atts[attID].mimeType,
+									atts[attID].disposition,
+									atts[attID].contentID,
+									keyPackets,
+									atts[attID].attDataID,
+									atts[attID].armSig,
+								)
+								atts[att.attachID] = att
+								msg.attIDs = append(msg.attIDs, att.attachID)
+							}
+						}
 
-					acc.messageIDs = append(acc.messageIDs, msg.messageID)
-					acc.updateIDs = append(acc.updateIDs, updateID)
+						messages[msg.messageID] = msg
+
+						updateID, err := b.newUpdate(&messageCreated{messageID: msg.messageID})
+						if err != nil {
+							return proton.Message{}, err
+						}
+
+						acc.messageIDs = append(acc.messageIDs, msg.messageID)
+						acc.updateIDs = append(acc.updateIDs, updateID)
 
-					return msg.toMessage(nil, nil), nil
+						return msg.toMessage(b.attData, atts), nil
+					})
 				})
 			})
 		})
@@ -844,21 +990,7 @@ func (b *Backend) SendMessage(userID, messageID string, packages []*proton.Messa
 										return err
 									}
 
-									armBody, err := crypto.NewPGPSplitMessage(bodyKey, bodyData).GetPGPMessage().GetArmored()
-									if err != nil {
-										return err
-									}
-
-									addrID, err := b.getAddressID(email)
-									if err != nil {
-										return err
-									}
-
-									newMsg := newMessageFromSent(addrID, armBody, msg)
-									newMsg.flags |= proton.MessageFlagReceived
-									newMsg.addLabel(proton.InboxLabel, labels)
-									newMsg.unread = true
-									messages[newMsg.messageID] = newMsg
+									attKeys := make(map[string][]byte, len(msg.attIDs))
 
 									for _, attID := range msg.attIDs {
 										attKey, err := base64.StdEncoding.DecodeString(recipient.AttachmentKeyPackets[attID])
@@ -866,39 +998,47 @@ func (b *Backend) SendMessage(userID, messageID string, packages []*proton.Messa
 											return err
 										}
 
-										att := newAttachment(
-											atts[attID].filename,
-											atts[attID].mimeType,
-											atts[attID].disposition,
-											atts[attID].contentID,
-											attKey,
-											atts[attID].attDataID,
-											atts[attID].armSig,
-										)
-										atts[att.attachID] = att
-										messages[newMsg.messageID].attIDs = append(messages[newMsg.messageID].attIDs, att.attachID)
+										attKeys[attID] = attKey
+									}
+
+									addrID, err := b.getAddressID(email)
+									if err != nil {
+										return err
 									}
-									// Sort Message attachments
-									messages[newMsg.messageID].attIDs = sortAttachment(atts, messages[newMsg.messageID].attIDs)
-									msg.attIDs = sortAttachment(atts, msg.attIDs)
 
-									// Send the update event
-									updateID, err := b.newUpdate(&messageCreated{messageID: newMsg.messageID})
+									newMsg, err := b.deliverMessage(acc, addrID, msg, bodyKey, bodyData, attKeys, messages, labels, atts)
 									if err != nil {
 										return err
+									} else if newMsg == nil {
+										return nil
 									}
 
-									acc.messageIDs = append(acc.messageIDs, newMsg.messageID)
-									acc.updateIDs = append(acc.updateIDs, updateID)
+									if err := b.forwardMessage(acc, addrID, newMsg, msg, bodyKey, bodyData, attKeys, messages, labels, atts); err != nil {
+										return err
+									}
 
-									return nil
+									return b.autoRespond(acc, newMsg, b.accounts[userID], msg, labels)
 								}); err != nil {
 									return proton.Message{}, err
 								}
 							}
 						}
 
-						return msg.toMessage(b.attData, atts), nil
+						res := msg.toMessage(b.attData, atts)
+
+						// The filters of the sender run on the sent copy once it has been delivered.
+						fm, err := msg.toFilterMessage(len(msg.armBody))
+						if err != nil {
+							return proton.Message{}, err
+						}
+
+						if !acc.applyFilters(msg, fm, labels) {
+							if err := b.deleteMessage(acc, messageID); err != nil {
+								return proton.Message{}, err
+							}
+						}
+
+						return res, nil
 					})
 				})
 			})
@@ -906,6 +1046,72 @@ func (b *Backend) SendMessage(userID, messageID string, packages []*proton.Messa
 	})
 }
 
+// deliverMessage stores in the mailbox of the given address a received copy of the sent message,
+// whose body and attachments are encrypted with the given key packets.
+// It returns nil if the recipient's filters discarded the message.
+func (b *unsafeBackend) deliverMessage(
+	acc *account,
+	addrID string,
+	sent *message,
+	bodyKey, bodyData []byte,
+	attKeys map[string][]byte,
+	messages map[string]*message,
+	labels map[string]*label,
+	atts map[string]*attachment,
+) (*message, error) {
+	armBody, err := crypto.NewPGPSplitMessage(bodyKey, bodyData).GetPGPMessage().GetArmored()
+	if err != nil {
+		return nil, err
+	}
+
+	newMsg := newMessageFromSent(addrID, armBody, sent)
+	newMsg.flags |= proton.MessageFlagReceived
+	newMsg.addLabel(proton.InboxLabel, labels)
+	newMsg.unread = true
+
+	acc.applyIncomingDefaults(newMsg, labels)
+
+	fm, err := newMsg.toFilterMessage(len(bodyData))
+	if err != nil {
+		return nil, err
+	}
+
+	// Messages discarded by the filters of the recipient are not delivered.
+	if !acc.applyFilters(newMsg, fm, labels) {
+		return nil, nil
+	}
+
+	messages[newMsg.messageID] = newMsg
+
+	for _, attID := range sent.attIDs {
+		att := newAttachment(
+			atts[attID].filename,
+			atts[attID].mimeType,
+			atts[attID].disposition,
+			atts[attID].contentID,
+			attKeys[attID],
+			atts[attID].attDataID,
+			atts[attID].armSig,
+		)
+		atts[att.attachID] = att
+		newMsg.attIDs = append(newMsg.attIDs, att.attachID)
+	}
+	// Sort Message attachments
+	newMsg.attIDs = sortAttachment(atts, newMsg.attIDs)
+	sent.attIDs = sortAttachment(atts, sent.attIDs)
+
+	// Send the update event
+	updateID, err := b.newUpdate(&messageCreated{messageID: newMsg.messageID})
+	if err != nil {
+		return nil, err
+	}
+
+	acc.messageIDs = append(acc.messageIDs, newMsg.messageID)
+	acc.updateIDs = append(acc.updateIDs, updateID)
+
+	return newMsg, nil
+}
+
 func sortAttachment(atts map[string]*attachment, attIDs []string) []string {
 	// collect attachment with contentID
 	attContentId := make(map[string]string, len(attIDs))
@@ -1042,7 +1248,16 @@ func (b *Backend) GetEvent(userID, rawEventID string) (event proton.Event, more
 			return withMessages(b, func(messages map[string]*message) (proton.Event, error) {
 				return withLabels(b, func(labels map[string]*label) (proton.Event, error) {
 					return withAtts(b, func(attachments map[string]*attachment) (proton.Event, error) {
-						firstUpdate := slices.Index(acc.updateIDs, eventID) + 1
+						latestEventID := acc.updateIDs[len(acc.updateIDs)-1]
+
+						// Clients whose event ID is unknown or expired must refresh everything, as the API asks them to.
+						index := slices.Index(acc.updateIDs, eventID)
+
+						if index < 0 || (eventID != latestEventID && b.isUpdateExpired(eventID)) {
+							return buildEvent([]update{&userRefreshed{refresh: proton.RefreshAll}}, acc.addresses, messages, labels, latestEventID.String(), b.attData, attachments, acc.toUser()), nil
+						}
+
+						firstUpdate := index + 1
 						lastUpdate := getLastUpdateIndex(len(acc.updateIDs), firstUpdate, b.maxUpdatesPerEvent)
 
 						updates, err := withUpdates(b, func(updates map[ID]update) ([]update, error) {
@@ -1078,6 +1293,11 @@ func (b *Backend) GetPublicKeys(email string) ([]proton.PublicKey, error) {
 			for _, addr := range acc.addresses {
 				if addr.email == email {
 					for _, key := range addr.keys {
+						// Forwarding keys only decrypt forwarded messages; nobody encrypts to them.
+						if key.forwardingID != "" {
+							continue
+						}
+
 						pubKey, err := key.getPubKey()
 						if err != nil {
 							return nil, err
@@ -1186,7 +1406,8 @@ func (b *Backend) GetMessageGroupCount(userID string) ([]proton.MessageGroupCoun
 				labelStats := make(map[string]stats)
 
 				for _, msg := range m {
-					for _, lbl := range msg.getLabelIDs() {
+					// Custom labels and folders are counted too, as they are by the API.
+					for _, lbl := range msg.allLabelIDs() {
 						v, ok := labelStats[lbl]
 						if !ok {
 							v = stats{}
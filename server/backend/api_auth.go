@@ -1,11 +1,16 @@
 package backend
 
 import (
+	"crypto/rand"
+	"encoding/base32"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ProtonMail/go-proton-api"
 	"github.com/ProtonMail/go-srp"
+	"github.com/bradenaw/juniper/xslices"
 	"github.com/google/uuid"
 )
 
@@ -26,7 +31,7 @@ func (b *Backend) NewAuthInfo(username string) (proton.AuthInfo, error) {
 
 			session := uuid.NewString()
 
-			b.srp[session] = server
+			b.srp[session] = srpSession{userID: acc.userID, server: server}
 
 			return proton.AuthInfo{
 				Version:         4,
@@ -34,29 +39,49 @@ func (b *Backend) NewAuthInfo(username string) (proton.AuthInfo, error) {
 				ServerEphemeral: base64.StdEncoding.EncodeToString(challenge),
 				Salt:            base64.StdEncoding.EncodeToString(acc.salt),
 				SRPSession:      session,
+				TwoFA: proton.TwoFAInfo{
+					Enabled: acc.twoFA(),
+					FIDO2:   proton.FIDO2Info{RegisteredKeys: xslices.Map(acc.fido2Keys, (*fido2Key).toRegisteredKey)},
+				},
 			}, nil
 		})
 	})
 }
 
 func (b *Backend) NewAuth(username string, ephemeral, proof []byte, session string) (proton.Auth, error) {
+	rpID := b.domain
+
 	return writeBackendRetErr(b, func(b *unsafeBackend) (proton.Auth, error) {
 		return withAccName(b, username, func(acc *account) (proton.Auth, error) {
-			server, ok := b.srp[session]
-			if !ok {
+			srpSession, ok := b.srp[session]
+			if !ok || srpSession.userID != acc.userID {
 				log.Errorf("Session '%v' not found for user='%v'", session, username)
 				return proton.Auth{}, fmt.Errorf("invalid session")
 			}
 
 			delete(b.srp, session)
 
-			serverProof, err := server.VerifyProofs(ephemeral, proof)
+			serverProof, err := srpSession.server.VerifyProofs(ephemeral, proof)
 			if err != nil {
 				return proton.Auth{}, fmt.Errorf("invalid proof: %w", err)
 			}
 
 			authUID, auth := uuid.NewString(), newAuth(b.authLife)
 
+			auth.passwordMode = acc.passwordMode
+
+			// Sessions of accounts with a second factor only get full scope once it is verified.
+			auth.twoFA.Enabled = acc.twoFA()
+			auth.pending = auth.twoFA.Enabled != 0
+
+			if len(acc.fido2Keys) > 0 {
+				if auth.fido2Challenge, err = newFIDO2Challenge(); err != nil {
+					return proton.Auth{}, err
+				}
+
+				auth.twoFA.FIDO2 = newFIDO2Info(acc, rpID, auth.fido2Challenge)
+			}
+
 			acc.auth[authUID] = auth
 
 			return auth.toAuth(acc.userID, authUID, serverProof), nil
@@ -78,6 +103,11 @@ func (b *Backend) NewAuthRef(authUID, authRef string) (proton.Auth, error) {
 
 			newAuth := newAuth(b.authLife)
 
+			newAuth.twoFA = auth.twoFA
+			newAuth.pending = auth.pending
+			newAuth.fido2Challenge = auth.fido2Challenge
+			newAuth.passwordMode = auth.passwordMode
+
 			acc.auth[authUID] = newAuth
 
 			return newAuth.toAuth(acc.userID, authUID, nil), nil
@@ -87,7 +117,25 @@ func (b *Backend) NewAuthRef(authUID, authRef string) (proton.Auth, error) {
 	})
 }
 
+// ErrTwoFactorRequired is returned when verifying a session whose second factor was not verified yet.
+var ErrTwoFactorRequired = errors.New("two-factor authentication required")
+
+// VerifyAuth returns the ID of the user of the session.
+// It returns ErrTwoFactorRequired if the session still needs to verify its second factor.
 func (b *Backend) VerifyAuth(authUID, authAcc string) (string, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
+		return withAccAuth(b, authUID, authAcc, func(acc *account) (string, error) {
+			if acc.auth[authUID].pending {
+				return "", ErrTwoFactorRequired
+			}
+
+			return acc.userID, nil
+		})
+	})
+}
+
+// VerifyPartialAuth returns the ID of the user of the session, even if it still needs to verify its second factor.
+func (b *Backend) VerifyPartialAuth(authUID, authAcc string) (string, error) {
 	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
 		return withAccAuth(b, authUID, authAcc, func(acc *account) (string, error) {
 			return acc.userID, nil
@@ -95,6 +143,75 @@ func (b *Backend) VerifyAuth(authUID, authAcc string) (string, error) {
 	})
 }
 
+// Auth2FA verifies the second factor of the session, either a TOTP code or a FIDO2 assertion,
+// granting it full scope.
+func (b *Backend) Auth2FA(userID, authUID string, req proton.Auth2FAReq) (proton.TwoFAStatus, error) {
+	rpID := b.domain
+
+	return writeBackendRetErr(b, func(b *unsafeBackend) (proton.TwoFAStatus, error) {
+		return withAcc(b, userID, func(acc *account) (proton.TwoFAStatus, error) {
+			auth, ok := acc.auth[authUID]
+			if !ok {
+				return 0, fmt.Errorf("invalid auth")
+			}
+
+			if !auth.pending {
+				return 0, fmt.Errorf("two-factor authentication not required")
+			}
+
+			if req.TwoFactorCode != "" {
+				if acc.totpSecret == "" {
+					return 0, fmt.Errorf("TOTP not enabled")
+				}
+
+				// Tolerate codes of the periods just before and after the current one.
+				if ok, err := proton.ValidateTOTP(acc.totpSecret, req.TwoFactorCode, time.Now(), 1); err != nil {
+					return 0, err
+				} else if !ok {
+					return 0, fmt.Errorf("invalid TOTP code")
+				}
+			} else if err := verifyFIDO2(acc, auth, rpID, req.FIDO2); err != nil {
+				return 0, err
+			}
+
+			auth.pending = false
+
+			acc.auth[authUID] = auth
+
+			return auth.twoFA.Enabled, nil
+		})
+	})
+}
+
+// EnableTOTP enables TOTP for the user and returns the base32-encoded secret from which codes are generated.
+// It applies to the sessions created afterwards.
+func (b *Backend) EnableTOTP(userID string) (string, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
+		return withAcc(b, userID, func(acc *account) (string, error) {
+			key := make([]byte, 20)
+
+			if _, err := rand.Read(key); err != nil {
+				return "", err
+			}
+
+			acc.totpSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
+
+			return acc.totpSecret, nil
+		})
+	})
+}
+
+// DisableTOTP disables TOTP for the user. It applies to the sessions created afterwards.
+func (b *Backend) DisableTOTP(userID string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			acc.totpSecret = ""
+
+			return nil
+		})
+	})
+}
+
 func (b *Backend) GetSessions(userID string) ([]proton.AuthSession, error) {
 	return readBackendRetErr(b, func(b *unsafeBackend) ([]proton.AuthSession, error) {
 		return withAcc(b, userID, func(acc *account) ([]proton.AuthSession, error) {
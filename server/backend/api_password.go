@@ -0,0 +1,208 @@
+package backend
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+)
+
+// ErrWrongPassword is returned when the proof of the current login password is wrong.
+var ErrWrongPassword = errors.New("wrong password")
+
+// GetModulus returns the modulus clients compute SRP verifiers with.
+func (b *Backend) GetModulus() proton.AuthModulus {
+	return proton.AuthModulus{
+		Modulus:   modulus,
+		ModulusID: modulusID,
+	}
+}
+
+// UpdatePassword changes the login password of a user in two-password mode, given a proof of the current one.
+// The other sessions of the user are revoked. It returns the server proof.
+func (b *Backend) UpdatePassword(userID, authUID string, req proton.UpdatePasswordReq) ([]byte, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) ([]byte, error) {
+		return withAcc(b, userID, func(acc *account) ([]byte, error) {
+			// In one-password mode, the keys change with the password.
+			if acc.passwordMode != proton.TwoPasswordMode {
+				return nil, fmt.Errorf("user keys must be updated with the password")
+			}
+
+			salt, verifier, err := parseAuthVerifier(req.Auth)
+			if err != nil {
+				return nil, err
+			}
+
+			serverProof, err := b.verifyPassword(acc, req.PasswordProof)
+			if err != nil {
+				return nil, err
+			}
+
+			acc.salt, acc.verifier = salt, verifier
+
+			acc.revokeOtherSessions(authUID)
+
+			return serverProof, nil
+		})
+	})
+}
+
+// UpdatePrivateKeys replaces the user keys with the same keys locked with a new passphrase, given a proof of
+// the current login password. In one-password mode, the login password changes too. The address keys,
+// whose tokens are encrypted to the user keys, are unchanged. The other sessions of the user are revoked.
+// It returns the server proof.
+func (b *Backend) UpdatePrivateKeys(userID, authUID string, req proton.UpdatePrivateKeysReq) ([]byte, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) ([]byte, error) {
+		return withAcc(b, userID, func(acc *account) ([]byte, error) {
+			if (req.Auth != nil) != (acc.passwordMode == proton.OnePasswordMode) {
+				return nil, fmt.Errorf("the password must change with the user keys only in one-password mode")
+			}
+
+			keySalt, err := base64.StdEncoding.DecodeString(req.KeySalt)
+			if err != nil || len(keySalt) == 0 {
+				return nil, fmt.Errorf("invalid key salt")
+			}
+
+			keys, err := updateUserKeys(acc.keys, req.UserKeys)
+			if err != nil {
+				return nil, err
+			}
+
+			var salt, verifier []byte
+
+			if req.Auth != nil {
+				if salt, verifier, err = parseAuthVerifier(*req.Auth); err != nil {
+					return nil, err
+				}
+			}
+
+			serverProof, err := b.verifyPassword(acc, req.PasswordProof)
+			if err != nil {
+				return nil, err
+			}
+
+			acc.keys, acc.keySalt = keys, keySalt
+
+			if req.Auth != nil {
+				acc.salt, acc.verifier = salt, verifier
+			}
+
+			acc.revokeOtherSessions(authUID)
+
+			updateID, err := b.newUpdate(&userInfoUpdate{})
+			if err != nil {
+				return nil, err
+			}
+
+			acc.updateIDs = append(acc.updateIDs, updateID)
+
+			return serverProof, nil
+		})
+	})
+}
+
+// verifyPassword verifies the proof of the login password of the account, returning the server proof.
+func (b *unsafeBackend) verifyPassword(acc *account, proof proton.PasswordProof) ([]byte, error) {
+	srpSession, ok := b.srp[proof.SRPSession]
+	if !ok || srpSession.userID != acc.userID {
+		return nil, fmt.Errorf("invalid session")
+	}
+
+	delete(b.srp, proof.SRPSession)
+
+	clientEphemeral, err := base64.StdEncoding.DecodeString(proof.ClientEphemeral)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client ephemeral: %w", err)
+	}
+
+	clientProof, err := base64.StdEncoding.DecodeString(proof.ClientProof)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client proof: %w", err)
+	}
+
+	serverProof, err := srpSession.server.VerifyProofs(clientEphemeral, clientProof)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	return serverProof, nil
+}
+
+func (acc *account) revokeOtherSessions(authUID string) {
+	for uid := range acc.auth {
+		if uid != authUID {
+			delete(acc.auth, uid)
+		}
+	}
+}
+
+// parseAuthVerifier returns the salt and verifier of the SRP verifier, which must be computed with the modulus of the server.
+func parseAuthVerifier(auth proton.AuthVerifier) ([]byte, []byte, error) {
+	if auth.Version != 4 {
+		return nil, nil, fmt.Errorf("unsupported auth version %d", auth.Version)
+	}
+
+	if auth.ModulusID != modulusID {
+		return nil, nil, fmt.Errorf("unknown modulus")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(auth.Salt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid salt: %w", err)
+	}
+
+	verifier, err := base64.StdEncoding.DecodeString(auth.Verifier)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid verifier: %w", err)
+	}
+
+	return salt, verifier, nil
+}
+
+// updateUserKeys returns the user keys with their private keys replaced by the given ones.
+// All the keys must be replaced, by locked keys with the same fingerprints.
+func updateUserKeys(keys []key, entries []proton.PrivateKeyEntry) ([]key, error) {
+	if len(entries) != len(keys) {
+		return nil, fmt.Errorf("all user keys must be updated")
+	}
+
+	res := slices.Clone(keys)
+
+	updated := make(map[string]bool)
+
+	for _, entry := range entries {
+		idx := slices.IndexFunc(res, func(key key) bool { return key.keyID == entry.ID })
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown user key %s", entry.ID)
+		} else if updated[entry.ID] {
+			return nil, fmt.Errorf("user key %s updated twice", entry.ID)
+		}
+
+		updated[entry.ID] = true
+
+		oldKey, err := crypto.NewKeyFromArmored(res[idx].key)
+		if err != nil {
+			return nil, err
+		}
+
+		newKey, err := crypto.NewKeyFromArmored(entry.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user key %s: %w", entry.ID, err)
+		}
+
+		if newKey.GetFingerprint() != oldKey.GetFingerprint() {
+			return nil, fmt.Errorf("user key %s does not match", entry.ID)
+		}
+
+		if locked, err := newKey.IsLocked(); err != nil || !locked {
+			return nil, fmt.Errorf("user key %s is not locked", entry.ID)
+		}
+
+		res[idx].key = entry.PrivateKey
+	}
+
+	return res, nil
+}
@@ -39,16 +39,27 @@ type unsafeBackend struct {
 
 	labels map[string]*label
 
+	forwardings map[string]*forwarding
+
 	updates            map[ID]update
+	updateTimes        map[ID]time.Time
+	nextUpdateID       ID
 	maxUpdatesPerEvent int
 
-	srp map[string]*srp.Server
+	// eventRetention is how long event IDs remain valid; if zero, they never expire.
+	eventRetention time.Duration
+	lastCompaction time.Time
+
+	srp map[string]srpSession
 
 	authLife    time.Duration
 	enableDedup bool
 
 	csTicket []string
 
+	messageReports  []MessageReport
+	unsubscriptions []Unsubscription
+
 	featureFlags []proton.FeatureToggle
 
 	observabilityStatistics ObservabilityStatistics
@@ -98,9 +109,11 @@ func New(authLife time.Duration, domain string, enableDedup bool) *Backend {
 			attData:                 make(map[string][]byte),
 			messages:                make(map[string]*message),
 			labels:                  make(map[string]*label),
+			forwardings:             make(map[string]*forwarding),
 			updates:                 make(map[ID]update),
+			updateTimes:             make(map[ID]time.Time),
 			maxUpdatesPerEvent:      0,
-			srp:                     make(map[string]*srp.Server),
+			srp:                     make(map[string]srpSession),
 			authLife:                authLife,
 			enableDedup:             enableDedup,
 			observabilityStatistics: NewObservabilityStatistics(),
@@ -120,39 +133,79 @@ func (b *Backend) SetMaxUpdatesPerEvent(max int) {
 	})
 }
 
+// SetEventRetention sets how long event IDs remain valid. Clients asking for the events following an expired
+// event ID are told to refresh, and expired updates are regularly compacted away. If zero, event IDs never expire.
+func (b *Backend) SetEventRetention(retention time.Duration) {
+	writeBackend(b, func(b *unsafeBackend) {
+		b.eventRetention = retention
+	})
+}
+
+// CompactEvents removes the expired updates, keeping the latest event ID of each user valid.
+func (b *Backend) CompactEvents() {
+	writeBackend(b, func(b *unsafeBackend) {
+		b.compactUpdates()
+	})
+}
+
 func (b *Backend) CreateUser(username string, password []byte) (string, error) {
 	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
-		salt, err := crypto.RandomToken(16)
-		if err != nil {
-			return "", err
-		}
+		return b.createUser(username, password, nil)
+	})
+}
 
-		passphrase, err := hashPassword(password, salt)
-		if err != nil {
-			return "", err
-		}
+// CreateUserWithMailboxPassword creates a user in two-password mode: the password is used to log in,
+// and the mailbox password to unlock the keys.
+func (b *Backend) CreateUserWithMailboxPassword(username string, password, mailboxPassword []byte) (string, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
+		return b.createUser(username, password, mailboxPassword)
+	})
+}
 
-		srpAuth, err := srp.NewAuthForVerifier(password, modulus, salt)
-		if err != nil {
-			return "", err
-		}
+// createUser creates a user in two-password mode if it has a mailbox password, or in one-password mode otherwise.
+func (b *unsafeBackend) createUser(username string, password, mailboxPassword []byte) (string, error) {
+	salt, err := crypto.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
 
-		verifier, err := srpAuth.GenerateVerifier(2048)
-		if err != nil {
-			return "", err
-		}
+	keySalt, passwordMode := salt, proton.OnePasswordMode
 
-		armKey, err := GenerateKey(username, username, passphrase, "rsa", 2048)
-		if err != nil {
+	if mailboxPassword != nil {
+		if keySalt, err = crypto.RandomToken(16); err != nil {
 			return "", err
 		}
 
-		userID := uuid.NewString()
+		passwordMode = proton.TwoPasswordMode
+	} else {
+		mailboxPassword = password
+	}
 
-		b.accounts[userID] = newAccount(userID, username, armKey, salt, verifier)
+	passphrase, err := hashPassword(mailboxPassword, keySalt)
+	if err != nil {
+		return "", err
+	}
 
-		return userID, nil
-	})
+	srpAuth, err := srp.NewAuthForVerifier(password, modulus, salt)
+	if err != nil {
+		return "", err
+	}
+
+	verifier, err := srpAuth.GenerateVerifier(2048)
+	if err != nil {
+		return "", err
+	}
+
+	armKey, err := GenerateKey(username, username, passphrase, "rsa", 2048)
+	if err != nil {
+		return "", err
+	}
+
+	userID := uuid.NewString()
+
+	b.accounts[userID] = newAccount(userID, username, armKey, salt, keySalt, verifier, passwordMode)
+
+	return userID, nil
 }
 
 func (b *Backend) RemoveUser(userID string) error {
@@ -212,12 +265,7 @@ func (b *Backend) CreateUserKey(userID string, password []byte) error {
 			return fmt.Errorf("user %s does not exist", userID)
 		}
 
-		salt, err := crypto.RandomToken(16)
-		if err != nil {
-			return err
-		}
-
-		passphrase, err := hashPassword(password, salt)
+		passphrase, err := hashPassword(password, user.keySalt)
 		if err != nil {
 			return err
 		}
@@ -256,19 +304,27 @@ func (b *Backend) RemoveUserKey(userID, keyID string) error {
 
 func (b *Backend) CreateAddress(userID, email string, password []byte, withKey bool, status proton.AddressStatus, addrType proton.AddressType, withSending bool) (string, error) {
 	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
-		return b.createAddress(userID, email, password, withKey, status, addrType, false, withSending, 2048)
+		return b.createAddress(userID, email, password, withKey, status, addrType, false, withSending, "rsa", 2048)
 	})
 }
 
 func (b *Backend) CreateAddressWithCustomKeyLength(userID, email string, password []byte, withKey bool, status proton.AddressStatus, addrType proton.AddressType, withSending bool, keyLength int) (string, error) {
 	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
-		return b.createAddress(userID, email, password, withKey, status, addrType, false, withSending, keyLength)
+		return b.createAddress(userID, email, password, withKey, status, addrType, false, withSending, "rsa", keyLength)
+	})
+}
+
+// CreateAddressWithKeyType creates an address whose key is of the given type (e.g. "x25519"),
+// as needed to forward its messages to internal forwardees.
+func (b *Backend) CreateAddressWithKeyType(userID, email string, password []byte, withKey bool, status proton.AddressStatus, addrType proton.AddressType, withSending bool, keyType string) (string, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
+		return b.createAddress(userID, email, password, withKey, status, addrType, false, withSending, keyType, 2048)
 	})
 }
 
 func (b *Backend) CreateAddressAsUpdate(userID, email string, password []byte, withKey bool, status proton.AddressStatus, addrType proton.AddressType) (string, error) {
 	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
-		return b.createAddress(userID, email, password, withKey, status, addrType, true, true, 2048)
+		return b.createAddress(userID, email, password, withKey, status, addrType, true, true, "rsa", 2048)
 	})
 }
 
@@ -280,7 +336,7 @@ func (b *Backend) CreateAddressWithSendDisabled(
 	addrType proton.AddressType,
 ) (string, error) {
 	return writeBackendRetErr(b, func(b *unsafeBackend) (string, error) {
-		return b.createAddress(userID, email, password, withKey, status, addrType, false, false, 2048)
+		return b.createAddress(userID, email, password, withKey, status, addrType, false, false, "rsa", 2048)
 	})
 }
 
@@ -292,6 +348,7 @@ func (b *unsafeBackend) createAddress(
 	addrType proton.AddressType,
 	issueUpdateInsteadOfCreate bool,
 	allowSend bool,
+	keyType string,
 	keyLength int,
 ) (string, error) {
 	return withAcc(b, userID, func(acc *account) (string, error) {
@@ -307,12 +364,12 @@ func (b *unsafeBackend) createAddress(
 				return "", fmt.Errorf("invalid key length: %d", keyLength)
 			}
 
-			armKey, err := GenerateKey(acc.username, email, token, "rsa", keyLength)
+			armKey, err := GenerateKey(acc.username, email, token, keyType, keyLength)
 			if err != nil {
 				return "", err
 			}
 
-			passphrase, err := hashPassword([]byte(password), acc.salt)
+			passphrase, err := hashPassword([]byte(password), acc.keySalt)
 			if err != nil {
 				return "", err
 			}
@@ -421,7 +478,7 @@ func (b *Backend) CreateAddressKey(userID, addrID string, password []byte) error
 				return err
 			}
 
-			passphrase, err := hashPassword([]byte(password), acc.salt)
+			passphrase, err := hashPassword([]byte(password), acc.keySalt)
 			if err != nil {
 				return err
 			}
@@ -582,22 +639,7 @@ func (b *Backend) CreateMessage(
 func (b *Backend) Encrypt(userID, addrID, decBody string) (string, error) {
 	return readBackendRetErr(b, func(b *unsafeBackend) (string, error) {
 		return withAcc(b, userID, func(acc *account) (string, error) {
-			pubKey, err := acc.addresses[addrID].keys[0].getPubKey()
-			if err != nil {
-				return "", err
-			}
-
-			kr, err := crypto.NewKeyRing(pubKey)
-			if err != nil {
-				return "", err
-			}
-
-			enc, err := kr.Encrypt(crypto.NewPlainMessageFromString(decBody), nil)
-			if err != nil {
-				return "", err
-			}
-
-			return enc.GetArmored()
+			return acc.encrypt(addrID, decBody)
 		})
 	})
 }
@@ -661,7 +703,8 @@ func withAccAuth[T any](b *unsafeBackend, authUID, authAcc string, fn func(acc *
 		}
 
 		if time.Since(val.creation) > b.authLife {
-			acc.auth[authUID] = auth{ref: val.ref, creation: val.creation}
+			val.acc = ""
+			acc.auth[authUID] = val
 		} else if val.acc == authAcc {
 			return fn(acc)
 		}
@@ -691,15 +734,61 @@ func withLabels[T any](b *unsafeBackend, fn func(map[string]*label) (T, error))
 }
 
 func (b *unsafeBackend) newUpdate(event update) (ID, error) {
+	if b.eventRetention > 0 && time.Since(b.lastCompaction) >= b.eventRetention {
+		b.compactUpdates()
+	}
+
 	return withUpdates(b, func(updates map[ID]update) (ID, error) {
-		updateID := ID(len(updates))
+		updateID := b.nextUpdateID
+
+		b.nextUpdateID++
 
 		updates[updateID] = event
+		b.updateTimes[updateID] = time.Now()
 
 		return updateID, nil
 	})
 }
 
+// isUpdateExpired returns whether the event ID of the update has expired.
+func (b *unsafeBackend) isUpdateExpired(updateID ID) bool {
+	return b.eventRetention > 0 && time.Since(b.updateTimes[updateID]) > b.eventRetention
+}
+
+// compactUpdates removes the expired updates from the accounts and from the backend.
+// The latest update of each account is kept, even if it expired, so that up-to-date clients remain valid.
+func (b *unsafeBackend) compactUpdates() {
+	b.lastCompaction = time.Now()
+
+	if b.eventRetention == 0 {
+		return
+	}
+
+	kept := make(map[ID]struct{}, len(b.accounts))
+
+	for _, acc := range b.accounts {
+		if len(acc.updateIDs) == 0 {
+			continue
+		}
+
+		first := slices.IndexFunc(acc.updateIDs, func(updateID ID) bool { return !b.isUpdateExpired(updateID) })
+		if first < 0 {
+			first = len(acc.updateIDs) - 1
+		}
+
+		acc.updateIDs = slices.Clone(acc.updateIDs[first:])
+
+		kept[acc.updateIDs[0]] = struct{}{}
+	}
+
+	for updateID := range b.updates {
+		if _, ok := kept[updateID]; !ok && b.isUpdateExpired(updateID) {
+			delete(b.updates, updateID)
+			delete(b.updateTimes, updateID)
+		}
+	}
+}
+
 func withUpdates[T any](b *unsafeBackend, fn func(map[ID]update) (T, error)) (T, error) {
 	return fn(b.updates)
 }
@@ -2,7 +2,7 @@ package backend
 
 import "github.com/ProtonMail/gopenpgp/v2/crypto"
 
-var preCompKey *crypto.Key
+var preCompKey, preCompX25519Key *crypto.Key
 
 func init() {
 	key, err := crypto.GenerateKey("name", "email", "rsa", 1024)
@@ -11,12 +11,22 @@ func init() {
 	}
 
 	preCompKey = key
+
+	if preCompX25519Key, err = crypto.GenerateKey("name", "email", "x25519", 0); err != nil {
+		panic(err)
+	}
 }
 
-// FastGenerateKey is a fast version of GenerateKey that uses a pre-computed key.
+// FastGenerateKey is a fast version of GenerateKey that uses a pre-computed key of the requested type.
 // This is useful for testing but is incredibly insecure.
-func FastGenerateKey(_, _ string, passphrase []byte, _ string, _ int) (string, error) {
-	encKey, err := preCompKey.Lock(passphrase)
+func FastGenerateKey(_, _ string, passphrase []byte, keyType string, _ int) (string, error) {
+	key := preCompKey
+
+	if keyType == "x25519" {
+		key = preCompX25519Key
+	}
+
+	encKey, err := key.Lock(passphrase)
 	if err != nil {
 		return "", err
 	}
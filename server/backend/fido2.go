@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/bradenaw/juniper/xslices"
+)
+
+type fido2Key struct {
+	name         string
+	credentialID []byte
+	publicKey    *ecdsa.PublicKey
+	signCount    uint32
+}
+
+func (key *fido2Key) toRegisteredKey() proton.RegisteredKey {
+	return proton.RegisteredKey{
+		AttestationFormat: "none",
+		CredentialID:      bytesToInts(key.credentialID),
+		Name:              key.name,
+	}
+}
+
+func (b *Backend) RegisterFIDO2Key(userID, name string, credentialID, publicKey []byte) error {
+	pub, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok || ecPub.Curve != elliptic.P256() {
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			if slices.ContainsFunc(acc.fido2Keys, func(key *fido2Key) bool { return bytes.Equal(key.credentialID, credentialID) }) {
+				return fmt.Errorf("credential already registered")
+			}
+
+			acc.fido2Keys = append(acc.fido2Keys, &fido2Key{
+				name:         name,
+				credentialID: slices.Clone(credentialID),
+				publicKey:    ecPub,
+			})
+
+			return nil
+		})
+	})
+}
+
+func (b *Backend) RemoveFIDO2Key(userID string, credentialID []byte) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			idx := slices.IndexFunc(acc.fido2Keys, func(key *fido2Key) bool { return bytes.Equal(key.credentialID, credentialID) })
+			if idx < 0 {
+				return fmt.Errorf("credential not registered")
+			}
+
+			acc.fido2Keys = slices.Delete(acc.fido2Keys, idx, idx+1)
+
+			return nil
+		})
+	})
+}
+
+// newFIDO2Info returns the FIDO2 info of a new session of the account, with the options of an assertion
+// signing the given challenge with any of its registered keys for the relying party rpID.
+func newFIDO2Info(acc *account, rpID string, challenge []byte) proton.FIDO2Info {
+	return proton.FIDO2Info{
+		AuthenticationOptions: proton.FIDO2AuthenticationOptions{
+			PublicKey: proton.FIDO2PublicKeyOptions{
+				Timeout:   60000,
+				Challenge: bytesToInts(challenge),
+				RPID:      rpID,
+				AllowCredentials: xslices.Map(acc.fido2Keys, func(key *fido2Key) proton.FIDO2CredentialDescriptor {
+					return proton.FIDO2CredentialDescriptor{Type: "public-key", ID: bytesToInts(key.credentialID)}
+				}),
+				UserVerification: "discouraged",
+			},
+		},
+		RegisteredKeys: xslices.Map(acc.fido2Keys, (*fido2Key).toRegisteredKey),
+	}
+}
+
+// verifyFIDO2 verifies that the assertion of the request signs the challenge of the session
+// with one of the registered keys of the account, for the relying party rpID.
+func verifyFIDO2(acc *account, auth auth, rpID string, req proton.FIDO2Req) error {
+	if auth.fido2Challenge == nil {
+		return fmt.Errorf("FIDO2 not enabled")
+	}
+
+	credentialID := intsToBytes(req.CredentialID)
+
+	idx := slices.IndexFunc(acc.fido2Keys, func(key *fido2Key) bool { return bytes.Equal(key.credentialID, credentialID) })
+	if idx < 0 {
+		return fmt.Errorf("credential not registered")
+	}
+
+	key := acc.fido2Keys[idx]
+
+	clientData, err := base64.StdEncoding.DecodeString(req.ClientData)
+	if err != nil {
+		return fmt.Errorf("invalid client data: %w", err)
+	}
+
+	authData, err := base64.StdEncoding.DecodeString(req.AuthenticatorData)
+	if err != nil {
+		return fmt.Errorf("invalid authenticator data: %w", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	var data struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+
+	if err := json.Unmarshal(clientData, &data); err != nil {
+		return fmt.Errorf("invalid client data: %w", err)
+	}
+
+	if data.Type != "webauthn.get" {
+		return fmt.Errorf("invalid client data type %q", data.Type)
+	}
+
+	if data.Challenge != base64.RawURLEncoding.EncodeToString(auth.fido2Challenge) {
+		return fmt.Errorf("invalid challenge")
+	}
+
+	if origin, err := url.Parse(data.Origin); err != nil || (origin.Hostname() != rpID && !strings.HasSuffix(origin.Hostname(), "."+rpID)) {
+		return fmt.Errorf("invalid origin %q", data.Origin)
+	}
+
+	// The authenticator data starts with the hash of the relying party ID, the flags and the signature counter.
+	if len(authData) < 37 {
+		return fmt.Errorf("invalid authenticator data")
+	}
+
+	if rpIDHash := sha256.Sum256([]byte(rpID)); !bytes.Equal(authData[:32], rpIDHash[:]) {
+		return fmt.Errorf("invalid relying party")
+	}
+
+	if authData[32]&0x01 == 0 {
+		return fmt.Errorf("user not present")
+	}
+
+	signCount := binary.BigEndian.Uint32(authData[33:37])
+
+	// A counter that doesn't increase may mean the key was cloned.
+	if (signCount != 0 || key.signCount != 0) && signCount <= key.signCount {
+		return fmt.Errorf("invalid signature counter")
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+
+	digest := sha256.Sum256(append(slices.Clone(authData), clientDataHash[:]...))
+
+	if !ecdsa.VerifyASN1(key.publicKey, digest[:], sig) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	key.signCount = signCount
+
+	return nil
+}
+
+func newFIDO2Challenge() ([]byte, error) {
+	challenge := make([]byte, 32)
+
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func bytesToInts(b []byte) []int {
+	return xslices.Map(b, func(v byte) int { return int(v) })
+}
+
+func intsToBytes(v []int) []byte {
+	return xslices.Map(v, func(x int) byte { return byte(x) })
+}
@@ -0,0 +1,284 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ProtonMail/gluon/rfc822"
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/pkg/sieve"
+	"github.com/google/uuid"
+)
+
+var ErrNoSuchFilter = errors.New("no such filter")
+
+type filter struct {
+	filterID string
+	name     string
+	status   proton.FilterStatus
+	version  int
+	sieve    string
+	script   *sieve.Script
+}
+
+func newFilter(name string, status proton.FilterStatus, version int, src string) (*filter, error) {
+	script, err := sieve.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filter{
+		filterID: uuid.NewString(),
+		name:     name,
+		status:   status,
+		version:  version,
+		sieve:    src,
+		script:   script,
+	}, nil
+}
+
+func (f *filter) toFilter(priority int) proton.Filter {
+	return proton.Filter{
+		ID:       f.filterID,
+		Name:     f.name,
+		Status:   f.status,
+		Priority: priority,
+		Version:  f.version,
+		Sieve:    f.sieve,
+	}
+}
+
+// filterMessage is the view of a message that filters are evaluated against.
+type filterMessage struct {
+	header *rfc822.Header
+	size   int
+}
+
+func (msg filterMessage) Header(name string) []string {
+	var values []string
+
+	msg.header.Entries(func(key, val string) {
+		if strings.EqualFold(key, name) {
+			values = append(values, val)
+		}
+	})
+
+	return values
+}
+
+func (msg filterMessage) Size() int {
+	return msg.size
+}
+
+func (b *Backend) GetFilters(userID string) ([]proton.Filter, error) {
+	return readBackendRetErr(b, func(b *unsafeBackend) ([]proton.Filter, error) {
+		return withAcc(b, userID, func(acc *account) ([]proton.Filter, error) {
+			filters := make([]proton.Filter, 0, len(acc.filters))
+
+			for idx, f := range acc.filters {
+				filters = append(filters, f.toFilter(idx+1))
+			}
+
+			return filters, nil
+		})
+	})
+}
+
+func (b *Backend) CreateFilter(userID, name string, status proton.FilterStatus, version int, src string) (proton.Filter, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (proton.Filter, error) {
+		return withAcc(b, userID, func(acc *account) (proton.Filter, error) {
+			f, err := newFilter(name, status, version, src)
+			if err != nil {
+				return proton.Filter{}, err
+			}
+
+			acc.filters = append(acc.filters, f)
+
+			return f.toFilter(len(acc.filters)), nil
+		})
+	})
+}
+
+func (b *Backend) UpdateFilter(userID, filterID, name string, status proton.FilterStatus, version int, src string) (proton.Filter, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (proton.Filter, error) {
+		return withAcc(b, userID, func(acc *account) (proton.Filter, error) {
+			idx := slices.IndexFunc(acc.filters, func(f *filter) bool { return f.filterID == filterID })
+			if idx < 0 {
+				return proton.Filter{}, ErrNoSuchFilter
+			}
+
+			f, err := newFilter(name, status, version, src)
+			if err != nil {
+				return proton.Filter{}, err
+			}
+
+			f.filterID = filterID
+
+			acc.filters[idx] = f
+
+			return f.toFilter(idx + 1), nil
+		})
+	})
+}
+
+func (b *Backend) SetFilterStatus(userID, filterID string, status proton.FilterStatus) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			idx := slices.IndexFunc(acc.filters, func(f *filter) bool { return f.filterID == filterID })
+			if idx < 0 {
+				return ErrNoSuchFilter
+			}
+
+			acc.filters[idx].status = status
+
+			return nil
+		})
+	})
+}
+
+func (b *Backend) DeleteFilter(userID, filterID string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			idx := slices.IndexFunc(acc.filters, func(f *filter) bool { return f.filterID == filterID })
+			if idx < 0 {
+				return ErrNoSuchFilter
+			}
+
+			acc.filters = slices.Delete(acc.filters, idx, idx+1)
+
+			return nil
+		})
+	})
+}
+
+// OrderFilters sets the priority of the filters to the order of the given filter IDs, which must list all filters.
+func (b *Backend) OrderFilters(userID string, filterIDs []string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			if len(filterIDs) != len(acc.filters) {
+				return fmt.Errorf("expected %d filter IDs, got %d", len(acc.filters), len(filterIDs))
+			}
+
+			filters := make([]*filter, 0, len(filterIDs))
+
+			for _, filterID := range filterIDs {
+				idx := slices.IndexFunc(acc.filters, func(f *filter) bool { return f.filterID == filterID })
+				if idx < 0 || slices.Contains(filters, acc.filters[idx]) {
+					return fmt.Errorf("%w: %s", ErrNoSuchFilter, filterID)
+				}
+
+				filters = append(filters, acc.filters[idx])
+			}
+
+			acc.filters = filters
+
+			return nil
+		})
+	})
+}
+
+// ApplyFilters runs the enabled filters of the user on the given message, as when it is received.
+// The message is deleted if a filter discards it; ApplyFilters returns whether the message was kept.
+func (b *Backend) ApplyFilters(userID, messageID string, header *rfc822.Header, size int) (bool, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (bool, error) {
+		return withAcc(b, userID, func(acc *account) (bool, error) {
+			return withMessages(b, func(messages map[string]*message) (bool, error) {
+				return withLabels(b, func(labels map[string]*label) (bool, error) {
+					msg, ok := messages[messageID]
+					if !ok {
+						return false, errors.New("no such message")
+					}
+
+					// Like labelling on import, the changes are carried by the creation event of the message.
+					if acc.applyFilters(msg, filterMessage{header: header, size: size}, labels) {
+						return true, nil
+					}
+
+					return false, b.deleteMessage(acc, messageID)
+				})
+			})
+		})
+	})
+}
+
+// applyFilters runs the enabled filters of the account on the message, in order of priority,
+// filing it into the labels and folders they name and setting the flags they add.
+// It returns false if a filter discarded the message, in which case the caller must not keep it.
+func (acc *account) applyFilters(msg *message, fm filterMessage, labels map[string]*label) bool {
+	for _, f := range acc.filters {
+		if f.status != proton.FilterStatusEnabled {
+			continue
+		}
+
+		actions := f.script.Evaluate(fm)
+
+		if isDiscarded(actions) {
+			return false
+		}
+
+		for _, mailbox := range actions.FileInto {
+			if labelID, ok := acc.getFilterLabelID(mailbox, labels); ok {
+				msg.addLabel(labelID, labels)
+			}
+		}
+
+		for _, flag := range actions.Flags {
+			switch strings.ToLower(flag) {
+			case `\seen`:
+				msg.unread = false
+
+			case `\flagged`:
+				msg.starred = true
+			}
+		}
+	}
+
+	return true
+}
+
+// isDiscarded returns whether the actions of a script discard the message, rather than keep or file it somewhere.
+func isDiscarded(actions sieve.Actions) bool {
+	return actions.Discard && !actions.Keep && len(actions.FileInto) == 0
+}
+
+// getFilterLabelID returns the ID of the label or folder that a filter files messages into,
+// given either its path or, for system folders, its name.
+func (acc *account) getFilterLabelID(mailbox string, labels map[string]*label) (string, bool) {
+	switch strings.ToLower(mailbox) {
+	case "inbox":
+		return proton.InboxLabel, true
+
+	case "archive":
+		return proton.ArchiveLabel, true
+
+	case "trash":
+		return proton.TrashLabel, true
+
+	case "spam":
+		return proton.SpamLabel, true
+
+	case "starred":
+		return proton.StarredLabel, true
+	}
+
+	for _, labelID := range acc.labelIDs {
+		if strings.Join(labels[labelID].toLabel(labels).Path, "/") == mailbox {
+			return labelID, true
+		}
+	}
+
+	return "", false
+}
+
+// toFilterMessage returns the view of the message that filters are evaluated against,
+// with a header built from the metadata of the message.
+func (msg *message) toFilterMessage(size int) (filterMessage, error) {
+	header, err := rfc822.NewHeader([]byte(msg.getHeader()))
+	if err != nil {
+		return filterMessage{}, fmt.Errorf("failed to build filter header: %w", err)
+	}
+
+	return filterMessage{header: header, size: size}, nil
+}
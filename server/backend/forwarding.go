@@ -0,0 +1,444 @@
+package backend
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/pkg/sieve"
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/google/uuid"
+)
+
+var ErrNoSuchForwarding = errors.New("no such forwarding")
+
+type forwarding struct {
+	forwardingID string
+	fwdType      proton.ForwardingType
+	state        proton.ForwardingState
+	createTime   time.Time
+
+	forwarderID     string
+	forwarderAddrID string
+	forwardeeEmail  string
+
+	// forwardeeID and forwardeeAddrID are only set for internal forwardings.
+	forwardeeID     string
+	forwardeeAddrID string
+
+	filter *proton.ForwardingFilter
+	script *sieve.Script
+
+	// forwardeeKey and activationToken are the key shared with the forwardee, until they accept it.
+	forwardeeKey    string
+	activationToken string
+	instances       []packet.ForwardingInstance
+}
+
+func (fwd *forwarding) toOutgoing() proton.OutgoingForwarding {
+	return proton.OutgoingForwarding{
+		ID:                 fwd.forwardingID,
+		ForwarderAddressID: fwd.forwarderAddrID,
+		ForwardeeEmail:     fwd.forwardeeEmail,
+		Type:               fwd.fwdType,
+		State:              fwd.state,
+		Filter:             fwd.filter,
+		CreateTime:         fwd.createTime.Unix(),
+	}
+}
+
+func (fwd *forwarding) toIncoming(forwarderEmail string) proton.IncomingForwarding {
+	res := proton.IncomingForwarding{
+		ID:                 fwd.forwardingID,
+		ForwarderEmail:     forwarderEmail,
+		ForwardeeAddressID: fwd.forwardeeAddrID,
+		Type:               fwd.fwdType,
+		State:              fwd.state,
+		CreateTime:         fwd.createTime.Unix(),
+	}
+
+	if fwd.state == proton.ForwardingStatePending {
+		res.ForwardingKeys = []proton.ForwardingKey{{
+			PrivateKey:      fwd.forwardeeKey,
+			ActivationToken: fwd.activationToken,
+		}}
+	}
+
+	return res
+}
+
+// transformKeyPacket re-encrypts the session keys of the given key packets, encrypted to keys of the forwarder,
+// to the corresponding keys of the forwardee. Key packets of other keys are dropped.
+func (fwd *forwarding) transformKeyPacket(keyPacket []byte) ([]byte, error) {
+	var (
+		reader = packet.NewReader(bytes.NewReader(keyPacket))
+		buf    = new(bytes.Buffer)
+	)
+
+	for {
+		p, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		encKey, ok := p.(*packet.EncryptedKey)
+		if !ok {
+			continue
+		}
+
+		for _, instance := range fwd.instances {
+			if encKey.KeyId != instance.GetForwarderKeyId() {
+				continue
+			}
+
+			transformed, err := encKey.ProxyTransform(instance)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := transformed.Serialize(buf); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if buf.Len() == 0 {
+		return nil, errors.New("no forwarding instance for the key packet")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (b *Backend) GetOutgoingForwardings(userID string) ([]proton.OutgoingForwarding, error) {
+	return readBackendRetErr(b, func(b *unsafeBackend) ([]proton.OutgoingForwarding, error) {
+		return withAcc(b, userID, func(acc *account) ([]proton.OutgoingForwarding, error) {
+			res := []proton.OutgoingForwarding{}
+
+			for _, fwd := range b.getForwardings(func(fwd *forwarding) bool { return fwd.forwarderID == acc.userID }) {
+				res = append(res, fwd.toOutgoing())
+			}
+
+			return res, nil
+		})
+	})
+}
+
+func (b *Backend) GetIncomingForwardings(userID string) ([]proton.IncomingForwarding, error) {
+	return readBackendRetErr(b, func(b *unsafeBackend) ([]proton.IncomingForwarding, error) {
+		return withAcc(b, userID, func(acc *account) ([]proton.IncomingForwarding, error) {
+			res := []proton.IncomingForwarding{}
+
+			for _, fwd := range b.getForwardings(func(fwd *forwarding) bool { return fwd.forwardeeID == acc.userID }) {
+				res = append(res, fwd.toIncoming(b.accounts[fwd.forwarderID].addresses[fwd.forwarderAddrID].email))
+			}
+
+			return res, nil
+		})
+	})
+}
+
+// CreateForwarding creates a forwarding from one of the user's addresses.
+// Internal forwardings are pending until the forwardee accepts them. External forwardings are active right away,
+// as the dev server does not send verification emails to the forwardee.
+func (b *Backend) CreateForwarding(userID string, req proton.CreateForwardingReq) (proton.OutgoingForwarding, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (proton.OutgoingForwarding, error) {
+		return withAcc(b, userID, func(acc *account) (proton.OutgoingForwarding, error) {
+			if _, ok := acc.addresses[req.ForwarderAddressID]; !ok {
+				return proton.OutgoingForwarding{}, fmt.Errorf("no such address: %s", req.ForwarderAddressID)
+			}
+
+			if _, ok := acc.getAddr(req.ForwardeeEmail); ok {
+				return proton.OutgoingForwarding{}, errors.New("cannot forward to an address of the same account")
+			}
+
+			if len(b.getForwardings(func(fwd *forwarding) bool {
+				return fwd.forwarderAddrID == req.ForwarderAddressID && fwd.forwardeeEmail == req.ForwardeeEmail
+			})) > 0 {
+				return proton.OutgoingForwarding{}, errors.New("forwarding already exists")
+			}
+
+			fwd := &forwarding{
+				forwardingID:    uuid.NewString(),
+				fwdType:         req.Type,
+				state:           proton.ForwardingStateActive,
+				createTime:      time.Now(),
+				forwarderID:     acc.userID,
+				forwarderAddrID: req.ForwarderAddressID,
+				forwardeeEmail:  req.ForwardeeEmail,
+				filter:          req.Filter,
+			}
+
+			if req.Filter != nil {
+				script, err := sieve.Parse(req.Filter.Sieve)
+				if err != nil {
+					return proton.OutgoingForwarding{}, err
+				}
+
+				fwd.script = script
+			}
+
+			switch req.Type {
+			case proton.ForwardingTypeInternal:
+				if err := b.withAccEmail(req.ForwardeeEmail, func(forwardee *account) error {
+					addr, _ := forwardee.getAddr(req.ForwardeeEmail)
+
+					fwd.forwardeeID = forwardee.userID
+					fwd.forwardeeAddrID = addr.addrID
+
+					return nil
+				}); err != nil {
+					return proton.OutgoingForwarding{}, err
+				}
+
+				if _, err := crypto.NewKeyFromArmored(req.ForwardeePrivateKey); err != nil {
+					return proton.OutgoingForwarding{}, fmt.Errorf("invalid forwardee key: %w", err)
+				}
+
+				if req.ActivationToken == "" || len(req.ProxyInstances) == 0 {
+					return proton.OutgoingForwarding{}, errors.New("missing forwarding key material")
+				}
+
+				fwd.state = proton.ForwardingStatePending
+				fwd.forwardeeKey = req.ForwardeePrivateKey
+				fwd.activationToken = req.ActivationToken
+
+				for _, instance := range req.ProxyInstances {
+					fwd.instances = append(fwd.instances, packet.ForwardingInstance{
+						KeyVersion:           instance.PgpVersion,
+						ForwarderFingerprint: instance.ForwarderKeyFingerprint,
+						ForwardeeFingerprint: instance.ForwardeeKeyFingerprint,
+						ProxyParameter:       instance.ProxyParam,
+					})
+				}
+
+			case proton.ForwardingTypeExternal:
+				// Nothing to share with external forwardees.
+
+			default:
+				return proton.OutgoingForwarding{}, fmt.Errorf("invalid forwarding type: %d", req.Type)
+			}
+
+			b.forwardings[fwd.forwardingID] = fwd
+
+			return fwd.toOutgoing(), nil
+		})
+	})
+}
+
+// SetForwardingPaused pauses an active forwarding of the user, or resumes a paused one.
+func (b *Backend) SetForwardingPaused(userID, forwardingID string, paused bool) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		fwd, ok := b.forwardings[forwardingID]
+		if !ok || fwd.forwarderID != userID {
+			return ErrNoSuchForwarding
+		}
+
+		switch {
+		case paused && fwd.state == proton.ForwardingStateActive:
+			fwd.state = proton.ForwardingStatePaused
+
+		case !paused && fwd.state == proton.ForwardingStatePaused:
+			fwd.state = proton.ForwardingStateActive
+
+		default:
+			return fmt.Errorf("forwarding is in state %d", fwd.state)
+		}
+
+		return nil
+	})
+}
+
+// AcceptForwarding activates a pending forwarding to one of the user's addresses.
+// The forwarding keys, re-locked by the forwardee, are added to the keys of the forwardee address.
+func (b *Backend) AcceptForwarding(userID, forwardingID string, keys []proton.AcceptForwardingKey) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			fwd, ok := b.forwardings[forwardingID]
+			if !ok || fwd.forwardeeID != acc.userID {
+				return ErrNoSuchForwarding
+			}
+
+			if fwd.state != proton.ForwardingStatePending {
+				return fmt.Errorf("forwarding is in state %d", fwd.state)
+			}
+
+			if len(keys) == 0 {
+				return errors.New("missing forwarding keys")
+			}
+
+			addr := acc.addresses[fwd.forwardeeAddrID]
+
+			for _, fwdKey := range keys {
+				if _, err := crypto.NewKeyFromArmored(fwdKey.PrivateKey); err != nil {
+					return fmt.Errorf("invalid forwarding key: %w", err)
+				}
+
+				addr.keys = append(addr.keys, key{
+					keyID:        uuid.NewString(),
+					key:          fwdKey.PrivateKey,
+					tok:          fwdKey.Token,
+					sig:          fwdKey.Signature,
+					forwardingID: fwd.forwardingID,
+				})
+			}
+
+			fwd.state = proton.ForwardingStateActive
+			fwd.forwardeeKey = ""
+			fwd.activationToken = ""
+
+			updateID, err := b.newUpdate(&addressUpdated{addressID: addr.addrID})
+			if err != nil {
+				return err
+			}
+
+			acc.updateIDs = append(acc.updateIDs, updateID)
+
+			return nil
+		})
+	})
+}
+
+func (b *Backend) RejectForwarding(userID, forwardingID string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		fwd, ok := b.forwardings[forwardingID]
+		if !ok || fwd.forwardeeID != userID {
+			return ErrNoSuchForwarding
+		}
+
+		if fwd.state != proton.ForwardingStatePending {
+			return fmt.Errorf("forwarding is in state %d", fwd.state)
+		}
+
+		fwd.state = proton.ForwardingStateRejected
+		fwd.forwardeeKey = ""
+		fwd.activationToken = ""
+
+		return nil
+	})
+}
+
+// DeleteForwarding deletes a forwarding from or to the user.
+// The forwarding keys are removed from the forwardee address.
+func (b *Backend) DeleteForwarding(userID, forwardingID string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		fwd, ok := b.forwardings[forwardingID]
+		if !ok || (fwd.forwarderID != userID && fwd.forwardeeID != userID) {
+			return ErrNoSuchForwarding
+		}
+
+		delete(b.forwardings, forwardingID)
+
+		forwardee, ok := b.accounts[fwd.forwardeeID]
+		if !ok {
+			return nil
+		}
+
+		addr, ok := forwardee.addresses[fwd.forwardeeAddrID]
+		if !ok {
+			return nil
+		}
+
+		if !slices.ContainsFunc(addr.keys, func(key key) bool { return key.forwardingID == forwardingID }) {
+			return nil
+		}
+
+		addr.keys = slices.DeleteFunc(addr.keys, func(key key) bool { return key.forwardingID == forwardingID })
+
+		updateID, err := b.newUpdate(&addressUpdated{addressID: addr.addrID})
+		if err != nil {
+			return err
+		}
+
+		forwardee.updateIDs = append(forwardee.updateIDs, updateID)
+
+		return nil
+	})
+}
+
+// getForwardings returns the forwardings matching the given predicate, oldest first.
+func (b *unsafeBackend) getForwardings(fn func(fwd *forwarding) bool) []*forwarding {
+	var res []*forwarding
+
+	for _, fwd := range b.forwardings {
+		if fn(fwd) {
+			res = append(res, fwd)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b *forwarding) int {
+		return a.createTime.Compare(b.createTime)
+	})
+
+	return res
+}
+
+// forwardMessage runs the active forwardings of the address that received the message.
+// Messages are delivered to internal forwardees with their key packets re-encrypted to the forwarding keys;
+// external forwardings only flag the received message, as the dev server does not deliver outside of it.
+// Forwarded copies are not forwarded any further.
+func (b *unsafeBackend) forwardMessage(
+	acc *account,
+	addrID string,
+	received, sent *message,
+	bodyKey, bodyData []byte,
+	attKeys map[string][]byte,
+	messages map[string]*message,
+	labels map[string]*label,
+	atts map[string]*attachment,
+) error {
+	for _, fwd := range b.getForwardings(func(fwd *forwarding) bool {
+		return fwd.forwarderID == acc.userID && fwd.forwarderAddrID == addrID && fwd.state == proton.ForwardingStateActive
+	}) {
+		if fwd.script != nil {
+			fm, err := received.toFilterMessage(len(bodyData))
+			if err != nil {
+				return err
+			}
+
+			if isDiscarded(fwd.script.Evaluate(fm)) {
+				continue
+			}
+		}
+
+		received.flags |= proton.MessageFlagAutoForwarder
+
+		if fwd.fwdType != proton.ForwardingTypeInternal {
+			continue
+		}
+
+		forwardee, ok := b.accounts[fwd.forwardeeID]
+		if !ok {
+			continue
+		}
+
+		fwdBodyKey, err := fwd.transformKeyPacket(bodyKey)
+		if err != nil {
+			log.WithError(err).WithField("forwardingID", fwd.forwardingID).Warn("Cannot forward message")
+			continue
+		}
+
+		fwdAttKeys := make(map[string][]byte, len(attKeys))
+
+		for attID, attKey := range attKeys {
+			if fwdAttKeys[attID], err = fwd.transformKeyPacket(attKey); err != nil {
+				return err
+			}
+		}
+
+		fwdMsg, err := b.deliverMessage(forwardee, fwd.forwardeeAddrID, sent, fwdBodyKey, bodyData, fwdAttKeys, messages, labels, atts)
+		if err != nil {
+			return err
+		} else if fwdMsg != nil {
+			fwdMsg.flags |= proton.MessageFlagAutoForwardee
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package backend
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/bradenaw/juniper/xslices"
+	"github.com/google/uuid"
+)
+
+func (b *Backend) GetIncomingDefaults(userID string, page, pageSize int) (int, []proton.IncomingDefault, error) {
+	var total int
+
+	incomingDefaults, err := readBackendRetErr(b, func(b *unsafeBackend) ([]proton.IncomingDefault, error) {
+		return withAcc(b, userID, func(acc *account) ([]proton.IncomingDefault, error) {
+			total = len(acc.incomingDefaults)
+
+			chunks := xslices.Chunk(acc.incomingDefaults, pageSize)
+			if page >= len(chunks) {
+				return []proton.IncomingDefault{}, nil
+			}
+
+			return slices.Clone(chunks[page]), nil
+		})
+	})
+
+	return total, incomingDefaults, err
+}
+
+// AddIncomingDefault adds a sender to the allow or block lists, overwriting any existing entry for the same sender.
+func (b *Backend) AddIncomingDefault(userID string, location proton.IncomingDefaultLocation, email, domain string) (proton.IncomingDefault, error) {
+	return writeBackendRetErr(b, func(b *unsafeBackend) (proton.IncomingDefault, error) {
+		return withAcc(b, userID, func(acc *account) (proton.IncomingDefault, error) {
+			if location != proton.IncomingDefaultLocationInbox && location != proton.IncomingDefaultLocationSpam {
+				return proton.IncomingDefault{}, errors.New("invalid location")
+			}
+
+			entry := proton.IncomingDefault{
+				ID:       uuid.NewString(),
+				Location: location,
+				Time:     time.Now().Unix(),
+			}
+
+			switch {
+			case email != "" && domain == "":
+				entry.Type, entry.Email = proton.IncomingDefaultTypeEmail, strings.ToLower(email)
+
+			case domain != "" && email == "":
+				entry.Type, entry.Domain = proton.IncomingDefaultTypeDomain, strings.ToLower(domain)
+
+			default:
+				return proton.IncomingDefault{}, errors.New("either the email or the domain must be set")
+			}
+
+			if idx := slices.IndexFunc(acc.incomingDefaults, func(other proton.IncomingDefault) bool {
+				return other.Email == entry.Email && other.Domain == entry.Domain
+			}); idx >= 0 {
+				entry.ID = acc.incomingDefaults[idx].ID
+				acc.incomingDefaults[idx] = entry
+			} else {
+				acc.incomingDefaults = append(acc.incomingDefaults, entry)
+			}
+
+			return entry, nil
+		})
+	})
+}
+
+func (b *Backend) DeleteIncomingDefaults(userID string, incomingDefaultIDs ...string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			acc.incomingDefaults = slices.DeleteFunc(acc.incomingDefaults, func(entry proton.IncomingDefault) bool {
+				return slices.Contains(incomingDefaultIDs, entry.ID)
+			})
+
+			return nil
+		})
+	})
+}
+
+// applyIncomingDefaults delivers the message to the location the allow and block lists of the account give for its sender.
+func (acc *account) applyIncomingDefaults(msg *message, labels map[string]*label) {
+	if msg.sender == nil {
+		return
+	}
+
+	email := strings.ToLower(msg.sender.Address)
+
+	entry, ok := acc.getIncomingDefault(func(entry proton.IncomingDefault) bool { return entry.Email == email })
+	if !ok {
+		_, domain, found := strings.Cut(email, "@")
+		if !found {
+			return
+		}
+
+		if entry, ok = acc.getIncomingDefault(func(entry proton.IncomingDefault) bool { return entry.Domain == domain }); !ok {
+			return
+		}
+	}
+
+	switch entry.Location {
+	case proton.IncomingDefaultLocationInbox:
+		msg.addLabel(proton.InboxLabel, labels)
+
+	case proton.IncomingDefaultLocationSpam:
+		msg.addLabel(proton.SpamLabel, labels)
+	}
+}
+
+func (acc *account) getIncomingDefault(fn func(proton.IncomingDefault) bool) (proton.IncomingDefault, bool) {
+	idx := slices.IndexFunc(acc.incomingDefaults, fn)
+	if idx < 0 {
+		return proton.IncomingDefault{}, false
+	}
+
+	return acc.incomingDefaults[idx], true
+}
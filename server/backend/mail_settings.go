@@ -1,6 +1,9 @@
 package backend
 
 import (
+	"net/mail"
+	"time"
+
 	"github.com/ProtonMail/gluon/rfc822"
 	"github.com/ProtonMail/go-proton-api"
 )
@@ -11,6 +14,10 @@ type mailSettings struct {
 	pgpScheme     proton.EncryptionScheme
 	draftMIMEType rfc822.MIMEType
 	attachPubKey  bool
+	autoResponder proton.AutoResponder
+
+	// autoResponded holds the senders already sent an auto-response since the auto-responder was last configured.
+	autoResponded map[string]struct{}
 }
 
 func newMailSettings(displayName string) *mailSettings {
@@ -20,6 +27,12 @@ func newMailSettings(displayName string) *mailSettings {
 		attachPubKey:  false,
 		sign:          0,
 		pgpScheme:     0,
+		autoResponder: proton.AutoResponder{
+			DaysSelected: []time.Weekday{},
+			Zone:         "UTC",
+			Subject:      "Auto",
+		},
+		autoResponded: make(map[string]struct{}),
 	}
 }
 
@@ -30,5 +43,71 @@ func (settings *mailSettings) toMailSettings() proton.MailSettings {
 		AttachPublicKey: proton.Bool(settings.attachPubKey),
 		Sign:            settings.sign,
 		PGPScheme:       settings.pgpScheme,
+		AutoResponder:   settings.autoResponder,
+	}
+}
+
+// autoRespond sends the auto-response of the account that received the given message, if its auto-responder is active,
+// to the mailbox of the account that sent it. Each sender gets a single auto-response until the auto-responder is reconfigured.
+func (b *unsafeBackend) autoRespond(acc *account, received *message, senderAcc *account, sent *message, labels map[string]*label) error {
+	settings := acc.mailSettings
+
+	// Auto-generated messages are not responded to, so that two auto-responders do not answer each other forever.
+	if acc == senderAcc || sent.flags.Has(proton.MessageFlagAuto) || !settings.autoResponder.IsActive(time.Now()) {
+		return nil
+	}
+
+	if _, ok := settings.autoResponded[sent.sender.Address]; ok {
+		return nil
+	}
+
+	armBody, err := senderAcc.encrypt(sent.addrID, settings.autoResponder.Message)
+	if err != nil {
+		return err
+	}
+
+	subject := settings.autoResponder.Subject
+	if subject == "" {
+		subject = "Auto: " + sent.subject
 	}
+
+	addr := acc.addresses[received.addrID]
+
+	res := newMessage(
+		sent.addrID,
+		subject,
+		&mail.Address{Name: addr.displayName, Address: addr.email},
+		[]*mail.Address{sent.sender}, nil, nil, nil,
+		armBody,
+		rfc822.TextHTML,
+		"",
+		time.Now(),
+	)
+
+	res.flags |= proton.MessageFlagReceived | proton.MessageFlagInternal | proton.MessageFlagAuto
+	res.draftAction = proton.AutoResponseAction
+	res.internalParentID = sent.messageID
+	res.unread = true
+	res.addLabel(proton.InboxLabel, labels)
+
+	if sent.externalID != "" {
+		res.inReplyTo = "<" + sent.externalID + ">"
+		res.references = res.inReplyTo
+	}
+
+	b.messages[res.messageID] = res
+
+	createdID, err := b.newUpdate(&messageCreated{messageID: res.messageID})
+	if err != nil {
+		return err
+	}
+
+	senderAcc.messageIDs = append(senderAcc.messageIDs, res.messageID)
+	senderAcc.updateIDs = append(senderAcc.updateIDs, createdID)
+
+	received.flags |= proton.MessageFlagAutoReplied
+
+	settings.autoResponded[sent.sender.Address] = struct{}{}
+
+	return nil
 }
@@ -20,6 +20,7 @@ type message struct {
 	labelIDs         []string
 	attIDs           []string
 	inReplyTo        string
+	references       string
 	internalParentID string
 
 	// sysLabel is the system label for the message.
@@ -38,6 +39,10 @@ type message struct {
 
 	draftAction proton.CreateDraftAction
 
+	// listUnsubscribe and listUnsubscribePost are the unsubscribe headers of messages from mailing lists.
+	listUnsubscribe     string
+	listUnsubscribePost string
+
 	armBody  string
 	mimeType rfc822.MIMEType
 
@@ -90,9 +95,10 @@ func newMessageFromSent(addrID, armBody string, msg *message) *message {
 		replytos: msg.replytos,
 		date:     time.Now(),
 
-		armBody:   armBody,
-		mimeType:  msg.mimeType,
-		inReplyTo: msg.inReplyTo,
+		armBody:    armBody,
+		mimeType:   msg.mimeType,
+		inReplyTo:  msg.inReplyTo,
+		references: msg.references,
 	}
 }
 
@@ -103,12 +109,24 @@ func newMessageFromTemplate(
 	internalParentID string,
 	action proton.CreateDraftAction,
 ) *message {
+	// Threading headers set by the client take precedence over the ones derived from the parent.
+	inReplyTo := parentRef
+	if template.InReplyTo != "" {
+		inReplyTo = template.InReplyTo
+	}
+
+	references := inReplyTo
+	if template.References != "" {
+		references = template.References
+	}
+
 	return &message{
 		messageID:        uuid.NewString(),
 		externalID:       template.ExternalID,
 		addrID:           addrID,
 		sysLabel:         new(""),
-		inReplyTo:        parentRef,
+		inReplyTo:        inReplyTo,
+		references:       references,
 		internalParentID: internalParentID,
 
 		subject: template.Subject,
@@ -177,19 +195,44 @@ func (msg *message) getLabelIDs() []string {
 	return labelIDs
 }
 
+// allLabelIDs returns the IDs of the custom labels and folders of the message, followed by its system labels.
+func (msg *message) allLabelIDs() []string {
+	return slices.Concat(msg.labelIDs, msg.getLabelIDs())
+}
+
+// changeLabels applies the given change to the labels of the message and returns whether they changed.
+func (msg *message) changeLabels(fn func()) bool {
+	before := msg.allLabelIDs()
+
+	fn()
+
+	after := msg.allLabelIDs()
+
+	slices.Sort(before)
+	slices.Sort(after)
+
+	return !slices.Equal(before, after)
+}
+
 func (msg *message) toMetadata(attData map[string][]byte, att map[string]*attachment) proton.MessageMetadata {
-	labelIDs := msg.getLabelIDs()
+	labelIDs := msg.allLabelIDs()
 
 	messageSize := len(msg.armBody)
 	for _, a := range msg.attIDs {
 		messageSize += len(attData[att[a].attDataID])
 	}
 
+	// Drafts have no date until they are sent.
+	var date int64
+	if !msg.date.IsZero() {
+		date = msg.date.Unix()
+	}
+
 	return proton.MessageMetadata{
 		ID:         msg.messageID,
 		ExternalID: msg.externalID,
 		AddressID:  msg.addrID,
-		LabelIDs:   append(msg.labelIDs, labelIDs...),
+		LabelIDs:   labelIDs,
 
 		Subject:  msg.subject,
 		Sender:   msg.sender,
@@ -197,6 +240,7 @@ func (msg *message) toMetadata(attData map[string][]byte, att map[string]*attach
 		CCList:   msg.ccList,
 		BCCList:  msg.bccList,
 		ReplyTos: msg.replytos,
+		Time:     date,
 		Size:     messageSize,
 
 		Flags:        msg.flags,
@@ -234,14 +278,22 @@ func (msg *message) getHeader() string {
 		builder.WriteString("Content-Type: " + string(msg.mimeType) + "\r\n")
 	}
 
-	if len(msg.inReplyTo) > 0 {
-		builder.WriteString("References: " + msg.inReplyTo + "\r\n")
+	if msg.references != "" {
+		builder.WriteString("References: " + msg.references + "\r\n")
 	}
 
 	if msg.inReplyTo != "" {
 		builder.WriteString("In-Reply-To: " + msg.inReplyTo + "\r\n")
 	}
 
+	if msg.listUnsubscribe != "" {
+		builder.WriteString("List-Unsubscribe: " + msg.listUnsubscribe + "\r\n")
+	}
+
+	if msg.listUnsubscribePost != "" {
+		builder.WriteString("List-Unsubscribe-Post: " + msg.listUnsubscribePost + "\r\n")
+	}
+
 	builder.WriteString("Date: " + msg.date.Format(time.RFC822) + "\r\n")
 
 	return builder.String()
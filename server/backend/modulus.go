@@ -8,6 +8,9 @@ import (
 
 var modulus string
 
+// modulusID identifies the modulus in the verifiers clients compute with it.
+const modulusID = "devModulusID"
+
 func init() {
 	arm, err := crypto.NewClearTextMessage(asc, sig).GetArmored()
 	if err != nil {
@@ -53,6 +53,7 @@ func (s *Backend) quarkUserCreate(args ...string) (proton.User, error) {
 	// Flag arguments.
 	name := fs.String("name", "", "new user's name")
 	pass := fs.String("password", "", "new user's password")
+	mailboxPass := fs.String("mailbox-password", "", "new user's mailbox password, creating the user in two-password mode")
 	newAddr := fs.Bool("create-address", false, "create the user's default address, will not automatically setup the address key")
 	genKeys := fs.String("gen-keys", "", "generate new address keys for the user")
 	status := fs.Int("status", 2, "User status")
@@ -66,11 +67,25 @@ func (s *Backend) quarkUserCreate(args ...string) (proton.User, error) {
 		return proton.User{}, err
 	}
 
-	userID, err := s.CreateUser(*name, []byte(*pass))
+	var userID string
+
+	if *mailboxPass != "" {
+		userID, err = s.CreateUserWithMailboxPassword(*name, []byte(*pass), []byte(*mailboxPass))
+	} else {
+		userID, err = s.CreateUser(*name, []byte(*pass))
+	}
+
 	if err != nil {
 		return proton.User{}, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	// The address keys are unlocked with the user keys, which are unlocked with the mailbox password.
+	keyPass := *pass
+
+	if *mailboxPass != "" {
+		keyPass = *mailboxPass
+	}
+
 	keyLength := 2048
 
 	// TODO: Support other formats key formats ED25519
@@ -82,7 +97,7 @@ func (s *Backend) quarkUserCreate(args ...string) (proton.User, error) {
 			}
 		}
 
-		if _, err := s.CreateAddressWithCustomKeyLength(userID, *name+"@"+s.domain, []byte(*pass), *genKeys != "", addressStatus, proton.AddressTypeOriginal, true, keyLength); err != nil {
+		if _, err := s.CreateAddressWithCustomKeyLength(userID, *name+"@"+s.domain, []byte(keyPass), *genKeys != "", addressStatus, proton.AddressTypeOriginal, true, keyLength); err != nil {
 			return proton.User{}, fmt.Errorf("failed to create address with keys: %w", err)
 		}
 	}
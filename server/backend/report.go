@@ -1,8 +1,30 @@
 package backend
 
-import "slices"
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 
-import "github.com/google/uuid"
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/google/uuid"
+)
+
+// MessageReport is a report of a message as spam or phishing, recorded so that tests can check it.
+type MessageReport struct {
+	UserID string
+	Type   string
+
+	proton.ReportMessageReq
+}
+
+// Unsubscription is a one-click unsubscribe request that the dev server would have posted to a mailing list.
+type Unsubscription struct {
+	UserID    string
+	MessageID string
+	URL       string
+}
 
 func (b *Backend) CreateCSTicket() string {
 	tokenUUID, err := uuid.NewUUID()
@@ -22,3 +44,150 @@ func (b *Backend) GetCSTicket(token string) bool {
 		return slices.Contains(b.csTicket, token)
 	})
 }
+
+// ReportMessage records the report of a message as spam or phishing, flags the message accordingly
+// and moves it to spam.
+func (b *Backend) ReportMessage(userID, reportType string, req proton.ReportMessageReq) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			return b.withMessages(func(messages map[string]*message) error {
+				return b.withLabels(func(labels map[string]*label) error {
+					msg, ok := messages[req.MessageID]
+					if !ok || !acc.hasMessage(msg) {
+						return errors.New("no such message")
+					}
+
+					switch reportType {
+					case "spam":
+						msg.flags |= proton.MessageFlagSpamManual
+
+					case "phishing":
+						msg.flags |= proton.MessageFlagPhishingManual
+
+					default:
+						return fmt.Errorf("invalid report type: %s", reportType)
+					}
+
+					msg.addLabel(proton.SpamLabel, labels)
+
+					updateID, err := b.newUpdate(&messageUpdated{messageID: msg.messageID})
+					if err != nil {
+						return err
+					}
+
+					acc.updateIDs = append(acc.updateIDs, updateID)
+
+					b.messageReports = append(b.messageReports, MessageReport{
+						UserID:           userID,
+						Type:             reportType,
+						ReportMessageReq: req,
+					})
+
+					return nil
+				})
+			})
+		})
+	})
+}
+
+// GetMessageReports returns the messages reported as spam or phishing, in the order they were reported.
+func (b *Backend) GetMessageReports() []MessageReport {
+	return readBackendRet(b, func(b *unsafeBackend) []MessageReport {
+		return slices.Clone(b.messageReports)
+	})
+}
+
+// SetMessageListUnsubscribe sets the List-Unsubscribe and List-Unsubscribe-Post headers of a message.
+func (b *Backend) SetMessageListUnsubscribe(userID, messageID, listUnsubscribe, listUnsubscribePost string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			return b.withMessages(func(messages map[string]*message) error {
+				msg, ok := messages[messageID]
+				if !ok || !acc.hasMessage(msg) {
+					return errors.New("no such message")
+				}
+
+				msg.listUnsubscribe = listUnsubscribe
+				msg.listUnsubscribePost = listUnsubscribePost
+
+				return nil
+			})
+		})
+	})
+}
+
+// Unsubscribe records the one-click unsubscribe request for the mailing list that sent the message,
+// instead of posting it, and flags the message as unsubscribed.
+func (b *Backend) Unsubscribe(userID, messageID string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			return b.withMessages(func(messages map[string]*message) error {
+				msg, ok := messages[messageID]
+				if !ok || !acc.hasMessage(msg) {
+					return errors.New("no such message")
+				}
+
+				list, ok := proton.ParseListUnsubscribe(msg.getParsedHeaders())
+				if !ok || !list.OneClick {
+					return errors.New("message does not support one-click unsubscribe")
+				}
+
+				idx := slices.IndexFunc(list.HTTP, func(u *url.URL) bool { return strings.EqualFold(u.Scheme, "https") })
+
+				b.unsubscriptions = append(b.unsubscriptions, Unsubscription{
+					UserID:    userID,
+					MessageID: messageID,
+					URL:       list.HTTP[idx].String(),
+				})
+
+				return b.setMessageFlag(acc, msg, proton.MessageFlagUnsubscribed)
+			})
+		})
+	})
+}
+
+// GetUnsubscriptions returns the one-click unsubscribe requests, in the order they were made.
+func (b *Backend) GetUnsubscriptions() []Unsubscription {
+	return readBackendRet(b, func(b *unsafeBackend) []Unsubscription {
+		return slices.Clone(b.unsubscriptions)
+	})
+}
+
+func (b *Backend) SetMessagesUnsubscribed(userID string, messageIDs ...string) error {
+	return writeBackendRet(b, func(b *unsafeBackend) error {
+		return b.withAcc(userID, func(acc *account) error {
+			return b.withMessages(func(messages map[string]*message) error {
+				for _, messageID := range messageIDs {
+					msg, ok := messages[messageID]
+					if !ok || !acc.hasMessage(msg) {
+						continue
+					}
+
+					if err := b.setMessageFlag(acc, msg, proton.MessageFlagUnsubscribed); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+		})
+	})
+}
+
+// setMessageFlag sets the flag on the message, emitting an update if it was not set yet.
+func (b *unsafeBackend) setMessageFlag(acc *account, msg *message, flag proton.MessageFlag) error {
+	if msg.flags.Has(flag) {
+		return nil
+	}
+
+	msg.flags |= flag
+
+	updateID, err := b.newUpdate(&messageUpdated{messageID: msg.messageID})
+	if err != nil {
+		return err
+	}
+
+	acc.updateIDs = append(acc.updateIDs, updateID)
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-srp"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/google/uuid"
 )
@@ -35,13 +36,34 @@ func (v *ID) FromString(s string) error {
 	return nil
 }
 
+// srpSession is an SRP exchange started by a client to prove it knows the login password of a user.
+type srpSession struct {
+	userID string
+	server *srp.Server
+}
+
 type auth struct {
 	acc string
 	ref string
 
 	creation time.Time
+
+	// twoFA is the second factors enabled for the account when the session was created.
+	// Until one of them is verified, the session is pending and only has the two-factor scope.
+	twoFA   proton.TwoFAInfo
+	pending bool
+
+	// fido2Challenge is the challenge FIDO2 assertions of the session must sign.
+	fido2Challenge []byte
+
+	passwordMode proton.PasswordMode
 }
 
+const (
+	scopeFull      = "full self user loggedin mail settings keys"
+	scopeTwoFactor = "twofactor"
+)
+
 func newAuth(authLife time.Duration) auth {
 	return auth{
 		acc: uuid.NewString(),
@@ -60,8 +82,18 @@ func (auth *auth) toAuth(userID, authUID string, proof []byte) proton.Auth {
 		RefreshToken: auth.ref,
 		ServerProof:  base64.StdEncoding.EncodeToString(proof),
 
-		PasswordMode: proton.OnePasswordMode,
+		Scope:        auth.scope(),
+		TwoFA:        auth.twoFA,
+		PasswordMode: auth.passwordMode,
+	}
+}
+
+func (auth *auth) scope() string {
+	if auth.pending {
+		return scopeTwoFactor
 	}
+
+	return scopeFull
 }
 
 func (auth *auth) toAuthSession(authUID string) proton.AuthSession {
@@ -77,6 +109,9 @@ type key struct {
 	key   string
 	tok   string
 	sig   string
+
+	// forwardingID is set on the keys that forwardees receive for the forwarding they accepted.
+	forwardingID string
 }
 
 func (key key) unlock(passphrase []byte) (*crypto.KeyRing, error) {
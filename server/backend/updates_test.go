@@ -3,6 +3,9 @@ package backend
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 func Test_mergeUpdates(t *testing.T) {
@@ -61,3 +64,47 @@ func Test_mergeUpdates(t *testing.T) {
 		})
 	}
 }
+
+func Test_compactUpdates(t *testing.T) {
+	b := New(time.Hour, "proton.local", false).safeBackend
+
+	b.eventRetention = time.Minute
+
+	// Create updates for two accounts, the oldest ones having expired.
+	newUpdate := func(acc *account, age time.Duration) ID {
+		updateID, err := b.newUpdate(&labelCreated{labelID: "label"})
+		require.NoError(t, err)
+
+		b.updateTimes[updateID] = time.Now().Add(-age)
+		acc.updateIDs = append(acc.updateIDs, updateID)
+
+		return updateID
+	}
+
+	active, idle := &account{}, &account{}
+
+	b.accounts["active"] = active
+	b.accounts["idle"] = idle
+
+	newUpdate(active, time.Hour)
+	newUpdate(active, time.Hour)
+	recent := newUpdate(active, time.Second)
+	latest := newUpdate(active, 0)
+
+	newUpdate(idle, time.Hour)
+	idleLatest := newUpdate(idle, time.Hour)
+
+	require.True(t, b.isUpdateExpired(idleLatest))
+	require.False(t, b.isUpdateExpired(recent))
+
+	b.compactUpdates()
+
+	// The expired updates are removed, but the latest update of each account is kept even if it expired.
+	require.Equal(t, []ID{recent, latest}, active.updateIDs)
+	require.Equal(t, []ID{idleLatest}, idle.updateIDs)
+	require.Len(t, b.updates, 3)
+	require.Len(t, b.updateTimes, 3)
+
+	// New update IDs are never reused.
+	require.Equal(t, latest+3, newUpdate(active, 0))
+}
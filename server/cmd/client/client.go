@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 
+	"github.com/ProtonMail/go-proton-api"
 	"github.com/ProtonMail/go-proton-api/server/proto"
 	"github.com/urfave/cli/v2"
 	"google.golang.org/grpc"
@@ -143,6 +144,50 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "export",
+			Usage:  "export the mailbox of a user through the API",
+			Action: exportAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "url",
+					Usage: "URL of the API",
+					Value: proton.DefaultHostURL,
+				},
+				&cli.StringFlag{
+					Name:     "username",
+					Usage:    "username of the account",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "password",
+					Usage:    "password of the account",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "mailbox-password",
+					Usage: "mailbox password of the account, if different from the password",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "export format: mbox, maildir or eml-zip",
+					Value: string(proton.ExportFormatMbox),
+				},
+				&cli.StringFlag{
+					Name:     "output",
+					Usage:    "mbox file, Maildir directory or zip file to write",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "checkpoint",
+					Usage: "file in which to record progress, to resume an interrupted export",
+				},
+				&cli.BoolFlag{
+					Name:  "labels-as-keywords",
+					Usage: "list labels in the X-Keywords header rather than as Maildir folders",
+				},
+			},
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
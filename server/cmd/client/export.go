@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ProtonMail/gluon/async"
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/urfave/cli/v2"
+)
+
+func exportAction(c *cli.Context) error {
+	m := proton.New(proton.WithHostURL(c.String("url")))
+	defer m.Close()
+
+	client, auth, err := m.NewClientWithLogin(c.Context, c.String("username"), []byte(c.String("password")))
+	if err != nil {
+		return fmt.Errorf("failed to log in: %w", err)
+	}
+	defer client.Close()
+
+	if auth.TwoFA.Enabled != 0 {
+		return errors.New("accounts with two-factor authentication are not supported")
+	}
+
+	user, err := client.GetUser(c.Context)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := client.GetAddresses(c.Context)
+	if err != nil {
+		return err
+	}
+
+	salts, err := client.GetSalts(c.Context)
+	if err != nil {
+		return err
+	}
+
+	mailboxPass := c.String("mailbox-password")
+	if mailboxPass == "" {
+		mailboxPass = c.String("password")
+	}
+
+	keyPass, err := salts.SaltForKey([]byte(mailboxPass), user.Keys.Primary().ID)
+	if err != nil {
+		return err
+	}
+
+	_, addrKRs, err := proton.Unlock(user, addrs, keyPass, async.NoopPanicHandler{})
+	if err != nil {
+		return fmt.Errorf("failed to unlock keys: %w", err)
+	}
+
+	labelMode := proton.MaildirLabelsAsFolders
+
+	if c.Bool("labels-as-keywords") {
+		labelMode = proton.MaildirLabelsAsKeywords
+	}
+
+	n, err := client.ExportMessages(c.Context, addrKRs, proton.ExportReq{
+		Format:         proton.ExportFormat(c.String("format")),
+		Path:           c.String("output"),
+		CheckpointPath: c.String("checkpoint"),
+		MaildirLabels:  labelMode,
+	})
+	if err != nil {
+		return fmt.Errorf("export failed after %d messages: %w", n, err)
+	}
+
+	_, err = fmt.Fprintf(c.App.Writer, "exported %d messages\n", n)
+
+	return err
+}
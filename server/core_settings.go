@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/base64"
 	"net/http"
 
 	"github.com/ProtonMail/go-proton-api"
@@ -62,3 +63,24 @@ func (s *Server) handlePutUserSettingsCrashReports() gin.HandlerFunc {
 		})
 	}
 }
+
+func (s *Server) handlePutUserSettingsPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.UpdatePasswordReq
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		serverProof, err := s.b.UpdatePassword(c.GetString("UserID"), c.GetString("AuthUID"), req)
+		if err != nil {
+			abortWithPasswordError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"ServerProof": base64.StdEncoding.EncodeToString(serverProof),
+		})
+	}
+}
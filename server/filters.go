@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/pkg/sieve"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleGetMailFilters() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filters, err := s.b.GetFilters(c.GetString("UserID"))
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"Filters": filters,
+		})
+	}
+}
+
+func (s *Server) handlePostMailFilters() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.CreateFilterReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		filter, err := s.b.CreateFilter(c.GetString("UserID"), req.Name, req.Status, req.Version, req.Sieve)
+		if err != nil {
+			abortWithSieveError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"Filter": filter,
+		})
+	}
+}
+
+func (s *Server) handlePutMailFilter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.UpdateFilterReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		filter, err := s.b.UpdateFilter(c.GetString("UserID"), c.Param("filterID"), req.Name, req.Status, req.Version, req.Sieve)
+		if err != nil {
+			abortWithSieveError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"Filter": filter,
+		})
+	}
+}
+
+func (s *Server) handlePutMailFilterStatus(status proton.FilterStatus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.b.SetFilterStatus(c.GetString("UserID"), c.Param("filterID"), status); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+}
+
+func (s *Server) handleDeleteMailFilter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.b.DeleteFilter(c.GetString("UserID"), c.Param("filterID")); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+}
+
+func (s *Server) handlePutMailFiltersOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.OrderFiltersReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if err := s.b.OrderFilters(c.GetString("UserID"), req.FilterIDs); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+}
+
+func (s *Server) handlePutMailFiltersCheck() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.CheckSieveReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		issues := []proton.SieveIssue{}
+
+		if _, err := sieve.Parse(req.Sieve); err != nil {
+			var sieveErr *sieve.Error
+
+			if !errors.As(err, &sieveErr) {
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+
+			issues = append(issues, proton.SieveIssue{
+				Line:    sieveErr.Line,
+				Column:  sieveErr.Column,
+				Message: sieveErr.Message,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"Issues": issues,
+		})
+	}
+}
+
+// abortWithSieveError rejects a filter whose script is invalid, or which does not exist.
+func abortWithSieveError(c *gin.Context, err error) {
+	if sieveErr := new(sieve.Error); errors.As(err, &sieveErr) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, proton.APIError{
+			Code:    proton.InvalidValue,
+			Message: "Invalid Sieve script: " + sieveErr.Error(),
+		})
+
+		return
+	}
+
+	c.AbortWithStatus(http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleGetMailForwardingsOutgoing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		forwardings, err := s.b.GetOutgoingForwardings(c.GetString("UserID"))
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"OutgoingAddressForwardings": forwardings,
+		})
+	}
+}
+
+func (s *Server) handleGetMailForwardingsIncoming() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		forwardings, err := s.b.GetIncomingForwardings(c.GetString("UserID"))
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"IncomingAddressForwardings": forwardings,
+		})
+	}
+}
+
+func (s *Server) handlePostMailForwardings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.CreateForwardingReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		forwarding, err := s.b.CreateForwarding(c.GetString("UserID"), req)
+		if err != nil {
+			abortWithSieveError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"OutgoingAddressForwarding": forwarding,
+		})
+	}
+}
+
+func (s *Server) handlePutMailForwardingPaused(paused bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.b.SetForwardingPaused(c.GetString("UserID"), c.Param("forwardingID"), paused); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+}
+
+func (s *Server) handlePutMailForwardingAccept() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.AcceptForwardingReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if err := s.b.AcceptForwarding(c.GetString("UserID"), c.Param("forwardingID"), req.ForwardingKeys); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+}
+
+func (s *Server) handlePutMailForwardingReject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.b.RejectForwarding(c.GetString("UserID"), c.Param("forwardingID")); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+}
+
+func (s *Server) handleDeleteMailForwarding() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.b.DeleteForwarding(c.GetString("UserID"), c.Param("forwardingID")); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+}
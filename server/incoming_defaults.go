@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleGetMailIncomingDefaults() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		total, incomingDefaults, err := s.b.GetIncomingDefaults(c.GetString("UserID"),
+			mustParseInt(c.DefaultQuery("Page", strconv.Itoa(defaultPage))),
+			mustParseInt(c.DefaultQuery("PageSize", strconv.Itoa(defaultPageSize))),
+		)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"IncomingDefaults": incomingDefaults,
+			"Total":            total,
+		})
+	}
+}
+
+func (s *Server) handlePostMailIncomingDefaults() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.AddIncomingDefaultReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		incomingDefault, err := s.b.AddIncomingDefault(c.GetString("UserID"), req.Location, req.Email, req.Domain)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, proton.APIError{
+				Code:    proton.InvalidValue,
+				Message: err.Error(),
+			})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"IncomingDefault": incomingDefault,
+		})
+	}
+}
+
+func (s *Server) handlePutMailIncomingDefaultsDelete() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.DeleteIncomingDefaultsReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if err := s.b.DeleteIncomingDefaults(c.GetString("UserID"), req.IDs...); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+}
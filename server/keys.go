@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/base64"
 	"net/http"
 
 	"github.com/ProtonMail/go-proton-api"
@@ -35,3 +36,24 @@ func (s *Server) handleGetKeySalts() gin.HandlerFunc {
 		})
 	}
 }
+
+func (s *Server) handlePutKeysPrivate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.UpdatePrivateKeysReq
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		serverProof, err := s.b.UpdatePrivateKeys(c.GetString("UserID"), c.GetString("AuthUID"), req)
+		if err != nil {
+			abortWithPasswordError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"ServerProof": base64.StdEncoding.EncodeToString(serverProof),
+		})
+	}
+}
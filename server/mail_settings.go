@@ -104,3 +104,28 @@ func (s *Server) handlePutMailSettingsPGPScheme() gin.HandlerFunc {
 		})
 	}
 }
+
+func (s *Server) handlePutMailSettingsAutoResponder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.SetAutoResponderReq
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		settings, err := s.b.SetMailSettingsAutoResponder(c.GetString("UserID"), req.AutoResponder)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, proton.APIError{
+				Code:    proton.InvalidValue,
+				Message: err.Error(),
+			})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"MailSettings": settings,
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/mail"
 	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ProtonMail/gluon/rfc822"
@@ -102,7 +103,7 @@ func (s *Server) postMailMessages(c *gin.Context) {
 		return
 	}
 
-	message, err := s.b.CreateDraft(c.GetString("UserID"), addrID, req.Message, req.ParentID, req.Action)
+	message, err := s.b.CreateDraft(c.GetString("UserID"), addrID, req.Message, req.ParentID, req.Action, req.AttachmentKeyPackets)
 	if err != nil {
 		c.AbortWithStatus(http.StatusUnprocessableEntity)
 		return
@@ -281,6 +282,43 @@ func (s *Server) handlePutMailMessagesUnforwarded() gin.HandlerFunc {
 	}
 }
 
+func (s *Server) handlePostMailMessageUnsubscribe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.b.Unsubscribe(c.GetString("UserID"), c.Param("messageID")); err != nil {
+			_ = c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+}
+
+func (s *Server) handlePutMailMessagesUnsubscribed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.MessageActionReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if err := s.b.SetMessagesUnsubscribed(c.GetString("UserID"), req.IDs...); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"Code": 1001,
+			"Responses": xslices.Map(req.IDs, func(id string) any {
+				return gin.H{
+					"ID": id,
+					"Response": gin.H{
+						"Code": 1000,
+					},
+				}
+			}),
+		})
+	}
+}
+
 func (s *Server) handlePutMailMessagesLabel() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req proton.LabelMessagesReq
@@ -313,6 +351,15 @@ func (s *Server) handlePutMailMessagesUnlabel() gin.HandlerFunc {
 	}
 }
 
+func (s *Server) handleDeleteMailMessagesEmpty() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.b.EmptyLabel(c.GetString("UserID"), c.Query("LabelID"), c.Query("AddressID")); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+	}
+}
+
 func (s *Server) handlePutMailMessagesImport() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		form, err := c.MultipartForm()
@@ -432,7 +479,8 @@ func (s *Server) importMessage(
 			return "", fmt.Errorf("invalid label ID: %s", labelID)
 		}
 
-		if label.Type != proton.LabelTypeLabel {
+		// Starred is a system label, but it is not exclusive.
+		if label.Type != proton.LabelTypeLabel && labelID != proton.StarredLabel {
 			exclusive++
 		}
 	}
@@ -463,6 +511,29 @@ func (s *Server) importMessage(
 		}
 	}
 
+	if header.Has("Message-Id") {
+		externalID := strings.Trim(strings.TrimSpace(header.Get("Message-Id")), "<>")
+
+		if err := s.b.SetMessageThreading(userID, messageID, externalID, header.Get("In-Reply-To"), header.Get("References")); err != nil {
+			return "", fmt.Errorf("failed to set threading headers: %w", err)
+		}
+	}
+
+	if header.Has("List-Unsubscribe") {
+		if err := s.b.SetMessageListUnsubscribe(userID, messageID, header.Get("List-Unsubscribe"), header.Get("List-Unsubscribe-Post")); err != nil {
+			return "", fmt.Errorf("failed to set list unsubscribe: %w", err)
+		}
+	}
+
+	// Imported messages go through the filters of the user like received ones; a discarded message is deleted
+	// and has no ID to report.
+	kept, err := s.b.ApplyFilters(userID, messageID, header, len(literal))
+	if err != nil {
+		return "", fmt.Errorf("failed to apply filters: %w", err)
+	} else if !kept {
+		return "", nil
+	}
+
 	return messageID, nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/ProtonMail/go-proton-api"
 	"github.com/gin-gonic/gin"
 )
 
@@ -37,3 +38,19 @@ func (s *Server) handlePostReportBugAttachments() gin.HandlerFunc {
 		}
 	}
 }
+
+func (s *Server) handlePostReportMessage(reportType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req proton.ReportMessageReq
+
+		if err := c.BindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		if err := s.b.ReportMessage(c.GetString("UserID"), reportType, req); err != nil {
+			_ = c.AbortWithError(http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server/backend"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -66,16 +67,23 @@ func initRouter(s *Server) {
 			if keys := core.Group("/keys"); keys != nil {
 				keys.GET("", s.handleGetKeys())
 				keys.GET("/salts", s.handleGetKeySalts())
+				keys.PUT("/private", s.handlePutKeysPrivate())
 			}
 
 			if events := core.Group("/events"); events != nil {
 				events.GET("/:eventID", s.handleGetEvents())
 				events.GET("/latest", s.handleGetEventsLatest())
 			}
+			if reports := core.Group("/reports"); reports != nil {
+				reports.POST("/spam", s.handlePostReportMessage("spam"))
+				reports.POST("/phishing", s.handlePostReportMessage("phishing"))
+			}
+
 			if settings := core.Group("/settings"); settings != nil {
 				settings.GET("", s.handleGetUserSettings())
 				settings.PUT("/telemetry", s.handlePutUserSettingsTelemetry())
 				settings.PUT("/crashreports", s.handlePutUserSettingsCrashReports())
+				settings.PUT("/password", s.handlePutUserSettingsPassword())
 			}
 		}
 	}
@@ -88,6 +96,7 @@ func initRouter(s *Server) {
 			settings.PUT("/drafttype", s.handlePutMailSettingsDraftType())
 			settings.PUT("/sign", s.handlePutMailSettingsSign())
 			settings.PUT("/pgpscheme", s.handlePutMailSettingsPGPScheme())
+			settings.PUT("/autoresponder", s.handlePutMailSettingsAutoResponder())
 		}
 
 		if messages := mail.Group("/messages"); messages != nil {
@@ -97,21 +106,52 @@ func initRouter(s *Server) {
 			messages.GET("/:messageID", s.handleGetMailMessage())
 			messages.POST("/:messageID", s.handlePostMailMessage())
 			messages.PUT("/:messageID", s.handlePutMailMessage())
+			messages.POST("/:messageID/unsubscribe", s.handlePostMailMessageUnsubscribe())
 			messages.PUT("/read", s.handlePutMailMessagesRead())
 			messages.PUT("/unread", s.handlePutMailMessagesUnread())
 			messages.PUT("/label", s.handlePutMailMessagesLabel())
 			messages.PUT("/unlabel", s.handlePutMailMessagesUnlabel())
 			messages.POST("/import", s.handlePutMailMessagesImport())
 			messages.PUT("/delete", s.handleDeleteMailMessages())
+			messages.DELETE("/empty", s.handleDeleteMailMessagesEmpty())
 			messages.GET("/count", s.handleMessageGroupCount())
 			messages.PUT("/forward", s.handlePutMailMessagesForwarded())
 			messages.PUT("/unforward", s.handlePutMailMessagesUnforwarded())
+			messages.PUT("/mark/unsubscribed", s.handlePutMailMessagesUnsubscribed())
 		}
 
 		if attachments := mail.Group("/attachments"); attachments != nil {
 			attachments.POST("", s.handlePostMailAttachments())
 			attachments.GET(":attachID", s.handleGetMailAttachment())
 		}
+
+		if filters := mail.Group("/filters"); filters != nil {
+			filters.GET("", s.handleGetMailFilters())
+			filters.POST("", s.handlePostMailFilters())
+			filters.PUT("/order", s.handlePutMailFiltersOrder())
+			filters.PUT("/check", s.handlePutMailFiltersCheck())
+			filters.PUT("/:filterID", s.handlePutMailFilter())
+			filters.PUT("/:filterID/enable", s.handlePutMailFilterStatus(proton.FilterStatusEnabled))
+			filters.PUT("/:filterID/disable", s.handlePutMailFilterStatus(proton.FilterStatusDisabled))
+			filters.DELETE("/:filterID", s.handleDeleteMailFilter())
+		}
+
+		if incomingDefaults := mail.Group("/incomingdefaults"); incomingDefaults != nil {
+			incomingDefaults.GET("", s.handleGetMailIncomingDefaults())
+			incomingDefaults.POST("", s.handlePostMailIncomingDefaults())
+			incomingDefaults.PUT("/delete", s.handlePutMailIncomingDefaultsDelete())
+		}
+
+		if forwardings := mail.Group("/forwardings"); forwardings != nil {
+			forwardings.POST("", s.handlePostMailForwardings())
+			forwardings.GET("/outgoing", s.handleGetMailForwardingsOutgoing())
+			forwardings.PUT("/outgoing/:forwardingID/pause", s.handlePutMailForwardingPaused(true))
+			forwardings.PUT("/outgoing/:forwardingID/resume", s.handlePutMailForwardingPaused(false))
+			forwardings.GET("/incoming", s.handleGetMailForwardingsIncoming())
+			forwardings.PUT("/incoming/:forwardingID/accept", s.handlePutMailForwardingAccept())
+			forwardings.PUT("/incoming/:forwardingID/reject", s.handlePutMailForwardingReject())
+			forwardings.DELETE("/:forwardingID", s.handleDeleteMailForwarding())
+		}
 	}
 
 	// All contacts routes need authentication.
@@ -138,10 +178,16 @@ func initRouter(s *Server) {
 		auth.POST("", s.handlePostAuth())
 		auth.POST("/info", s.handlePostAuthInfo())
 		auth.POST("/refresh", s.handlePostAuthRefresh())
+		auth.GET("/modulus", s.handleGetAuthModulus())
+
+		// These routes are also available to sessions that still need to verify their second factor.
+		if auth := auth.Group("", s.requirePartialAuth()); auth != nil {
+			auth.POST("/2fa", s.handlePostAuth2FA())
+			auth.DELETE("", s.handleDeleteAuth())
+		}
 
 		// These routes require auth.
 		if auth := auth.Group("", s.requireAuth()); auth != nil {
-			auth.DELETE("", s.handleDeleteAuth())
 
 			if sessions := auth.Group("/sessions"); sessions != nil {
 				sessions.GET("", s.handleGetAuthSessions())
@@ -290,6 +336,15 @@ func (s *Server) handleOffline() gin.HandlerFunc {
 }
 
 func (s *Server) requireAuth() gin.HandlerFunc {
+	return s.verifyAuth(s.b.VerifyAuth)
+}
+
+// requirePartialAuth is like requireAuth, but also accepts sessions that still need to verify their second factor.
+func (s *Server) requirePartialAuth() gin.HandlerFunc {
+	return s.verifyAuth(s.b.VerifyPartialAuth)
+}
+
+func (s *Server) verifyAuth(verify func(authUID, authAcc string) (string, error)) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authUID := c.Request.Header.Get("x-pm-uid")
 		if authUID == "" {
@@ -303,8 +358,14 @@ func (s *Server) requireAuth() gin.HandlerFunc {
 			return
 		}
 
-		userID, err := s.b.VerifyAuth(authUID, strings.Split(auth, " ")[1])
-		if err != nil {
+		userID, err := verify(authUID, strings.Split(auth, " ")[1])
+		if errors.Is(err, backend.ErrTwoFactorRequired) {
+			c.AbortWithStatusJSON(http.StatusForbidden, proton.APIError{
+				Code:    proton.InsufficientScope,
+				Message: "Two-factor authentication required",
+			})
+			return
+		} else if err != nil {
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
@@ -124,10 +124,47 @@ func (s *Server) RemoveUser(userID string) error {
 	return s.b.RemoveUser(userID)
 }
 
+// CreateUserWithMailboxPassword creates a user in two-password mode, with an address:
+// the password is used to log in, and the mailbox password to unlock the keys.
+func (s *Server) CreateUserWithMailboxPassword(username string, password, mailboxPassword []byte) (string, string, error) {
+	userID, err := s.b.CreateUserWithMailboxPassword(username, password, mailboxPassword)
+	if err != nil {
+		return "", "", err
+	}
+
+	addrID, err := s.b.CreateAddress(userID, username+"@"+s.domain, mailboxPassword, true, proton.AddressStatusEnabled, proton.AddressTypeOriginal, true)
+	if err != nil {
+		return "", "", err
+	}
+
+	return userID, addrID, nil
+}
+
 func (s *Server) RefreshUser(userID string, refresh proton.RefreshFlag) error {
 	return s.b.RefreshUser(userID, refresh)
 }
 
+// EnableTOTP enables TOTP for the user and returns the base32-encoded secret from which codes are generated,
+// e.g. with proton.GenerateTOTP. Sessions created afterwards need to call Auth2FA with a valid code.
+func (s *Server) EnableTOTP(userID string) (string, error) {
+	return s.b.EnableTOTP(userID)
+}
+
+func (s *Server) DisableTOTP(userID string) error {
+	return s.b.DisableTOTP(userID)
+}
+
+// RegisterFIDO2Key registers a FIDO2 security key for the user, given the ID and PKIX-encoded ES256 public key
+// of its credential, e.g. those of a proton.FIDO2Authenticator. Sessions created afterwards need to call Auth2FA
+// with an assertion of one of the registered keys, or with a TOTP code if TOTP is also enabled.
+func (s *Server) RegisterFIDO2Key(userID, name string, credentialID, publicKey []byte) error {
+	return s.b.RegisterFIDO2Key(userID, name, credentialID, publicKey)
+}
+
+func (s *Server) RemoveFIDO2Key(userID string, credentialID []byte) error {
+	return s.b.RemoveFIDO2Key(userID, credentialID)
+}
+
 func (s *Server) GetUserKeyIDs(userID string) ([]string, error) {
 	user, err := s.b.GetUser(userID)
 	if err != nil {
@@ -151,6 +188,11 @@ func (s *Server) CreateAddress(userID, email string, password []byte, withSend b
 	return s.b.CreateAddress(userID, email, password, true, proton.AddressStatusEnabled, proton.AddressTypeOriginal, withSend)
 }
 
+// CreateAddressWithKeyType creates an address whose key is of the given type (e.g. "x25519").
+func (s *Server) CreateAddressWithKeyType(userID, email string, password []byte, withSend bool, keyType string) (string, error) {
+	return s.b.CreateAddressWithKeyType(userID, email, password, true, proton.AddressStatusEnabled, proton.AddressTypeOriginal, withSend, keyType)
+}
+
 func (s *Server) CreateExternalAddress(userID, email string, password []byte, withSend bool) (string, error) {
 	return s.b.CreateAddress(userID, email, password, true, proton.AddressStatusEnabled, proton.AddressTypeExternal, withSend)
 }
@@ -229,6 +271,17 @@ func (s *Server) SetMaxUpdatesPerEvent(max int) {
 	s.b.SetMaxUpdatesPerEvent(max)
 }
 
+// SetEventRetention sets how long event IDs remain valid; clients asking for the events following
+// an expired or unknown event ID receive a refresh event. If zero, the default, event IDs never expire.
+func (s *Server) SetEventRetention(retention time.Duration) {
+	s.b.SetEventRetention(retention)
+}
+
+// CompactEvents removes the expired events; it also happens regularly as new events are created.
+func (s *Server) CompactEvents() {
+	s.b.CompactEvents()
+}
+
 func (s *Server) SetAuthLife(authLife time.Duration) {
 	s.b.SetAuthLife(authLife)
 }
@@ -272,3 +325,13 @@ func (s *Server) DeleteFeatureFlags() {
 func (s *Server) GetObservabilityStatistics() backend.ObservabilityStatistics {
 	return s.b.GetObservabilityStatistics()
 }
+
+// GetMessageReports returns the messages reported as spam or phishing, in the order they were reported.
+func (s *Server) GetMessageReports() []backend.MessageReport {
+	return s.b.GetMessageReports()
+}
+
+// GetUnsubscriptions returns the one-click unsubscribe requests made on behalf of users.
+func (s *Server) GetUnsubscriptions() []backend.Unsubscription {
+	return s.b.GetUnsubscriptions()
+}
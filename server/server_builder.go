@@ -24,6 +24,7 @@ type serverBuilder struct {
 	cacher         AuthCacher
 	rateLimiter    *rateLimiter
 	enableDedup    bool
+	eventRetention time.Duration
 }
 
 func newServerBuilder() *serverBuilder {
@@ -59,6 +60,8 @@ func (builder *serverBuilder) build() *Server {
 		proxyTransport: builder.proxyTransport,
 	}
 
+	s.b.SetEventRetention(builder.eventRetention)
+
 	s.r.Use(gin.CustomRecovery(func(c *gin.Context, recovered any) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 			"Code":    http.StatusInternalServerError,
@@ -264,3 +267,16 @@ func (withMessageDedup) config(builder *serverBuilder) {
 func WithMessageDedup() Option {
 	return &withMessageDedup{}
 }
+
+type withEventRetention struct {
+	retention time.Duration
+}
+
+func (opt withEventRetention) config(builder *serverBuilder) {
+	builder.eventRetention = opt.retention
+}
+
+// WithEventRetention sets how long event IDs remain valid before clients using them are told to refresh.
+func WithEventRetention(retention time.Duration) Option {
+	return &withEventRetention{retention: retention}
+}
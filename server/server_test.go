@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -487,6 +488,55 @@ func TestServer_Events_Refresh(t *testing.T) {
 	})
 }
 
+func TestServer_Events_Retention(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+			oldEventID, err := c.GetLatestEventID(ctx)
+			require.NoError(t, err)
+
+			_, err = c.CreateLabel(ctx, proton.CreateLabelReq{Name: "first", Color: "#f66", Type: proton.LabelTypeLabel})
+			require.NoError(t, err)
+
+			// Event IDs are valid until they expire.
+			events, _, err := c.GetEvent(ctx, oldEventID)
+			require.NoError(t, err)
+			require.Zero(t, events[0].Refresh)
+			require.Len(t, events[0].Labels, 1)
+
+			time.Sleep(time.Second)
+
+			_, err = c.CreateLabel(ctx, proton.CreateLabelReq{Name: "second", Color: "#f66", Type: proton.LabelTypeLabel})
+			require.NoError(t, err)
+
+			latestEventID, err := c.GetLatestEventID(ctx)
+			require.NoError(t, err)
+
+			// Clients with an expired event ID are told to refresh everything, from the latest event.
+			events, more, err := c.GetEvent(ctx, oldEventID)
+			require.NoError(t, err)
+			require.False(t, more)
+			require.Equal(t, proton.RefreshAll, events[0].Refresh)
+			require.Equal(t, latestEventID, events[0].EventID)
+			require.Empty(t, events[0].Labels)
+
+			// So are clients with an unknown event ID.
+			events, _, err = c.GetEvent(ctx, backend.ID(1<<32).String())
+			require.NoError(t, err)
+			require.Equal(t, proton.RefreshAll, events[0].Refresh)
+			require.Equal(t, latestEventID, events[0].EventID)
+
+			// The latest event ID remains valid however old it is, even once the events are compacted.
+			time.Sleep(time.Second)
+
+			s.CompactEvents()
+
+			events, _, err = c.GetEvent(ctx, latestEventID)
+			require.NoError(t, err)
+			require.Equal(t, []proton.Event{{EventID: latestEventID}}, events)
+		})
+	}, WithEventRetention(500*time.Millisecond))
+}
+
 func TestServer_Events_UserSettings(t *testing.T) {
 	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
 		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
@@ -949,6 +999,70 @@ func TestServer_AuthDelete(t *testing.T) {
 	})
 }
 
+func TestServer_Auth2FA_TOTP(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		userID, _, err := s.CreateUser("user", []byte("pass"))
+		require.NoError(t, err)
+
+		secret, err := s.EnableTOTP(userID)
+		require.NoError(t, err)
+
+		info, err := m.AuthInfo(ctx, proton.AuthInfoReq{Username: "user"})
+		require.NoError(t, err)
+		require.Equal(t, proton.HasTOTP, info.TwoFA.Enabled)
+
+		c, auth, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+		require.NoError(t, err)
+		defer c.Close()
+
+		require.Equal(t, proton.HasTOTP, auth.TwoFA.Enabled)
+		require.Equal(t, "twofactor", auth.Scope)
+
+		// API calls are rejected until the second factor is verified.
+		_, err = c.GetUser(ctx)
+		require.Error(t, err)
+
+		var apiErr *proton.APIError
+
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, http.StatusForbidden, apiErr.Status)
+		require.Equal(t, proton.InsufficientScope, apiErr.Code)
+
+		// Wrong codes are rejected.
+		code, err := proton.GenerateTOTP(secret, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Error(t, c.Auth2FA(ctx, proton.Auth2FAReq{TwoFactorCode: code}))
+
+		// The code of the previous period is accepted.
+		code, err = proton.GenerateTOTP(secret, time.Now().Add(-proton.TOTPPeriod))
+		require.NoError(t, err)
+		require.NoError(t, c.Auth2FA(ctx, proton.Auth2FAReq{TwoFactorCode: code}))
+
+		_, err = c.GetUser(ctx)
+		require.NoError(t, err)
+
+		// Refreshed sessions keep their full scope.
+		refreshed, _, err := m.NewClientWithRefresh(ctx, auth.UID, auth.RefreshToken)
+		require.NoError(t, err)
+		defer refreshed.Close()
+
+		_, err = refreshed.GetUser(ctx)
+		require.NoError(t, err)
+
+		// Once TOTP is disabled, new sessions get full scope.
+		require.NoError(t, s.DisableTOTP(userID))
+
+		c2, auth2, err := m.NewClientWithLogin(ctx, "user", []byte("pass"))
+		require.NoError(t, err)
+		defer c2.Close()
+
+		require.Zero(t, auth2.TwoFA.Enabled)
+
+		_, err = c2.GetUser(ctx)
+		require.NoError(t, err)
+	})
+}
+
 func TestServer_ForceUpgrade(t *testing.T) {
 	ctx := t.Context()
 
@@ -2232,6 +2346,66 @@ func TestServer_GetMessageGroupCount(t *testing.T) {
 	})
 }
 
+func TestServer_GetMessageGroupCount_CustomLabels(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+			user, err := c.GetUser(ctx)
+			require.NoError(t, err)
+
+			addr, err := c.GetAddresses(ctx)
+			require.NoError(t, err)
+
+			salt, err := c.GetSalts(ctx)
+			require.NoError(t, err)
+
+			pass, err := salt.SaltForKey([]byte("pass"), user.Keys.Primary().ID)
+			require.NoError(t, err)
+
+			_, addrKRs, err := proton.Unlock(user, addr, pass, async.NoopPanicHandler{})
+			require.NoError(t, err)
+
+			label, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "label", Color: "#f66", Type: proton.LabelTypeLabel})
+			require.NoError(t, err)
+
+			folder, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "folder", Color: "#f66", Type: proton.LabelTypeFolder})
+			require.NoError(t, err)
+
+			res := importMessages(ctx, t, c, addr[0].ID, addrKRs[addr[0].ID], []string{}, proton.MessageFlagReceived, 3)
+			msgIDs := xslices.Map(res, func(r proton.ImportRes) string { return r.MessageID })
+
+			require.NoError(t, c.LabelMessages(ctx, msgIDs, label.ID))
+			require.NoError(t, c.LabelMessages(ctx, msgIDs[:2], folder.ID))
+			require.NoError(t, c.MarkMessagesRead(ctx, msgIDs[0]))
+
+			countOf := func(labelID string) proton.MessageGroupCount {
+				counts, err := c.GetGroupedMessageCount(ctx)
+				require.NoError(t, err)
+
+				if idx := slices.IndexFunc(counts, func(count proton.MessageGroupCount) bool { return count.LabelID == labelID }); idx >= 0 {
+					return counts[idx]
+				}
+
+				return proton.MessageGroupCount{LabelID: labelID}
+			}
+
+			// Custom labels and folders are counted like system labels.
+			require.Equal(t, proton.MessageGroupCount{LabelID: label.ID, Total: 3, Unread: 2}, countOf(label.ID))
+			require.Equal(t, proton.MessageGroupCount{LabelID: folder.ID, Total: 2, Unread: 1}, countOf(folder.ID))
+
+			// Deleting a label removes it from its messages, so it's no longer counted.
+			require.NoError(t, c.DeleteLabel(ctx, label.ID))
+			require.Equal(t, proton.MessageGroupCount{LabelID: label.ID}, countOf(label.ID))
+			require.Equal(t, proton.MessageGroupCount{LabelID: folder.ID, Total: 2, Unread: 1}, countOf(folder.ID))
+
+			for _, msgID := range msgIDs {
+				msg, err := c.GetMessage(ctx, msgID)
+				require.NoError(t, err)
+				require.NotContains(t, msg.LabelIDs, label.ID)
+			}
+		})
+	})
+}
+
 func TestServer_TestDraftActions(t *testing.T) {
 	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
 		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
@@ -2768,3 +2942,768 @@ func TestServer_Import_MessageSizeExceeded_NoDoubleEncryption(t *testing.T) {
 		})
 	})
 }
+
+func TestServer_Filters_Import(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+			user, err := c.GetUser(ctx)
+			require.NoError(t, err)
+
+			addr, err := c.GetAddresses(ctx)
+			require.NoError(t, err)
+
+			salt, err := c.GetSalts(ctx)
+			require.NoError(t, err)
+
+			pass, err := salt.SaltForKey([]byte("pass"), user.Keys.Primary().ID)
+			require.NoError(t, err)
+
+			_, addrKRs, err := proton.Unlock(user, addr, pass, async.NoopPanicHandler{})
+			require.NoError(t, err)
+
+			parent, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "Projects", Type: proton.LabelTypeFolder})
+			require.NoError(t, err)
+
+			folder, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "Work", Type: proton.LabelTypeFolder, ParentID: parent.ID})
+			require.NoError(t, err)
+
+			for _, req := range []proton.CreateFilterReq{
+				{
+					Name:   "work",
+					Status: proton.FilterStatusEnabled,
+					Sieve:  "require [\"fileinto\", \"imap4flags\"];\nif header :contains \"Subject\" \"work\" { fileinto \"Projects/Work\"; addflag \"\\\\Seen\"; }",
+				},
+				{
+					Name:   "junk",
+					Status: proton.FilterStatusEnabled,
+					Sieve:  "if header :is \"Subject\" \"junk\" { discard; }",
+				},
+				{
+					Name:   "disabled",
+					Status: proton.FilterStatusDisabled,
+					Sieve:  "require \"fileinto\";\nfileinto \"Trash\";",
+				},
+			} {
+				req.Version = proton.SieveVersion
+
+				_, err := c.CreateFilter(ctx, req)
+				require.NoError(t, err)
+			}
+
+			subjects := []string{"work item", "junk", "hello"}
+
+			res := importMessagesWithSubjectGenerator(ctx, t, c, addr[0].ID, addrKRs[addr[0].ID], []string{proton.InboxLabel}, proton.MessageFlagReceived, len(subjects), func() string {
+				subject := subjects[0]
+				subjects = subjects[1:]
+				return subject
+			})
+			require.Len(t, res, 3)
+
+			// The work message is filed into the folder and marked as read.
+			work, err := c.GetMessage(ctx, res[0].MessageID)
+			require.NoError(t, err)
+			require.Contains(t, work.LabelIDs, folder.ID)
+			require.NotContains(t, work.LabelIDs, proton.InboxLabel)
+			require.False(t, bool(work.Unread))
+
+			// The junk message is discarded, so it has no ID.
+			require.Equal(t, proton.SuccessCode, res[1].Code)
+			require.Empty(t, res[1].MessageID)
+
+			// Other messages are kept in the inbox.
+			hello, err := c.GetMessage(ctx, res[2].MessageID)
+			require.NoError(t, err)
+			require.Contains(t, hello.LabelIDs, proton.InboxLabel)
+			require.True(t, bool(hello.Unread))
+		})
+	})
+}
+
+func TestServer_Filters_Send(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+			user, err := c.GetUser(ctx)
+			require.NoError(t, err)
+
+			addr, err := c.GetAddresses(ctx)
+			require.NoError(t, err)
+
+			salt, err := c.GetSalts(ctx)
+			require.NoError(t, err)
+
+			pass, err := salt.SaltForKey([]byte("pass"), user.Keys.Primary().ID)
+			require.NoError(t, err)
+
+			_, addrKRs, err := proton.Unlock(user, addr, pass, async.NoopPanicHandler{})
+			require.NoError(t, err)
+
+			_, err = c.CreateFilter(ctx, proton.CreateFilterReq{
+				Name:    "archive",
+				Status:  proton.FilterStatusEnabled,
+				Version: proton.SieveVersion,
+				Sieve:   "require [\"fileinto\", \"imap4flags\"];\nif address :localpart \"To\" \"user\" { fileinto \"archive\"; addflag \"\\\\Flagged\"; }",
+			})
+			require.NoError(t, err)
+
+			draft, err := c.CreateDraft(ctx, addrKRs[addr[0].ID], proton.CreateDraftReq{
+				Message: proton.DraftTemplate{
+					Subject: "My subject",
+					Sender:  &mail.Address{Address: addr[0].Email},
+					ToList:  []*mail.Address{{Address: "user@proton.local"}},
+				},
+			})
+			require.NoError(t, err)
+
+			var req proton.SendDraftReq
+
+			require.NoError(t, req.AddTextPackage(addrKRs[addr[0].ID], "Hello", "text/plain", map[string]proton.SendPreferences{"user@proton.local": {
+				Encrypt:          true,
+				PubKey:           addrKRs[addr[0].ID],
+				SignatureType:    proton.DetachedSignature,
+				EncryptionScheme: proton.InternalScheme,
+				MIMEType:         rfc822.TextPlain,
+			}}, map[string]*crypto.SessionKey{}))
+
+			_, err = c.SendDraft(ctx, draft.ID, req)
+			require.NoError(t, err)
+
+			// Both the sent copy and the received copy go through the filter.
+			metadata, err := c.GetMessageMetadata(ctx, proton.MessageFilter{})
+			require.NoError(t, err)
+			require.Len(t, metadata, 2)
+
+			for _, metadata := range metadata {
+				require.Contains(t, metadata.LabelIDs, proton.ArchiveLabel)
+				require.Contains(t, metadata.LabelIDs, proton.StarredLabel)
+			}
+		})
+	})
+}
+
+func TestServer_AutoResponder(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "other", "pass", func(other *proton.Client) {
+			settings, err := other.SetAutoResponder(ctx, proton.SetAutoResponderReq{AutoResponder: proton.AutoResponder{
+				IsEnabled: true,
+				Repeat:    proton.AutoResponderRepeatPermanent,
+				Zone:      "Europe/Zurich",
+				Subject:   "Out of office",
+				Message:   "I am away",
+			}})
+			require.NoError(t, err)
+			require.True(t, bool(settings.AutoResponder.IsEnabled))
+
+			_, err = other.SetAutoResponder(ctx, proton.SetAutoResponderReq{AutoResponder: proton.AutoResponder{Zone: "Nowhere/Special"}})
+			require.Error(t, err)
+
+			withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+				user, err := c.GetUser(ctx)
+				require.NoError(t, err)
+
+				addr, err := c.GetAddresses(ctx)
+				require.NoError(t, err)
+
+				salt, err := c.GetSalts(ctx)
+				require.NoError(t, err)
+
+				pass, err := salt.SaltForKey([]byte("pass"), user.Keys.Primary().ID)
+				require.NoError(t, err)
+
+				_, addrKRs, err := proton.Unlock(user, addr, pass, async.NoopPanicHandler{})
+				require.NoError(t, err)
+
+				// Send two messages; only the first one gets an auto-response.
+				for range 2 {
+					sendInternalMessage(ctx, t, c, addrKRs[addr[0].ID], addr[0].Email, "other@proton.local", "Hello")
+				}
+
+				inbox, err := c.GetMessageMetadata(ctx, proton.MessageFilter{LabelID: proton.InboxLabel})
+				require.NoError(t, err)
+				require.Len(t, inbox, 1)
+				require.Equal(t, "Out of office", inbox[0].Subject)
+				require.Equal(t, "other@proton.local", inbox[0].Sender.Address)
+				require.True(t, inbox[0].Flags.Has(proton.MessageFlagAuto))
+
+				res, err := c.GetMessage(ctx, inbox[0].ID)
+				require.NoError(t, err)
+
+				body, err := res.Decrypt(addrKRs[addr[0].ID])
+				require.NoError(t, err)
+				require.Equal(t, "I am away", string(body))
+
+				// The received message that was responded to is flagged as such.
+				received, err := other.GetMessageMetadata(ctx, proton.MessageFilter{LabelID: proton.InboxLabel})
+				require.NoError(t, err)
+				require.Len(t, received, 2)
+				require.Equal(t, 1, xslices.CountFunc(received, func(metadata proton.MessageMetadata) bool {
+					return metadata.Flags.Has(proton.MessageFlagAutoReplied)
+				}))
+			})
+		})
+	})
+}
+
+func TestServer_IncomingDefaults(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "other", "pass", func(other *proton.Client) {
+			_, err := other.AddIncomingDefault(ctx, proton.AddIncomingDefaultReq{Location: proton.IncomingDefaultLocationSpam, Domain: "proton.local"})
+			require.NoError(t, err)
+
+			withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+				user, err := c.GetUser(ctx)
+				require.NoError(t, err)
+
+				addr, err := c.GetAddresses(ctx)
+				require.NoError(t, err)
+
+				salt, err := c.GetSalts(ctx)
+				require.NoError(t, err)
+
+				pass, err := salt.SaltForKey([]byte("pass"), user.Keys.Primary().ID)
+				require.NoError(t, err)
+
+				_, addrKRs, err := proton.Unlock(user, addr, pass, async.NoopPanicHandler{})
+				require.NoError(t, err)
+
+				// The domain of the sender is blocked, so the message is delivered to spam.
+				eventID, err := other.GetLatestEventID(ctx)
+				require.NoError(t, err)
+
+				sendInternalMessage(ctx, t, c, addrKRs[addr[0].ID], addr[0].Email, "other@proton.local", "blocked")
+
+				events, _, err := other.GetEvent(ctx, eventID)
+				require.NoError(t, err)
+				require.Len(t, events, 1)
+				require.Len(t, events[0].Messages, 1)
+				require.Equal(t, "blocked", events[0].Messages[0].Message.Subject)
+				require.Contains(t, events[0].Messages[0].Message.LabelIDs, proton.SpamLabel)
+				require.NotContains(t, events[0].Messages[0].Message.LabelIDs, proton.InboxLabel)
+
+				// The sender itself is allowed, which takes precedence over its domain.
+				_, err = other.AddIncomingDefault(ctx, proton.AddIncomingDefaultReq{Location: proton.IncomingDefaultLocationInbox, Email: addr[0].Email})
+				require.NoError(t, err)
+
+				sendInternalMessage(ctx, t, c, addrKRs[addr[0].ID], addr[0].Email, "other@proton.local", "allowed")
+
+				inbox, err := other.GetMessageMetadata(ctx, proton.MessageFilter{LabelID: proton.InboxLabel})
+				require.NoError(t, err)
+				require.Len(t, inbox, 1)
+				require.Equal(t, "allowed", inbox[0].Subject)
+			})
+		})
+	})
+}
+
+func TestServer_Forwarding(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "forwardee", "pass", func(forwardee *proton.Client) {
+			withUser(ctx, t, s, m, "sender", "pass", func(sender *proton.Client) {
+				withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+					user, err := c.GetUser(ctx)
+					require.NoError(t, err)
+
+					// Only addresses with a Curve25519 key can forward to internal forwardees.
+					fwdAddrID, err := s.CreateAddressWithKeyType(user.ID, "fwd@proton.local", []byte("pass"), true, "x25519")
+					require.NoError(t, err)
+
+					_, _, addr, addrKRs := unlockUser(ctx, t, c, "pass")
+
+					_, err = c.CreateForwarding(ctx, addrKRs[addr[0].ID], proton.CreateForwardingReq{
+						ForwarderAddressID: addr[0].ID,
+						ForwardeeEmail:     "forwardee@proton.local",
+						Type:               proton.ForwardingTypeInternal,
+					})
+					require.ErrorIs(t, err, proton.ErrForwardingKeyUnsupported)
+
+					created, err := c.CreateForwarding(ctx, addrKRs[fwdAddrID], proton.CreateForwardingReq{
+						ForwarderAddressID: fwdAddrID,
+						ForwardeeEmail:     "forwardee@proton.local",
+						Type:               proton.ForwardingTypeInternal,
+						Filter: &proton.ForwardingFilter{
+							Version: proton.SieveVersion,
+							Sieve:   `if header :contains "Subject" "private" { discard; }`,
+						},
+					})
+					require.NoError(t, err)
+					require.Equal(t, proton.ForwardingStatePending, created.State)
+
+					// The forwardee accepts the forwarding, which adds the forwarding key to their address.
+					_, fwdeeUserKR, fwdeeAddr, fwdeeAddrKRs := unlockUser(ctx, t, forwardee, "pass")
+
+					incoming, err := forwardee.GetIncomingForwardings(ctx)
+					require.NoError(t, err)
+					require.Len(t, incoming, 1)
+					require.Equal(t, "fwd@proton.local", incoming[0].ForwarderEmail)
+					require.Len(t, incoming[0].ForwardingKeys, 1)
+
+					require.NoError(t, forwardee.AcceptForwarding(ctx, incoming[0], fwdeeUserKR, fwdeeAddrKRs[fwdeeAddr[0].ID]))
+
+					outgoing, err := c.GetOutgoingForwardings(ctx)
+					require.NoError(t, err)
+					require.Len(t, outgoing, 1)
+					require.Equal(t, proton.ForwardingStateActive, outgoing[0].State)
+
+					// The forwarding key does not show up among the public keys of the forwardee.
+					pubKeys, _, err := sender.GetPublicKeys(ctx, "forwardee@proton.local")
+					require.NoError(t, err)
+					require.Len(t, pubKeys, 1)
+
+					// Messages are forwarded unless the filter discards them.
+					_, _, senderAddr, senderAddrKRs := unlockUser(ctx, t, sender, "pass")
+
+					sendInternalMessage(ctx, t, sender, senderAddrKRs[senderAddr[0].ID], senderAddr[0].Email, "fwd@proton.local", "hello")
+					sendInternalMessage(ctx, t, sender, senderAddrKRs[senderAddr[0].ID], senderAddr[0].Email, "fwd@proton.local", "private")
+
+					received, err := c.GetMessageMetadata(ctx, proton.MessageFilter{AddressID: fwdAddrID})
+					require.NoError(t, err)
+					require.Len(t, received, 2)
+					require.Equal(t, 1, xslices.CountFunc(received, func(metadata proton.MessageMetadata) bool {
+						return metadata.Flags.Has(proton.MessageFlagAutoForwarder)
+					}))
+
+					_, _, fwdeeAddr, fwdeeAddrKRs = unlockUser(ctx, t, forwardee, "pass")
+
+					inbox, err := forwardee.GetMessageMetadata(ctx, proton.MessageFilter{LabelID: proton.InboxLabel})
+					require.NoError(t, err)
+					require.Len(t, inbox, 1)
+					require.Equal(t, "hello", inbox[0].Subject)
+					require.True(t, inbox[0].Flags.Has(proton.MessageFlagAutoForwardee))
+
+					// The forwardee decrypts the forwarded message with the forwarding key.
+					msg, err := forwardee.GetMessage(ctx, inbox[0].ID)
+					require.NoError(t, err)
+
+					body, err := msg.Decrypt(fwdeeAddrKRs[fwdeeAddr[0].ID])
+					require.NoError(t, err)
+					require.Equal(t, "Hello", string(body))
+
+					// Paused forwardings do not forward messages.
+					require.NoError(t, c.PauseForwarding(ctx, created.ID))
+
+					sendInternalMessage(ctx, t, sender, senderAddrKRs[senderAddr[0].ID], senderAddr[0].Email, "fwd@proton.local", "paused")
+
+					inbox, err = forwardee.GetMessageMetadata(ctx, proton.MessageFilter{LabelID: proton.InboxLabel})
+					require.NoError(t, err)
+					require.Len(t, inbox, 1)
+
+					require.NoError(t, c.ResumeForwarding(ctx, created.ID))
+					require.Error(t, c.ResumeForwarding(ctx, created.ID))
+
+					// Deleting the forwarding removes the forwarding key.
+					require.NoError(t, c.DeleteForwarding(ctx, created.ID))
+
+					outgoing, err = c.GetOutgoingForwardings(ctx)
+					require.NoError(t, err)
+					require.Empty(t, outgoing)
+
+					fwdeeAddr, err = forwardee.GetAddresses(ctx)
+					require.NoError(t, err)
+					require.Len(t, fwdeeAddr[0].Keys, 1)
+				})
+			})
+		})
+	})
+}
+
+func TestServer_Forwarding_External(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "other", "pass", func(other *proton.Client) {
+			withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+				_, _, addr, addrKRs := unlockUser(ctx, t, c, "pass")
+
+				// External forwardings need no key and are active right away.
+				created, err := c.CreateForwarding(ctx, addrKRs[addr[0].ID], proton.CreateForwardingReq{
+					ForwarderAddressID: addr[0].ID,
+					ForwardeeEmail:     "someone@example.com",
+					Type:               proton.ForwardingTypeExternal,
+				})
+				require.NoError(t, err)
+				require.Equal(t, proton.ForwardingStateActive, created.State)
+
+				_, _, otherAddr, otherAddrKRs := unlockUser(ctx, t, other, "pass")
+
+				sendInternalMessage(ctx, t, other, otherAddrKRs[otherAddr[0].ID], otherAddr[0].Email, addr[0].Email, "hello")
+
+				inbox, err := c.GetMessageMetadata(ctx, proton.MessageFilter{LabelID: proton.InboxLabel})
+				require.NoError(t, err)
+				require.Len(t, inbox, 1)
+				require.True(t, inbox[0].Flags.Has(proton.MessageFlagAutoForwarder))
+
+				// Invalid filters are rejected.
+				_, err = c.CreateForwarding(ctx, addrKRs[addr[0].ID], proton.CreateForwardingReq{
+					ForwarderAddressID: addr[0].ID,
+					ForwardeeEmail:     "else@example.com",
+					Type:               proton.ForwardingTypeExternal,
+					Filter:             &proton.ForwardingFilter{Version: proton.SieveVersion, Sieve: "fileinto;"},
+				})
+				require.Error(t, err)
+			})
+		})
+	})
+}
+
+func unlockUser(ctx context.Context, t *testing.T, c *proton.Client, pass string) (proton.User, *crypto.KeyRing, []proton.Address, map[string]*crypto.KeyRing) {
+	user, err := c.GetUser(ctx)
+	require.NoError(t, err)
+
+	addr, err := c.GetAddresses(ctx)
+	require.NoError(t, err)
+
+	salt, err := c.GetSalts(ctx)
+	require.NoError(t, err)
+
+	keyPass, err := salt.SaltForKey([]byte(pass), user.Keys.Primary().ID)
+	require.NoError(t, err)
+
+	userKR, addrKRs, err := proton.Unlock(user, addr, keyPass, async.NoopPanicHandler{})
+	require.NoError(t, err)
+
+	return user, userKR, addr, addrKRs
+}
+
+func TestServer_MoveMessages(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+			withMessages(ctx, t, c, "pass", 2, func(messageIDs []string) {
+				folder, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "Folder", Type: proton.LabelTypeFolder})
+				require.NoError(t, err)
+
+				label, err := c.CreateLabel(ctx, proton.CreateLabelReq{Name: "Label", Type: proton.LabelTypeLabel})
+				require.NoError(t, err)
+
+				requireLabelIDs := func(contains, notContains []string) {
+					metadata, err := c.GetMessageMetadata(ctx, proton.MessageFilter{ID: messageIDs})
+					require.NoError(t, err)
+					require.Len(t, metadata, len(messageIDs))
+
+					for _, metadata := range metadata {
+						require.Subset(t, metadata.LabelIDs, contains)
+
+						for _, labelID := range notContains {
+							require.NotContains(t, metadata.LabelIDs, labelID)
+						}
+					}
+				}
+
+				// Labels do not change the folder of the messages.
+				require.NoError(t, c.MoveMessages(ctx, messageIDs, proton.InboxLabel))
+				require.NoError(t, c.LabelMessages(ctx, messageIDs, label.ID))
+				requireLabelIDs([]string{proton.InboxLabel, label.ID}, nil)
+
+				// Moving the messages to a folder takes them out of the previous one, but keeps their labels.
+				require.NoError(t, c.MoveMessages(ctx, messageIDs, folder.ID))
+				requireLabelIDs([]string{folder.ID, label.ID}, []string{proton.InboxLabel})
+
+				require.NoError(t, c.MoveMessages(ctx, messageIDs, proton.ArchiveLabel))
+				requireLabelIDs([]string{proton.ArchiveLabel, label.ID}, []string{folder.ID})
+
+				require.NoError(t, c.MoveMessages(ctx, messageIDs, proton.InboxLabel))
+				requireLabelIDs([]string{proton.InboxLabel, label.ID}, []string{proton.ArchiveLabel})
+
+				// Labels are not folders.
+				require.ErrorIs(t, c.MoveMessages(ctx, messageIDs, label.ID), proton.ErrNotAFolder)
+				require.ErrorIs(t, c.MoveMessages(ctx, messageIDs, proton.StarredLabel), proton.ErrNotAFolder)
+
+				// Moving to inbox, sent or drafts moves messages to the one matching their flags.
+				require.NoError(t, c.MoveMessages(ctx, messageIDs, proton.DraftsLabel))
+				requireLabelIDs([]string{proton.InboxLabel}, []string{proton.DraftsLabel})
+
+				// Only the messages whose labels change get an update.
+				eventID, err := c.GetLatestEventID(ctx)
+				require.NoError(t, err)
+
+				require.NoError(t, c.MoveMessages(ctx, messageIDs[:1], proton.TrashLabel))
+				require.NoError(t, c.MoveMessages(ctx, messageIDs, proton.TrashLabel))
+
+				events, _, err := c.GetEvent(ctx, eventID)
+				require.NoError(t, err)
+				require.Len(t, events, 1)
+				require.Len(t, events[0].Messages, 2)
+
+				// Unknown labels are rejected.
+				require.Error(t, c.LabelMessages(ctx, messageIDs, "unknown"))
+			})
+		})
+	})
+}
+
+func TestServer_EmptyLabel(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "other", "pass", func(other *proton.Client) {
+			withMessages(ctx, t, other, "pass", 1, func(otherIDs []string) {
+				require.NoError(t, other.MoveMessages(ctx, otherIDs, proton.TrashLabel))
+
+				withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+					withMessages(ctx, t, c, "pass", 3, func(messageIDs []string) {
+						require.NoError(t, c.MoveMessages(ctx, messageIDs[:2], proton.TrashLabel))
+
+						addr, err := c.GetAddresses(ctx)
+						require.NoError(t, err)
+
+						eventID, err := c.GetLatestEventID(ctx)
+						require.NoError(t, err)
+
+						require.NoError(t, c.EmptyLabel(ctx, proton.TrashLabel, addr[0].ID))
+
+						// Only the messages in the trash are deleted.
+						remaining, err := c.GetMessageIDs(ctx, "", 100)
+						require.NoError(t, err)
+						require.Equal(t, messageIDs[2:], remaining)
+
+						events, _, err := c.GetEvent(ctx, eventID)
+						require.NoError(t, err)
+						require.Len(t, events, 1)
+						require.Len(t, events[0].Messages, 2)
+						require.True(t, xslices.All(events[0].Messages, func(event proton.MessageEvent) bool {
+							return event.Action == proton.EventDelete
+						}))
+
+						// The trash of other users is left alone.
+						otherRemaining, err := other.GetMessageIDs(ctx, "", 100)
+						require.NoError(t, err)
+						require.Equal(t, otherIDs, otherRemaining)
+
+						// The aggregate labels cannot be emptied.
+						require.Error(t, c.EmptyLabel(ctx, proton.AllMailLabel, ""))
+					})
+				})
+			})
+		})
+	})
+}
+
+func TestServer_ReportMessage(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+			_, _, addr, addrKRs := unlockUser(ctx, t, c, "pass")
+
+			res := importMessages(ctx, t, c, addr[0].ID, addrKRs[addr[0].ID], []string{proton.InboxLabel}, proton.MessageFlagReceived, 2)
+
+			require.NoError(t, c.ReportSpam(ctx, addrKRs[addr[0].ID], res[0].MessageID))
+			require.NoError(t, c.ReportPhishing(ctx, addrKRs[addr[0].ID], res[1].MessageID))
+
+			// The reports carry the original headers and the decrypted body.
+			reports := s.GetMessageReports()
+			require.Len(t, reports, 2)
+			require.Equal(t, "spam", reports[0].Type)
+			require.Equal(t, res[0].MessageID, reports[0].MessageID)
+			require.Contains(t, reports[0].Header, "From: <sender@example.com>")
+			require.Equal(t, "Hello World!", reports[0].Body)
+			require.Equal(t, "phishing", reports[1].Type)
+			require.Equal(t, res[1].MessageID, reports[1].MessageID)
+
+			// The reported messages are flagged and moved to spam.
+			spam, err := c.GetMessage(ctx, res[0].MessageID)
+			require.NoError(t, err)
+			require.True(t, spam.Flags.Has(proton.MessageFlagSpamManual))
+			require.ElementsMatch(t, []string{proton.AllMailLabel, proton.SpamLabel}, spam.LabelIDs)
+
+			phishing, err := c.GetMessage(ctx, res[1].MessageID)
+			require.NoError(t, err)
+			require.True(t, phishing.Flags.Has(proton.MessageFlagPhishingManual))
+			require.ElementsMatch(t, []string{proton.AllMailLabel, proton.SpamLabel}, phishing.LabelIDs)
+		})
+	})
+}
+
+func TestServer_Unsubscribe(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+			user, _, addr, addrKRs := unlockUser(ctx, t, c, "pass")
+
+			str, err := c.ImportMessages(ctx, addrKRs[addr[0].ID], 1, 1, []proton.ImportReq{
+				{
+					Metadata: proton.ImportMetadata{AddressID: addr[0].ID, LabelIDs: []string{proton.InboxLabel}, Flags: proton.MessageFlagReceived},
+					Message: []byte("From: list@example.com\r\nSubject: One-click\r\n" +
+						"List-Unsubscribe: <mailto:unsubscribe@example.com>, <https://example.com/unsubscribe?id=1>\r\n" +
+						"List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n\r\nHello World!"),
+				},
+				{
+					Metadata: proton.ImportMetadata{AddressID: addr[0].ID, LabelIDs: []string{proton.InboxLabel}, Flags: proton.MessageFlagReceived},
+					Message: []byte("From: list@example.com\r\nSubject: Mailto\r\n" +
+						"List-Unsubscribe: <mailto:unsubscribe@example.com?subject=stop>\r\n\r\nHello World!"),
+				},
+				{
+					Metadata: proton.ImportMetadata{AddressID: addr[0].ID, LabelIDs: []string{proton.InboxLabel}, Flags: proton.MessageFlagReceived},
+					Message:  newMessageLiteral("sender@example.com", "recipient@example.com"),
+				},
+			}...)
+			require.NoError(t, err)
+
+			res, err := stream.Collect(ctx, str)
+			require.NoError(t, err)
+
+			// One-click unsubscribe is performed by the API, which the dev server records.
+			oneClick, err := c.GetMessage(ctx, res[0].MessageID)
+			require.NoError(t, err)
+
+			_, isDraft, err := c.Unsubscribe(ctx, oneClick)
+			require.NoError(t, err)
+			require.False(t, isDraft)
+
+			require.Equal(t, []backend.Unsubscription{{
+				UserID:    user.ID,
+				MessageID: oneClick.ID,
+				URL:       "https://example.com/unsubscribe?id=1",
+			}}, s.GetUnsubscriptions())
+
+			oneClick, err = c.GetMessage(ctx, res[0].MessageID)
+			require.NoError(t, err)
+			require.True(t, oneClick.Flags.Has(proton.MessageFlagUnsubscribed))
+
+			// Lists without one-click unsubscribe are unsubscribed from by sending the returned draft.
+			mailto, err := c.GetMessage(ctx, res[1].MessageID)
+			require.NoError(t, err)
+
+			draft, isDraft, err := c.Unsubscribe(ctx, mailto)
+			require.NoError(t, err)
+			require.True(t, isDraft)
+			require.Equal(t, "stop", draft.Subject)
+			require.Equal(t, []*mail.Address{{Address: "unsubscribe@example.com"}}, draft.ToList)
+			require.Equal(t, addr[0].Email, draft.Sender.Address)
+			require.Len(t, s.GetUnsubscriptions(), 1)
+
+			require.NoError(t, c.MarkMessagesUnsubscribed(ctx, mailto.ID))
+
+			mailto, err = c.GetMessage(ctx, res[1].MessageID)
+			require.NoError(t, err)
+			require.True(t, mailto.Flags.Has(proton.MessageFlagUnsubscribed))
+
+			// Messages that are not from mailing lists cannot be unsubscribed from.
+			plain, err := c.GetMessage(ctx, res[2].MessageID)
+			require.NoError(t, err)
+
+			_, _, err = c.Unsubscribe(ctx, plain)
+			require.ErrorIs(t, err, proton.ErrNoListUnsubscribe)
+		})
+	})
+}
+
+func TestServer_ReplyForwardDrafts(t *testing.T) {
+	withServer(t, func(ctx context.Context, s *Server, m *proton.Manager) {
+		withUser(ctx, t, s, m, "user", "pass", func(c *proton.Client) {
+			user, err := c.GetUser(ctx)
+			require.NoError(t, err)
+
+			_, err = s.CreateAddress(user.ID, "alias@"+s.GetDomain(), []byte("pass"), true)
+			require.NoError(t, err)
+
+			_, _, addr, addrKRs := unlockUser(ctx, t, c, "pass")
+
+			literal := "From: Alice <alice@example.com>\r\n" +
+				"To: user@" + s.GetDomain() + ", bob@example.com\r\n" +
+				"Cc: carol@example.com\r\n" +
+				"Subject: Hello\r\n" +
+				"Message-Id: <parent@example.com>\r\n" +
+				"References: <root@example.com>\r\n" +
+				"Content-Type: multipart/mixed; boundary=boundary\r\n\r\n" +
+				"--boundary\r\nContent-Type: text/plain\r\n\r\nHello World!\r\n" +
+				"--boundary\r\nContent-Type: application/octet-stream\r\nContent-Disposition: attachment; filename=notes.txt\r\n\r\nSome notes\r\n" +
+				"--boundary--\r\n"
+
+			str, err := c.ImportMessages(ctx, addrKRs[addr[0].ID], 1, 1, proton.ImportReq{
+				Metadata: proton.ImportMetadata{AddressID: addr[0].ID, LabelIDs: []string{proton.InboxLabel}, Flags: proton.MessageFlagReceived},
+				Message:  []byte(literal),
+			})
+			require.NoError(t, err)
+
+			res, err := stream.Collect(ctx, str)
+			require.NoError(t, err)
+
+			parent, err := c.GetMessage(ctx, res[0].MessageID)
+			require.NoError(t, err)
+			require.Len(t, parent.Attachments, 1)
+
+			body, err := parent.Decrypt(addrKRs[addr[0].ID])
+			require.NoError(t, err)
+
+			// Reply to all from the address that received the message.
+			reply, err := c.CreateDraft(ctx, addrKRs[addr[0].ID], proton.NewReplyDraftReq(
+				parent,
+				body,
+				&mail.Address{Address: addr[0].Email},
+				true,
+				addr,
+			))
+			require.NoError(t, err)
+			require.Equal(t, "Re: Hello", reply.Subject)
+			require.Equal(t, []*mail.Address{{Name: "Alice", Address: "alice@example.com"}, {Address: "bob@example.com"}}, reply.ToList)
+			require.Equal(t, []*mail.Address{{Address: "carol@example.com"}}, reply.CCList)
+			require.Equal(t, []string{"<parent@example.com>"}, reply.ParsedHeaders.Values["In-Reply-To"])
+			require.Equal(t, []string{"<root@example.com> <parent@example.com>"}, reply.ParsedHeaders.Values["References"])
+
+			replyBody, err := reply.Decrypt(addrKRs[addr[0].ID])
+			require.NoError(t, err)
+			require.Contains(t, string(replyBody), "> Hello World!")
+
+			// Forward from the other address, which has a different key.
+			forwardReq, err := proton.NewForwardDraftReq(
+				parent,
+				body,
+				&mail.Address{Address: addr[1].Email},
+				addrKRs[addr[0].ID],
+				addrKRs[addr[1].ID],
+			)
+			require.NoError(t, err)
+
+			forwardReq.Message.ToList = []*mail.Address{{Address: "dave@example.com"}}
+
+			forward, err := c.CreateDraft(ctx, addrKRs[addr[1].ID], forwardReq)
+			require.NoError(t, err)
+			require.Equal(t, "Fwd: Hello", forward.Subject)
+			require.Equal(t, addr[1].ID, forward.AddressID)
+			require.Len(t, forward.Attachments, 1)
+			require.Equal(t, "notes.txt", forward.Attachments[0].Name)
+
+			// The forwarded attachment can be decrypted with the key of the other address.
+			keyPackets, err := base64.StdEncoding.DecodeString(forward.Attachments[0].KeyPackets)
+			require.NoError(t, err)
+
+			sessionKey, err := addrKRs[addr[1].ID].DecryptSessionKey(keyPackets)
+			require.NoError(t, err)
+
+			attData, err := c.GetAttachment(ctx, forward.Attachments[0].ID)
+			require.NoError(t, err)
+
+			dec, err := sessionKey.Decrypt(attData)
+			require.NoError(t, err)
+			require.Equal(t, "Some notes", string(dec.GetBinary()))
+
+			// Key packets that do not match the attachments of the parent are rejected.
+			forwardReq.AttachmentKeyPackets = append(forwardReq.AttachmentKeyPackets, forwardReq.AttachmentKeyPackets[0])
+
+			_, err = c.CreateDraft(ctx, addrKRs[addr[1].ID], forwardReq)
+			require.Error(t, err)
+		})
+	})
+}
+
+func sendInternalMessage(ctx context.Context, t *testing.T, c *proton.Client, addrKR *crypto.KeyRing, from, to, subject string) {
+	pubKeys, _, err := c.GetPublicKeys(ctx, to)
+	require.NoError(t, err)
+
+	pubKR, err := pubKeys.GetKeyRing()
+	require.NoError(t, err)
+
+	draft, err := c.CreateDraft(ctx, addrKR, proton.CreateDraftReq{
+		Message: proton.DraftTemplate{
+			Subject: subject,
+			Sender:  &mail.Address{Address: from},
+			ToList:  []*mail.Address{{Address: to}},
+		},
+	})
+	require.NoError(t, err)
+
+	var req proton.SendDraftReq
+
+	require.NoError(t, req.AddTextPackage(addrKR, "Hello", "text/plain", map[string]proton.SendPreferences{to: {
+		Encrypt:          true,
+		PubKey:           pubKR,
+		SignatureType:    proton.DetachedSignature,
+		EncryptionScheme: proton.InternalScheme,
+		MIMEType:         rfc822.TextPlain,
+	}}, map[string]*crypto.SessionKey{}))
+
+	_, err = c.SendDraft(ctx, draft.ID, req)
+	require.NoError(t, err)
+}
@@ -0,0 +1,136 @@
+package proton
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrSessionNotFound is returned by session stores that have no session for a user.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is what an app needs to resume the session of an account without asking for its passwords.
+type Session struct {
+	UserID       string
+	UID          string
+	RefreshToken string
+
+	// SaltedKeyPass is the salted passphrase of the user keys, which unlocks them with Unlock.
+	SaltedKeyPass []byte
+}
+
+// SessionStore stores the sessions of several accounts, one per user.
+type SessionStore interface {
+	// Put stores the session, replacing any session of the same user.
+	Put(session Session) error
+
+	// Get returns the session of the user, or ErrSessionNotFound.
+	Get(userID string) (Session, error)
+
+	// Delete removes the session of the user, if any.
+	Delete(userID string) error
+
+	// List returns all the sessions, sorted by user ID.
+	List() ([]Session, error)
+}
+
+// TrackSession stores the session of the client, then keeps it up to date: the session is updated when the client
+// refreshes its auth, and removed when the client is deauthed. Sessions of clients that log out with AuthDelete
+// should be deleted by the caller.
+func TrackSession(c *Client, store SessionStore, session Session) error {
+	if err := store.Put(session); err != nil {
+		return err
+	}
+
+	c.AddAuthHandler(func(auth Auth) {
+		// The app may have updated other fields of the session in the meantime, e.g. the salted key passphrase.
+		stored, err := store.Get(session.UserID)
+		if errors.Is(err, ErrSessionNotFound) {
+			stored = session
+		} else if err != nil {
+			log.WithError(err).WithField("userID", session.UserID).Warn("Failed to get session")
+			stored = session
+		}
+
+		stored.UID = auth.UID
+		stored.RefreshToken = auth.RefreshToken
+
+		if err := store.Put(stored); err != nil {
+			log.WithError(err).WithField("userID", session.UserID).Error("Failed to store refreshed session")
+		}
+	})
+
+	c.AddDeauthHandler(func() {
+		if err := store.Delete(session.UserID); err != nil {
+			log.WithError(err).WithField("userID", session.UserID).Error("Failed to delete session")
+		}
+	})
+
+	return nil
+}
+
+// NewMemorySessionStore returns a session store that keeps the sessions in memory only.
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{sessions: make(map[string]Session)}
+}
+
+type memorySessionStore struct {
+	sessions map[string]Session
+	lock     sync.RWMutex
+}
+
+func (store *memorySessionStore) Put(session Session) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.sessions[session.UserID] = cloneSession(session)
+
+	return nil
+}
+
+func (store *memorySessionStore) Get(userID string) (Session, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	session, ok := store.sessions[userID]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+
+	return cloneSession(session), nil
+}
+
+func (store *memorySessionStore) Delete(userID string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	delete(store.sessions, userID)
+
+	return nil
+}
+
+func (store *memorySessionStore) List() ([]Session, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return sortedSessions(store.sessions), nil
+}
+
+func sortedSessions(sessions map[string]Session) []Session {
+	res := make([]Session, 0, len(sessions))
+
+	for _, session := range sessions {
+		res = append(res, cloneSession(session))
+	}
+
+	slices.SortFunc(res, func(a, b Session) int { return strings.Compare(a.UserID, b.UserID) })
+
+	return res
+}
+
+func cloneSession(session Session) Session {
+	session.SaltedKeyPass = slices.Clone(session.SaltedKeyPass)
+
+	return session
+}
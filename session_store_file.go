@@ -0,0 +1,171 @@
+package proton
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SessionStoreKeySize is the size of the keys that encrypt file session stores.
+const SessionStoreKeySize = 32
+
+// OpenFileSessionStore opens the session store persisted in the given file, creating it on the first change.
+//
+// The file is encrypted with AES-256-GCM using the given key, which the caller is responsible for keeping
+// safe, e.g. in the keychain of the system. Each change rewrites the file atomically.
+func OpenFileSessionStore(path string, key []byte) (SessionStore, error) {
+	if len(key) != SessionStoreKeySize {
+		return nil, fmt.Errorf("invalid session store key size %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &fileSessionStore{
+		memorySessionStore: memorySessionStore{sessions: make(map[string]Session)},
+		path:               path,
+		aead:               aead,
+	}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+type fileSessionStore struct {
+	memorySessionStore
+
+	path string
+	aead cipher.AEAD
+}
+
+func (store *fileSessionStore) Put(session Session) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	prev, ok := store.sessions[session.UserID]
+
+	store.sessions[session.UserID] = cloneSession(session)
+
+	if err := store.save(); err != nil {
+		if ok {
+			store.sessions[session.UserID] = prev
+		} else {
+			delete(store.sessions, session.UserID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (store *fileSessionStore) Delete(userID string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	prev, ok := store.sessions[userID]
+	if !ok {
+		return nil
+	}
+
+	delete(store.sessions, userID)
+
+	if err := store.save(); err != nil {
+		store.sessions[userID] = prev
+		return err
+	}
+
+	return nil
+}
+
+func (store *fileSessionStore) load() error {
+	b, err := os.ReadFile(store.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read session store: %w", err)
+	}
+
+	if len(b) < store.aead.NonceSize() {
+		return fmt.Errorf("invalid session store")
+	}
+
+	data, err := store.aead.Open(nil, b[:store.aead.NonceSize()], b[store.aead.NonceSize():], nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt session store: %w", err)
+	}
+
+	var sessions []Session
+
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return fmt.Errorf("failed to decode session store: %w", err)
+	}
+
+	for _, session := range sessions {
+		store.sessions[session.UserID] = session
+	}
+
+	return nil
+}
+
+// save writes the sessions to a temporary file, then replaces the store with it.
+// The caller must hold the lock.
+func (store *fileSessionStore) save() error {
+	data, err := json.Marshal(sortedSessions(store.sessions))
+	if err != nil {
+		return fmt.Errorf("failed to encode session store: %w", err)
+	}
+
+	nonce := make([]byte, store.aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(store.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create session store directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create session store: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(store.aead.Seal(nonce, nonce, data, nil)); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write session store: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync session store: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session store: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), store.path); err != nil {
+		return fmt.Errorf("failed to replace session store: %w", err)
+	}
+
+	return nil
+}
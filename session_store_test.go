@@ -0,0 +1,190 @@
+package proton_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gluon/async"
+	"github.com/ProtonMail/go-proton-api"
+	"github.com/ProtonMail/go-proton-api/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSessionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions")
+	key := bytes.Repeat([]byte{1}, proton.SessionStoreKeySize)
+
+	_, err := proton.OpenFileSessionStore(path, key[:16])
+	require.Error(t, err)
+
+	store, err := proton.OpenFileSessionStore(path, key)
+	require.NoError(t, err)
+
+	sessions, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	session1 := proton.Session{UserID: "user1", UID: "uid1", RefreshToken: "refresh-token-1", SaltedKeyPass: []byte("pass1")}
+	session2 := proton.Session{UserID: "user2", UID: "uid2", RefreshToken: "refresh-token-2", SaltedKeyPass: []byte("pass2")}
+
+	require.NoError(t, store.Put(session2))
+	require.NoError(t, store.Put(session1))
+
+	_, err = store.Get("user3")
+	require.ErrorIs(t, err, proton.ErrSessionNotFound)
+
+	// The sessions are encrypted.
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "refresh-token-1")
+
+	// They persist across opens, but only with the right key.
+	_, err = proton.OpenFileSessionStore(path, bytes.Repeat([]byte{2}, proton.SessionStoreKeySize))
+	require.Error(t, err)
+
+	store, err = proton.OpenFileSessionStore(path, key)
+	require.NoError(t, err)
+
+	sessions, err = store.List()
+	require.NoError(t, err)
+	require.Equal(t, []proton.Session{session1, session2}, sessions)
+
+	session, err := store.Get("user2")
+	require.NoError(t, err)
+	require.Equal(t, session2, session)
+
+	// So do updates and deletions.
+	session1.RefreshToken = "refresh-token-3"
+
+	require.NoError(t, store.Put(session1))
+	require.NoError(t, store.Delete("user2"))
+
+	store, err = proton.OpenFileSessionStore(path, key)
+	require.NoError(t, err)
+
+	sessions, err = store.List()
+	require.NoError(t, err)
+	require.Equal(t, []proton.Session{session1}, sessions)
+}
+
+func TestManager_ResumeAll(t *testing.T) {
+	ctx := context.Background()
+
+	s := server.New()
+	defer s.Close()
+
+	m := proton.New(
+		proton.WithHostURL(s.GetHostURL()),
+		proton.WithTransport(proton.InsecureTransport()),
+	)
+	defer m.Close()
+
+	path := filepath.Join(t.TempDir(), "sessions")
+	key := bytes.Repeat([]byte{1}, proton.SessionStoreKeySize)
+
+	store, err := proton.OpenFileSessionStore(path, key)
+	require.NoError(t, err)
+
+	// Log in two accounts and track their sessions.
+	login := func(username string) (string, *proton.Client) {
+		userID, _, err := s.CreateUser(username, []byte("pass"))
+		require.NoError(t, err)
+
+		c, auth, err := m.NewClientWithLogin(ctx, username, []byte("pass"))
+		require.NoError(t, err)
+
+		user, err := c.GetUser(ctx)
+		require.NoError(t, err)
+
+		salts, err := c.GetSalts(ctx)
+		require.NoError(t, err)
+
+		saltedKeyPass, err := salts.SaltForKey([]byte("pass"), user.Keys.Primary().ID)
+		require.NoError(t, err)
+
+		require.NoError(t, proton.TrackSession(c, store, proton.Session{
+			UserID:        userID,
+			UID:           auth.UID,
+			RefreshToken:  auth.RefreshToken,
+			SaltedKeyPass: saltedKeyPass,
+		}))
+
+		return userID, c
+	}
+
+	userID1, c1 := login("user1")
+	userID2, c2 := login("user2")
+
+	// Refreshed auths are stored.
+	before, err := store.Get(userID1)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	s.SetAuthLife(500 * time.Millisecond)
+
+	_, err = c1.GetUser(ctx)
+	require.NoError(t, err)
+
+	s.SetAuthLife(time.Hour)
+
+	after, err := store.Get(userID1)
+	require.NoError(t, err)
+	require.NotEqual(t, before.RefreshToken, after.RefreshToken)
+	require.Equal(t, before.SaltedKeyPass, after.SaltedKeyPass)
+
+	// The app restarts: all the accounts are resumed from the store.
+	c1.Close()
+	c2.Close()
+
+	store, err = proton.OpenFileSessionStore(path, key)
+	require.NoError(t, err)
+
+	resumed, err := m.ResumeAll(ctx, store)
+	require.NoError(t, err)
+	require.Len(t, resumed, 2)
+
+	for _, session := range resumed {
+		user, err := session.Client.GetUser(ctx)
+		require.NoError(t, err)
+		require.Equal(t, session.UserID, user.ID)
+
+		addresses, err := session.Client.GetAddresses(ctx)
+		require.NoError(t, err)
+
+		_, _, err = proton.Unlock(user, addresses, session.SaltedKeyPass, async.NoopPanicHandler{})
+		require.NoError(t, err)
+
+		// The refresh tokens were rotated and stored.
+		stored, err := store.Get(session.UserID)
+		require.NoError(t, err)
+		require.Equal(t, session.RefreshToken, stored.RefreshToken)
+
+		session.Client.Close()
+	}
+
+	// Revoked sessions are removed when resuming.
+	require.NoError(t, s.RevokeUser(userID2))
+
+	resumed, err = m.ResumeAll(ctx, store)
+	require.NoError(t, err)
+	require.Len(t, resumed, 1)
+	require.Equal(t, userID1, resumed[0].UserID)
+
+	_, err = store.Get(userID2)
+	require.ErrorIs(t, err, proton.ErrSessionNotFound)
+
+	// And when the client is deauthed.
+	require.NoError(t, s.RevokeUser(userID1))
+
+	_, err = resumed[0].Client.GetUser(ctx)
+	require.Error(t, err)
+
+	sessions, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+}
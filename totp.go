@@ -0,0 +1,76 @@
+package proton
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPPeriod is the period during which a TOTP code is valid.
+	TOTPPeriod = 30 * time.Second
+
+	// TOTPDigits is the number of digits of a TOTP code.
+	TOTPDigits = 6
+)
+
+// GenerateTOTP returns the TOTP code (RFC 6238) of the given base32-encoded secret at the given time,
+// as expected by Auth2FA for users with TOTP enabled.
+func GenerateTOTP(secret string, at time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return totpCode(key, uint64(at.Unix())/uint64(TOTPPeriod/time.Second)), nil
+}
+
+// ValidateTOTP returns whether the code is the TOTP code of the secret at the given time,
+// or at one of the skew periods before or after it, to tolerate clock differences.
+func ValidateTOTP(secret, code string, at time.Time, skew int) (bool, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return false, err
+	}
+
+	counter := at.Unix() / int64(TOTPPeriod/time.Second)
+
+	for delta := -int64(skew); delta <= int64(skew); delta++ {
+		if counter+delta < 0 {
+			continue
+		}
+
+		if hmac.Equal([]byte(totpCode(key, uint64(counter+delta))), []byte(code)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	return key, nil
+}
+
+// totpCode returns the HOTP code (RFC 4226) of the key at the given counter.
+func totpCode(key []byte, counter uint64) string {
+	mac := hmac.New(sha1.New, key)
+	mac.Write(binary.BigEndian.AppendUint64(nil, counter))
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", TOTPDigits, value%1_000_000)
+}
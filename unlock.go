@@ -1,6 +1,7 @@
 package proton
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 
@@ -39,3 +40,26 @@ func Unlock(user User, addresses []Address, saltedKeyPass []byte, panicHandler a
 
 	return userKR, addrKRs, nil
 }
+
+// UnlockWithMailboxPassword unlocks the keys of the user and of the given addresses with the mailbox password,
+// salted with the key salt of the primary user key. The mailbox password is the login password for users
+// in one-password mode, and their second password for users in two-password mode (see Auth.PasswordMode).
+func (c *Client) UnlockWithMailboxPassword(
+	ctx context.Context,
+	user User,
+	addresses []Address,
+	mailboxPassword []byte,
+	panicHandler async.PanicHandler,
+) (*crypto.KeyRing, map[string]*crypto.KeyRing, error) {
+	salts, err := c.GetSalts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	saltedKeyPass, err := salts.SaltForKey(mailboxPassword, user.Keys.Primary().ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return Unlock(user, addresses, saltedKeyPass, panicHandler)
+}